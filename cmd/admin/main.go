@@ -0,0 +1,90 @@
+// Command admin grants and revokes site admin rights, so that the first admin
+// can be made before anyone can use the admin console.
+//
+// Usage:
+//
+//	go run ./cmd/admin list
+//	go run ./cmd/admin grant USERNAME
+//	go run ./cmd/admin revoke USERNAME
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/matt-horst/split-ways/internal/database"
+
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage:
+	admin list
+	admin grant USERNAME
+	admin revoke USERNAME`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalln(usage)
+	}
+
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatalf("Couldn't load ENV file: %v\n", err)
+	}
+
+	dbConnStr, ok := os.LookupEnv("DATABASE")
+	if !ok {
+		log.Fatalln("Couldn't find database connection string in ENV")
+	}
+
+	db, err := sql.Open("postgres", dbConnStr)
+	if err != nil {
+		log.Fatalf("Couldn't open database connection: %v\n", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	queries := database.New(db)
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "list":
+		users, err := queries.ListUsers(ctx)
+		if err != nil {
+			log.Fatalf("Couldn't list users: %v\n", err)
+		}
+
+		for _, user := range users {
+			if user.IsAdmin {
+				fmt.Println(user.Username)
+			}
+		}
+	case "grant", "revoke":
+		if len(args) != 1 {
+			log.Fatalln(usage)
+		}
+
+		user, err := queries.GetUserByUsername(ctx, args[0])
+		if err != nil {
+			log.Fatalf("Couldn't find user `%s`: %v\n", args[0], err)
+		}
+
+		if _, err := queries.SetUserAdmin(ctx, database.SetUserAdminParams{
+			ID:      user.ID,
+			IsAdmin: os.Args[1] == "grant",
+		}); err != nil {
+			log.Fatalf("Couldn't update user: %v\n", err)
+		}
+	default:
+		log.Fatalf("Unknown command `%s`\n%s\n", os.Args[1], strings.TrimSpace(usage))
+	}
+}
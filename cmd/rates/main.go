@@ -0,0 +1,134 @@
+// Command rates maintains the exchange rates used to convert transactions
+// into the currency of their group.
+//
+// Usage:
+//
+//	go run ./cmd/rates list
+//	go run ./cmd/rates set BASE QUOTE RATE
+//	go run ./cmd/rates delete BASE QUOTE
+//	go run ./cmd/rates import FILE
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage:
+	rates list
+	rates set BASE QUOTE RATE
+	rates delete BASE QUOTE
+	rates import FILE`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalln(usage)
+	}
+
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatalf("Couldn't load ENV file: %v\n", err)
+	}
+
+	dbConnStr, ok := os.LookupEnv("DATABASE")
+	if !ok {
+		log.Fatalln("Couldn't find database connection string in ENV")
+	}
+
+	db, err := sql.Open("postgres", dbConnStr)
+	if err != nil {
+		log.Fatalf("Couldn't open database connection: %v\n", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	queries := database.New(db)
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "list":
+		rates, err := queries.GetExchangeRates(ctx)
+		if err != nil {
+			log.Fatalf("Couldn't get exchange rates: %v\n", err)
+		}
+
+		for _, rate := range rates {
+			fmt.Printf("%s,%s,%s\n", rate.Base, rate.Quote, rate.Rate)
+		}
+	case "set":
+		if len(args) != 3 {
+			log.Fatalln(usage)
+		}
+
+		params, err := api.ParseExchangeRate(args[0], args[1], args[2])
+		if err != nil {
+			log.Fatalf("Couldn't parse exchange rate: %v\n", err)
+		}
+
+		inUse, err := api.ExchangeRateInUse(ctx, queries, params.Base, params.Quote)
+		if err != nil {
+			log.Fatalf("Couldn't check exchange rate: %v\n", err)
+		}
+
+		if _, err := queries.SetExchangeRate(ctx, params); err != nil {
+			log.Fatalf("Couldn't set exchange rate: %v\n", err)
+		}
+
+		if inUse {
+			fmt.Fprintf(os.Stderr, "Warning: groups convert between %s and %s, so their balances now use the new rate. Groups that had settled up may owe money again.\n", params.Base, params.Quote)
+		}
+	case "delete":
+		if len(args) != 2 {
+			log.Fatalln(usage)
+		}
+
+		base, err := accounting.ParseCurrency(args[0])
+		if err != nil {
+			log.Fatalf("Couldn't parse currency: %v\n", err)
+		}
+
+		quote, err := accounting.ParseCurrency(args[1])
+		if err != nil {
+			log.Fatalf("Couldn't parse currency: %v\n", err)
+		}
+
+		if err := api.DeleteExchangeRate(ctx, db, nil, queries, base, quote); err != nil {
+			log.Fatalf("Couldn't delete exchange rate: %v\n", err)
+		}
+	case "import":
+		if len(args) != 1 {
+			log.Fatalln(usage)
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatalf("Couldn't open file: %v\n", err)
+		}
+		defer f.Close()
+
+		n, err := api.ImportExchangeRates(ctx, db, nil, queries, f)
+		if err != nil {
+			log.Fatalf("Couldn't import exchange rates: %v\n", err)
+		}
+
+		fmt.Printf("Imported %d exchange rates\n", n)
+		fmt.Fprintln(os.Stderr, "Warning: groups that convert with any of these rates now use the new ones for all their transactions. Groups that had settled up may owe money again.")
+	default:
+		log.Fatalf("Unknown command `%s`\n%s\n", os.Args[1], strings.TrimSpace(usage))
+	}
+}
@@ -1,14 +1,125 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/matt-horst/split-ways/web/pages"
 )
 
+type SetDisabledData struct {
+	Disabled bool `json:"disabled"`
+}
+
+type SetAdminData struct {
+	Admin bool `json:"admin"`
+}
+
+type ResetPasswordData struct {
+	Password string `json:"password"`
+}
+
+// ExportAdminUser is a user as seen from the admin console.
+type ExportAdminUser struct {
+	ID           uuid.UUID  `json:"id"`
+	Username     string     `json:"username"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Placeholder  bool       `json:"placeholder"`
+	Admin        bool       `json:"admin"`
+	DisabledAt   *time.Time `json:"disabled_at"`
+	FailedLogins int32      `json:"failed_logins"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	// GroupCount and TwoFactor are only sent when listing users.
+	GroupCount int64 `json:"group_count,omitempty"`
+	TwoFactor  bool  `json:"two_factor,omitempty"`
+}
+
+type ExportAdminGroup struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Currency    string    `json:"currency"`
+	CreatedAt   time.Time `json:"created_at"`
+	Owner       uuid.UUID `json:"owner"`
+	OwnerName   string    `json:"owner_name"`
+	MemberCount int64     `json:"member_count"`
+}
+
+type ExportSiteStats struct {
+	Users          int64 `json:"users"`
+	Placeholders   int64 `json:"placeholders"`
+	Admins         int64 `json:"admins"`
+	DisabledUsers  int64 `json:"disabled_users"`
+	Groups         int64 `json:"groups"`
+	Friendships    int64 `json:"friendships"`
+	Transactions   int64 `json:"transactions"`
+	ActiveSessions int64 `json:"active_sessions"`
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func exportAdminUser(user database.User) ExportAdminUser {
+	return ExportAdminUser{
+		ID:           user.ID,
+		Username:     user.Username,
+		CreatedAt:    user.CreatedAt,
+		Placeholder:  user.Placeholder,
+		Admin:        user.IsAdmin,
+		DisabledAt:   nullTime(user.DisabledAt),
+		FailedLogins: user.FailedLogins,
+		LockedUntil:  nullTime(user.LockedUntil),
+	}
+}
+
+// AdminMiddleware lets through only site admins, who must be logged in
+// rather than using an API token.
+func (cfg *Config) AdminMiddleware(next http.Handler) http.Handler {
+	return cfg.AuthenticatedUserMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(userContextKey).(database.User)
+		if !ok {
+			log.Printf("Attempted admin request with unauthenticated user\n")
+			http.Error(w, "User not authenticated", http.StatusUnauthorized)
+			return
+		}
+
+		if !requireSession(w, r) {
+			return
+		}
+
+		if !user.IsAdmin {
+			log.Printf("Attempt by non-admin %s to %s %s\n", user.ID, r.Method, r.URL.Path)
+			http.Error(w, "Only admins can do that", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// HandlerReset deletes every user and group. It's only for development, so
+// it's refused unless the server was started with ALLOW_RESET.
 func (cfg *Config) HandlerReset(w http.ResponseWriter, r *http.Request) {
+	if !cfg.AllowReset {
+		log.Printf("Attempted reset with ALLOW_RESET unset\n")
+		http.NotFound(w, r)
+		return
+	}
+
 	if err := api.Reset(r.Context(), cfg.DB, cfg.Queries); err != nil {
 		log.Printf("couldn't reset database: %v\n", err)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
@@ -19,3 +130,287 @@ func (cfg *Config) HandlerReset(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (cfg *Config) HandlerAdminGetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := cfg.Queries.GetSiteStats(r.Context())
+	if err != nil {
+		log.Printf("couldn't get site stats: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(ExportSiteStats(stats))
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerAdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := cfg.Queries.ListUsers(r.Context())
+	if err != nil {
+		log.Printf("couldn't list users: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	export := make([]ExportAdminUser, len(users))
+	for i, row := range users {
+		export[i] = exportAdminUser(database.User{
+			ID:           row.ID,
+			Username:     row.Username,
+			CreatedAt:    row.CreatedAt,
+			Placeholder:  row.Placeholder,
+			IsAdmin:      row.IsAdmin,
+			DisabledAt:   row.DisabledAt,
+			FailedLogins: row.FailedLogins,
+			LockedUntil:  row.LockedUntil,
+		})
+		export[i].GroupCount = row.GroupCount
+		export[i].TwoFactor = row.TwoFactor
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(export)
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerAdminGetGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := cfg.Queries.ListGroups(r.Context())
+	if err != nil {
+		log.Printf("couldn't list groups: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	export := make([]ExportAdminGroup, len(groups))
+	for i, group := range groups {
+		export[i] = ExportAdminGroup{
+			ID:          group.ID,
+			Name:        group.Name,
+			Currency:    group.Currency,
+			CreatedAt:   group.CreatedAt,
+			Owner:       group.Owner,
+			OwnerName:   group.OwnerName,
+			MemberCount: group.MemberCount,
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(export)
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+// adminUserID is the user an admin request is about. On failure the error
+// response has already been written.
+func adminUserID(w http.ResponseWriter, r *http.Request) (database.User, uuid.UUID, bool) {
+	admin, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted admin request with unauthenticated user\n")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return admin, uuid.UUID{}, false
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't parse user id: %v\n", err)
+		http.Error(w, "Couldn't parse user id", http.StatusBadRequest)
+		return admin, uuid.UUID{}, false
+	}
+
+	return admin, userID, true
+}
+
+// writeAdminUser responds to an admin request with the user it changed.
+func writeAdminUser(w http.ResponseWriter, user database.User, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrUserNotFound):
+			http.Error(w, "Couldn't find user", http.StatusNotFound)
+		case errors.Is(err, api.ErrAdminSelf):
+			http.Error(w, "You can't do that to your own account", http.StatusBadRequest)
+		default:
+			log.Printf("couldn't update user: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(exportAdminUser(user))
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerAdminSetDisabled(w http.ResponseWriter, r *http.Request) {
+	admin, userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	data := SetDisabledData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := api.SetDisabled(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, admin.ID, userID, data.Disabled)
+	if err == nil {
+		log.Printf("Admin %s set disabled of user %s to %t\n", admin.ID, userID, data.Disabled)
+	}
+
+	writeAdminUser(w, user, err)
+}
+
+func (cfg *Config) HandlerAdminSetAdmin(w http.ResponseWriter, r *http.Request) {
+	admin, userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	data := SetAdminData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := api.SetAdmin(r.Context(), cfg.Queries, admin.ID, userID, data.Admin)
+	if err == nil {
+		log.Printf("Admin %s set admin of user %s to %t\n", admin.ID, userID, data.Admin)
+	}
+
+	writeAdminUser(w, user, err)
+}
+
+func (cfg *Config) HandlerAdminUnlock(w http.ResponseWriter, r *http.Request) {
+	admin, userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := api.Unlock(r.Context(), cfg.Queries, userID)
+	if err == nil {
+		log.Printf("Admin %s unlocked user %s\n", admin.ID, userID)
+	}
+
+	writeAdminUser(w, user, err)
+}
+
+func (cfg *Config) HandlerAdminResetPassword(w http.ResponseWriter, r *http.Request) {
+	admin, userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	data := ResetPasswordData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	if data.Password == "" {
+		http.Error(w, "Password can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(data.Password)
+	if err != nil {
+		log.Printf("Couldn't hash password: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	err = api.ResetPassword(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, userID, hashedPassword)
+	if err != nil {
+		writeAdminUser(w, database.User{}, err)
+		return
+	}
+
+	log.Printf("Admin %s reset the password of user %s\n", admin.ID, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlerAdminResetTwoFactor turns off two-factor authentication for a user
+// who has lost their authenticator app and their recovery codes.
+func (cfg *Config) HandlerAdminResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	admin, userID, ok := adminUserID(w, r)
+	if !ok {
+		return
+	}
+
+	err := api.ResetTwoFactor(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, userID)
+	if err != nil {
+		if errors.Is(err, api.ErrTwoFactorNotEnabled) {
+			http.Error(w, "User doesn't have two-factor authentication", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("Couldn't reset two-factor authentication: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin %s turned off two-factor authentication for user %s\n", admin.ID, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Config) HandlerAdminPage(w http.ResponseWriter, r *http.Request) {
+	admin, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to serve admin page to unauthorized user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	stats, err := cfg.Queries.GetSiteStats(r.Context())
+	if err != nil {
+		log.Printf("Couldn't get site stats: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	users, err := cfg.Queries.ListUsers(r.Context())
+	if err != nil {
+		log.Printf("Couldn't list users: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	groups, err := cfg.Queries.ListGroups(r.Context())
+	if err != nil {
+		log.Printf("Couldn't list groups: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	err = pages.Admin(admin, stats, users, groups, time.Now()).Render(r.Context(), w)
+	if err != nil {
+		log.Printf("Couldn't send page: %v\n", err)
+		return
+	}
+}
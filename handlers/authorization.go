@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+// authorize checks that user has permission p in a group. Every handler for
+// a group goes through here, or through authorizeChange. On failure the
+// error response has already been written.
+func (cfg *Config) authorize(w http.ResponseWriter, r *http.Request, user database.User, groupID uuid.UUID, p api.Permission) (api.Membership, bool) {
+	m, err := api.Authorize(r.Context(), cfg.Queries, user.ID, groupID, p)
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrNotMember):
+			log.Printf("Attempt by user %s to use group %s they don't belong to\n", user.ID, groupID)
+			http.Error(w, "User does not belong to group", http.StatusForbidden)
+		case errors.Is(err, api.ErrForbidden):
+			log.Printf("Attempt by %s %s to use group %s without permission %d\n", m.Role, user.ID, groupID, p)
+			http.Error(w, "Your role in this group doesn't allow that", http.StatusForbidden)
+		default:
+			log.Printf("Couldn't authorize user: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+
+		return m, false
+	}
+
+	return m, true
+}
+
+// authorizeChange checks that user may edit, delete or restore something
+// created by createdBy in a group. On failure the error response has
+// already been written.
+func (cfg *Config) authorizeChange(w http.ResponseWriter, r *http.Request, user database.User, groupID uuid.UUID, createdBy uuid.NullUUID) (api.Membership, bool) {
+	m, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup)
+	if !ok {
+		return m, false
+	}
+
+	if !m.CanEditTransaction(createdBy) {
+		log.Printf("Attempt by %s %s to change another user's transaction\n", m.Role, user.ID)
+		http.Error(w, "Can't change other users' transactions", http.StatusForbidden)
+		return m, false
+	}
+
+	return m, true
+}
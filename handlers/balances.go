@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func (cfg *Config) HandlerGetSettleUp(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to get settle up plan with unauthenticated user\n")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	groupIDPath, ok := mux.Vars(r)["group_id"]
+	if !ok {
+		log.Printf("Couldn't find group id\n")
+		http.Error(w, "Couldn't find group id", http.StatusBadRequest)
+		return
+	}
+
+	groupID, err := uuid.Parse(groupIDPath)
+	if err != nil {
+		log.Printf("Couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup); !ok {
+		return
+	}
+
+	payments, err := accounting.GetSettleUpForGroup(cfg.Queries, r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't get settle up plan: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	if payments == nil {
+		payments = []accounting.SuggestedPayment{}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(payments); err != nil {
+		log.Printf("Couldn't send response body: %v\n", err)
+		return
+	}
+}
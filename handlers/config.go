@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"database/sql"
+	"time"
+
 	"github.com/gorilla/sessions"
 	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/matt-horst/split-ways/internal/mail"
 )
 
 type Config struct {
@@ -12,4 +15,21 @@ type Config struct {
 	Queries *database.Queries
 	Store   *sessions.CookieStore
 	JwtKey  string
+
+	// TrashRetention is how long deleted transactions are kept before they
+	// are purged. Zero keeps them forever.
+	TrashRetention time.Duration
+
+	// Mailer sends emails to verify addresses and reset passwords, with
+	// links back to the site at BaseURL.
+	Mailer  mail.Mailer
+	BaseURL string
+
+	// TwoFactorKey encrypts the secrets of users' authenticator apps. Without
+	// it, two-factor authentication can't be set up.
+	TwoFactorKey []byte
+
+	// AllowReset lets admins delete every user and group. It must never be
+	// set in production.
+	AllowReset bool
 }
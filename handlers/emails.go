@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+type UpdateEmailData struct {
+	// Email is removed if it's empty.
+	Email string `json:"email"`
+}
+
+type ForgotPasswordData struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetData struct {
+	// Token is from the link in the password reset email.
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ExportEmail struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+}
+
+// sendVerificationEmail emails user a link to verify their address. Failing
+// to send it is only logged, since it can be sent again from the settings
+// page.
+func (cfg *Config) sendVerificationEmail(r *http.Request, user database.User) {
+	err := api.SendVerificationEmail(r.Context(), cfg.Queries, cfg.Mailer, cfg.BaseURL, user, time.Now())
+	if err != nil {
+		log.Printf("Couldn't send verification email to user %s: %v\n", user.ID, err)
+	}
+}
+
+func (cfg *Config) HandlerUpdateEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to update email of unauthenticated user\n")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if !requireSession(w, r) {
+		return
+	}
+
+	data := UpdateEmailData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't change email address", http.StatusBadRequest)
+		return
+	}
+
+	email := ""
+	if strings.TrimSpace(data.Email) != "" {
+		email, err = api.ParseEmail(data.Email)
+		if err != nil {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if email != user.Email.String {
+		user, err = api.SetEmail(r.Context(), cfg.Queries, user.ID, email)
+		if err != nil {
+			if errors.Is(err, api.ErrEmailTaken) {
+				http.Error(w, "Email address already in use", http.StatusBadRequest)
+				return
+			}
+
+			log.Printf("Couldn't update email: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		if user.Email.Valid {
+			cfg.sendVerificationEmail(r, user)
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(ExportEmail{
+		Email:    user.Email.String,
+		Verified: user.EmailVerifiedAt.Valid,
+	})
+	if err != nil {
+		log.Printf("Couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+// HandlerResendVerification emails the user another link to verify their
+// address.
+func (cfg *Config) HandlerResendVerification(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to verify email of unauthenticated user\n")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	err := api.SendVerificationEmail(r.Context(), cfg.Queries, cfg.Mailer, cfg.BaseURL, user, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrNoEmail):
+			http.Error(w, "You don't have an email address", http.StatusBadRequest)
+		case errors.Is(err, api.ErrEmailVerified):
+			http.Error(w, "Your email address is already verified", http.StatusConflict)
+		default:
+			log.Printf("Couldn't send verification email: %v\n", err)
+			http.Error(w, "Couldn't send email", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlerForgotPassword emails a link to reset their password to whoever
+// owns a verified email address. It succeeds whether or not anyone does.
+func (cfg *Config) HandlerForgotPassword(w http.ResponseWriter, r *http.Request) {
+	data := ForgotPasswordData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't reset password", http.StatusBadRequest)
+		return
+	}
+
+	err = api.RequestPasswordReset(r.Context(), cfg.Queries, cfg.Mailer, cfg.BaseURL, data.Email, time.Now())
+	if err != nil {
+		if errors.Is(err, api.ErrInvalidEmail) {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Couldn't request password reset: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlerPasswordReset sets a new password with the token from a password
+// reset email. The token can only be used once.
+func (cfg *Config) HandlerPasswordReset(w http.ResponseWriter, r *http.Request) {
+	data := PasswordResetData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't reset password", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(data.Password)
+	if err != nil {
+		log.Printf("Couldn't hash password: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := api.ResetForgottenPassword(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, data.Token, hashedPassword, time.Now())
+	if err != nil {
+		if errors.Is(err, api.ErrInvalidEmailToken) {
+			http.Error(w, "This link is invalid or has expired", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Couldn't reset password: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Password of user %s reset by email\n", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
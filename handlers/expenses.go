@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -8,10 +9,33 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
 	"github.com/matt-horst/split-ways/internal/database"
 	"github.com/shopspring/decimal"
 )
 
+type ExpenseSplitData struct {
+	Username string          `json:"username"`
+	Value    decimal.Decimal `json:"value"`
+}
+
+type ExpenseItemData struct {
+	Description string          `json:"description"`
+	Amount      decimal.Decimal `json:"amount"`
+	Members     []string        `json:"members"`
+}
+
+type ExpenseChargeData struct {
+	Kind   string          `json:"kind"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+type ExpensePayerData struct {
+	Username string          `json:"username"`
+	Amount   decimal.Decimal `json:"amount"`
+}
+
 func (cfg *Config) HandlerCreateExpense(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(userContextKey).(database.User)
 	if !ok {
@@ -34,22 +58,22 @@ func (cfg *Config) HandlerCreateExpense(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if _, err = cfg.Queries.GetUserGroup(
-		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  user.ID,
-			GroupID: groupID,
-		},
-	); err != nil {
-		log.Printf("Attempt to create expense non-user group: %v\n", err)
-		http.Error(w, "User does not belong to group", http.StatusForbidden)
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermCreateTransaction); !ok {
 		return
 	}
 
 	data := struct {
-		Description string          `json:"description"`
-		Amount      decimal.Decimal `json:"amount"`
-		PaidBy      string          `json:"paid_by"`
+		Description  string              `json:"description"`
+		Amount       decimal.Decimal     `json:"amount"`
+		PaidBy       string              `json:"paid_by"`
+		Payers       []ExpensePayerData  `json:"payers"`
+		SplitMode    string              `json:"split_mode"`
+		Participants []string            `json:"participants"`
+		Splits       []ExpenseSplitData  `json:"splits"`
+		Items        []ExpenseItemData   `json:"items"`
+		Charges      []ExpenseChargeData `json:"charges"`
+		Currency     string              `json:"currency"`
+		OccurredOn   string              `json:"occurred_on"`
 	}{}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -58,92 +82,109 @@ func (cfg *Config) HandlerCreateExpense(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	paidBy := uuid.NullUUID{Valid: true, UUID: user.ID}
-	if data.PaidBy != "" {
-		paidByUser, err := cfg.Queries.GetUserByUsername(r.Context(), data.PaidBy)
-		if err != nil {
-			log.Printf("Couldn't find user: %v\n", err)
-			http.Error(w, fmt.Sprintf("Couldn't find user %s", data.PaidBy), http.StatusBadRequest)
-			return
-		}
+	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't find group: %v\n", err)
+		http.Error(w, "Couldn't find group", http.StatusBadRequest)
+		return
+	}
 
-		if _, err := cfg.Queries.GetUserGroup(
-			r.Context(),
-			database.GetUserGroupParams{GroupID: groupID, UserID: paidByUser.ID},
-		); err != nil {
-			log.Printf("Couldn't create expense where paid by user is not in group: %v\n", err)
-			http.Error(w, fmt.Sprintf("%s is not in group", data.PaidBy), http.StatusBadRequest)
+	currency, err := cfg.getTransactionCurrency(r.Context(), data.Currency, group.Currency, group)
+	if err != nil {
+		if isCurrencyError(err) {
+			log.Printf("Couldn't use currency: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		paidBy.UUID = paidByUser.ID
-	}
-
-	transaction, err := cfg.Queries.CreateTransaction(
-		r.Context(),
-		database.CreateTransactionParams{
-			GroupID: groupID,
-			CreatedBy: uuid.NullUUID{
-				UUID:  user.ID,
-				Valid: true,
-			},
-			Kind: "expense",
-		},
-	)
-	if err != nil {
-		log.Printf("Couldn't create transaction: %v\n", err)
+		log.Printf("Couldn't get currency: %v\n", err)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
-	expense, err := cfg.Queries.CreateExpense(
-		r.Context(),
-		database.CreateExpenseParams{
-			TransactionID: transaction.ID,
-			PaidBy:        paidBy,
-			Description:   data.Description,
-			Amount:        data.Amount,
-		},
-	)
+	occurredOn, err := parseOccurredOn(data.OccurredOn)
 	if err != nil {
-		log.Printf("Couldn't create expense: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		log.Printf("Couldn't parse date: %v\n", err)
+		http.Error(w, "Couldn't parse date", http.StatusBadRequest)
 		return
 	}
 
-	users, err := cfg.Queries.GetUsersByGroup(r.Context(), groupID)
+	splitMode, err := accounting.ParseSplitMode(data.SplitMode)
 	if err != nil {
-		log.Printf("Couldn't get users in group: %v", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		log.Printf("Couldn't parse split mode: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	debtAmount := data.Amount.Div(decimal.NewFromInt(int64(len(users))))
+	var items []accounting.Item
+	var charges []accounting.Charge
+	if splitMode == accounting.SplitItems {
+		items, charges, ok = cfg.getItems(w, r, groupID, data.Items, data.Charges)
+		if !ok {
+			return
+		}
+
+		// The receipt's total is the expense's amount unless one was given.
+		if data.Amount.IsZero() {
+			data.Amount = accounting.ItemsTotal(items, charges)
+		}
+	}
 
-	for _, u := range users {
-		if u.ID == expense.PaidBy.UUID {
-			continue
+	payers := []accounting.Contribution{{UserID: user.ID, Amount: data.Amount}}
+	if len(data.Payers) > 0 || data.PaidBy != "" {
+		payers, ok = cfg.getPayers(w, r, groupID, data.Amount, data.PaidBy, data.Payers)
+		if !ok {
+			return
 		}
+	}
 
-		_, err = cfg.Queries.CreateDebt(
-			r.Context(),
-			database.CreateDebtParams{
-				ExpenseID: expense.ID,
-				OwedTo:    expense.PaidBy,
-				OwedBy: uuid.NullUUID{
-					UUID:  u.ID,
-					Valid: true,
-				},
-				Amount: debtAmount,
-			},
-		)
-		if err != nil {
-			log.Printf("Couldn't create split: %v\n", err)
-			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+	if err := accounting.ValidateContributions(data.Amount, payers); err != nil {
+		log.Printf("Couldn't split expense: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var parts []accounting.SplitPart
+	if splitMode != accounting.SplitItems {
+		parts, ok = cfg.getSplitParts(w, r, groupID, splitMode, data.Participants, data.Splits)
+		if !ok {
 			return
 		}
 	}
 
+	parts, shares, err := splitExpense(splitMode, data.Amount, parts, items, charges)
+	if err != nil {
+		log.Printf("Couldn't split expense: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expense, err := api.CreateExpense(
+		r.Context(),
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		api.CreateExpenseParams{
+			GroupID:     groupID,
+			CreatedBy:   user.ID,
+			Payers:      payers,
+			Description: data.Description,
+			Amount:      data.Amount,
+			Currency:    currency,
+			SplitMode:   splitMode,
+			Parts:       parts,
+			Shares:      shares,
+			Items:       items,
+			Charges:     charges,
+			OccurredOn:  occurredOn,
+		},
+	)
+	if err != nil {
+		log.Printf("Couldn't create expense: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 
@@ -176,9 +217,13 @@ func (cfg *Config) HandlerUpdateExpense(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if !tx.CreatedBy.Valid || tx.CreatedBy.UUID != user.ID {
-		log.Printf("Attempt to edit expense for unauthorized user\n")
-		http.Error(w, "Can't edit other users' expenses", http.StatusForbidden)
+	if tx.DeletedAt.Valid {
+		log.Printf("Attempt to edit deleted transaction %s\n", tx.ID)
+		http.Error(w, "Transaction is in the trash", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorizeChange(w, r, user, tx.GroupID, tx.CreatedBy); !ok {
 		return
 	}
 
@@ -190,9 +235,17 @@ func (cfg *Config) HandlerUpdateExpense(w http.ResponseWriter, r *http.Request)
 	}
 
 	data := struct {
-		Amount      decimal.Decimal `json:"amount"`
-		Description string          `json:"description"`
-		PaidBy      string          `json:"paid_by"`
+		Amount       decimal.Decimal     `json:"amount"`
+		Description  string              `json:"description"`
+		PaidBy       string              `json:"paid_by"`
+		Payers       []ExpensePayerData  `json:"payers"`
+		SplitMode    string              `json:"split_mode"`
+		Participants []string            `json:"participants"`
+		Splits       []ExpenseSplitData  `json:"splits"`
+		Items        []ExpenseItemData   `json:"items"`
+		Charges      []ExpenseChargeData `json:"charges"`
+		Currency     string              `json:"currency"`
+		OccurredOn   string              `json:"occurred_on"`
 	}{}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		log.Printf("Couldn't decode request body: %v\n", err)
@@ -200,6 +253,66 @@ func (cfg *Config) HandlerUpdateExpense(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	group, err := cfg.Queries.GetGroup(r.Context(), tx.GroupID)
+	if err != nil {
+		log.Printf("Couldn't find group: %v\n", err)
+		http.Error(w, "Couldn't find group", http.StatusBadRequest)
+		return
+	}
+
+	currency, err := cfg.getTransactionCurrency(r.Context(), data.Currency, expense.Currency, group)
+	if err != nil {
+		if isCurrencyError(err) {
+			log.Printf("Couldn't use currency: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Couldn't get currency: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	occurredOn, err := parseOccurredOn(data.OccurredOn)
+	if err != nil {
+		log.Printf("Couldn't parse date: %v\n", err)
+		http.Error(w, "Couldn't parse date", http.StatusBadRequest)
+		return
+	}
+
+	splitMode := accounting.SplitMode(expense.SplitMode)
+	resplit := data.SplitMode != "" || len(data.Participants) > 0 || len(data.Splits) > 0 || len(data.Items) > 0
+	if resplit {
+		splitMode, err = accounting.ParseSplitMode(data.SplitMode)
+		if err != nil {
+			log.Printf("Couldn't parse split mode: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var items []accounting.Item
+	var charges []accounting.Charge
+	if splitMode == accounting.SplitItems {
+		if resplit {
+			items, charges, ok = cfg.getItems(w, r, tx.GroupID, data.Items, data.Charges)
+			if !ok {
+				return
+			}
+		} else {
+			items, charges, err = accounting.GetExpenseItems(cfg.Queries, r.Context(), expense.ID)
+			if err != nil {
+				log.Printf("Couldn't get items for expense: %v\n", err)
+				http.Error(w, "Something went wrong", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if !data.Amount.IsPositive() {
+			data.Amount = accounting.ItemsTotal(items, charges)
+		}
+	}
+
 	if data.Amount.LessThan(decimal.Zero) {
 		data.Amount = expense.Amount
 	}
@@ -208,74 +321,280 @@ func (cfg *Config) HandlerUpdateExpense(w http.ResponseWriter, r *http.Request)
 		data.Description = expense.Description
 	}
 
-	paidByID := expense.PaidBy
-	if data.PaidBy != "" {
-		paidBy, err := cfg.Queries.GetUserByUsername(r.Context(), data.PaidBy)
+	var payers []accounting.Contribution
+	if len(data.Payers) > 0 || data.PaidBy != "" {
+		payers, ok = cfg.getPayers(w, r, tx.GroupID, data.Amount, data.PaidBy, data.Payers)
+		if !ok {
+			return
+		}
+	} else {
+		payers, err = cfg.getStoredPayers(r.Context(), expense, data.Amount)
+		if err != nil {
+			log.Printf("Couldn't get payers for expense: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := accounting.ValidateContributions(data.Amount, payers); err != nil {
+		log.Printf("Couldn't split expense: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var parts []accounting.SplitPart
+	switch {
+	case splitMode == accounting.SplitItems:
+		// Itemized expenses are split by their items alone.
+	case resplit:
+		parts, ok = cfg.getSplitParts(w, r, tx.GroupID, splitMode, data.Participants, data.Splits)
+		if !ok {
+			return
+		}
+	default:
+		parts, err = cfg.getStoredSplitParts(r.Context(), tx.GroupID, expense)
 		if err != nil {
-			log.Printf("Couldn't find user: %v\n", err)
-			http.Error(w, fmt.Sprintf("Couldn't find user %s", data.PaidBy), http.StatusBadRequest)
+			log.Printf("Couldn't get splits for expense: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
 			return
 		}
+	}
 
-		paidByID.Valid = true
-		paidByID.UUID = paidBy.ID
+	parts, shares, err := splitExpense(splitMode, data.Amount, parts, items, charges)
+	if err != nil {
+		log.Printf("Couldn't split expense: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	expense, err = cfg.Queries.UpdateExpense(
+	if _, err := api.UpdateExpense(
 		r.Context(),
-		database.UpdateExpenseParams{
-			ID:          expense.ID,
-			PaidBy:      paidByID,
-			Description: data.Description,
-			Amount:      data.Amount,
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		api.UpdateExpenseParams{
+			TransactionID: tx.ID,
+			ExpenseID:     expense.ID,
+			UpdatedBy:     user.ID,
+			Payers:        payers,
+			Description:   data.Description,
+			Amount:        data.Amount,
+			Currency:      currency,
+			SplitMode:     splitMode,
+			Parts:         parts,
+			Shares:        shares,
+			Items:         items,
+			Charges:       charges,
+			OccurredOn:    occurredOn,
 		},
-	)
-	if err != nil {
+	); err != nil {
 		log.Printf("Couldn't update expense: %v\n", err)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
-	usersInGroup, err := cfg.Queries.GetUsersByGroup(r.Context(), tx.GroupID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// splitExpense works out each member's share of an expense. Itemized
+// expenses are split by their items, and record each member's share as their
+// split part so that the participants are kept with the expense.
+func splitExpense(mode accounting.SplitMode, amount decimal.Decimal, parts []accounting.SplitPart, items []accounting.Item, charges []accounting.Charge) ([]accounting.SplitPart, []accounting.Share, error) {
+	if mode != accounting.SplitItems {
+		shares, err := accounting.ComputeShares(mode, amount, parts)
+		return parts, shares, err
+	}
+
+	shares, err := accounting.ComputeItemShares(amount, items, charges)
 	if err != nil {
-		log.Printf("Couldn't get users in group: %v\n", err)
-		http.Error(w, "Couldn't find group", http.StatusBadRequest)
-		return
+		return nil, nil, err
 	}
 
-	debtAmount := expense.Amount.Div(decimal.NewFromInt(int64(len(usersInGroup))))
+	parts = make([]accounting.SplitPart, len(shares))
+	for i, share := range shares {
+		parts[i] = accounting.SplitPart{UserID: share.UserID, Value: share.Amount}
+	}
 
-	if err := cfg.Queries.DeleteDebtsByExpense(r.Context(), expense.ID); err != nil {
-		log.Printf("Couldn't delete debts for expense: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
-		return
+	return parts, shares, nil
+}
+
+// getItems resolves the members sharing each item of a receipt to users of
+// the group. On failure the error response has already been written.
+func (cfg *Config) getItems(w http.ResponseWriter, r *http.Request, groupID uuid.UUID, itemData []ExpenseItemData, chargeData []ExpenseChargeData) ([]accounting.Item, []accounting.Charge, bool) {
+	members := make(map[string]uuid.UUID)
+
+	items := make([]accounting.Item, len(itemData))
+	for i, data := range itemData {
+		items[i] = accounting.Item{Description: data.Description, Amount: data.Amount}
+
+		for _, username := range data.Members {
+			id, ok := members[username]
+			if !ok {
+				u, err := cfg.Queries.GetGroupMemberByUsername(
+					r.Context(),
+					database.GetGroupMemberByUsernameParams{GroupID: groupID, Username: username},
+				)
+				if err != nil {
+					log.Printf("Couldn't split item with user not in group: %v\n", err)
+					http.Error(w, fmt.Sprintf("%s is not in group", username), http.StatusBadRequest)
+					return nil, nil, false
+				}
+
+				id = u.ID
+				members[username] = id
+			}
+
+			items[i].Members = append(items[i].Members, id)
+		}
+	}
+
+	var charges []accounting.Charge
+	for _, data := range chargeData {
+		kind, err := accounting.ParseChargeKind(data.Kind)
+		if err != nil {
+			log.Printf("Couldn't parse charge: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, nil, false
+		}
+
+		if data.Amount.IsZero() {
+			continue
+		}
+
+		charges = append(charges, accounting.Charge{Kind: kind, Amount: data.Amount})
+	}
+
+	return items, charges, true
+}
+
+// getSplitParts resolves the members taking part in an expense to users of
+// the group. Equal splits are divided between the named participants, or
+// every member of the group when none are given; other modes use the members
+// named in splits. On failure the error response has already been written.
+func (cfg *Config) getSplitParts(w http.ResponseWriter, r *http.Request, groupID uuid.UUID, mode accounting.SplitMode, participants []string, splits []ExpenseSplitData) ([]accounting.SplitPart, bool) {
+	if mode == accounting.SplitEqual {
+		if len(participants) == 0 {
+			users, err := cfg.Queries.GetUsersByGroup(r.Context(), groupID)
+			if err != nil {
+				log.Printf("Couldn't get users in group: %v\n", err)
+				http.Error(w, "Something went wrong", http.StatusInternalServerError)
+				return nil, false
+			}
+
+			parts := make([]accounting.SplitPart, len(users))
+			for i, u := range users {
+				parts[i] = accounting.SplitPart{UserID: u.ID}
+			}
+
+			return parts, true
+		}
+
+		splits = make([]ExpenseSplitData, len(participants))
+		for i, username := range participants {
+			splits[i] = ExpenseSplitData{Username: username}
+		}
+	}
+
+	parts := make([]accounting.SplitPart, 0, len(splits))
+	for _, split := range splits {
+		u, err := cfg.Queries.GetGroupMemberByUsername(
+			r.Context(),
+			database.GetGroupMemberByUsernameParams{GroupID: groupID, Username: split.Username},
+		)
+		if err != nil {
+			log.Printf("Couldn't split expense with user not in group: %v\n", err)
+			http.Error(w, fmt.Sprintf("%s is not in group", split.Username), http.StatusBadRequest)
+			return nil, false
+		}
+
+		parts = append(parts, accounting.SplitPart{UserID: u.ID, Value: split.Value})
+	}
+
+	return parts, true
+}
+
+// getStoredSplitParts rebuilds the split parts that were saved with an
+// expense so that it can be re-split when only the amount changes. Equal
+// splits saved before participants were recorded cover the whole group.
+func (cfg *Config) getStoredSplitParts(ctx context.Context, groupID uuid.UUID, expense database.Expense) ([]accounting.SplitPart, error) {
+	splits, err := cfg.Queries.GetExpenseSplitsByExpense(ctx, expense.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(splits) == 0 && accounting.SplitMode(expense.SplitMode) == accounting.SplitEqual {
+		users, err := cfg.Queries.GetUsersByGroup(ctx, groupID)
+		if err != nil {
+			return nil, err
+		}
+
+		parts := make([]accounting.SplitPart, len(users))
+		for i, u := range users {
+			parts[i] = accounting.SplitPart{UserID: u.ID}
+		}
+
+		return parts, nil
 	}
 
-	for _, u := range usersInGroup {
-		if expense.PaidBy.Valid && expense.PaidBy.UUID == u.ID {
+	parts := make([]accounting.SplitPart, 0, len(splits))
+	for _, split := range splits {
+		if !split.UserID.Valid {
 			continue
 		}
 
-		if _, err := cfg.Queries.CreateDebt(
+		parts = append(parts, accounting.SplitPart{UserID: split.UserID.UUID, Value: split.Value})
+	}
+
+	return parts, nil
+}
+
+// getPayers resolves the members who paid for an expense to users of the
+// group. A single paid_by user is taken to have paid the whole amount. On
+// failure the error response has already been written.
+func (cfg *Config) getPayers(w http.ResponseWriter, r *http.Request, groupID uuid.UUID, amount decimal.Decimal, paidBy string, payers []ExpensePayerData) ([]accounting.Contribution, bool) {
+	if len(payers) == 0 {
+		payers = []ExpensePayerData{{Username: paidBy, Amount: amount}}
+	}
+
+	contributions := make([]accounting.Contribution, 0, len(payers))
+	for _, payer := range payers {
+		u, err := cfg.Queries.GetGroupMemberByUsername(
 			r.Context(),
-			database.CreateDebtParams{
-				ExpenseID: expense.ID,
-				OwedTo:    expense.PaidBy,
-				OwedBy:    uuid.NullUUID{Valid: true, UUID: u.ID},
-				Amount:    debtAmount,
-			},
-		); err != nil {
-			log.Printf("Couldn't create debt: %v\n", err)
-			http.Error(w, "Something went wrong", http.StatusInternalServerError)
-			return
+			database.GetGroupMemberByUsernameParams{GroupID: groupID, Username: payer.Username},
+		)
+		if err != nil {
+			log.Printf("Couldn't create expense where paid by user is not in group: %v\n", err)
+			http.Error(w, fmt.Sprintf("%s is not in group", payer.Username), http.StatusBadRequest)
+			return nil, false
 		}
+
+		contributions = append(contributions, accounting.Contribution{UserID: u.ID, Amount: payer.Amount})
 	}
 
-	if _, err := cfg.Queries.UpdateTransaction(r.Context(), tx.ID); err != nil {
-		log.Printf("Couldn't update transaction: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
-		return
+	return contributions, true
+}
+
+// getStoredPayers rebuilds the payers that were saved with an expense. When
+// one member paid for everything they are taken to have paid the new amount
+// too; several payers have to be given again if the amount changes.
+func (cfg *Config) getStoredPayers(ctx context.Context, expense database.Expense, amount decimal.Decimal) ([]accounting.Contribution, error) {
+	payers, err := cfg.Queries.GetExpensePayersByExpense(ctx, expense.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	contributions := make([]accounting.Contribution, 0, len(payers))
+	for _, payer := range payers {
+		if !payer.UserID.Valid {
+			continue
+		}
+
+		contributions = append(contributions, accounting.Contribution{UserID: payer.UserID.UUID, Amount: payer.Amount})
+	}
+
+	if len(contributions) == 1 {
+		contributions[0].Amount = amount
+	}
+
+	return contributions, nil
 }
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+type AddFriendData struct {
+	Username string `json:"username"`
+}
+
+// ExportFriend is a friend along with the direct group holding the ledger
+// between the two of them.
+type ExportFriend struct {
+	ExportUser
+	GroupID uuid.UUID `json:"group_id"`
+}
+
+// ExportBalances is what a user is owed by each person they share a group or
+// friendship with, in Currency.
+type ExportBalances struct {
+	Currency string               `json:"currency"`
+	Balances []accounting.Balance `json:"balances"`
+}
+
+func (cfg *Config) HandlerAddFriend(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to add friend with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	data := AddFriendData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	friend, err := cfg.Queries.GetUserByUsername(r.Context(), data.Username)
+	if err != nil {
+		log.Printf("couldn't find user: %v\n", err)
+		http.Error(w, "Couldn't find user", http.StatusBadRequest)
+		return
+	}
+
+	if friend.Placeholder {
+		log.Printf("attempt to befriend placeholder %s\n", friend.ID)
+		http.Error(w, "Placeholder members can't be added as friends", http.StatusBadRequest)
+		return
+	}
+
+	friendship, err := api.AddFriend(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, user, friend)
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrFriendSelf):
+			http.Error(w, "You can't add yourself as a friend", http.StatusBadRequest)
+		case errors.Is(err, api.ErrAlreadyFriends):
+			http.Error(w, "Already friends", http.StatusConflict)
+		default:
+			log.Printf("couldn't add friend: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err = json.NewEncoder(w).Encode(ExportFriend{
+		ExportUser: ExportUser{
+			ID:        friend.ID,
+			Username:  friend.Username,
+			CreatedAt: friend.CreatedAt,
+			UpdatedAt: friend.UpdatedAt,
+		},
+		GroupID: friendship.GroupID,
+	})
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerRemoveFriend(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to remove friend with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	friendID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't parse friend id: %v\n", err)
+		http.Error(w, "Couldn't parse friend id", http.StatusBadRequest)
+		return
+	}
+
+	err = api.RemoveFriend(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, user.ID, friendID)
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrNotFriends):
+			http.Error(w, "Couldn't find friend", http.StatusNotFound)
+		case errors.Is(err, api.ErrOutstandingBalance):
+			log.Printf("couldn't remove friend: %v\n", err)
+			http.Error(w, "You still have a balance with this friend. Settle up before removing them.", http.StatusConflict)
+		default:
+			log.Printf("couldn't remove friend: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Config) HandlerGetBalances(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to get balances with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	balances, err := accounting.GetBalancesByPerson(cfg.Queries, r.Context(), user.ID)
+	if err != nil {
+		log.Printf("couldn't get balances: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(ExportBalances{
+		Currency: accounting.DefaultCurrency,
+		Balances: balances,
+	})
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
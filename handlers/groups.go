@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/accounting"
 	"github.com/matt-horst/split-ways/internal/api"
 	"github.com/matt-horst/split-ways/internal/database"
 )
@@ -23,19 +26,52 @@ type ExportUser struct {
 }
 
 type CreateGroupData struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
 }
 
 type UpdateGroupData struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
 }
 
 type AddUserToGroupData struct {
 	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type UpdateMemberRoleData struct {
+	Role string `json:"role"`
+}
+
+type TransferOwnershipData struct {
+	ID uuid.UUID `json:"id"`
+}
+
+type LeaveGroupData struct {
+	// Successor is the member who takes over when the owner leaves.
+	Successor uuid.NullUUID `json:"successor"`
+	// Confirm leaves even with an outstanding balance.
+	Confirm bool `json:"confirm"`
+}
+
+// GroupMember is a user along with their role in a group.
+type GroupMember struct {
+	ExportUser
+	Role api.Role `json:"role"`
+	// Placeholder members have no account and can't log in.
+	Placeholder bool `json:"placeholder"`
+}
+
+type AddPlaceholderData struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
 }
 
 type RemoveUserFromGroupData struct {
 	ID uuid.UUID `json:"id"`
+	// Force removes the user even with an outstanding balance.
+	Force bool `json:"force"`
 }
 
 func (cfg *Config) HandlerCreateGroup(w http.ResponseWriter, r *http.Request) {
@@ -55,14 +91,26 @@ func (cfg *Config) HandlerCreateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if data.Currency == "" {
+		data.Currency = accounting.DefaultCurrency
+	}
+
+	currency, err := accounting.ParseCurrency(data.Currency)
+	if err != nil {
+		log.Printf("couldn't parse currency: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	group, err := api.CreateGroup(
 		r.Context(),
 		cfg.DB,
 		cfg.Tx,
 		cfg.Queries,
 		database.CreateGroupParams{
-			Name:  data.Name,
-			Owner: user.ID,
+			Name:     data.Name,
+			Owner:    user.ID,
+			Currency: currency,
 		},
 	)
 	if err != nil {
@@ -111,9 +159,7 @@ func (cfg *Config) HandlerUpdateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if group.Owner != user.ID {
-		log.Printf("attempt to update group from non-owner\n")
-		http.Error(w, "Can't update group as non-owner", http.StatusForbidden)
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermManageGroup); !ok {
 		return
 	}
 
@@ -125,9 +171,37 @@ func (cfg *Config) HandlerUpdateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	group, err = cfg.Queries.UpdateGroupName(
+	if data.Name == "" {
+		data.Name = group.Name
+	}
+
+	currency := group.Currency
+	if data.Currency != "" {
+		currency, err = accounting.ParseCurrency(data.Currency)
+		if err != nil {
+			log.Printf("couldn't parse currency: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if currency != group.Currency {
+		if err := cfg.checkGroupCurrency(r.Context(), groupID, currency); err != nil {
+			if errors.Is(err, accounting.ErrNoExchangeRate) {
+				log.Printf("couldn't change group currency: %v\n", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			log.Printf("couldn't check group currency: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	group, err = cfg.Queries.UpdateGroup(
 		r.Context(),
-		database.UpdateGroupNameParams{ID: groupID, Name: data.Name},
+		database.UpdateGroupParams{ID: groupID, Name: data.Name, Currency: currency},
 	)
 	if err != nil {
 		log.Printf("couldn't update group: %v\n", err)
@@ -165,21 +239,7 @@ func (cfg *Config) HandlerAddUserToGroup(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("couldn't find group: %v\n", err)
-			http.Error(w, "Couldn't find group", http.StatusBadRequest)
-			return
-		}
-
-		log.Printf("couldn't get group: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
-		return
-	}
-
-	if group.Owner != user.ID {
-		http.Error(w, "You must be group owner to perform this action", http.StatusForbidden)
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermManageMembers); !ok {
 		return
 	}
 
@@ -192,6 +252,16 @@ func (cfg *Config) HandlerAddUserToGroup(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	role := api.RoleMember
+	if data.Role != "" {
+		role, err = api.ParseRole(data.Role)
+		if err != nil {
+			log.Printf("couldn't parse role: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	addUser, err := cfg.Queries.GetUserByUsername(r.Context(), data.Username)
 	if err != nil {
 		log.Printf("couldn't find user: %v\n", err)
@@ -199,11 +269,16 @@ func (cfg *Config) HandlerAddUserToGroup(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	_, err = cfg.Queries.CreateUserGroup(
+	_, err = api.AddUserToGroup(
 		r.Context(),
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		user.ID,
 		database.CreateUserGroupParams{
 			UserID:  addUser.ID,
 			GroupID: groupID,
+			Role:    string(role),
 		},
 	)
 	if err != nil {
@@ -213,6 +288,12 @@ func (cfg *Config) HandlerAddUserToGroup(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		if errors.Is(err, api.ErrMemberNameTaken) {
+			log.Printf("couldn't add user to group: %v\n", err)
+			http.Error(w, "Someone in this group already has that name", http.StatusBadRequest)
+			return
+		}
+
 		// Duplicate key error
 		if strings.Contains(err.Error(), "users_groups_user_id_group_id_key") {
 			log.Printf("couldn't add duplicate user group: %v\n", err)
@@ -228,6 +309,82 @@ func (cfg *Config) HandlerAddUserToGroup(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (cfg *Config) HandlerAddPlaceholder(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted add placeholder to group with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermManageMembers); !ok {
+		return
+	}
+
+	data := AddPlaceholderData{}
+
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(data.Name)
+	if name == "" {
+		http.Error(w, "Placeholder members need a name", http.StatusBadRequest)
+		return
+	}
+
+	role := api.RoleMember
+	if data.Role != "" {
+		role, err = api.ParseRole(data.Role)
+		if err != nil {
+			log.Printf("couldn't parse role: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	placeholder, err := api.AddPlaceholder(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, user.ID, groupID, name, role)
+	if err != nil {
+		if errors.Is(err, api.ErrMemberNameTaken) {
+			log.Printf("couldn't add placeholder: %v\n", err)
+			http.Error(w, "Someone in this group already has that name", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("couldn't add placeholder: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err = json.NewEncoder(w).Encode(GroupMember{
+		ExportUser: ExportUser{
+			ID:        placeholder.ID,
+			Username:  placeholder.Username,
+			CreatedAt: placeholder.CreatedAt,
+			UpdatedAt: placeholder.UpdatedAt,
+		},
+		Role:        role,
+		Placeholder: true,
+	})
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
 func (cfg *Config) HandlerGetGroupUsers(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(userContextKey).(database.User)
 	if !ok {
@@ -250,14 +407,7 @@ func (cfg *Config) HandlerGetGroupUsers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	ok, err = api.IsUserInGroup(r.Context(), cfg.Queries, groupID, user.ID)
-	if err != nil {
-		log.Printf("couldn't determine if user in group: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
-		return
-	}
-	if !ok {
-		http.Error(w, "User not member of group", http.StatusForbidden)
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup); !ok {
 		return
 	}
 
@@ -277,21 +427,37 @@ func (cfg *Config) HandlerGetGroupUsers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	sanitizedUsers := make([]ExportUser, len(users))
+	userGroups, err := cfg.Queries.GetUserGroupsByGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("couldn't get roles by group: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	roles := make(map[uuid.UUID]api.Role, len(userGroups))
+	for _, userGroup := range userGroups {
+		roles[userGroup.UserID] = api.Role(userGroup.Role)
+	}
+
+	sanitizedUsers := make([]GroupMember, len(users))
 
 	for i, user := range users {
-		sanitizedUsers[i] = ExportUser{
-			ID:        user.ID,
-			Username:  user.Username,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
+		sanitizedUsers[i] = GroupMember{
+			ExportUser: ExportUser{
+				ID:        user.ID,
+				Username:  user.Username,
+				CreatedAt: user.CreatedAt,
+				UpdatedAt: user.UpdatedAt,
+			},
+			Role:        roles[user.ID],
+			Placeholder: user.Placeholder,
 		}
 	}
 
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	err = json.NewEncoder(w).Encode(users)
+	err = json.NewEncoder(w).Encode(sanitizedUsers)
 	if err != nil {
 		log.Printf("couldn't write response body: %v\n", err)
 		return
@@ -320,25 +486,85 @@ func (cfg *Config) HandlerRemoveUserFromGroup(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
-	if err != nil {
+	data := RemoveUserFromGroupData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Malformed request body", http.StatusBadRequest)
+		return
+	}
+
+	permission := api.PermManageMembers
+	if data.Force {
+		permission = api.PermForceRemoveMember
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, permission); !ok {
+		return
+	}
+
+	if _, err := api.RemoveUserFromGroup(
+		r.Context(),
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		user.ID,
+		database.DeleteUserGroupParams{UserID: data.ID, GroupID: groupID},
+		data.Force,
+	); err != nil {
+		if errors.Is(err, api.ErrOutstandingBalance) {
+			log.Printf("couldn't remove user from group: %v\n", err)
+			http.Error(w, "This member still owes or is owed money in the group.", http.StatusConflict)
+			return
+		}
+
+		if errors.Is(err, api.ErrRemoveOwner) {
+			log.Printf("couldn't remove user from group: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("couldn't find group: %v\n", err)
-			http.Error(w, "Couldn't find group", http.StatusBadRequest)
+			log.Printf("couln't remove user from group; user not in group: %v\n", err)
+			http.Error(w, "User not in group", http.StatusBadRequest)
 			return
 		}
 
-		log.Printf("couldn't get group: %v\n", err)
-		http.Error(w, "Somthing went wrong", http.StatusInternalServerError)
+		log.Printf("couldn't delete user group: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
-	if group.Owner != user.ID {
-		http.Error(w, "You must be group owner to perform this action", http.StatusForbidden)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *Config) HandlerUpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempt to change member role using unauthorized user\n")
+		http.Error(w, "User unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	data := RemoveUserFromGroupData{}
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		log.Printf("couldn't parse user id: %v\n", err)
+		http.Error(w, "Couldn't parse user id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermManageMembers); !ok {
+		return
+	}
+
+	data := UpdateMemberRoleData{}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		log.Printf("couldn't decode request body: %v\n", err)
@@ -346,18 +572,155 @@ func (cfg *Config) HandlerRemoveUserFromGroup(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if _, err := cfg.Queries.DeleteUserGroup(r.Context(), database.DeleteUserGroupParams{UserID: data.ID, GroupID: groupID}); err != nil {
+	userGroup, err := api.UpdateMemberRole(
+		r.Context(),
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		user.ID,
+		database.UpdateUserGroupRoleParams{GroupID: groupID, UserID: userID, Role: data.Role},
+	)
+	if err != nil {
+		if errors.Is(err, api.ErrInvalidRole) || errors.Is(err, api.ErrOwnerRole) {
+			log.Printf("couldn't change member role: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("couln't remove user from group; user not in group: %v\n", err)
+			log.Printf("couldn't change member role; user not in group: %v\n", err)
 			http.Error(w, "User not in group", http.StatusBadRequest)
 			return
 		}
 
-		log.Printf("couldn't delete user group: %v\n", err)
+		log.Printf("couldn't change member role: %v\n", err)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(userGroup); err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+	}
+}
+
+func (cfg *Config) HandlerTransferOwnership(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempt to transfer group ownership using unauthorized user\n")
+		http.Error(w, "User unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermTransferOwnership); !ok {
+		return
+	}
+
+	data := TransferOwnershipData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Malformed request body", http.StatusBadRequest)
+		return
+	}
+
+	group, err := api.TransferOwnership(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, user.ID, groupID, data.ID)
+	if err != nil {
+		if errors.Is(err, api.ErrNotMember) {
+			log.Printf("couldn't transfer group ownership: %v\n", err)
+			http.Error(w, "User not in group", http.StatusBadRequest)
+			return
+		}
+
+		if errors.Is(err, api.ErrPlaceholderOwner) {
+			log.Printf("couldn't transfer group ownership: %v\n", err)
+			http.Error(w, "Placeholder members can't own a group", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("couldn't transfer group ownership: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(group); err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+	}
+}
+
+func (cfg *Config) HandlerLeaveGroup(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempt to leave group using unauthorized user\n")
+		http.Error(w, "User unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup); !ok {
+		return
+	}
+
+	data := LeaveGroupData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Malformed request body", http.StatusBadRequest)
+		return
+	}
+
+	err = api.LeaveGroup(
+		r.Context(),
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		api.LeaveGroupParams{
+			GroupID:   groupID,
+			UserID:    user.ID,
+			Successor: data.Successor,
+			Confirm:   data.Confirm,
+		},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrOutstandingBalance):
+			log.Printf("couldn't leave group: %v\n", err)
+			http.Error(w, "You still have a balance in this group. Settle up first, or confirm to leave anyway.", http.StatusConflict)
+		case errors.Is(err, api.ErrSuccessorRequired):
+			log.Printf("couldn't leave group: %v\n", err)
+			http.Error(w, "Choose another member to take over the group before leaving", http.StatusBadRequest)
+		case errors.Is(err, api.ErrNotMember):
+			log.Printf("couldn't leave group: %v\n", err)
+			http.Error(w, "Successor not in group", http.StatusBadRequest)
+		case errors.Is(err, api.ErrPlaceholderOwner):
+			log.Printf("couldn't leave group: %v\n", err)
+			http.Error(w, "Placeholder members can't own a group", http.StatusBadRequest)
+		case errors.Is(err, api.ErrDirectGroup):
+			log.Printf("couldn't leave group: %v\n", err)
+			http.Error(w, "Remove your friend instead", http.StatusBadRequest)
+		default:
+			log.Printf("couldn't leave group: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -383,22 +746,7 @@ func (cfg *Config) HandlerDeleteGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("couldn't find group: %v\n", err)
-			http.Error(w, "Couldn't find group", http.StatusBadRequest)
-			return
-		}
-
-		log.Printf("coudln't get group: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
-		return
-	}
-
-	if group.Owner != user.ID {
-		log.Printf("attempt to remove user from group by non-owner\n")
-		http.Error(w, "Can't remove users from group as non-owner", http.StatusForbidden)
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermDeleteGroup); !ok {
 		return
 	}
 
@@ -416,3 +764,25 @@ func (cfg *Config) HandlerDeleteGroup(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// checkGroupCurrency makes sure that every transaction already in a group can
+// be converted into currency before the group switches to it.
+func (cfg *Config) checkGroupCurrency(ctx context.Context, groupID uuid.UUID, currency string) error {
+	used, err := cfg.Queries.GetCurrenciesByGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	rates, err := accounting.GetExchangeRates(cfg.Queries, ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range used {
+		if !rates.CanConvert(code, currency) {
+			return fmt.Errorf("%w from %s to %s", accounting.ErrNoExchangeRate, code, currency)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/matt-horst/split-ways/web/pages"
+)
+
+type CreateInviteData struct {
+	Role string `json:"role"`
+	// ExpiresInDays defaults to a week, and can be at most 30.
+	ExpiresInDays int  `json:"expires_in_days"`
+	SingleUse     bool `json:"single_use"`
+	// PlaceholderID makes the invite a link to claim a placeholder member.
+	// Such invites always have the placeholder's role and are single use.
+	PlaceholderID uuid.NullUUID `json:"placeholder_id"`
+}
+
+type ExportInvite struct {
+	ID        uuid.UUID `json:"id"`
+	GroupID   uuid.UUID `json:"group_id"`
+	Role      api.Role  `json:"role"`
+	SingleUse bool      `json:"single_use"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	// PlaceholderID is the placeholder member claimed by the invite, if any.
+	PlaceholderID uuid.NullUUID `json:"placeholder_id"`
+	// Path is where the invite is accepted, relative to the site.
+	Path string `json:"path"`
+}
+
+// invitePath is where an invite is accepted.
+func (cfg *Config) invitePath(invite database.GroupInvite) (string, error) {
+	token, err := auth.MakeInviteToken(invite.ID, cfg.JwtKey, invite.CreatedAt, invite.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return "/invite/" + token, nil
+}
+
+func (cfg *Config) HandlerCreateInvite(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to create invite with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermManageMembers); !ok {
+		return
+	}
+
+	data := CreateInviteData{}
+
+	err = json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	role := api.RoleMember
+	if data.Role != "" {
+		role, err = api.ParseRole(data.Role)
+		if err != nil {
+			log.Printf("couldn't parse role: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if data.PlaceholderID.Valid {
+		userGroup, err := cfg.Queries.GetUserGroup(r.Context(), database.GetUserGroupParams{
+			UserID:  data.PlaceholderID.UUID,
+			GroupID: groupID,
+		})
+		if err != nil {
+			log.Printf("couldn't find placeholder in group: %v\n", err)
+			http.Error(w, "User not in group", http.StatusBadRequest)
+			return
+		}
+
+		placeholder, err := cfg.Queries.GetUserByID(r.Context(), userGroup.UserID)
+		if err != nil {
+			log.Printf("couldn't find placeholder: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		if !placeholder.Placeholder {
+			http.Error(w, "Only placeholder members can be claimed", http.StatusBadRequest)
+			return
+		}
+
+		role = api.Role(userGroup.Role)
+		data.SingleUse = true
+	}
+
+	expiresIn := api.DefaultInviteDuration
+	if data.ExpiresInDays != 0 {
+		expiresIn = time.Duration(data.ExpiresInDays) * 24 * time.Hour
+	}
+
+	if expiresIn <= 0 || expiresIn > api.MaxInviteDuration {
+		log.Printf("invalid invite expiry of %d days\n", data.ExpiresInDays)
+		http.Error(w, "Invites can last between 1 and 30 days", http.StatusBadRequest)
+		return
+	}
+
+	invite, err := cfg.Queries.CreateGroupInvite(r.Context(), database.CreateGroupInviteParams{
+		GroupID:       groupID,
+		CreatedBy:     uuid.NullUUID{UUID: user.ID, Valid: true},
+		Role:          string(role),
+		SingleUse:     data.SingleUse,
+		ExpiresAt:     time.Now().Add(expiresIn),
+		PlaceholderID: data.PlaceholderID,
+	})
+	if err != nil {
+		log.Printf("couldn't create invite: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	path, err := cfg.invitePath(invite)
+	if err != nil {
+		log.Printf("couldn't sign invite: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err = json.NewEncoder(w).Encode(ExportInvite{
+		ID:            invite.ID,
+		GroupID:       invite.GroupID,
+		Role:          api.Role(invite.Role),
+		SingleUse:     invite.SingleUse,
+		ExpiresAt:     invite.ExpiresAt,
+		CreatedAt:     invite.CreatedAt,
+		PlaceholderID: invite.PlaceholderID,
+		Path:          path,
+	})
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerRevokeInvite(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to revoke invite with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	groupID, err := uuid.Parse(vars["group_id"])
+	if err != nil {
+		log.Printf("couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	inviteID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Printf("couldn't parse invite id: %v\n", err)
+		http.Error(w, "Couldn't parse invite id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermManageMembers); !ok {
+		return
+	}
+
+	_, err = cfg.Queries.DeleteGroupInvite(r.Context(), database.DeleteGroupInviteParams{
+		ID:      inviteID,
+		GroupID: groupID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Printf("couldn't find invite %s in group %s\n", inviteID, groupID)
+			http.Error(w, "Couldn't find invite", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("couldn't revoke invite: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findInvite finds the invite signed by the token in the path of r,
+// returning api.ErrInviteNotFound if the token is invalid or the invite can
+// no longer be accepted.
+func (cfg *Config) findInvite(r *http.Request) (database.GroupInvite, error) {
+	inviteID, err := auth.ValidateInviteToken(mux.Vars(r)["token"], cfg.JwtKey)
+	if err != nil {
+		log.Printf("couldn't validate invite token: %v\n", err)
+		return database.GroupInvite{}, api.ErrInviteNotFound
+	}
+
+	return api.GetInvite(r.Context(), cfg.Queries, inviteID)
+}
+
+func (cfg *Config) HandlerAcceptInvite(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to accept invite with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	invite, err := cfg.findInvite(r)
+	if err != nil {
+		if errors.Is(err, api.ErrInviteNotFound) {
+			http.Error(w, "This invite is invalid or has expired", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("couldn't get invite: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = api.AcceptInvite(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, invite.ID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrInviteNotFound), errors.Is(err, api.ErrNotPlaceholder):
+			log.Printf("invite %s was used up before %s accepted it: %v\n", invite.ID, user.ID, err)
+			http.Error(w, "This invite is invalid or has expired", http.StatusNotFound)
+		case errors.Is(err, api.ErrAlreadyMember):
+			http.Error(w, "You already belong to this group", http.StatusConflict)
+		case errors.Is(err, api.ErrMemberNameTaken):
+			http.Error(w, "Someone in this group already has your username", http.StatusConflict)
+		default:
+			log.Printf("couldn't accept invite: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	group, err := cfg.Queries.GetGroup(r.Context(), invite.GroupID)
+	if err != nil {
+		log.Printf("couldn't find group: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(group)
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+// HandlerInvitePage shows an invite to anyone with its link. Users who aren't
+// logged in are sent through signup or login first.
+func (cfg *Config) HandlerInvitePage(w http.ResponseWriter, r *http.Request) {
+	invite, err := cfg.findInvite(r)
+	if err != nil {
+		if errors.Is(err, api.ErrInviteNotFound) {
+			templ.Handler(pages.InvalidInvite(), templ.WithStatus(http.StatusNotFound)).ServeHTTP(w, r)
+			return
+		}
+
+		log.Printf("couldn't get invite: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	group, err := cfg.Queries.GetGroup(r.Context(), invite.GroupID)
+	if err != nil {
+		log.Printf("couldn't find group: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	user, loggedIn := cfg.sessionUser(w, r)
+
+	member := false
+	if loggedIn {
+		member, err = api.IsUserInGroup(r.Context(), cfg.Queries, user.ID, group.ID)
+		if err != nil {
+			log.Printf("couldn't check group membership: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	placeholder := ""
+	if invite.PlaceholderID.Valid {
+		u, err := cfg.Queries.GetUserByID(r.Context(), invite.PlaceholderID.UUID)
+		if err != nil {
+			log.Printf("couldn't find placeholder: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		placeholder = u.Username
+	}
+
+	templ.Handler(pages.Invite(group, api.Role(invite.Role), placeholder, r.URL.Path, loggedIn, member)).ServeHTTP(w, r)
+}
@@ -1,15 +1,19 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/a-h/templ"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
 	"github.com/matt-horst/split-ways/internal/database"
 	"github.com/matt-horst/split-ways/web/pages"
+	"github.com/shopspring/decimal"
 )
 
 func (cfg *Config) HandlerManageGroupPage(w http.ResponseWriter, r *http.Request) {
@@ -34,16 +38,8 @@ func (cfg *Config) HandlerManageGroupPage(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	_, err = cfg.Queries.GetUserGroup(
-		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  user.ID,
-			GroupID: groupID,
-		},
-	)
-	if err != nil {
-		log.Printf("Attempt to group from non member: %v\n", err)
-		http.Error(w, "Not member of group", http.StatusForbidden)
+	membership, ok := cfg.authorize(w, r, user, groupID, api.PermManageMembers)
+	if !ok {
 		return
 	}
 
@@ -61,7 +57,36 @@ func (cfg *Config) HandlerManageGroupPage(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	templ.Handler(pages.ManageGroup(group, user, members)).ServeHTTP(w, r)
+	userGroups, err := cfg.Queries.GetUserGroupsByGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't find group roles: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	roles := make(map[uuid.UUID]api.Role, len(userGroups))
+	for _, userGroup := range userGroups {
+		roles[userGroup.UserID] = api.Role(userGroup.Role)
+	}
+
+	invites, err := cfg.Queries.GetPendingInvitesByGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't find group invites: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	invitePaths := make(map[uuid.UUID]string, len(invites))
+	for _, invite := range invites {
+		invitePaths[invite.ID], err = cfg.invitePath(invite)
+		if err != nil {
+			log.Printf("Couldn't sign invite: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	templ.Handler(pages.ManageGroup(group, membership, members, roles, invites, invitePaths)).ServeHTTP(w, r)
 }
 
 func (cfg *Config) HandlerCreateExpensePage(w http.ResponseWriter, r *http.Request) {
@@ -86,16 +111,7 @@ func (cfg *Config) HandlerCreateExpensePage(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	_, err = cfg.Queries.GetUserGroup(
-		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  user.ID,
-			GroupID: groupID,
-		},
-	)
-	if err != nil {
-		log.Printf("Attempt to group from non member: %v\n", err)
-		http.Error(w, "Not member of group", http.StatusForbidden)
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermCreateTransaction); !ok {
 		return
 	}
 
@@ -106,7 +122,14 @@ func (cfg *Config) HandlerCreateExpensePage(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	templ.Handler(pages.CreateExpense(group)).ServeHTTP(w, r)
+	members, err := cfg.Queries.GetUsersByGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't find group members: %v\n", err)
+		http.Error(w, "Couldn't find group members", http.StatusBadRequest)
+		return
+	}
+
+	templ.Handler(pages.CreateExpense(group, members)).ServeHTTP(w, r)
 }
 
 func (cfg *Config) HandlerCreatePaymentPage(w http.ResponseWriter, r *http.Request) {
@@ -131,16 +154,7 @@ func (cfg *Config) HandlerCreatePaymentPage(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	_, err = cfg.Queries.GetUserGroup(
-		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  user.ID,
-			GroupID: groupID,
-		},
-	)
-	if err != nil {
-		log.Printf("Attempt to group from non member: %v\n", err)
-		http.Error(w, "Not member of group", http.StatusForbidden)
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermCreateTransaction); !ok {
 		return
 	}
 
@@ -176,16 +190,8 @@ func (cfg *Config) HandlerGroupPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.Queries.GetUserGroup(
-		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  user.ID,
-			GroupID: groupID,
-		},
-	)
-	if err != nil {
-		log.Printf("Attempted to view group page by unauthorized user: %v\n", err)
-		http.Error(w, "User not authorized", http.StatusForbidden)
+	membership, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup)
+	if !ok {
 		return
 	}
 
@@ -203,6 +209,13 @@ func (cfg *Config) HandlerGroupPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	suggestions, err := accounting.GetSettleUpForGroup(cfg.Queries, r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't get settle up plan for group: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
 	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
 	if err != nil {
 		log.Printf("Couldn't find group: %v\n", err)
@@ -210,7 +223,7 @@ func (cfg *Config) HandlerGroupPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	templ.Handler(pages.Group(user, group, txs, bs)).ServeHTTP(w, r)
+	templ.Handler(pages.Group(membership, group, txs, bs, suggestions)).ServeHTTP(w, r)
 }
 
 func (cfg *Config) HandlerEditPage(w http.ResponseWriter, r *http.Request) {
@@ -236,9 +249,13 @@ func (cfg *Config) HandlerEditPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !tx.CreatedBy.Valid || tx.CreatedBy.UUID != user.ID {
-		log.Printf("Attempt to edit transaction by forbidden user\n")
-		http.Error(w, "Can't edit other users' transactions", http.StatusForbidden)
+	if tx.DeletedAt.Valid {
+		log.Printf("Attempt to edit deleted transaction %s\n", tx.ID)
+		http.Error(w, "Transaction is in the trash", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorizeChange(w, r, user, tx.GroupID, tx.CreatedBy); !ok {
 		return
 	}
 
@@ -258,7 +275,54 @@ func (cfg *Config) HandlerEditPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := pages.EditExpense(group, expense).Render(r.Context(), w); err != nil {
+		members, err := cfg.Queries.GetUsersByGroup(r.Context(), group.ID)
+		if err != nil {
+			log.Printf("Couldn't find group members: %v\n", err)
+			http.Error(w, "Couldn't find group members", http.StatusBadRequest)
+			return
+		}
+
+		splits, err := cfg.Queries.GetExpenseSplitsByExpense(r.Context(), expense.ID)
+		if err != nil {
+			log.Printf("Couldn't find splits for expense: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		// Expenses without stored splits were divided across the whole
+		// group, which a nil map represents.
+		var values map[uuid.UUID]decimal.Decimal
+		if len(splits) > 0 {
+			values = make(map[uuid.UUID]decimal.Decimal, len(splits))
+			for _, split := range splits {
+				if split.UserID.Valid {
+					values[split.UserID.UUID] = split.Value
+				}
+			}
+		}
+
+		dbPayers, err := cfg.Queries.GetExpensePayersByExpense(r.Context(), expense.ID)
+		if err != nil {
+			log.Printf("Couldn't find payers for expense: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		payers := make(map[uuid.UUID]decimal.Decimal, len(dbPayers))
+		for _, payer := range dbPayers {
+			if payer.UserID.Valid {
+				payers[payer.UserID.UUID] = payer.Amount
+			}
+		}
+
+		items, charges, err := accounting.GetExpenseItems(cfg.Queries, r.Context(), expense.ID)
+		if err != nil {
+			log.Printf("Couldn't find items for expense: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+
+		if err := pages.EditExpense(group, tx, expense, members, values, payers, items, charges).Render(r.Context(), w); err != nil {
 			log.Printf("Failed to serve edit expense page: %v\n", err)
 			return
 		}
@@ -271,7 +335,7 @@ func (cfg *Config) HandlerEditPage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := pages.EditPayment(group, payment).Render(r.Context(), w); err != nil {
+		if err := pages.EditPayment(group, tx, payment).Render(r.Context(), w); err != nil {
 			log.Printf("Failed to serve edit payment page: %v\n", err)
 			return
 		}
@@ -293,9 +357,202 @@ func (cfg *Config) HandlerDashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = pages.Dashboard(user.Username, groups).Render(r.Context(), w)
+	friends, err := cfg.Queries.GetFriendsByUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Couldn't find friends by user: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	balances, err := accounting.GetBalancesByPerson(cfg.Queries, r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Couldn't get balances by person: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	err = pages.Dashboard(user.Username, groups, friends, balances).Render(r.Context(), w)
+	if err != nil {
+		log.Printf("Couldn't send page: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerRecurringPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to serve recurring page to unauthorized user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("Couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	membership, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup)
+	if !ok {
+		return
+	}
+
+	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't find group: %v\n", err)
+		http.Error(w, "Couldn't find group", http.StatusBadRequest)
+		return
+	}
+
+	members, err := cfg.Queries.GetUsersByGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't find group members: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	schedules, err := cfg.Queries.GetRecurringTransactionsByGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't get recurring transactions: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	splits := make(map[uuid.UUID][]database.RecurringSplit, len(schedules))
+	for _, s := range schedules {
+		splits[s.ID], err = cfg.Queries.GetRecurringSplitsByRecurring(r.Context(), s.ID)
+		if err != nil {
+			log.Printf("Couldn't get recurring splits: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	templ.Handler(pages.Recurring(membership, group, members, schedules, splits)).ServeHTTP(w, r)
+}
+
+func (cfg *Config) HandlerTrashPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to serve trash page to unauthorized user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("Couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	membership, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup)
+	if !ok {
+		return
+	}
+
+	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't find group: %v\n", err)
+		http.Error(w, "Couldn't find group", http.StatusBadRequest)
+		return
+	}
+
+	txs, err := accounting.GetDeletedTransactionsByGroup(cfg.Queries, r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't get deleted transactions by group: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	retentionDays := int(cfg.TrashRetention.Hours() / 24)
+
+	templ.Handler(pages.Trash(membership, group, txs, retentionDays)).ServeHTTP(w, r)
+}
+
+func (cfg *Config) HandlerSettingsPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to serve settings page to unauthorized user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	apiTokens, err := cfg.Queries.GetAPITokensByUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Couldn't find API tokens by user: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	twoFactor, err := api.TwoFactorEnabled(r.Context(), cfg.Queries, user.ID)
+	if err != nil {
+		log.Printf("Couldn't find two-factor authentication of user: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	var recoveryCodes int64
+	if twoFactor {
+		recoveryCodes, err = cfg.Queries.CountRecoveryCodes(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Couldn't count recovery codes: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = pages.Settings(user, apiTokens, pages.TwoFactorSettings{
+		Available:     len(cfg.TwoFactorKey) > 0,
+		Enabled:       twoFactor,
+		RecoveryCodes: recoveryCodes,
+	}).Render(r.Context(), w)
 	if err != nil {
 		log.Printf("Couldn't send page: %v\n", err)
 		return
 	}
 }
+
+// HandlerVerifyEmailPage verifies the email address that the link in the
+// request was sent to.
+func (cfg *Config) HandlerVerifyEmailPage(w http.ResponseWriter, r *http.Request) {
+	user, err := api.VerifyEmail(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, r.URL.Query().Get("token"), time.Now())
+	if err != nil {
+		if errors.Is(err, api.ErrInvalidEmailToken) {
+			templ.Handler(pages.InvalidEmailLink(), templ.WithStatus(http.StatusNotFound)).ServeHTTP(w, r)
+			return
+		}
+
+		if errors.Is(err, api.ErrEmailTaken) {
+			templ.Handler(pages.EmailTaken(), templ.WithStatus(http.StatusConflict)).ServeHTTP(w, r)
+			return
+		}
+
+		log.Printf("Couldn't verify email: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	_, loggedIn := cfg.sessionUser(w, r)
+
+	templ.Handler(pages.EmailVerified(user.Email.String, loggedIn)).ServeHTTP(w, r)
+}
+
+// HandlerResetPasswordPage asks for a new password, if the password reset
+// link in the request can still be used.
+func (cfg *Config) HandlerResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	err := api.CheckPasswordReset(r.Context(), cfg.Queries, r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, api.ErrInvalidEmailToken) {
+			templ.Handler(pages.InvalidEmailLink(), templ.WithStatus(http.StatusNotFound)).ServeHTTP(w, r)
+			return
+		}
+
+		log.Printf("Couldn't check password reset: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	templ.Handler(pages.ResetPassword()).ServeHTTP(w, r)
+}
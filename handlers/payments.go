@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/api"
 	"github.com/matt-horst/split-ways/internal/database"
 	"github.com/shopspring/decimal"
 )
@@ -34,23 +35,16 @@ func (cfg *Config) HandlerCreatePayment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	_, err = cfg.Queries.GetUserGroup(
-		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  user.ID,
-			GroupID: groupID,
-		},
-	)
-	if err != nil {
-		log.Printf("Attempt to create payment non-user group: %v\n", err)
-		http.Error(w, "User does not belong to group", http.StatusForbidden)
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermCreateTransaction); !ok {
 		return
 	}
 
 	data := struct {
-		PaidBy string          `json:"paid_by"`
-		PaidTo string          `json:"paid_to"`
-		Amount decimal.Decimal `json:"amount"`
+		PaidBy     string          `json:"paid_by"`
+		PaidTo     string          `json:"paid_to"`
+		Amount     decimal.Decimal `json:"amount"`
+		Currency   string          `json:"currency"`
+		OccurredOn string          `json:"occurred_on"`
 	}{}
 
 	err = json.NewDecoder(r.Body).Decode(&data)
@@ -60,76 +54,66 @@ func (cfg *Config) HandlerCreatePayment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	paidBy, err := cfg.Queries.GetUserByUsername(r.Context(), data.PaidBy)
+	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
 	if err != nil {
-		log.Printf("Couldn't find paid by user: %v\n", err)
-		http.Error(w, fmt.Sprintf("Couldn't find user `%v`", data.PaidBy), http.StatusBadRequest)
+		log.Printf("Couldn't find group: %v\n", err)
+		http.Error(w, "Couldn't find group", http.StatusBadRequest)
 		return
 	}
 
-	paidTo, err := cfg.Queries.GetUserByUsername(r.Context(), data.PaidTo)
+	currency, err := cfg.getTransactionCurrency(r.Context(), data.Currency, group.Currency, group)
 	if err != nil {
-		log.Printf("Couldn't find paid by user: %v\n", err)
-		http.Error(w, fmt.Sprintf("Couldn't find user `%v`", data.PaidTo), http.StatusBadRequest)
+		if isCurrencyError(err) {
+			log.Printf("Couldn't use currency: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Couldn't get currency: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
-	_, err = cfg.Queries.GetUserGroup(
-		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  paidBy.ID,
-			GroupID: groupID,
-		},
-	)
+	occurredOn, err := parseOccurredOn(data.OccurredOn)
 	if err != nil {
-		log.Printf("Attempt to create payment paid by user not in group: %v\n", err)
-		http.Error(w, fmt.Sprintf("User `%v` not in group", data.PaidBy), http.StatusBadRequest)
+		log.Printf("Couldn't parse date: %v\n", err)
+		http.Error(w, "Couldn't parse date", http.StatusBadRequest)
 		return
 	}
 
-	_, err = cfg.Queries.GetUserGroup(
+	paidBy, err := cfg.Queries.GetGroupMemberByUsername(
 		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  paidTo.ID,
-			GroupID: groupID,
-		},
+		database.GetGroupMemberByUsernameParams{GroupID: groupID, Username: data.PaidBy},
 	)
 	if err != nil {
-		log.Printf("Attempt to create payment paid to user not in group: %v\n", err)
-		http.Error(w, fmt.Sprintf("User `%v` not in group", data.PaidTo), http.StatusBadRequest)
+		log.Printf("Attempt to create payment paid by user not in group: %v\n", err)
+		http.Error(w, fmt.Sprintf("User `%v` not in group", data.PaidBy), http.StatusBadRequest)
 		return
 	}
 
-	transaction, err := cfg.Queries.CreateTransaction(
+	paidTo, err := cfg.Queries.GetGroupMemberByUsername(
 		r.Context(),
-		database.CreateTransactionParams{
-			GroupID: groupID,
-			CreatedBy: uuid.NullUUID{
-				UUID:  user.ID,
-				Valid: true,
-			},
-			Kind: "payment",
-		},
+		database.GetGroupMemberByUsernameParams{GroupID: groupID, Username: data.PaidTo},
 	)
 	if err != nil {
-		log.Printf("Couldn't create transaction: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		log.Printf("Attempt to create payment paid to user not in group: %v\n", err)
+		http.Error(w, fmt.Sprintf("User `%v` not in group", data.PaidTo), http.StatusBadRequest)
 		return
 	}
 
-	payment, err := cfg.Queries.CreatePayment(
+	payment, err := api.CreatePayment(
 		r.Context(),
-		database.CreatePaymentParams{
-			TransactionID: transaction.ID,
-			PaidBy: uuid.NullUUID{
-				UUID:  paidBy.ID,
-				Valid: true,
-			},
-			PaidTo: uuid.NullUUID{
-				UUID:  paidTo.ID,
-				Valid: true,
-			},
-			Amount: data.Amount,
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		api.CreatePaymentParams{
+			GroupID:    groupID,
+			CreatedBy:  user.ID,
+			PaidBy:     paidBy.ID,
+			PaidTo:     paidTo.ID,
+			Amount:     data.Amount,
+			Currency:   currency,
+			OccurredOn: occurredOn,
 		},
 	)
 	if err != nil {
@@ -170,9 +154,13 @@ func (cfg *Config) HandlerUpdatePayment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if !tx.CreatedBy.Valid || tx.CreatedBy.UUID != user.ID {
-		log.Printf("Attempt to update payment for other user\n")
-		http.Error(w, "Can't update other users' payemnts", http.StatusForbidden)
+	if tx.DeletedAt.Valid {
+		log.Printf("Attempt to edit deleted transaction %s\n", tx.ID)
+		http.Error(w, "Transaction is in the trash", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorizeChange(w, r, user, tx.GroupID, tx.CreatedBy); !ok {
 		return
 	}
 
@@ -184,9 +172,11 @@ func (cfg *Config) HandlerUpdatePayment(w http.ResponseWriter, r *http.Request)
 	}
 
 	data := struct {
-		PaidBy string          `json:"paid_by"`
-		PaidTo string          `json:"paid_to"`
-		Amount decimal.Decimal `json:"amount"`
+		PaidBy     string          `json:"paid_by"`
+		PaidTo     string          `json:"paid_to"`
+		Amount     decimal.Decimal `json:"amount"`
+		Currency   string          `json:"currency"`
+		OccurredOn string          `json:"occurred_on"`
 	}{}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -195,12 +185,42 @@ func (cfg *Config) HandlerUpdatePayment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	group, err := cfg.Queries.GetGroup(r.Context(), tx.GroupID)
+	if err != nil {
+		log.Printf("Couldn't find group: %v\n", err)
+		http.Error(w, "Couldn't find group", http.StatusBadRequest)
+		return
+	}
+
+	currency, err := cfg.getTransactionCurrency(r.Context(), data.Currency, payment.Currency, group)
+	if err != nil {
+		if isCurrencyError(err) {
+			log.Printf("Couldn't use currency: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Couldn't get currency: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	occurredOn, err := parseOccurredOn(data.OccurredOn)
+	if err != nil {
+		log.Printf("Couldn't parse date: %v\n", err)
+		http.Error(w, "Couldn't parse date", http.StatusBadRequest)
+		return
+	}
+
 	paidBy := payment.PaidBy
 	if data.PaidBy != "" {
-		paidByUser, err := cfg.Queries.GetUserByUsername(r.Context(), data.PaidBy)
+		paidByUser, err := cfg.Queries.GetGroupMemberByUsername(
+			r.Context(),
+			database.GetGroupMemberByUsernameParams{GroupID: tx.GroupID, Username: data.PaidBy},
+		)
 		if err != nil {
-			log.Printf("Couln't find user: %v\n", err)
-			http.Error(w, fmt.Sprintf("Couldn't find user %s", data.PaidBy), http.StatusBadRequest)
+			log.Printf("Couln't find user in group: %v\n", err)
+			http.Error(w, fmt.Sprintf("User `%v` not in group", data.PaidBy), http.StatusBadRequest)
 			return
 		}
 
@@ -210,10 +230,13 @@ func (cfg *Config) HandlerUpdatePayment(w http.ResponseWriter, r *http.Request)
 
 	paidTo := payment.PaidTo
 	if data.PaidTo != "" {
-		paidToUser, err := cfg.Queries.GetUserByUsername(r.Context(), data.PaidTo)
+		paidToUser, err := cfg.Queries.GetGroupMemberByUsername(
+			r.Context(),
+			database.GetGroupMemberByUsernameParams{GroupID: tx.GroupID, Username: data.PaidTo},
+		)
 		if err != nil {
-			log.Printf("Couln't find user: %v\n", err)
-			http.Error(w, fmt.Sprintf("Couldn't find user %s", data.PaidTo), http.StatusBadRequest)
+			log.Printf("Couln't find user in group: %v\n", err)
+			http.Error(w, fmt.Sprintf("User `%v` not in group", data.PaidTo), http.StatusBadRequest)
 			return
 		}
 
@@ -225,13 +248,20 @@ func (cfg *Config) HandlerUpdatePayment(w http.ResponseWriter, r *http.Request)
 		data.Amount = payment.Amount
 	}
 
-	payment, err = cfg.Queries.UpdatePayment(
+	payment, err = api.UpdatePayment(
 		r.Context(),
-		database.UpdatePaymentParams{
-			ID:     payment.ID,
-			Amount: data.Amount,
-			PaidBy: paidBy,
-			PaidTo: paidTo,
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		api.UpdatePaymentParams{
+			ID:            payment.ID,
+			TransactionID: tx.ID,
+			UpdatedBy:     user.ID,
+			Amount:        data.Amount,
+			Currency:      currency,
+			PaidBy:        paidBy,
+			PaidTo:        paidTo,
+			OccurredOn:    occurredOn,
 		},
 	)
 	if err != nil {
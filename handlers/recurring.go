@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+type RecurringData struct {
+	Kind         string             `json:"kind"`
+	Frequency    string             `json:"frequency"`
+	StartsOn     string             `json:"starts_on"`
+	EndsOn       string             `json:"ends_on"`
+	Paused       bool               `json:"paused"`
+	Description  string             `json:"description"`
+	Amount       decimal.Decimal    `json:"amount"`
+	Currency     string             `json:"currency"`
+	PaidBy       string             `json:"paid_by"`
+	PaidTo       string             `json:"paid_to"`
+	SplitMode    string             `json:"split_mode"`
+	Participants []string           `json:"participants"`
+	Splits       []ExpenseSplitData `json:"splits"`
+}
+
+// recurringDetails is a schedule from a request once it has been checked and
+// its members resolved to users of the group.
+type recurringDetails struct {
+	Kind      string
+	Frequency accounting.Frequency
+	StartsOn  time.Time
+	EndsOn    sql.NullTime
+	Currency  string
+	PaidBy    uuid.NullUUID
+	PaidTo    uuid.NullUUID
+	SplitMode accounting.SplitMode
+	Parts     []accounting.SplitPart
+}
+
+func (cfg *Config) HandlerCreateRecurring(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to create recurring transaction with unauthenticated user")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	groupIDPath, ok := mux.Vars(r)["group_id"]
+	if !ok {
+		log.Printf("Couldn't find group id\n")
+		http.Error(w, "Couldn't find group id", http.StatusBadRequest)
+		return
+	}
+
+	groupID, err := uuid.Parse(groupIDPath)
+	if err != nil {
+		log.Printf("Couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermCreateTransaction); !ok {
+		return
+	}
+
+	data := RecurringData{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't create recurring transaction", http.StatusBadRequest)
+		return
+	}
+
+	if data.Kind == "expense" && data.PaidBy == "" {
+		data.PaidBy = user.Username
+	}
+
+	details, ok := cfg.getRecurringDetails(w, r, groupID, data)
+	if !ok {
+		return
+	}
+
+	recurring, err := api.CreateRecurring(
+		r.Context(),
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		api.CreateRecurringParams{
+			GroupID:     groupID,
+			CreatedBy:   user.ID,
+			Kind:        details.Kind,
+			Frequency:   details.Frequency,
+			StartsOn:    details.StartsOn,
+			EndsOn:      details.EndsOn,
+			Description: data.Description,
+			Amount:      data.Amount,
+			Currency:    details.Currency,
+			PaidBy:      details.PaidBy,
+			PaidTo:      details.PaidTo,
+			SplitMode:   details.SplitMode,
+			Parts:       details.Parts,
+		},
+	)
+	if err != nil {
+		log.Printf("Couldn't create recurring transaction: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err = json.NewEncoder(w).Encode(recurring)
+	if err != nil {
+		log.Printf("Couldn't send response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerUpdateRecurring(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to update recurring transaction with unauthenticated user")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	current, ok := cfg.getGroupRecurring(w, r, user)
+	if !ok {
+		return
+	}
+
+	data := RecurringData{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't update recurring transaction", http.StatusBadRequest)
+		return
+	}
+
+	// The kind of a schedule is fixed once it has been created.
+	data.Kind = current.Kind
+
+	details, ok := cfg.getRecurringDetails(w, r, current.GroupID, data)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+
+	recurring, err := api.UpdateRecurring(
+		r.Context(),
+		cfg.DB,
+		cfg.Tx,
+		cfg.Queries,
+		api.UpdateRecurringParams{
+			ID:          current.ID,
+			Frequency:   details.Frequency,
+			StartsOn:    details.StartsOn,
+			EndsOn:      details.EndsOn,
+			Paused:      data.Paused,
+			Description: data.Description,
+			Amount:      data.Amount,
+			Currency:    details.Currency,
+			PaidBy:      details.PaidBy,
+			PaidTo:      details.PaidTo,
+			SplitMode:   details.SplitMode,
+			Parts:       details.Parts,
+			Today:       time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		},
+	)
+	if err != nil {
+		log.Printf("Couldn't update recurring transaction: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(recurring)
+	if err != nil {
+		log.Printf("Couldn't send response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerDeleteRecurring(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to delete recurring transaction with unauthenticated user")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	recurring, ok := cfg.getGroupRecurring(w, r, user)
+	if !ok {
+		return
+	}
+
+	// Transactions already created from the schedule are kept.
+	if err := cfg.Queries.DeleteRecurringTransaction(r.Context(), recurring.ID); err != nil {
+		log.Printf("Couldn't delete recurring transaction: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getGroupRecurring finds the schedule named in the path and checks that it
+// belongs to the group in the path and that user may change it.
+// On failure the error response has already been written.
+func (cfg *Config) getGroupRecurring(w http.ResponseWriter, r *http.Request, user database.User) (database.RecurringTransaction, bool) {
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("Couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return database.RecurringTransaction{}, false
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Couldn't parse recurring transaction id: %v\n", err)
+		http.Error(w, "Couldn't parse recurring transaction id", http.StatusBadRequest)
+		return database.RecurringTransaction{}, false
+	}
+
+	membership, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup)
+	if !ok {
+		return database.RecurringTransaction{}, false
+	}
+
+	recurring, err := cfg.Queries.GetRecurringTransaction(r.Context(), id)
+	if err != nil || recurring.GroupID != groupID {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Couldn't find recurring transaction %s in group %s\n", id, groupID)
+			http.Error(w, "Couldn't find recurring transaction", http.StatusNotFound)
+			return database.RecurringTransaction{}, false
+		}
+
+		log.Printf("Couldn't get recurring transaction: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return database.RecurringTransaction{}, false
+	}
+
+	if !membership.CanEditTransaction(recurring.CreatedBy) {
+		log.Printf("Attempt to change recurring transaction by unauthorized user\n")
+		http.Error(w, "Can't change other users' recurring transactions", http.StatusForbidden)
+		return database.RecurringTransaction{}, false
+	}
+
+	return recurring, true
+}
+
+// getRecurringDetails checks a schedule from a request and resolves its
+// members to users of the group. Equal splits without any participants are
+// left without parts so that they follow the group's membership. On failure
+// the error response has already been written.
+func (cfg *Config) getRecurringDetails(w http.ResponseWriter, r *http.Request, groupID uuid.UUID, data RecurringData) (recurringDetails, bool) {
+	details := recurringDetails{Kind: data.Kind}
+
+	if data.Kind != "expense" && data.Kind != "payment" {
+		log.Printf("Unknown recurring transaction kind `%s`\n", data.Kind)
+		http.Error(w, fmt.Sprintf("Unknown kind `%s`", data.Kind), http.StatusBadRequest)
+		return details, false
+	}
+
+	if !data.Amount.IsPositive() || !data.Amount.Shift(2).IsInteger() {
+		log.Printf("Invalid recurring amount %s\n", data.Amount)
+		http.Error(w, "Amount must be a positive number of cents", http.StatusBadRequest)
+		return details, false
+	}
+
+	freq, err := accounting.ParseFrequency(data.Frequency)
+	if err != nil {
+		log.Printf("Couldn't parse frequency: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return details, false
+	}
+	details.Frequency = freq
+
+	startsOn, err := parseOccurredOn(data.StartsOn)
+	if err != nil || !startsOn.Valid {
+		log.Printf("Couldn't parse start date: %v\n", err)
+		http.Error(w, "Couldn't parse start date", http.StatusBadRequest)
+		return details, false
+	}
+	details.StartsOn = startsOn.Time
+
+	details.EndsOn, err = parseOccurredOn(data.EndsOn)
+	if err != nil {
+		log.Printf("Couldn't parse end date: %v\n", err)
+		http.Error(w, "Couldn't parse end date", http.StatusBadRequest)
+		return details, false
+	}
+
+	var endsOn *time.Time
+	if details.EndsOn.Valid {
+		endsOn = &details.EndsOn.Time
+	}
+
+	if err := accounting.ValidateSchedule(details.StartsOn, endsOn); err != nil {
+		log.Printf("Invalid schedule: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return details, false
+	}
+
+	group, err := cfg.Queries.GetGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("Couldn't find group: %v\n", err)
+		http.Error(w, "Couldn't find group", http.StatusBadRequest)
+		return details, false
+	}
+
+	details.Currency, err = cfg.getTransactionCurrency(r.Context(), data.Currency, group.Currency, group)
+	if err != nil {
+		if isCurrencyError(err) {
+			log.Printf("Couldn't use currency: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return details, false
+		}
+
+		log.Printf("Couldn't get currency: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return details, false
+	}
+
+	paidBy, ok := cfg.getPayers(w, r, groupID, data.Amount, data.PaidBy, nil)
+	if !ok {
+		return details, false
+	}
+	details.PaidBy = uuid.NullUUID{UUID: paidBy[0].UserID, Valid: true}
+
+	if data.Kind == "payment" {
+		paidTo, ok := cfg.getPayers(w, r, groupID, data.Amount, data.PaidTo, nil)
+		if !ok {
+			return details, false
+		}
+		details.PaidTo = uuid.NullUUID{UUID: paidTo[0].UserID, Valid: true}
+
+		return details, true
+	}
+
+	details.SplitMode, err = accounting.ParseSplitMode(data.SplitMode)
+	if err == nil && details.SplitMode == accounting.SplitItems {
+		err = fmt.Errorf("%w: recurring expenses can't be itemized", accounting.ErrInvalidSplit)
+	}
+	if err != nil {
+		log.Printf("Couldn't parse split mode: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return details, false
+	}
+
+	if details.SplitMode == accounting.SplitEqual && len(data.Participants) == 0 {
+		return details, true
+	}
+
+	details.Parts, ok = cfg.getSplitParts(w, r, groupID, details.SplitMode, data.Participants, data.Splits)
+	if !ok {
+		return details, false
+	}
+
+	// Check the split now rather than finding out when it is first due.
+	if _, err := accounting.ComputeShares(details.SplitMode, data.Amount, details.Parts); err != nil {
+		log.Printf("Couldn't split recurring expense: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return details, false
+	}
+
+	return details, true
+}
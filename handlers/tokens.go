@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+type CreateAPITokenData struct {
+	Name string `json:"name"`
+	// Scope defaults to read.
+	Scope string `json:"scope"`
+	// ExpiresInDays defaults to 30, and can be at most 365.
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type ExportAPIToken struct {
+	ID         uuid.UUID      `json:"id"`
+	Name       string         `json:"name"`
+	Scope      api.TokenScope `json:"scope"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	// Token is only sent when the token is created, and can't be looked up
+	// again.
+	Token string `json:"token,omitempty"`
+}
+
+func exportAPIToken(apiToken database.ApiToken) ExportAPIToken {
+	export := ExportAPIToken{
+		ID:        apiToken.ID,
+		Name:      apiToken.Name,
+		Scope:     api.TokenScope(apiToken.Scope),
+		ExpiresAt: apiToken.ExpiresAt,
+		CreatedAt: apiToken.CreatedAt,
+	}
+
+	if apiToken.LastUsedAt.Valid {
+		export.LastUsedAt = &apiToken.LastUsedAt.Time
+	}
+
+	return export
+}
+
+func (cfg *Config) HandlerCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to create API token with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !requireSession(w, r) {
+		return
+	}
+
+	data := CreateAPITokenData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(data.Name)
+	if name == "" {
+		http.Error(w, "Tokens need a name", http.StatusBadRequest)
+		return
+	}
+
+	scope := api.ScopeRead
+	if data.Scope != "" {
+		scope, err = api.ParseTokenScope(data.Scope)
+		if err != nil {
+			log.Printf("couldn't parse scope: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	expiresIn := api.DefaultTokenDuration
+	if data.ExpiresInDays != 0 {
+		expiresIn = time.Duration(data.ExpiresInDays) * 24 * time.Hour
+	}
+
+	if expiresIn <= 0 || expiresIn > api.MaxTokenDuration {
+		log.Printf("invalid token expiry of %d days\n", data.ExpiresInDays)
+		http.Error(w, "Tokens can last between 1 and 365 days", http.StatusBadRequest)
+		return
+	}
+
+	apiToken, token, err := api.CreateAPIToken(r.Context(), cfg.Queries, user.ID, name, scope, time.Now().Add(expiresIn))
+	if err != nil {
+		log.Printf("couldn't create API token: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	export := exportAPIToken(apiToken)
+	export.Token = token
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err = json.NewEncoder(w).Encode(export)
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerGetAPITokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to get API tokens with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	apiTokens, err := cfg.Queries.GetAPITokensByUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("couldn't get API tokens: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	export := make([]ExportAPIToken, len(apiTokens))
+	for i, apiToken := range apiTokens {
+		export[i] = exportAPIToken(apiToken)
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(export)
+	if err != nil {
+		log.Printf("couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+func (cfg *Config) HandlerRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("attempted to revoke API token with unauthenticated user\n")
+		http.Error(w, "User not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !requireSession(w, r) {
+		return
+	}
+
+	tokenID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't parse token id: %v\n", err)
+		http.Error(w, "Couldn't parse token id", http.StatusBadRequest)
+		return
+	}
+
+	err = api.RevokeAPIToken(r.Context(), cfg.Queries, user.ID, tokenID)
+	if err != nil {
+		if errors.Is(err, api.ErrTokenNotFound) {
+			http.Error(w, "Couldn't find token", http.StatusNotFound)
+			return
+		}
+
+		log.Printf("couldn't revoke API token: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
 	"github.com/matt-horst/split-ways/internal/database"
 )
 
@@ -32,16 +39,8 @@ func (cfg *Config) HandlerDeleteTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	_, err = cfg.Queries.GetUserGroup(
-		r.Context(),
-		database.GetUserGroupParams{
-			UserID:  user.ID,
-			GroupID: groupID,
-		},
-	)
-	if err != nil {
-		log.Printf("Attempt to create expense non-user group: %v\n", err)
-		http.Error(w, "User does not belong to group", http.StatusForbidden)
+	membership, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup)
+	if !ok {
 		return
 	}
 
@@ -62,13 +61,25 @@ func (cfg *Config) HandlerDeleteTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if !tx.CreatedBy.Valid || tx.CreatedBy.UUID != user.ID {
-		log.Printf("Attempt to delete transaction by unauthroized user: %v\n", err)
+	if tx.GroupID != groupID {
+		log.Printf("Attempt to delete transaction %s from another group\n", tx.ID)
+		http.Error(w, "Couldn't find transaction", http.StatusBadRequest)
+		return
+	}
+
+	if !membership.CanEditTransaction(tx.CreatedBy) {
+		log.Printf("Attempt to delete transaction by unauthroized user\n")
 		http.Error(w, "You do not own this transaction", http.StatusForbidden)
 		return
 	}
 
-	if err := cfg.Queries.DeleteTransaction(r.Context(), data.ID); err != nil {
+	if tx.DeletedAt.Valid {
+		log.Printf("Attempt to delete transaction %s that is already deleted\n", tx.ID)
+		http.Error(w, "Transaction is already in the trash", http.StatusBadRequest)
+		return
+	}
+
+	if err := api.DeleteTransaction(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, data.ID, user.ID); err != nil {
 		log.Printf("Couldn't delete transaction: %v\n", err)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
@@ -76,3 +87,197 @@ func (cfg *Config) HandlerDeleteTransaction(w http.ResponseWriter, r *http.Reque
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (cfg *Config) HandlerRestoreTransaction(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to restore transaction with unauthenticated user")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("Couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Couldn't parse transaction id: %v\n", err)
+		http.Error(w, "Couldn't parse transaction id", http.StatusBadRequest)
+		return
+	}
+
+	membership, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.Queries.GetTransaction(r.Context(), id)
+	if err != nil || tx.GroupID != groupID {
+		log.Printf("Couldn't find transaction %s in group %s: %v\n", id, groupID, err)
+		http.Error(w, "Couldn't find transaction", http.StatusNotFound)
+		return
+	}
+
+	if !membership.CanEditTransaction(tx.CreatedBy) {
+		log.Printf("Attempt to restore transaction by unauthorized user\n")
+		http.Error(w, "You do not own this transaction", http.StatusForbidden)
+		return
+	}
+
+	if !tx.DeletedAt.Valid {
+		log.Printf("Attempt to restore transaction %s that isn't deleted\n", id)
+		http.Error(w, "Transaction is not in the trash", http.StatusBadRequest)
+		return
+	}
+
+	tx, err = api.RestoreTransaction(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, id, user.ID)
+	if err != nil {
+		log.Printf("Couldn't restore transaction: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(tx)
+	if err != nil {
+		log.Printf("Couldn't send response body: %v\n", err)
+		return
+	}
+}
+
+// HistoryEntry is one change in the history of a transaction. Before and
+// After are the transaction as it was on either side of the change, or null
+// where it didn't exist.
+type HistoryEntry struct {
+	ID        uuid.UUID        `json:"id"`
+	Actor     *accounting.User `json:"actor"`
+	Action    string           `json:"action"`
+	CreatedAt time.Time        `json:"created_at"`
+	Before    json.RawMessage  `json:"before"`
+	After     json.RawMessage  `json:"after"`
+}
+
+func (cfg *Config) HandlerGetTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to get transaction history with unauthenticated user")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := uuid.Parse(mux.Vars(r)["group_id"])
+	if err != nil {
+		log.Printf("Couldn't parse group id: %v\n", err)
+		http.Error(w, "Couldn't parse group id", http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Couldn't parse transaction id: %v\n", err)
+		http.Error(w, "Couldn't parse transaction id", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := cfg.authorize(w, r, user, groupID, api.PermViewGroup); !ok {
+		return
+	}
+
+	// The history outlives the transaction, so it is found by the entries
+	// themselves rather than by looking the transaction up.
+	entries, err := cfg.Queries.GetAuditEntriesByEntity(
+		r.Context(),
+		database.GetAuditEntriesByEntityParams{
+			Entity:   api.AuditTransaction,
+			EntityID: id,
+		},
+	)
+	if err != nil {
+		log.Printf("Couldn't get transaction history: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	if len(entries) == 0 || entries[0].GroupID != groupID {
+		log.Printf("Couldn't find history for transaction %s in group %s\n", id, groupID)
+		http.Error(w, "Couldn't find transaction", http.StatusNotFound)
+		return
+	}
+
+	history := make([]HistoryEntry, len(entries))
+	for i, entry := range entries {
+		history[i] = HistoryEntry{
+			ID:        entry.ID,
+			Action:    entry.Action,
+			CreatedAt: entry.CreatedAt,
+			Before:    entry.Before,
+			After:     entry.After,
+		}
+
+		if entry.ActorID.Valid {
+			history[i].Actor = &accounting.User{ID: entry.ActorID.UUID, Username: entry.ActorName}
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(history)
+	if err != nil {
+		log.Printf("Couldn't send response body: %v\n", err)
+		return
+	}
+}
+
+// parseOccurredOn parses the date a transaction happened on, formatted as
+// YYYY-MM-DD. An empty string gives a null date, which leaves the choice to
+// the database: today for new transactions, or the current date on updates.
+func parseOccurredOn(s string) (sql.NullTime, error) {
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+// getTransactionCurrency parses the currency of a transaction, using fallback
+// when none is given, and checks that it can be converted into the group's
+// currency.
+func (cfg *Config) getTransactionCurrency(ctx context.Context, code, fallback string, group database.Group) (string, error) {
+	if code == "" {
+		code = fallback
+	}
+
+	currency, err := accounting.ParseCurrency(code)
+	if err != nil {
+		return "", err
+	}
+
+	rates, err := accounting.GetExchangeRates(cfg.Queries, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if !rates.CanConvert(currency, group.Currency) {
+		return "", fmt.Errorf("%w from %s to %s", accounting.ErrNoExchangeRate, currency, group.Currency)
+	}
+
+	return currency, nil
+}
+
+// isCurrencyError reports whether err was caused by a currency that can't be
+// used rather than by something going wrong.
+func isCurrencyError(err error) bool {
+	return errors.Is(err, accounting.ErrUnknownCurrency) || errors.Is(err, accounting.ErrNoExchangeRate)
+}
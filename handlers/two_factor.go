@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/skip2/go-qrcode"
+)
+
+// twoFactorLoginDuration is how long a user has to give a code from their
+// authenticator app after giving their password.
+const twoFactorLoginDuration = 5 * time.Minute
+
+type TwoFactorCodeData struct {
+	// Code is from the user's authenticator app, or is one of their recovery
+	// codes.
+	Code string `json:"code"`
+}
+
+type ExportTwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	// QRCode is a PNG of URI as a data URL, for authenticator apps to scan.
+	QRCode string `json:"qr_code"`
+}
+
+type ExportRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ExportLogin is sent when logging in needs a second step.
+type ExportLogin struct {
+	TwoFactorRequired bool `json:"two_factor_required"`
+}
+
+// requireTwoFactorKey stops two-factor authentication from being set up
+// when the server has no key to encrypt secrets with. On failure the error
+// response has already been written.
+func (cfg *Config) requireTwoFactorKey(w http.ResponseWriter) bool {
+	if len(cfg.TwoFactorKey) == 0 {
+		http.Error(w, "Two-factor authentication isn't available on this server", http.StatusServiceUnavailable)
+		return false
+	}
+
+	return true
+}
+
+// writeTwoFactorError writes the error response for err from changing a
+// user's two-factor authentication.
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, api.ErrWrongCode):
+		http.Error(w, "Wrong code", http.StatusBadRequest)
+	case errors.Is(err, api.ErrTwoFactorNotStarted):
+		http.Error(w, "Set up two-factor authentication first", http.StatusBadRequest)
+	case errors.Is(err, api.ErrTwoFactorNotEnabled):
+		http.Error(w, "Two-factor authentication isn't on", http.StatusBadRequest)
+	case errors.Is(err, api.ErrTwoFactorEnabled):
+		http.Error(w, "Two-factor authentication is already on", http.StatusConflict)
+	default:
+		log.Printf("Couldn't change two-factor authentication: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+	}
+}
+
+// writeRecoveryCodes sends a user their new recovery codes, or the error
+// response for err.
+func writeRecoveryCodes(w http.ResponseWriter, codes []string, err error) {
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(ExportRecoveryCodes{RecoveryCodes: codes})
+	if err != nil {
+		log.Printf("Couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+// HandlerStartTwoFactor makes a new secret for the user to add to their
+// authenticator app. Two-factor authentication is turned on once they give
+// a code from it to HandlerEnableTwoFactor.
+func (cfg *Config) HandlerStartTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to set up two-factor authentication for unauthenticated user\n")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if !requireSession(w, r) || !cfg.requireTwoFactorKey(w) {
+		return
+	}
+
+	setup, err := api.StartTwoFactor(r.Context(), cfg.Queries, cfg.TwoFactorKey, user)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	png, err := qrcode.Encode(setup.URI, qrcode.Medium, 256)
+	if err != nil {
+		log.Printf("Couldn't make QR code: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(ExportTwoFactorSetup{
+		Secret: setup.Secret,
+		URI:    setup.URI,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+	if err != nil {
+		log.Printf("Couldn't write response body: %v\n", err)
+		return
+	}
+}
+
+// HandlerEnableTwoFactor turns on two-factor authentication, sending back
+// the user's recovery codes.
+func (cfg *Config) HandlerEnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to enable two-factor authentication for unauthenticated user\n")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if !requireSession(w, r) || !cfg.requireTwoFactorKey(w) {
+		return
+	}
+
+	data := TwoFactorCodeData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := api.EnableTwoFactor(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, cfg.TwoFactorKey, user.ID, data.Code, time.Now())
+	if err == nil {
+		log.Printf("User %s turned on two-factor authentication\n", user.ID)
+	}
+
+	writeRecoveryCodes(w, codes, err)
+}
+
+// HandlerRegenerateRecoveryCodes replaces the user's recovery codes.
+func (cfg *Config) HandlerRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to make recovery codes for unauthenticated user\n")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if !requireSession(w, r) {
+		return
+	}
+
+	data := TwoFactorCodeData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := api.RegenerateRecoveryCodes(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, cfg.TwoFactorKey, user.ID, data.Code, time.Now())
+
+	writeRecoveryCodes(w, codes, err)
+}
+
+// HandlerDisableTwoFactor turns off two-factor authentication.
+func (cfg *Config) HandlerDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userContextKey).(database.User)
+	if !ok {
+		log.Printf("Attempted to disable two-factor authentication for unauthenticated user\n")
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if !requireSession(w, r) {
+		return
+	}
+
+	data := TwoFactorCodeData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't decode request", http.StatusBadRequest)
+		return
+	}
+
+	err = api.DisableTwoFactor(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, cfg.TwoFactorKey, user.ID, data.Code, time.Now())
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	log.Printf("User %s turned off two-factor authentication\n", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startTwoFactorLogin remembers that the user of r gave the right password,
+// and now has to give a code to HandlerLoginTwoFactor.
+func (cfg *Config) startTwoFactorLogin(w http.ResponseWriter, r *http.Request, user database.User) error {
+	now := time.Now()
+
+	token, err := auth.MakeTwoFactorToken(user.ID, cfg.JwtKey, now, now.Add(twoFactorLoginDuration))
+	if err != nil {
+		return err
+	}
+
+	err = auth.SetTwoFactorToken(cfg.Store, w, r, token)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	return json.NewEncoder(w).Encode(ExportLogin{TwoFactorRequired: true})
+}
+
+// HandlerLoginTwoFactor is the second step of logging in, for users with
+// two-factor authentication, taking a code from their authenticator app or
+// a recovery code.
+func (cfg *Config) HandlerLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	data := TwoFactorCodeData{}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		log.Printf("Couldn't decode request body: %v\n", err)
+		http.Error(w, "Couldn't login", http.StatusBadRequest)
+		return
+	}
+
+	token, err := auth.GetTwoFactorToken(cfg.Store, r)
+	if err != nil {
+		http.Error(w, "Log in with your password first", http.StatusUnauthorized)
+		return
+	}
+
+	userID, issuedAt, err := auth.ValidateTwoFactorToken(token, cfg.JwtKey)
+	if err != nil {
+		http.Error(w, "Your login has expired. Log in again.", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.Queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't find user: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	// Logins started before the password was changed, say by someone who
+	// knew the old one, can't be finished. Tokens only record the second
+	// they were issued in.
+	if issuedAt.Before(user.PasswordChangedAt.Truncate(time.Second)) {
+		http.Error(w, "Your login has expired. Log in again.", http.StatusUnauthorized)
+		return
+	}
+
+	err = api.CompleteLogin(r.Context(), cfg.Queries, cfg.TwoFactorKey, user, data.Code, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, api.ErrWrongCode):
+			http.Error(w, "Wrong code", http.StatusUnauthorized)
+		case errors.Is(err, api.ErrTwoFactorNotEnabled):
+			http.Error(w, "Your login has expired. Log in again.", http.StatusUnauthorized)
+		case errors.Is(err, api.ErrAccountDisabled):
+			log.Printf("attempt to log in to disabled account %s\n", user.ID)
+			http.Error(w, "This account has been disabled", http.StatusForbidden)
+		case errors.Is(err, api.ErrAccountLocked):
+			log.Printf("attempt to log in to locked account %s\n", user.ID)
+			http.Error(w, "Too many failed logins. Try again later.", http.StatusTooManyRequests)
+		default:
+			log.Printf("Couldn't log in: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	err = cfg.startSession(w, r, user.ID)
+	if err != nil {
+		log.Printf("Couldn't start session: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
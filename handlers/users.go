@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/api"
 	"github.com/matt-horst/split-ways/internal/auth"
 	"github.com/matt-horst/split-ways/internal/database"
 )
@@ -16,11 +21,20 @@ type contextKey string
 
 const (
 	userContextKey contextKey = "user"
+	// apiTokenContextKey holds the personal API token a request was made
+	// with, if it wasn't made from a session.
+	apiTokenContextKey contextKey = "api-token"
 )
 
+// accessTokenDuration is how long an access token lasts before the
+// session's refresh token has to be swapped for a new one.
+const accessTokenDuration = 15 * time.Minute
+
 type CreateUserData struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// Email is optional, and is needed to reset a forgotten password.
+	Email string `json:"email,omitempty"`
 }
 
 type LoginUserData struct {
@@ -42,6 +56,26 @@ func (cfg *Config) HandlerCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	email := ""
+	if data.Email != "" {
+		email, err = api.ParseEmail(data.Email)
+		if err != nil {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+
+		err = api.CheckEmailAvailable(r.Context(), cfg.Queries, uuid.Nil, email)
+		if errors.Is(err, api.ErrEmailTaken) {
+			http.Error(w, "Email address already in use", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Couldn't check email: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	hashedPassword, err := auth.HashPassword(data.Password)
 	if err != nil {
 		log.Printf("Couldn't hash password: %v\n", err)
@@ -54,6 +88,7 @@ func (cfg *Config) HandlerCreateUser(w http.ResponseWriter, r *http.Request) {
 		database.CreateUserParams{
 			Username:       data.Username,
 			HashedPassword: hashedPassword,
+			Email:          sql.NullString{String: email, Valid: email != ""},
 		},
 	)
 	if err != nil {
@@ -68,16 +103,13 @@ func (cfg *Config) HandlerCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.JwtKey, time.Hour)
+	err = cfg.startSession(w, r, user.ID)
 	if err != nil {
-		log.Printf("Couldn't create JWT: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
-		return
+		log.Printf("Couldn't start session: %v\n", err)
 	}
 
-	err = auth.SetBearerToken(cfg.Store, w, r, token)
-	if err != nil {
-		log.Printf("Couldn't set bearer token: %v\n", err)
+	if user.Email.Valid {
+		cfg.sendVerificationEmail(r, user)
 	}
 
 	w.Header().Add("Content-Type", "application/json")
@@ -112,27 +144,34 @@ func (cfg *Config) HandlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ok, err := auth.CheckPasswordHash(data.Password, user.HashedPassword)
+	err = api.Login(r.Context(), cfg.Queries, user, data.Password, time.Now())
 	if err != nil {
-		log.Printf("Couldn't check password hash: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
-		return
-	}
-	if !ok {
-		http.Error(w, "Username and password do not match", http.StatusUnauthorized)
-		return
-	}
+		switch {
+		case errors.Is(err, api.ErrTwoFactorRequired):
+			err = cfg.startTwoFactorLogin(w, r, user)
+			if err != nil {
+				log.Printf("Couldn't start two-factor login: %v\n", err)
+				http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			}
+		case errors.Is(err, api.ErrWrongPassword):
+			http.Error(w, "Username and password do not match", http.StatusUnauthorized)
+		case errors.Is(err, api.ErrAccountDisabled):
+			log.Printf("attempt to log in to disabled account %s\n", user.ID)
+			http.Error(w, "This account has been disabled", http.StatusForbidden)
+		case errors.Is(err, api.ErrAccountLocked):
+			log.Printf("attempt to log in to locked account %s\n", user.ID)
+			http.Error(w, "Too many failed logins. Try again later.", http.StatusTooManyRequests)
+		default:
+			log.Printf("Couldn't log in: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
 
-	token, err := auth.MakeJWT(user.ID, cfg.JwtKey, time.Hour)
-	if err != nil {
-		log.Printf("Couldn't create new JWT: %v\n", err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
-	err = auth.SetBearerToken(cfg.Store, w, r, token)
+	err = cfg.startSession(w, r, user.ID)
 	if err != nil {
-		log.Printf("Couldn't set the bearer token: %v\n", err)
+		log.Printf("Couldn't start session: %v\n", err)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
@@ -148,6 +187,18 @@ func (cfg *Config) HandlerLogout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Revoke the session so that copies of its tokens stop working too.
+	if refreshToken, err := auth.GetRefreshToken(cfg.Store, r); err == nil {
+		err = api.EndSession(r.Context(), cfg.Queries, refreshToken)
+		if err != nil {
+			log.Printf("Couldn't end session: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Forget any login waiting for its second step too.
+	delete(session.Values, "two-factor")
 	session.Options.MaxAge = -1
 	if err := session.Save(r, w); err != nil {
 		log.Printf("Couldn't revoke user-session cookie: %v\n", err)
@@ -182,36 +233,196 @@ func (cfg *Config) HandlerUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = cfg.Queries.UpdatePassword(r.Context(), database.UpdatePasswordParams{
-		ID:             user.ID,
-		HashedPassword: hashedPassword,
-	})
+	if !requireSession(w, r) {
+		return
+	}
+
+	// Every other session is logged out, and this one carries on in a new
+	// session.
+	session, err := api.ChangePassword(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, user.ID, hashedPassword, time.Now())
 	if err != nil {
 		log.Printf("Couldn't update password: %v\n", err)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
 
+	err = cfg.setSession(w, r, session)
+	if err != nil {
+		log.Printf("Couldn't set session tokens: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (cfg *Config) AuthenticatedUserMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, err := auth.GetBearerToken(cfg.Store, r)
+// startSession logs the user in on the device of r.
+func (cfg *Config) startSession(w http.ResponseWriter, r *http.Request, userID uuid.UUID) error {
+	session, err := api.StartSession(r.Context(), cfg.Queries, userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return cfg.setSession(w, r, session)
+}
+
+// setSession stores a new access token for session, along with its refresh
+// token, in the session cookie.
+func (cfg *Config) setSession(w http.ResponseWriter, r *http.Request, session api.Session) error {
+	token, err := auth.MakeJWT(session.UserID, session.ID, cfg.JwtKey, accessTokenDuration)
+	if err != nil {
+		return err
+	}
+
+	return auth.SetSessionTokens(cfg.Store, w, r, token, session.RefreshToken)
+}
+
+// sessionUser finds the user logged in to the session of r, if any. Once the
+// access token has run out, the session is refreshed and its new tokens are
+// sent back with w.
+func (cfg *Config) sessionUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	token, err := auth.GetBearerToken(cfg.Store, r)
+	if err != nil {
+		return database.User{}, false
+	}
+
+	userID, sessionID, err := auth.ValidateJWT(token, cfg.JwtKey)
+	if err == nil {
+		active, err := api.SessionActive(r.Context(), cfg.Queries, userID, sessionID)
+		if err != nil || !active {
+			return database.User{}, false
+		}
+	} else {
+		userID, err = cfg.refreshSession(w, r)
 		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
-			return
+			return database.User{}, false
 		}
+	}
 
-		userID, err := auth.ValidateJWT(token, cfg.JwtKey)
-		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+	user, err := cfg.Queries.GetUserByID(r.Context(), userID)
+	if err != nil || user.DisabledAt.Valid {
+		return database.User{}, false
+	}
+
+	return user, true
+}
+
+// refreshSession swaps the refresh token of the session of r for new tokens,
+// returning the session's user.
+func (cfg *Config) refreshSession(w http.ResponseWriter, r *http.Request) (uuid.UUID, error) {
+	refreshToken, err := auth.GetRefreshToken(cfg.Store, r)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	session, err := api.RefreshSession(r.Context(), cfg.DB, cfg.Tx, cfg.Queries, refreshToken, time.Now())
+	if err != nil {
+		if !errors.Is(err, api.ErrInvalidRefreshToken) {
+			log.Printf("Couldn't refresh session: %v\n", err)
+		}
+
+		return uuid.UUID{}, err
+	}
+
+	err = cfg.setSession(w, r, session)
+	if err != nil {
+		log.Printf("Couldn't set session tokens: %v\n", err)
+		return uuid.UUID{}, err
+	}
+
+	return session.UserID, nil
+}
+
+// loginURL is the login page, which comes back to the page of r afterwards.
+func loginURL(r *http.Request) string {
+	if r.Method != http.MethodGet {
+		return "/login"
+	}
+
+	return "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+}
+
+// tokenUser finds the user of the personal API token in the Authorization
+// header of r, checking that the token's scope allows the request. On
+// failure the error response has already been written.
+func (cfg *Config) tokenUser(w http.ResponseWriter, r *http.Request) (database.User, database.ApiToken, bool) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	token, err := auth.GetAuthorizationToken(r.Header)
+	if err != nil {
+		log.Printf("Couldn't get authorization token: %v\n", err)
+		http.Error(w, "Malformed authorization header", http.StatusUnauthorized)
+		return database.User{}, database.ApiToken{}, false
+	}
+
+	apiToken, err := api.ValidateAPIToken(r.Context(), cfg.Queries, token)
+	if err != nil {
+		if errors.Is(err, api.ErrTokenNotFound) {
+			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		} else {
+			log.Printf("Couldn't validate API token: %v\n", err)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		}
+
+		return database.User{}, apiToken, false
+	}
+
+	if !api.TokenScope(apiToken.Scope).Allows(r.Method) {
+		log.Printf("Attempt to %s %s with %s token %s\n", r.Method, r.URL.Path, apiToken.Scope, apiToken.ID)
+		http.Error(w, "This token can only read", http.StatusForbidden)
+		return database.User{}, apiToken, false
+	}
+
+	user, err := cfg.Queries.GetUserByID(r.Context(), apiToken.UserID)
+	if err != nil {
+		log.Printf("Couldn't find user of API token: %v\n", err)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return database.User{}, apiToken, false
+	}
+
+	if user.DisabledAt.Valid {
+		log.Printf("Attempt to use API token %s of disabled account %s\n", apiToken.ID, user.ID)
+		http.Error(w, "This account has been disabled", http.StatusForbidden)
+		return database.User{}, apiToken, false
+	}
+
+	return user, apiToken, true
+}
+
+// requireSession stops requests made with a personal API token from doing
+// what only someone logged in may, like changing their password or making
+// more tokens. On failure the error response has already been written.
+func requireSession(w http.ResponseWriter, r *http.Request) bool {
+	if apiToken, ok := r.Context().Value(apiTokenContextKey).(database.ApiToken); ok {
+		log.Printf("Attempt to %s %s with API token %s\n", r.Method, r.URL.Path, apiToken.ID)
+		http.Error(w, "API tokens can't do that", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// AuthenticatedUserMiddleware lets through requests from a logged in
+// session, or with a personal API token in an `Authorization: Bearer`
+// header.
+func (cfg *Config) AuthenticatedUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			user, apiToken, ok := cfg.tokenUser(w, r)
+			if !ok {
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, apiTokenContextKey, apiToken)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 
-		user, err := cfg.Queries.GetUserByID(r.Context(), userID)
-		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		user, ok := cfg.sessionUser(w, r)
+		if !ok {
+			http.Redirect(w, r, loginURL(r), http.StatusSeeOther)
 			return
 		}
 
@@ -3,7 +3,7 @@ package accounting
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,26 +24,39 @@ type User struct {
 }
 
 type Transaction struct {
-	ID        uuid.UUID       `json:"id"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
-	CreatedBy *User           `json:"created_by"`
-	Kind      TransactionKind `json:"kind"`
-	Payment   *Payment        `json:"payment"`
-	Expense   *Expense        `json:"expense"`
+	ID         uuid.UUID       `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	OccurredOn time.Time       `json:"occurred_on"`
+	DeletedAt  *time.Time      `json:"deleted_at,omitempty"`
+	CreatedBy  *User           `json:"created_by"`
+	Kind       TransactionKind `json:"kind"`
+	Payment    *Payment        `json:"payment"`
+	Expense    *Expense        `json:"expense"`
 }
 
 type Payment struct {
-	PaidBy *User           `json:"paid_by"`
-	PaidTo *User           `json:"paid_to"`
-	Amount decimal.Decimal `json:"amount"`
+	PaidBy   *User           `json:"paid_by"`
+	PaidTo   *User           `json:"paid_to"`
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
 }
 
 type Expense struct {
-	Description string          `json:"description"`
-	PaidBy      *User           `json:"paid_by"`
-	Amount      decimal.Decimal `json:"amount"`
-	Debts       []Debt          `json:"debts"`
+	Description  string          `json:"description"`
+	PaidBy       *User           `json:"paid_by"`
+	Payers       []Payer         `json:"payers"`
+	Amount       decimal.Decimal `json:"amount"`
+	Currency     string          `json:"currency"`
+	SplitMode    SplitMode       `json:"split_mode"`
+	Participants []*User         `json:"participants"`
+	Debts        []Debt          `json:"debts"`
+}
+
+// Payer is a member who fronted some of the money for an expense.
+type Payer struct {
+	User   *User           `json:"user"`
+	Amount decimal.Decimal `json:"amount"`
 }
 
 type Debt struct {
@@ -58,6 +71,58 @@ func GetTransationsByGroup(queries *database.Queries, ctx context.Context, group
 		return nil, fmt.Errorf("couldn't get transactions by group: %v", err)
 	}
 
+	return loadTransactions(queries, ctx, groupID, dbTransactions)
+}
+
+// GetTransaction returns a single transaction, whether or not it is in the
+// trash.
+func GetTransaction(queries *database.Queries, ctx context.Context, id uuid.UUID) (Transaction, error) {
+	dbTransaction, err := queries.GetTransaction(ctx, id)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("couldn't get transaction: %w", err)
+	}
+
+	transactions, err := loadTransactions(queries, ctx, dbTransaction.GroupID, []database.Transaction{dbTransaction})
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	return transactions[0], nil
+}
+
+// GetDeletedTransactionsByGroup returns the group's transactions that are in
+// the trash, most recently deleted first.
+func GetDeletedTransactionsByGroup(queries *database.Queries, ctx context.Context, groupID uuid.UUID) ([]Transaction, error) {
+	dbTransactions, err := queries.GetDeletedTransactionsByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get deleted transactions by group: %v", err)
+	}
+
+	return loadTransactions(queries, ctx, groupID, dbTransactions)
+}
+
+// GetTransactionsPageByGroup returns at most limit of the group's
+// transactions, most recent first, skipping the first offset.
+func GetTransactionsPageByGroup(queries *database.Queries, ctx context.Context, groupID uuid.UUID, limit, offset int32) ([]Transaction, error) {
+	dbTransactions, err := queries.GetTransactionsByGroupPage(
+		ctx,
+		database.GetTransactionsByGroupPageParams{
+			GroupID: groupID,
+			Limit:   limit,
+			Offset:  offset,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get transactions by group: %v", err)
+	}
+
+	return loadTransactions(queries, ctx, groupID, dbTransactions)
+}
+
+// loadTransactions fills in the expenses, debts, splits and payments of
+// dbTransactions. Each kind of row is loaded for every transaction at once,
+// so the number of queries doesn't grow with the number of transactions.
+func loadTransactions(queries *database.Queries, ctx context.Context, groupID uuid.UUID, dbTransactions []database.Transaction) ([]Transaction, error) {
 	users := make(map[uuid.UUID]*User)
 
 	dbUsers, err := queries.GetUsersByGroup(ctx, groupID)
@@ -69,119 +134,305 @@ func GetTransationsByGroup(queries *database.Queries, ctx context.Context, group
 		users[u.ID] = &User{ID: u.ID, Username: u.Username}
 	}
 
+	lookup := func(id uuid.NullUUID) *User {
+		if !id.Valid {
+			return nil
+		}
+		return users[id.UUID]
+	}
+
+	transactionIDs := make([]uuid.UUID, len(dbTransactions))
+	for i, dbTransaction := range dbTransactions {
+		transactionIDs[i] = dbTransaction.ID
+	}
+
+	dbExpenses, err := queries.GetExpensesByTransactions(ctx, transactionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get expenses for transactions: %v", err)
+	}
+
+	dbDebts, err := queries.GetDebtsByTransactions(ctx, transactionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get debts for transactions: %v", err)
+	}
+
+	dbSplits, err := queries.GetExpenseSplitsByTransactions(ctx, transactionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get splits for transactions: %v", err)
+	}
+
+	dbPayers, err := queries.GetExpensePayersByTransactions(ctx, transactionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get payers for transactions: %v", err)
+	}
+
+	dbPayments, err := queries.GetPaymentsByTransactions(ctx, transactionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get payments for transactions: %v", err)
+	}
+
+	expenses := make(map[uuid.UUID]database.Expense, len(dbExpenses))
+	for _, dbExpense := range dbExpenses {
+		expenses[dbExpense.TransactionID] = dbExpense
+	}
+
+	debts := make(map[uuid.UUID][]database.Debt)
+	for _, dbDebt := range dbDebts {
+		debts[dbDebt.ExpenseID] = append(debts[dbDebt.ExpenseID], dbDebt)
+	}
+
+	splits := make(map[uuid.UUID][]database.ExpenseSplit)
+	for _, dbSplit := range dbSplits {
+		splits[dbSplit.ExpenseID] = append(splits[dbSplit.ExpenseID], dbSplit)
+	}
+
+	payers := make(map[uuid.UUID][]database.ExpensePayer)
+	for _, dbPayer := range dbPayers {
+		payers[dbPayer.ExpenseID] = append(payers[dbPayer.ExpenseID], dbPayer)
+	}
+
+	payments := make(map[uuid.UUID]database.Payment, len(dbPayments))
+	for _, dbPayment := range dbPayments {
+		payments[dbPayment.TransactionID] = dbPayment
+	}
+
+	// Members who have since left the group still appear in its history
+	// under their own names.
+	missing := make(map[uuid.UUID]bool)
+	need := func(id uuid.NullUUID) {
+		if id.Valid && users[id.UUID] == nil {
+			missing[id.UUID] = true
+		}
+	}
+
+	for _, dbTransaction := range dbTransactions {
+		need(dbTransaction.CreatedBy)
+	}
+	for _, dbExpense := range dbExpenses {
+		need(dbExpense.PaidBy)
+	}
+	for _, dbDebt := range dbDebts {
+		need(dbDebt.OwedBy)
+		need(dbDebt.OwedTo)
+	}
+	for _, dbSplit := range dbSplits {
+		need(dbSplit.UserID)
+	}
+	for _, dbPayer := range dbPayers {
+		need(dbPayer.UserID)
+	}
+	for _, dbPayment := range dbPayments {
+		need(dbPayment.PaidBy)
+		need(dbPayment.PaidTo)
+	}
+
+	if len(missing) > 0 {
+		ids := make([]uuid.UUID, 0, len(missing))
+		for id := range missing {
+			ids = append(ids, id)
+		}
+
+		formerUsers, err := queries.GetUsersByIDs(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't find former members: %v", err)
+		}
+
+		for _, u := range formerUsers {
+			users[u.ID] = &User{ID: u.ID, Username: u.Username}
+		}
+	}
+
 	transactions := make([]Transaction, len(dbTransactions))
 
 	for i, dbTransaction := range dbTransactions {
-		var createdByUser *User
-		if dbTransaction.CreatedBy.Valid {
-			if u, ok := users[dbTransaction.CreatedBy.UUID]; ok {
-				createdByUser = u
-			}
+		transaction := Transaction{
+			ID:         dbTransaction.ID,
+			CreatedAt:  dbTransaction.CreatedAt,
+			UpdatedAt:  dbTransaction.UpdatedAt,
+			OccurredOn: dbTransaction.OccurredOn,
+			CreatedBy:  lookup(dbTransaction.CreatedBy),
+			Kind:       TransactionKind(dbTransaction.Kind),
+		}
+
+		if dbTransaction.DeletedAt.Valid {
+			transaction.DeletedAt = &dbTransaction.DeletedAt.Time
 		}
 
 		switch dbTransaction.Kind {
 		case "expense":
-			dbExpense, err := queries.GetExpenseByTransaction(ctx, dbTransaction.ID)
-			if err != nil {
-				return nil, fmt.Errorf("couldn't find payment for transaction: %v", err)
+			dbExpense, ok := expenses[dbTransaction.ID]
+			if !ok {
+				return nil, fmt.Errorf("couldn't find expense for transaction: %v", dbTransaction.ID)
 			}
 
-			dbDebts, err := queries.GetDebtsByTransaction(ctx, dbTransaction.ID)
-			if err != nil {
-				return nil, fmt.Errorf("couldn't get debts for transaction: %v", err)
+			transaction.Expense = buildExpense(dbExpense, debts[dbExpense.ID], splits[dbExpense.ID], payers[dbExpense.ID], lookup)
+		case "payment":
+			dbPayment, ok := payments[dbTransaction.ID]
+			if !ok {
+				return nil, fmt.Errorf("couldn't find payment for transaction: %v", dbTransaction.ID)
 			}
 
-			var paidByUser *User
-			if dbExpense.PaidBy.Valid {
-				if u, ok := users[dbExpense.PaidBy.UUID]; ok {
-					paidByUser = u
-				}
+			transaction.Payment = &Payment{
+				PaidBy:   lookup(dbPayment.PaidBy),
+				PaidTo:   lookup(dbPayment.PaidTo),
+				Amount:   dbPayment.Amount,
+				Currency: dbPayment.Currency,
 			}
+		default:
+			return nil, fmt.Errorf("unknown transaction kind: %v", dbTransaction.Kind)
+		}
 
-			expense := &Expense{
-				Description: dbExpense.Description,
-				PaidBy:      paidByUser,
-				Amount:      dbExpense.Amount,
-				Debts:       make([]Debt, len(dbDebts)),
-			}
+		transactions[i] = transaction
+	}
 
-			for j, dbDebt := range dbDebts {
-				var owedByUser *User
-				if dbDebt.OwedBy.Valid {
-					if u, ok := users[dbDebt.OwedBy.UUID]; ok {
-						owedByUser = u
-					}
-				}
-
-				var owedToUser *User
-				if dbDebt.OwedTo.Valid {
-					if u, ok := users[dbDebt.OwedTo.UUID]; ok {
-						owedToUser = u
-					}
-				}
-
-				debt := Debt{
-					Amount: dbDebt.Amount,
-					OwedBy: owedByUser,
-					OwedTo: owedToUser,
-				}
-
-				expense.Debts[j] = debt
-			}
+	return transactions, nil
+}
 
-			transactions[i] = Transaction{
-				ID:        dbTransaction.ID,
-				CreatedAt: dbTransaction.CreatedAt,
-				UpdatedAt: dbTransaction.UpdatedAt,
-				CreatedBy: createdByUser,
-				Kind:      TransactionKind(dbTransaction.Kind),
-				Expense:   expense,
-			}
-		case "payment":
-			dbPayment, err := queries.GetPaymentByTransaction(ctx, dbTransaction.ID)
-			if err != nil {
-				return nil, fmt.Errorf("couldn't find payment for transaction: %v", err)
-			}
+func buildExpense(dbExpense database.Expense, dbDebts []database.Debt, dbSplits []database.ExpenseSplit, dbPayers []database.ExpensePayer, lookup func(uuid.NullUUID) *User) *Expense {
+	paidByUser := lookup(dbExpense.PaidBy)
 
-			var paidByUser *User
-			if dbPayment.PaidBy.Valid {
-				if u, ok := users[dbPayment.PaidBy.UUID]; ok {
-					paidByUser = u
-				}
-			}
+	expense := &Expense{
+		Description: dbExpense.Description,
+		PaidBy:      paidByUser,
+		Amount:      dbExpense.Amount,
+		Currency:    dbExpense.Currency,
+		SplitMode:   SplitMode(dbExpense.SplitMode),
+		Debts:       make([]Debt, len(dbDebts)),
+	}
 
-			var paidToUser *User
-			if dbPayment.PaidTo.Valid {
-				if u, ok := users[dbPayment.PaidTo.UUID]; ok {
-					paidToUser = u
-				}
-			}
+	for _, dbSplit := range dbSplits {
+		if u := lookup(dbSplit.UserID); u != nil {
+			expense.Participants = append(expense.Participants, u)
+		}
+	}
 
-			payment := &Payment{
-				PaidBy: paidByUser,
-				PaidTo: paidToUser,
-				Amount: dbPayment.Amount,
-			}
+	for _, dbPayer := range dbPayers {
+		expense.Payers = append(expense.Payers, Payer{
+			User:   lookup(dbPayer.UserID),
+			Amount: dbPayer.Amount,
+		})
+	}
 
-			transactions[i] = Transaction{
-				ID:        dbTransaction.ID,
-				CreatedAt: dbTransaction.CreatedAt,
-				UpdatedAt: dbTransaction.UpdatedAt,
-				CreatedBy: createdByUser,
-				Kind:      TransactionKind(dbTransaction.Kind),
-				Payment:   payment,
+	for j, dbDebt := range dbDebts {
+		expense.Debts[j] = Debt{
+			Amount: dbDebt.Amount,
+			OwedBy: lookup(dbDebt.OwedBy),
+			OwedTo: lookup(dbDebt.OwedTo),
+		}
+	}
+
+	// Expenses recorded before participants were stored only have
+	// their debts to go by, which leave out the payer.
+	if len(dbSplits) == 0 {
+		if paidByUser != nil {
+			expense.Participants = append(expense.Participants, paidByUser)
+		}
+
+		for _, debt := range expense.Debts {
+			if debt.OwedBy != nil {
+				expense.Participants = append(expense.Participants, debt.OwedBy)
 			}
-		default:
-			return nil, fmt.Errorf("unknown transaction kind: %v", dbTransaction.Kind)
 		}
 	}
 
-	return transactions, nil
+	return expense
 }
 
 type Balance struct {
-	Other  User
-	Amount decimal.Decimal
+	Other  User            `json:"other"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// BalanceMatrix holds what each member of a group is owed by every other
+// member, indexed by creditor and then debtor. Payments count as debts in the
+// opposite direction. Amounts are in the group's currency.
+type BalanceMatrix map[uuid.UUID]map[uuid.UUID]decimal.Decimal
+
+// Between returns how much other owes this, net of what this owes other.
+func (m BalanceMatrix) Between(this, other uuid.UUID) decimal.Decimal {
+	return m[this][other].Sub(m[other][this])
+}
+
+// Net returns how much the rest of the group owes this, net of what this
+// owes the rest of the group.
+func (m BalanceMatrix) Net(this uuid.UUID) decimal.Decimal {
+	net := decimal.Zero
+	for _, amount := range m[this] {
+		net = net.Add(amount)
+	}
+
+	for creditor, debtors := range m {
+		if creditor != this {
+			net = net.Sub(debtors[this])
+		}
+	}
+
+	return net
+}
+
+// Users returns everyone who owes or is owed anything in the matrix,
+// including former members of the group, in a stable order.
+func (m BalanceMatrix) Users() []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	users := []uuid.UUID{}
+	add := func(id uuid.UUID) {
+		if !seen[id] {
+			seen[id] = true
+			users = append(users, id)
+		}
+	}
+
+	for creditor, debtors := range m {
+		add(creditor)
+		for debtor := range debtors {
+			add(debtor)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].String() < users[j].String()
+	})
+
+	return users
+}
+
+func GetBalanceMatrixForGroup(queries *database.Queries, ctx context.Context, groupID uuid.UUID) (BalanceMatrix, error) {
+	group, err := queries.GetGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find group: %v", err)
+	}
+
+	rows, err := queries.GetPairwiseBalancesByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get balances for group: %v", err)
+	}
+
+	rates, err := GetExchangeRates(queries, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := make(BalanceMatrix)
+	for _, row := range rows {
+		if !row.Creditor.Valid || !row.Debtor.Valid {
+			continue
+		}
+
+		debtors, ok := matrix[row.Creditor.UUID]
+		if !ok {
+			debtors = make(map[uuid.UUID]decimal.Decimal)
+			matrix[row.Creditor.UUID] = debtors
+		}
+
+		total, err := rates.Convert(row.Total, row.Currency, group.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't convert balance: %w", err)
+		}
+
+		debtors[row.Debtor.UUID] = debtors[row.Debtor.UUID].Add(total)
+	}
+
+	return matrix, nil
 }
 
 func GetBalanceForGroup(queries *database.Queries, ctx context.Context, groupID, userId uuid.UUID) ([]Balance, error) {
@@ -190,96 +441,79 @@ func GetBalanceForGroup(queries *database.Queries, ctx context.Context, groupID,
 		return nil, fmt.Errorf("coudln't find users in group: %v", err)
 	}
 
-	balances := make([]Balance, len(users)-1)
-	i := 0
+	matrix, err := GetBalanceMatrixForGroup(queries, ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]Balance, 0, len(users))
 	for _, user := range users {
 		if user.ID == userId {
 			continue
 		}
 
-		b, err := GetBalanceBetweenUsers(queries, ctx, groupID, userId, user.ID)
-		if err != nil {
-			return nil, err
-		}
-
-		balances[i] = Balance{
+		balances = append(balances, Balance{
 			Other:  User{ID: user.ID, Username: user.Username},
-			Amount: b,
-		}
-
-		i++
+			Amount: matrix.Between(userId, user.ID),
+		})
 	}
 
 	return balances, nil
 }
 
-func GetBalanceBetweenUsers(queries *database.Queries, ctx context.Context, groupID, thisUserID, otherUserID uuid.UUID) (decimal.Decimal, error) {
-	thisUserNullID := uuid.NullUUID{
-		UUID:  thisUserID,
-		Valid: true,
-	}
-	otherUserNullID := uuid.NullUUID{
-		UUID:  otherUserID,
-		Valid: true,
+// GetBalancesByPerson totals how much each other user owes userID, net of
+// what userID owes them, across every group and friendship they share. Since
+// groups can use different currencies, the totals are in DefaultCurrency.
+// Users with nothing outstanding are left out.
+func GetBalancesByPerson(queries *database.Queries, ctx context.Context, userID uuid.UUID) ([]Balance, error) {
+	rows, err := queries.GetPairwiseBalancesByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get balances for user: %v", err)
 	}
 
-	totalDebtToOther, err := queries.GetSumOfDebts(
-		ctx,
-		database.GetSumOfDebtsParams{
-			GroupID: groupID,
-			OwedBy:  thisUserNullID,
-			OwedTo:  otherUserNullID,
-		},
-	)
+	rates, err := GetExchangeRates(queries, ctx)
 	if err != nil {
-		if !strings.Contains(err.Error(), "NULL") {
-			return decimal.Decimal{}, fmt.Errorf("couldn't get debts between users: %v", err)
-		}
+		return nil, err
 	}
 
-	totalDebtToThis, err := queries.GetSumOfDebts(
-		ctx,
-		database.GetSumOfDebtsParams{
-			GroupID: groupID,
-			OwedBy:  otherUserNullID,
-			OwedTo:  thisUserNullID,
-		},
-	)
-	if err != nil {
-		if !strings.Contains(err.Error(), "NULL") {
-			return decimal.Decimal{}, fmt.Errorf("couldn't get debts between users: %v", err)
+	totals := make(map[uuid.UUID]decimal.Decimal)
+	for _, row := range rows {
+		total, err := rates.Convert(row.Total, row.Currency, DefaultCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't convert balance: %w", err)
+		}
+
+		switch {
+		case row.Creditor.UUID == userID && row.Debtor.UUID != userID:
+			totals[row.Debtor.UUID] = totals[row.Debtor.UUID].Add(total)
+		case row.Debtor.UUID == userID && row.Creditor.UUID != userID:
+			totals[row.Creditor.UUID] = totals[row.Creditor.UUID].Sub(total)
 		}
 	}
 
-	totalPaymentsToOther, err := queries.GetSumOfPayments(
-		ctx,
-		database.GetSumOfPaymentsParams{
-			GroupID: groupID,
-			PaidBy:  thisUserNullID,
-			PaidTo:  otherUserNullID,
-		},
-	)
-	if err != nil {
-		if !strings.Contains(err.Error(), "NULL") {
-			return decimal.Decimal{}, fmt.Errorf("couldn't get payments between users: %v", err)
+	ids := make([]uuid.UUID, 0, len(totals))
+	for id, total := range totals {
+		if !total.IsZero() {
+			ids = append(ids, id)
 		}
 	}
 
-	totalPaymentsToThis, err := queries.GetSumOfPayments(
-		ctx,
-		database.GetSumOfPaymentsParams{
-			GroupID: groupID,
-			PaidBy:  otherUserNullID,
-			PaidTo:  thisUserNullID,
-		},
-	)
+	users, err := queries.GetUsersByIDs(ctx, ids)
 	if err != nil {
-		if !strings.Contains(err.Error(), "NULL") {
-			return decimal.Decimal{}, fmt.Errorf("couldn't get payments between users: %v", err)
-		}
+		return nil, fmt.Errorf("couldn't find users: %v", err)
 	}
 
-	total := totalDebtToThis.Sub(totalDebtToOther).Add(totalPaymentsToOther).Sub(totalPaymentsToThis)
+	balances := make([]Balance, 0, len(users))
+	for _, user := range users {
+		balances = append(balances, Balance{
+			Other:  User{ID: user.ID, Username: user.Username},
+			Amount: totals[user.ID],
+		})
+	}
 
-	return total, nil
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Other.Username < balances[j].Other.Username
+	})
+
+	return balances, nil
 }
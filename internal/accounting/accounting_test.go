@@ -0,0 +1,56 @@
+package accounting
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestBalanceMatrix(t *testing.T) {
+	alice := uuid.New()
+	bob := uuid.New()
+	carol := uuid.New()
+
+	matrix := BalanceMatrix{
+		alice: {
+			bob:   decimal.RequireFromString("10"),
+			carol: decimal.RequireFromString("5"),
+		},
+		bob: {
+			alice: decimal.RequireFromString("4"),
+		},
+	}
+
+	cases := []struct {
+		name     string
+		actual   decimal.Decimal
+		expected string
+	}{
+		{name: "Bob owes Alice", actual: matrix.Between(alice, bob), expected: "6"},
+		{name: "Alice is owed by Bob", actual: matrix.Between(bob, alice), expected: "-6"},
+		{name: "No balance", actual: matrix.Between(bob, carol), expected: "0"},
+		{name: "Alice net", actual: matrix.Net(alice), expected: "11"},
+		{name: "Bob net", actual: matrix.Net(bob), expected: "-6"},
+		{name: "Carol net", actual: matrix.Net(carol), expected: "-5"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if !c.actual.Equal(decimal.RequireFromString(c.expected)) {
+				t.Errorf("recieved: %v, expected: %v", c.actual, c.expected)
+			}
+		})
+	}
+	users := matrix.Users()
+	if len(users) != 3 {
+		t.Fatalf("Users() recieved: %v, expected alice, bob and carol", users)
+	}
+
+	for _, id := range []uuid.UUID{alice, bob, carol} {
+		if !slices.Contains(users, id) {
+			t.Errorf("Users() recieved: %v, missing: %v", users, id)
+		}
+	}
+}
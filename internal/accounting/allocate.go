@@ -0,0 +1,110 @@
+package accounting
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Allocate divides total, which must be a whole number of cents, between
+// weights so that the parts always sum exactly to total. Every part gets the
+// floor of its proportional share in cents and the leftover cents go to the
+// parts with the largest remainders. Ties are broken by rotating through the
+// parts starting at seed, so the same inputs always produce the same result
+// while different expenses don't always favour the same member.
+func Allocate(total decimal.Decimal, weights []decimal.Decimal, seed uint64) ([]decimal.Decimal, error) {
+	if total.IsNegative() {
+		return nil, fmt.Errorf("%w: amount must not be negative", ErrInvalidSplit)
+	}
+
+	cents := total.Shift(2)
+	if !cents.IsInteger() {
+		return nil, fmt.Errorf("%w: amount %s has fractional cents", ErrInvalidSplit, total.String())
+	}
+
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("%w: no members to split between", ErrInvalidSplit)
+	}
+
+	sum := decimal.Zero
+	for _, w := range weights {
+		if w.IsNegative() {
+			return nil, fmt.Errorf("%w: weights must not be negative", ErrInvalidSplit)
+		}
+		sum = sum.Add(w)
+	}
+
+	n := len(weights)
+
+	// Nothing to divide, so exact splits of zero, where every weight is
+	// zero too, still work.
+	if total.IsZero() {
+		parts := make([]decimal.Decimal, n)
+		for i := range parts {
+			parts[i] = decimal.Zero
+		}
+
+		return parts, nil
+	}
+
+	if !sum.IsPositive() {
+		return nil, fmt.Errorf("%w: at least one member must have a share", ErrInvalidSplit)
+	}
+
+	quotients := make([]decimal.Decimal, n)
+	remainders := make([]decimal.Decimal, n)
+	leftover := cents
+
+	for i, w := range weights {
+		// QuoRem keeps the division exact, so remainders over the same
+		// divisor can be compared without any rounding error.
+		quotients[i], remainders[i] = cents.Mul(w).QuoRem(sum, 0)
+		leftover = leftover.Sub(quotients[i])
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+
+	start := int(seed % uint64(n))
+	rotation := func(i int) int {
+		return (i - start + n) % n
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if cmp := remainders[i].Cmp(remainders[j]); cmp != 0 {
+			return cmp > 0
+		}
+		return rotation(i) < rotation(j)
+	})
+
+	extra := int(leftover.IntPart())
+	for k := 0; k < extra; k++ {
+		i := order[k%n]
+		quotients[i] = quotients[i].Add(decimal.NewFromInt(1))
+	}
+
+	parts := make([]decimal.Decimal, n)
+	for i, q := range quotients {
+		parts[i] = q.Shift(-2)
+	}
+
+	return parts, nil
+}
+
+// splitSeed derives the allocation seed for a split from its inputs so that
+// re-splitting an unchanged expense hands out leftover cents the same way.
+func splitSeed(amount decimal.Decimal, userIDs []uuid.UUID) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(amount.String()))
+	for _, id := range userIDs {
+		h.Write(id[:])
+	}
+
+	return h.Sum64()
+}
@@ -0,0 +1,220 @@
+package accounting
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// allocation is a random input to Allocate for property tests.
+type allocation struct {
+	Total   decimal.Decimal
+	Weights []decimal.Decimal
+	Seed    uint64
+}
+
+func (allocation) Generate(r *rand.Rand, size int) reflect.Value {
+	a := allocation{
+		Total:   decimal.New(r.Int63n(10_000_000), -2),
+		Weights: make([]decimal.Decimal, 1+r.Intn(12)),
+		Seed:    r.Uint64(),
+	}
+
+	for i := range a.Weights {
+		a.Weights[i] = decimal.New(r.Int63n(10_000), -int32(r.Intn(3)))
+	}
+	a.Weights[r.Intn(len(a.Weights))] = decimal.New(1+r.Int63n(10_000), 0)
+
+	return reflect.ValueOf(a)
+}
+
+func sum(ds []decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for _, d := range ds {
+		total = total.Add(d)
+	}
+	return total
+}
+
+func TestAllocateSumsToTotal(t *testing.T) {
+	property := func(a allocation) bool {
+		parts, err := Allocate(a.Total, a.Weights, a.Seed)
+		if err != nil {
+			t.Logf("Allocate() recieved error: %v", err)
+			return false
+		}
+
+		return sum(parts).Equal(a.Total)
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAllocateWithinOneCent(t *testing.T) {
+	cent := decimal.New(1, -2)
+
+	property := func(a allocation) bool {
+		parts, err := Allocate(a.Total, a.Weights, a.Seed)
+		if err != nil {
+			return false
+		}
+
+		weightTotal := sum(a.Weights)
+		for i, part := range parts {
+			if part.IsNegative() || !part.Shift(2).IsInteger() {
+				return false
+			}
+
+			exact := a.Total.Mul(a.Weights[i]).Div(weightTotal)
+			if part.Sub(exact).Abs().GreaterThanOrEqual(cent) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAllocateIsDeterministic(t *testing.T) {
+	property := func(a allocation) bool {
+		first, err := Allocate(a.Total, a.Weights, a.Seed)
+		if err != nil {
+			return false
+		}
+
+		second, err := Allocate(a.Total, a.Weights, a.Seed)
+		if err != nil {
+			return false
+		}
+
+		for i := range first {
+			if !first[i].Equal(second[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAllocateRotatesLeftoverCents(t *testing.T) {
+	total := decimal.RequireFromString("10")
+	weights := []decimal.Decimal{decimal.NewFromInt(1), decimal.NewFromInt(1), decimal.NewFromInt(1)}
+
+	for seed := uint64(0); seed < 3; seed++ {
+		parts, err := Allocate(total, weights, seed)
+		if err != nil {
+			t.Fatalf("Allocate() recieved error: %v", err)
+		}
+
+		for i, part := range parts {
+			expected := decimal.RequireFromString("3.33")
+			if uint64(i) == seed {
+				expected = decimal.RequireFromString("3.34")
+			}
+
+			if !part.Equal(expected) {
+				t.Errorf("Allocate() with seed %d part %d: %v, expected: %v", seed, i, part, expected)
+			}
+		}
+	}
+}
+
+func TestAllocateRejectsFractionalCents(t *testing.T) {
+	if _, err := Allocate(decimal.RequireFromString("10.005"), []decimal.Decimal{decimal.NewFromInt(1)}, 0); err == nil {
+		t.Errorf("Allocate() expected error for fractional cents")
+	}
+}
+
+func TestAllocateZeroTotal(t *testing.T) {
+	weights := []decimal.Decimal{decimal.Zero, decimal.Zero}
+
+	parts, err := Allocate(decimal.Zero, weights, 0)
+	if err != nil {
+		t.Fatalf("Allocate() recieved error: %v", err)
+	}
+
+	for i, part := range parts {
+		if !part.IsZero() {
+			t.Errorf("Allocate() part %d: %v, expected: 0", i, part)
+		}
+	}
+
+	splitParts := []SplitPart{{UserID: uuid.New(), Value: decimal.Zero}, {UserID: uuid.New(), Value: decimal.Zero}}
+	if _, err := ComputeShares(SplitExact, decimal.RequireFromString("0.00"), splitParts); err != nil {
+		t.Errorf("ComputeShares() recieved error for an exact split of zero: %v", err)
+	}
+}
+
+func TestComputeSharesSumsToAmount(t *testing.T) {
+	modes := []SplitMode{SplitEqual, SplitPercent, SplitShares, SplitExact}
+
+	property := func(a allocation) bool {
+		parts := make([]SplitPart, len(a.Weights))
+		for i, w := range a.Weights {
+			parts[i] = SplitPart{UserID: uuid.New(), Value: w}
+		}
+
+		for _, mode := range modes {
+			modeParts := make([]SplitPart, len(parts))
+			copy(modeParts, parts)
+
+			// Percent and exact splits must add up to their totals, so
+			// derive their values from an allocation of the weights.
+			switch mode {
+			case SplitPercent:
+				percents, err := Allocate(decimal.NewFromInt(100), a.Weights, a.Seed)
+				if err != nil {
+					return false
+				}
+				for i := range modeParts {
+					modeParts[i].Value = percents[i]
+				}
+			case SplitExact:
+				amounts, err := Allocate(a.Total, a.Weights, a.Seed)
+				if err != nil {
+					return false
+				}
+				for i := range modeParts {
+					modeParts[i].Value = amounts[i]
+				}
+			}
+
+			shares, err := ComputeShares(mode, a.Total, modeParts)
+			if err != nil {
+				t.Logf("ComputeShares(%v) recieved error: %v", mode, err)
+				return false
+			}
+
+			total := decimal.Zero
+			for _, share := range shares {
+				total = total.Add(share.Amount)
+			}
+
+			if !total.Equal(a.Total) {
+				t.Logf("ComputeShares(%v) shares sum to %v, expected %v", mode, total, a.Total)
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
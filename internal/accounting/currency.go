@@ -0,0 +1,156 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+const DefaultCurrency = "USD"
+
+var (
+	ErrUnknownCurrency = errors.New("unknown currency")
+	ErrNoExchangeRate  = errors.New("no exchange rate")
+)
+
+// currency describes how amounts in a currency are written.
+type currency struct {
+	Symbol string
+	// Exponent is the number of digits after the decimal point in the
+	// currency's smallest unit, like 2 for cents or 0 for yen.
+	Exponent int32
+}
+
+// currencies holds the currencies that groups and transactions can use,
+// keyed by ISO 4217 code.
+var currencies = map[string]currency{
+	"AUD": {Symbol: "A$", Exponent: 2},
+	"BRL": {Symbol: "R$", Exponent: 2},
+	"CAD": {Symbol: "CA$", Exponent: 2},
+	"CHF": {Symbol: "CHF ", Exponent: 2},
+	"CNY": {Symbol: "CN¥", Exponent: 2},
+	"CZK": {Symbol: "Kč ", Exponent: 2},
+	"DKK": {Symbol: "kr ", Exponent: 2},
+	"EUR": {Symbol: "€", Exponent: 2},
+	"GBP": {Symbol: "£", Exponent: 2},
+	"HKD": {Symbol: "HK$", Exponent: 2},
+	"INR": {Symbol: "₹", Exponent: 2},
+	"JPY": {Symbol: "¥", Exponent: 0},
+	"KRW": {Symbol: "₩", Exponent: 0},
+	"MXN": {Symbol: "MX$", Exponent: 2},
+	"NOK": {Symbol: "kr ", Exponent: 2},
+	"NZD": {Symbol: "NZ$", Exponent: 2},
+	"PLN": {Symbol: "zł ", Exponent: 2},
+	"SEK": {Symbol: "kr ", Exponent: 2},
+	"SGD": {Symbol: "S$", Exponent: 2},
+	"THB": {Symbol: "฿", Exponent: 2},
+	"TRY": {Symbol: "₺", Exponent: 2},
+	"USD": {Symbol: "$", Exponent: 2},
+	"ZAR": {Symbol: "R ", Exponent: 2},
+}
+
+// ParseCurrency normalizes a currency code and checks that it is supported.
+func ParseCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := currencies[code]; !ok {
+		return "", fmt.Errorf("%w `%s`", ErrUnknownCurrency, code)
+	}
+
+	return code, nil
+}
+
+// Currencies returns the codes of every supported currency in order.
+func Currencies() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// Exponent returns the number of digits after the decimal point that amounts
+// in a currency are written with, which is 2 for currencies that aren't
+// supported.
+func Exponent(code string) int32 {
+	c, ok := currencies[code]
+	if !ok {
+		return 2
+	}
+
+	return c.Exponent
+}
+
+// FormatAmount renders amount with the symbol of its currency, falling back
+// to the currency code for currencies without a known symbol. It has as many
+// decimal places as the currency's smallest unit.
+func FormatAmount(code string, amount decimal.Decimal) string {
+	places := Exponent(code)
+
+	c, ok := currencies[code]
+	if !ok {
+		return amount.StringFixed(places) + " " + code
+	}
+
+	if amount.IsNegative() {
+		return "-" + c.Symbol + amount.Neg().StringFixed(places)
+	}
+
+	return c.Symbol + amount.StringFixed(places)
+}
+
+// ExchangeRates holds how many units of the quote currency one unit of the
+// base currency buys, indexed by base and then quote.
+type ExchangeRates map[string]map[string]decimal.Decimal
+
+// Convert converts amount from one currency to another, rounded to the
+// smallest unit of the currency it is converted to.
+// A rate stored in the opposite direction is inverted when there is no direct
+// rate. It returns an error wrapping ErrNoExchangeRate when neither exists.
+func (r ExchangeRates) Convert(amount decimal.Decimal, from, to string) (decimal.Decimal, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	if rate, ok := r[from][to]; ok {
+		return amount.Mul(rate).Round(Exponent(to)), nil
+	}
+
+	if rate, ok := r[to][from]; ok && !rate.IsZero() {
+		return amount.Div(rate).Round(Exponent(to)), nil
+	}
+
+	return decimal.Decimal{}, fmt.Errorf("%w from %s to %s", ErrNoExchangeRate, from, to)
+}
+
+// CanConvert reports whether amounts can be converted between the currencies.
+func (r ExchangeRates) CanConvert(from, to string) bool {
+	_, err := r.Convert(decimal.Zero, from, to)
+	return err == nil
+}
+
+func GetExchangeRates(queries *database.Queries, ctx context.Context) (ExchangeRates, error) {
+	rows, err := queries.GetExchangeRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get exchange rates: %v", err)
+	}
+
+	rates := make(ExchangeRates)
+	for _, row := range rows {
+		quotes, ok := rates[row.Base]
+		if !ok {
+			quotes = make(map[string]decimal.Decimal)
+			rates[row.Base] = quotes
+		}
+
+		quotes[row.Quote] = row.Rate
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,100 @@
+package accounting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParseCurrency(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+		err      error
+	}{
+		{input: "USD", expected: "USD"},
+		{input: " eur ", expected: "EUR"},
+		{input: "", err: ErrUnknownCurrency},
+		{input: "XYZ", err: ErrUnknownCurrency},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			actual, err := ParseCurrency(c.input)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expected error %v, got %v", c.err, err)
+			}
+
+			if actual != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	cases := []struct {
+		currency string
+		amount   string
+		expected string
+	}{
+		{currency: "USD", amount: "12.5", expected: "$12.50"},
+		{currency: "EUR", amount: "3", expected: "€3.00"},
+		{currency: "GBP", amount: "-4.25", expected: "-£4.25"},
+		{currency: "JPY", amount: "1200", expected: "¥1200"},
+		{currency: "KRW", amount: "-5000", expected: "-₩5000"},
+		{currency: "XYZ", amount: "1", expected: "1.00 XYZ"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expected, func(t *testing.T) {
+			actual := FormatAmount(c.currency, decimal.RequireFromString(c.amount))
+			if actual != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	rates := ExchangeRates{
+		"EUR": {"USD": decimal.RequireFromString("1.10")},
+		"USD": {"JPY": decimal.RequireFromString("149.21")},
+	}
+
+	cases := []struct {
+		name     string
+		amount   string
+		from     string
+		to       string
+		expected string
+	}{
+		{name: "Same currency", amount: "10", from: "JPY", to: "JPY", expected: "10"},
+		{name: "Direct rate", amount: "10", from: "EUR", to: "USD", expected: "11"},
+		{name: "Inverse rate", amount: "11", from: "USD", to: "EUR", expected: "10"},
+		{name: "Rounded to the cent", amount: "1", from: "USD", to: "EUR", expected: "0.91"},
+		{name: "Rounded to the yen", amount: "10.55", from: "USD", to: "JPY", expected: "1574"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := rates.Convert(decimal.RequireFromString(c.amount), c.from, c.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !actual.Equal(decimal.RequireFromString(c.expected)) {
+				t.Errorf("expected %s, got %s", c.expected, actual)
+			}
+		})
+	}
+
+	if _, err := rates.Convert(decimal.NewFromInt(1), "USD", "GBP"); !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("expected %v, got %v", ErrNoExchangeRate, err)
+	}
+
+	if rates.CanConvert("GBP", "EUR") {
+		t.Errorf("expected no conversion from GBP to EUR")
+	}
+}
@@ -0,0 +1,202 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// SplitItems divides an expense according to its line items rather than a
+// single set of split parts.
+const SplitItems SplitMode = "items"
+
+type ChargeKind string
+
+const (
+	ChargeTax     ChargeKind = "tax"
+	ChargeTip     ChargeKind = "tip"
+	ChargeService ChargeKind = "service"
+)
+
+// itemKind is the kind stored for line items, as opposed to charges.
+const itemKind = "item"
+
+// Item is a line on a receipt, shared equally between its members.
+type Item struct {
+	Description string          `json:"description"`
+	Amount      decimal.Decimal `json:"amount"`
+	Members     []uuid.UUID     `json:"members"`
+}
+
+// Charge is an amount added on top of the items of a receipt, such as tax or
+// a tip, which is spread across the items in proportion to their amounts.
+type Charge struct {
+	Kind   ChargeKind      `json:"kind"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+func ParseChargeKind(s string) (ChargeKind, error) {
+	switch kind := ChargeKind(s); kind {
+	case ChargeTax, ChargeTip, ChargeService:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("%w: unknown charge `%s`", ErrInvalidSplit, s)
+	}
+}
+
+// ItemsTotal returns what a receipt comes to with its charges included.
+func ItemsTotal(items []Item, charges []Charge) decimal.Decimal {
+	total := decimal.Zero
+	for _, item := range items {
+		total = total.Add(item.Amount)
+	}
+
+	for _, charge := range charges {
+		total = total.Add(charge.Amount)
+	}
+
+	return total
+}
+
+// ComputeItemShares works out each member's share of an itemized expense.
+// Every item is divided equally between its members, and the charges are
+// then divided between the members in proportion to what their items came
+// to. The shares always sum exactly to amount, which must equal the items
+// and charges together. It returns an error wrapping ErrInvalidSplit when
+// the items are invalid or don't add up.
+func ComputeItemShares(amount decimal.Decimal, items []Item, charges []Charge) ([]Share, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no items to split", ErrInvalidSplit)
+	}
+
+	for _, item := range items {
+		if err := checkCents(item.Amount); err != nil {
+			return nil, err
+		}
+
+		if len(item.Members) == 0 {
+			return nil, fmt.Errorf("%w: item `%s` has nobody to split it between", ErrInvalidSplit, item.Description)
+		}
+	}
+
+	chargesTotal := decimal.Zero
+	for _, charge := range charges {
+		if err := checkCents(charge.Amount); err != nil {
+			return nil, err
+		}
+
+		chargesTotal = chargesTotal.Add(charge.Amount)
+	}
+
+	if total := ItemsTotal(items, charges); !total.Equal(amount) {
+		return nil, fmt.Errorf("%w: items add up to %s, expected %s", ErrInvalidSplit, total.String(), amount.String())
+	}
+
+	var order []uuid.UUID
+	subtotals := make(map[uuid.UUID]decimal.Decimal)
+	for _, item := range items {
+		seen := make(map[uuid.UUID]bool, len(item.Members))
+		weights := make([]decimal.Decimal, len(item.Members))
+		for i, member := range item.Members {
+			if seen[member] {
+				return nil, fmt.Errorf("%w: member listed more than once on item `%s`", ErrInvalidSplit, item.Description)
+			}
+			seen[member] = true
+
+			weights[i] = decimal.NewFromInt(1)
+		}
+
+		amounts, err := Allocate(item.Amount, weights, splitSeed(item.Amount, item.Members))
+		if err != nil {
+			return nil, err
+		}
+
+		for i, member := range item.Members {
+			if _, ok := subtotals[member]; !ok {
+				order = append(order, member)
+			}
+			subtotals[member] = subtotals[member].Add(amounts[i])
+		}
+	}
+
+	weights := make([]decimal.Decimal, len(order))
+	for i, member := range order {
+		weights[i] = subtotals[member]
+	}
+
+	// Charges on a receipt of free items are shared equally instead.
+	if amount.Sub(chargesTotal).IsZero() {
+		for i := range weights {
+			weights[i] = decimal.NewFromInt(1)
+		}
+	}
+
+	extra := make([]decimal.Decimal, len(order))
+	if chargesTotal.IsPositive() {
+		var err error
+		extra, err = Allocate(chargesTotal, weights, splitSeed(chargesTotal, order))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	shares := make([]Share, len(order))
+	for i, member := range order {
+		shares[i] = Share{UserID: member, Amount: subtotals[member].Add(extra[i])}
+	}
+
+	return shares, nil
+}
+
+func checkCents(amount decimal.Decimal) error {
+	if amount.IsNegative() {
+		return fmt.Errorf("%w: amounts must not be negative", ErrInvalidSplit)
+	}
+
+	if !amount.Shift(2).IsInteger() {
+		return fmt.Errorf("%w: amount %s has fractional cents", ErrInvalidSplit, amount.String())
+	}
+
+	return nil
+}
+
+// GetExpenseItems loads the line items and charges of an itemized expense in
+// the order they were entered.
+func GetExpenseItems(queries *database.Queries, ctx context.Context, expenseID uuid.UUID) ([]Item, []Charge, error) {
+	dbItems, err := queries.GetExpenseItemsByExpense(ctx, expenseID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't get items for expense: %v", err)
+	}
+
+	dbMembers, err := queries.GetExpenseItemMembersByExpense(ctx, expenseID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't get item members for expense: %v", err)
+	}
+
+	members := make(map[uuid.UUID][]uuid.UUID)
+	for _, dbMember := range dbMembers {
+		if dbMember.UserID.Valid {
+			members[dbMember.ItemID] = append(members[dbMember.ItemID], dbMember.UserID.UUID)
+		}
+	}
+
+	var items []Item
+	var charges []Charge
+	for _, dbItem := range dbItems {
+		if dbItem.Kind == itemKind {
+			items = append(items, Item{
+				Description: dbItem.Description,
+				Amount:      dbItem.Amount,
+				Members:     members[dbItem.ID],
+			})
+			continue
+		}
+
+		charges = append(charges, Charge{Kind: ChargeKind(dbItem.Kind), Amount: dbItem.Amount})
+	}
+
+	return items, charges, nil
+}
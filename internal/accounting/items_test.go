@@ -0,0 +1,114 @@
+package accounting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestComputeItemShares(t *testing.T) {
+	alice := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	bob := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	carol := uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+
+	cases := []struct {
+		name        string
+		amount      string
+		items       []Item
+		charges     []Charge
+		expectError bool
+		expected    map[uuid.UUID]string
+	}{
+		{
+			name:   "Items without charges",
+			amount: "30",
+			items: []Item{
+				{Description: "Pizza", Amount: decimal.RequireFromString("20"), Members: []uuid.UUID{alice, bob}},
+				{Description: "Salad", Amount: decimal.RequireFromString("10"), Members: []uuid.UUID{carol}},
+			},
+			expected: map[uuid.UUID]string{alice: "10", bob: "10", carol: "10"},
+		},
+		{
+			name:   "Charges spread by item totals",
+			amount: "36",
+			items: []Item{
+				{Description: "Steak", Amount: decimal.RequireFromString("20"), Members: []uuid.UUID{alice}},
+				{Description: "Soup", Amount: decimal.RequireFromString("10"), Members: []uuid.UUID{bob}},
+			},
+			charges: []Charge{
+				{Kind: ChargeTax, Amount: decimal.RequireFromString("3")},
+				{Kind: ChargeTip, Amount: decimal.RequireFromString("3")},
+			},
+			expected: map[uuid.UUID]string{alice: "24", bob: "12"},
+		},
+		{
+			name:   "Leftover cents",
+			amount: "10.01",
+			items: []Item{
+				{Description: "Shared", Amount: decimal.RequireFromString("9"), Members: []uuid.UUID{alice, bob, carol}},
+			},
+			charges:  []Charge{{Kind: ChargeService, Amount: decimal.RequireFromString("1.01")}},
+			expected: map[uuid.UUID]string{alice: "3.33", bob: "3.34", carol: "3.34"},
+		},
+		{
+			name:   "Not adding up",
+			amount: "25",
+			items: []Item{
+				{Description: "Pizza", Amount: decimal.RequireFromString("20"), Members: []uuid.UUID{alice}},
+			},
+			expectError: true,
+		},
+		{
+			name:   "Item without members",
+			amount: "20",
+			items: []Item{
+				{Description: "Pizza", Amount: decimal.RequireFromString("20")},
+			},
+			expectError: true,
+		},
+		{
+			name:        "No items",
+			amount:      "0",
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			amount := decimal.RequireFromString(c.amount)
+
+			shares, err := ComputeItemShares(amount, c.items, c.charges)
+			if c.expectError {
+				if !errors.Is(err, ErrInvalidSplit) {
+					t.Fatalf("expected %v, got %v", ErrInvalidSplit, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(shares) != len(c.expected) {
+				t.Fatalf("expected %d shares, got %d", len(c.expected), len(shares))
+			}
+
+			total := decimal.Zero
+			for _, share := range shares {
+				total = total.Add(share.Amount)
+			}
+
+			if !total.Equal(amount) {
+				t.Errorf("shares add up to %s, expected %s", total, amount)
+			}
+
+			for _, share := range shares {
+				if !share.Amount.Equal(decimal.RequireFromString(c.expected[share.UserID])) {
+					t.Errorf("expected %s, got %s", c.expected[share.UserID], share.Amount)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package accounting
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Contribution is the amount a member fronted towards an expense.
+type Contribution struct {
+	UserID uuid.UUID       `json:"user_id"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// OwedShare is a debt between two members that results from an expense.
+type OwedShare struct {
+	OwedBy uuid.UUID       `json:"owed_by"`
+	OwedTo uuid.UUID       `json:"owed_to"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// ValidateContributions checks that the payers of an expense fronted whole,
+// non-negative amounts of cents that add up to amount. It returns an error
+// wrapping ErrInvalidSplit otherwise.
+func ValidateContributions(amount decimal.Decimal, contributions []Contribution) error {
+	if len(contributions) == 0 {
+		return fmt.Errorf("%w: expense must have a payer", ErrInvalidSplit)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(contributions))
+	total := decimal.Zero
+	for _, c := range contributions {
+		if seen[c.UserID] {
+			return fmt.Errorf("%w: payer listed more than once", ErrInvalidSplit)
+		}
+		seen[c.UserID] = true
+
+		if c.Amount.IsNegative() {
+			return fmt.Errorf("%w: payments must not be negative", ErrInvalidSplit)
+		}
+
+		if !c.Amount.Shift(2).IsInteger() {
+			return fmt.Errorf("%w: payment %s has fractional cents", ErrInvalidSplit, c.Amount.String())
+		}
+
+		total = total.Add(c.Amount)
+	}
+
+	if !total.Equal(amount) {
+		return fmt.Errorf("%w: payers paid %s, expected %s", ErrInvalidSplit, total.String(), amount.String())
+	}
+
+	return nil
+}
+
+// ComputeDebts works out who owes whom for an expense. Each member's share is
+// divided between the payers in proportion to what they have fronted that
+// isn't already accounted for, so that every payer is owed back exactly what
+// they paid on behalf of others down to the cent. Debts between the same two
+// members in opposite directions are netted out. The shares must add up to
+// the contributions.
+func ComputeDebts(shares []Share, contributions []Contribution) ([]OwedShare, error) {
+	total := decimal.Zero
+	remaining := make([]decimal.Decimal, len(contributions))
+	payerIDs := make([]uuid.UUID, len(contributions))
+	for i, c := range contributions {
+		remaining[i] = c.Amount
+		payerIDs[i] = c.UserID
+		total = total.Add(c.Amount)
+	}
+
+	// An expense of nothing leaves nobody owing anything.
+	if total.IsZero() {
+		return nil, nil
+	}
+
+	type pair struct{ by, to uuid.UUID }
+	owed := make(map[pair]decimal.Decimal)
+	var order []pair
+
+	for _, share := range shares {
+		if share.Amount.IsZero() {
+			continue
+		}
+
+		// No part can exceed what a payer has left, so the last share
+		// always uses up exactly what remains of each contribution.
+		amounts, err := Allocate(share.Amount, remaining, splitSeed(share.Amount, []uuid.UUID{share.UserID}))
+		if err != nil {
+			return nil, err
+		}
+
+		for i, payerID := range payerIDs {
+			remaining[i] = remaining[i].Sub(amounts[i])
+
+			if payerID == share.UserID || amounts[i].IsZero() {
+				continue
+			}
+
+			key := pair{by: share.UserID, to: payerID}
+			if _, ok := owed[key]; !ok {
+				order = append(order, key)
+			}
+			owed[key] = owed[key].Add(amounts[i])
+		}
+	}
+
+	var debts []OwedShare
+	for _, key := range order {
+		net := owed[key].Sub(owed[pair{by: key.to, to: key.by}])
+		if !net.IsPositive() {
+			continue
+		}
+
+		debts = append(debts, OwedShare{OwedBy: key.by, OwedTo: key.to, Amount: net})
+	}
+
+	return debts, nil
+}
@@ -0,0 +1,182 @@
+package accounting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestValidateContributions(t *testing.T) {
+	alice := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	bob := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+
+	cases := []struct {
+		name          string
+		amount        string
+		contributions []Contribution
+		expectError   bool
+	}{
+		{
+			name:          "Single payer",
+			amount:        "30",
+			contributions: []Contribution{{UserID: alice, Amount: decimal.RequireFromString("30")}},
+		},
+		{
+			name:   "Several payers",
+			amount: "30",
+			contributions: []Contribution{
+				{UserID: alice, Amount: decimal.RequireFromString("10")},
+				{UserID: bob, Amount: decimal.RequireFromString("20")},
+			},
+		},
+		{
+			name:        "No payers",
+			amount:      "30",
+			expectError: true,
+		},
+		{
+			name:   "Not adding up",
+			amount: "30",
+			contributions: []Contribution{
+				{UserID: alice, Amount: decimal.RequireFromString("10")},
+				{UserID: bob, Amount: decimal.RequireFromString("10")},
+			},
+			expectError: true,
+		},
+		{
+			name:   "Payer listed twice",
+			amount: "30",
+			contributions: []Contribution{
+				{UserID: alice, Amount: decimal.RequireFromString("15")},
+				{UserID: alice, Amount: decimal.RequireFromString("15")},
+			},
+			expectError: true,
+		},
+		{
+			name:          "Fractional cents",
+			amount:        "30.001",
+			contributions: []Contribution{{UserID: alice, Amount: decimal.RequireFromString("30.001")}},
+			expectError:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateContributions(decimal.RequireFromString(c.amount), c.contributions)
+			if c.expectError {
+				if !errors.Is(err, ErrInvalidSplit) {
+					t.Fatalf("expected %v, got %v", ErrInvalidSplit, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestComputeDebts(t *testing.T) {
+	alice := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	bob := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	carol := uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+
+	type pair struct{ by, to uuid.UUID }
+
+	cases := []struct {
+		name          string
+		shares        []Share
+		contributions []Contribution
+		expected      map[pair]string
+	}{
+		{
+			name: "Single payer",
+			shares: []Share{
+				{UserID: alice, Amount: decimal.RequireFromString("10")},
+				{UserID: bob, Amount: decimal.RequireFromString("10")},
+				{UserID: carol, Amount: decimal.RequireFromString("10")},
+			},
+			contributions: []Contribution{{UserID: alice, Amount: decimal.RequireFromString("30")}},
+			expected: map[pair]string{
+				{bob, alice}:   "10",
+				{carol, alice}: "10",
+			},
+		},
+		{
+			name: "Two payers split with a third member",
+			shares: []Share{
+				{UserID: alice, Amount: decimal.RequireFromString("20")},
+				{UserID: bob, Amount: decimal.RequireFromString("20")},
+				{UserID: carol, Amount: decimal.RequireFromString("20")},
+			},
+			contributions: []Contribution{
+				{UserID: alice, Amount: decimal.RequireFromString("40")},
+				{UserID: bob, Amount: decimal.RequireFromString("20")},
+			},
+			expected: map[pair]string{
+				// Bob owes Alice part of his share, net of what Alice owes Bob
+				{bob, alice}:   "6.67",
+				{carol, alice}: "13.33",
+				{carol, bob}:   "6.67",
+			},
+		},
+		{
+			name: "Payers who only paid for themselves",
+			shares: []Share{
+				{UserID: alice, Amount: decimal.RequireFromString("15")},
+				{UserID: bob, Amount: decimal.RequireFromString("15")},
+			},
+			contributions: []Contribution{
+				{UserID: alice, Amount: decimal.RequireFromString("15")},
+				{UserID: bob, Amount: decimal.RequireFromString("15")},
+			},
+			expected: map[pair]string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			debts, err := ComputeDebts(c.shares, c.contributions)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(debts) != len(c.expected) {
+				t.Fatalf("expected %d debts, got %d: %v", len(c.expected), len(debts), debts)
+			}
+
+			// Every payer should be owed what they paid for others, net of
+			// what they owe themselves.
+			net := make(map[uuid.UUID]decimal.Decimal)
+			for _, debt := range debts {
+				expected, ok := c.expected[pair{debt.OwedBy, debt.OwedTo}]
+				if !ok {
+					t.Fatalf("unexpected debt %v", debt)
+				}
+
+				if !debt.Amount.Equal(decimal.RequireFromString(expected)) {
+					t.Errorf("expected %s, got %s", expected, debt.Amount)
+				}
+
+				net[debt.OwedTo] = net[debt.OwedTo].Add(debt.Amount)
+				net[debt.OwedBy] = net[debt.OwedBy].Sub(debt.Amount)
+			}
+
+			for _, contribution := range c.contributions {
+				expected := contribution.Amount
+				for _, share := range c.shares {
+					if share.UserID == contribution.UserID {
+						expected = expected.Sub(share.Amount)
+					}
+				}
+
+				if !net[contribution.UserID].Equal(expected) {
+					t.Errorf("expected payer to be owed %s, got %s", expected, net[contribution.UserID])
+				}
+			}
+		})
+	}
+}
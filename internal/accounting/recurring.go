@@ -0,0 +1,105 @@
+package accounting
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Frequency is how often a recurring transaction repeats.
+type Frequency string
+
+const (
+	FrequencyDaily   Frequency = "daily"
+	FrequencyWeekly  Frequency = "weekly"
+	FrequencyMonthly Frequency = "monthly"
+	FrequencyYearly  Frequency = "yearly"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+func ParseFrequency(s string) (Frequency, error) {
+	switch freq := Frequency(s); freq {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+		return freq, nil
+	default:
+		return "", fmt.Errorf("%w: unknown frequency `%s`", ErrInvalidSchedule, s)
+	}
+}
+
+// ValidateSchedule checks that a schedule starting on start and ending on end,
+// if it ends at all, has at least one occurrence.
+func ValidateSchedule(start time.Time, end *time.Time) error {
+	if end != nil && toDate(*end).Before(toDate(start)) {
+		return fmt.Errorf("%w: ends before it starts", ErrInvalidSchedule)
+	}
+
+	return nil
+}
+
+// Occurrence returns the nth occurrence of a schedule, counting the start date
+// as the zeroth. Monthly and yearly schedules keep the day of the month they
+// started on, falling back to the last day of shorter months, so a schedule
+// starting on the 31st still lands on the 31st whenever the month has one.
+func Occurrence(freq Frequency, start time.Time, n int) time.Time {
+	start = toDate(start)
+
+	switch freq {
+	case FrequencyDaily:
+		return start.AddDate(0, 0, n)
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case FrequencyMonthly:
+		return addMonths(start, n)
+	case FrequencyYearly:
+		return addMonths(start, 12*n)
+	default:
+		panic(fmt.Sprintf("unknown frequency `%s`", freq))
+	}
+}
+
+// NextOccurrence returns the first occurrence of a schedule that falls after
+// the given date.
+func NextOccurrence(freq Frequency, start, after time.Time) time.Time {
+	start, after = toDate(start), toDate(after)
+	if after.Before(start) {
+		return start
+	}
+
+	// Start from an estimate that never overshoots and step forward.
+	var n int
+	switch freq {
+	case FrequencyDaily:
+		n = int(after.Sub(start).Hours() / 24)
+	case FrequencyWeekly:
+		n = int(after.Sub(start).Hours() / 24 / 7)
+	case FrequencyMonthly:
+		n = monthsBetween(start, after)
+	case FrequencyYearly:
+		n = monthsBetween(start, after) / 12
+	}
+
+	for !Occurrence(freq, start, n).After(after) {
+		n++
+	}
+
+	return Occurrence(freq, start, n)
+}
+
+// addMonths moves t forward by n months, clamping the day to the end of the
+// month instead of overflowing into the next one.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
+// toDate drops the time of day from t, keeping the calendar date it falls on.
+func toDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
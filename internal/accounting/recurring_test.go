@@ -0,0 +1,128 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestNextOccurrence(t *testing.T) {
+	cases := []struct {
+		name     string
+		freq     Frequency
+		start    string
+		after    string
+		expected string
+	}{
+		{
+			name:     "Before the start",
+			freq:     FrequencyMonthly,
+			start:    "2025-03-15",
+			after:    "2025-01-01",
+			expected: "2025-03-15",
+		},
+		{
+			name:     "Daily",
+			freq:     FrequencyDaily,
+			start:    "2025-01-01",
+			after:    "2025-01-01",
+			expected: "2025-01-02",
+		},
+		{
+			name:     "Weekly",
+			freq:     FrequencyWeekly,
+			start:    "2025-01-01",
+			after:    "2025-01-10",
+			expected: "2025-01-15",
+		},
+		{
+			name:     "Monthly",
+			freq:     FrequencyMonthly,
+			start:    "2025-01-15",
+			after:    "2025-03-15",
+			expected: "2025-04-15",
+		},
+		{
+			name:     "Monthly clamped to a short month",
+			freq:     FrequencyMonthly,
+			start:    "2025-01-31",
+			after:    "2025-01-31",
+			expected: "2025-02-28",
+		},
+		{
+			name:     "Monthly returns to the original day",
+			freq:     FrequencyMonthly,
+			start:    "2025-01-31",
+			after:    "2025-02-28",
+			expected: "2025-03-31",
+		},
+		{
+			name:     "Monthly across a year",
+			freq:     FrequencyMonthly,
+			start:    "2024-11-30",
+			after:    "2024-12-30",
+			expected: "2025-01-30",
+		},
+		{
+			name:     "Yearly on a leap day",
+			freq:     FrequencyYearly,
+			start:    "2024-02-29",
+			after:    "2024-02-29",
+			expected: "2025-02-28",
+		},
+		{
+			name:     "Yearly back on a leap day",
+			freq:     FrequencyYearly,
+			start:    "2024-02-29",
+			after:    "2027-03-01",
+			expected: "2028-02-29",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := NextOccurrence(c.freq, date(c.start), date(c.after))
+			if !actual.Equal(date(c.expected)) {
+				t.Errorf("expected %s, got %s", c.expected, actual.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	start := date("2025-01-10")
+
+	if err := ValidateSchedule(start, nil); err != nil {
+		t.Errorf("unexpected error for open-ended schedule: %v", err)
+	}
+
+	end := date("2025-01-10")
+	if err := ValidateSchedule(start, &end); err != nil {
+		t.Errorf("unexpected error for single occurrence: %v", err)
+	}
+
+	end = date("2025-01-09")
+	if err := ValidateSchedule(start, &end); err == nil {
+		t.Errorf("expected error for schedule ending before it starts")
+	}
+}
+
+func TestParseFrequency(t *testing.T) {
+	for _, s := range []string{"daily", "weekly", "monthly", "yearly"} {
+		if _, err := ParseFrequency(s); err != nil {
+			t.Errorf("unexpected error parsing %s: %v", s, err)
+		}
+	}
+
+	if _, err := ParseFrequency("hourly"); err == nil {
+		t.Errorf("expected error parsing hourly")
+	}
+}
@@ -0,0 +1,247 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// NetBalance is a member's overall position in a group. A positive amount
+// means the rest of the group owes them money.
+type NetBalance struct {
+	User   User            `json:"user"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// SuggestedPayment is a transfer that helps settle up a group.
+type SuggestedPayment struct {
+	From   User            `json:"from"`
+	To     User            `json:"to"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// maxExactSettle is the largest number of unsettled members for which
+// SimplifyDebts searches for the minimal number of payments. Beyond this it
+// falls back to a greedy plan of at most n-1 payments.
+const maxExactSettle = 16
+
+// GetNetBalancesForGroup returns the net balance of every member of a group,
+// along with anyone who has left or been removed with money still owed, so
+// that the balances always sum to zero.
+func GetNetBalancesForGroup(queries *database.Queries, ctx context.Context, groupID uuid.UUID) ([]NetBalance, error) {
+	users, err := queries.GetUsersByGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("coudln't find users in group: %v", err)
+	}
+
+	matrix, err := GetBalanceMatrixForGroup(queries, ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[uuid.UUID]bool, len(users))
+	for _, user := range users {
+		members[user.ID] = true
+	}
+
+	var formerIDs []uuid.UUID
+	for _, id := range matrix.Users() {
+		if !members[id] {
+			formerIDs = append(formerIDs, id)
+		}
+	}
+
+	if len(formerIDs) > 0 {
+		formerUsers, err := queries.GetUsersByIDs(ctx, formerIDs)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't find former members: %v", err)
+		}
+
+		users = append(users, formerUsers...)
+	}
+
+	balances := make([]NetBalance, len(users))
+	for i, user := range users {
+		balances[i] = NetBalance{
+			User:   User{ID: user.ID, Username: user.Username},
+			Amount: matrix.Net(user.ID),
+		}
+	}
+
+	return balances, nil
+}
+
+func GetSettleUpForGroup(queries *database.Queries, ctx context.Context, groupID uuid.UUID) ([]SuggestedPayment, error) {
+	balances, err := GetNetBalancesForGroup(queries, ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return SimplifyDebts(balances), nil
+}
+
+// SimplifyDebts produces a set of payments that brings every balance to
+// zero. Members whose balances cancel each other out are grouped together so
+// that the plan uses as few payments as possible, and within each group the
+// largest debtor pays the largest creditor first. The balances must sum to
+// zero.
+func SimplifyDebts(balances []NetBalance) []SuggestedPayment {
+	var unsettled []NetBalance
+	for _, b := range balances {
+		if !b.Amount.IsZero() {
+			unsettled = append(unsettled, b)
+		}
+	}
+
+	// Sort so that the plan doesn't depend on the order rows came back in.
+	sort.Slice(unsettled, func(i, j int) bool {
+		if cmp := unsettled[i].Amount.Cmp(unsettled[j].Amount); cmp != 0 {
+			return cmp > 0
+		}
+		return unsettled[i].User.Username < unsettled[j].User.Username
+	})
+
+	var payments []SuggestedPayment
+	for _, group := range zeroSumGroups(unsettled) {
+		payments = append(payments, settleGreedy(group)...)
+	}
+
+	return payments
+}
+
+// zeroSumGroups partitions balances into as many groups summing to zero as
+// possible. Settling each group separately takes one payment fewer than it
+// has members, so more groups means fewer payments overall.
+func zeroSumGroups(balances []NetBalance) [][]NetBalance {
+	n := len(balances)
+	if n == 0 {
+		return nil
+	}
+
+	if n > maxExactSettle {
+		return [][]NetBalance{balances}
+	}
+
+	cents := make([]int64, n)
+	for i, b := range balances {
+		cents[i] = b.Amount.Shift(2).IntPart()
+	}
+
+	full := 1<<n - 1
+	sums := make([]int64, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := bits.TrailingZeros(uint(mask))
+		sums[mask] = sums[mask&(mask-1)] + cents[low]
+	}
+
+	// groups[mask] is the most zero-sum groups that the members removed
+	// from mask can be split into while working down to the empty set.
+	groups := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		best := 0
+		for rest := mask; rest != 0; rest &= rest - 1 {
+			i := bits.TrailingZeros(uint(rest))
+			if g := groups[mask&^(1<<i)]; g > best {
+				best = g
+			}
+		}
+
+		if sums[mask] == 0 {
+			best++
+		}
+		groups[mask] = best
+	}
+
+	// Walk back down from the full set, cutting a new group each time the
+	// remaining members sum to zero.
+	var result [][]NetBalance
+	mask, last := full, full
+	for mask != 0 {
+		next := -1
+		for rest := mask; rest != 0; rest &= rest - 1 {
+			i := bits.TrailingZeros(uint(rest))
+			candidate := mask &^ (1 << i)
+
+			want := groups[mask]
+			if sums[mask] == 0 {
+				want--
+			}
+
+			if groups[candidate] == want {
+				next = candidate
+				break
+			}
+		}
+		mask = next
+
+		if sums[mask] == 0 {
+			result = append(result, pick(balances, last&^mask))
+			last = mask
+		}
+	}
+
+	return result
+}
+
+func pick(balances []NetBalance, mask int) []NetBalance {
+	var picked []NetBalance
+	for i, b := range balances {
+		if mask&(1<<i) != 0 {
+			picked = append(picked, b)
+		}
+	}
+	return picked
+}
+
+// settleGreedy repeatedly has the member who owes the most pay the member
+// who is owed the most, which settles n members in at most n-1 payments.
+func settleGreedy(balances []NetBalance) []SuggestedPayment {
+	var creditors, debtors []NetBalance
+	for _, b := range balances {
+		switch {
+		case b.Amount.IsPositive():
+			creditors = append(creditors, b)
+		case b.Amount.IsNegative():
+			debtors = append(debtors, NetBalance{User: b.User, Amount: b.Amount.Neg()})
+		}
+	}
+
+	var payments []SuggestedPayment
+	for len(creditors) > 0 && len(debtors) > 0 {
+		sortBalances(creditors)
+		sortBalances(debtors)
+
+		amount := decimal.Min(creditors[0].Amount, debtors[0].Amount)
+		payments = append(payments, SuggestedPayment{
+			From:   debtors[0].User,
+			To:     creditors[0].User,
+			Amount: amount,
+		})
+
+		creditors[0].Amount = creditors[0].Amount.Sub(amount)
+		debtors[0].Amount = debtors[0].Amount.Sub(amount)
+
+		if creditors[0].Amount.IsZero() {
+			creditors = creditors[1:]
+		}
+		if debtors[0].Amount.IsZero() {
+			debtors = debtors[1:]
+		}
+	}
+
+	return payments
+}
+
+func sortBalances(balances []NetBalance) {
+	sort.SliceStable(balances, func(i, j int) bool {
+		if cmp := balances[i].Amount.Cmp(balances[j].Amount); cmp != 0 {
+			return cmp > 0
+		}
+		return balances[i].User.Username < balances[j].User.Username
+	})
+}
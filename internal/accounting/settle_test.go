@@ -0,0 +1,134 @@
+package accounting
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func balancesFromCents(cents ...int64) []NetBalance {
+	balances := make([]NetBalance, len(cents))
+	for i, c := range cents {
+		balances[i] = NetBalance{
+			User:   User{ID: uuid.New(), Username: string(rune('a' + i))},
+			Amount: decimal.New(c, -2),
+		}
+	}
+	return balances
+}
+
+// settles reports whether applying payments to balances brings every
+// balance to zero.
+func settles(balances []NetBalance, payments []SuggestedPayment) bool {
+	remaining := make(map[uuid.UUID]decimal.Decimal, len(balances))
+	for _, b := range balances {
+		remaining[b.User.ID] = b.Amount
+	}
+
+	for _, p := range payments {
+		if !p.Amount.IsPositive() || p.From.ID == p.To.ID {
+			return false
+		}
+		remaining[p.From.ID] = remaining[p.From.ID].Add(p.Amount)
+		remaining[p.To.ID] = remaining[p.To.ID].Sub(p.Amount)
+	}
+
+	for _, amount := range remaining {
+		if !amount.IsZero() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestSimplifyDebts(t *testing.T) {
+	cases := []struct {
+		name     string
+		cents    []int64
+		expected int
+	}{
+		{
+			name:     "Already settled",
+			cents:    []int64{0, 0, 0},
+			expected: 0,
+		},
+		{
+			name:     "Single debt",
+			cents:    []int64{1000, -1000},
+			expected: 1,
+		},
+		{
+			name:     "One creditor",
+			cents:    []int64{3000, -1000, -1000, -1000},
+			expected: 3,
+		},
+		{
+			name:     "Independent pairs",
+			cents:    []int64{500, 700, -500, -700},
+			expected: 2,
+		},
+		{
+			name:     "Greedy would use an extra payment",
+			cents:    []int64{-800, 600, -200, 300, 400, -300},
+			expected: 4,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			balances := balancesFromCents(c.cents...)
+			payments := SimplifyDebts(balances)
+
+			if len(payments) != c.expected {
+				t.Errorf("SimplifyDebts() returned %d payments, expected %d", len(payments), c.expected)
+			}
+
+			if !settles(balances, payments) {
+				t.Errorf("SimplifyDebts() payments %v don't settle balances", payments)
+			}
+		})
+	}
+}
+
+type zeroSumBalances []NetBalance
+
+func (zeroSumBalances) Generate(r *rand.Rand, size int) reflect.Value {
+	n := 2 + r.Intn(20)
+	cents := make([]int64, n)
+	total := int64(0)
+	for i := 0; i < n-1; i++ {
+		cents[i] = r.Int63n(20_000) - 10_000
+		total += cents[i]
+	}
+	cents[n-1] = -total
+
+	return reflect.ValueOf(zeroSumBalances(balancesFromCents(cents...)))
+}
+
+func TestSimplifyDebtsSettlesEveryone(t *testing.T) {
+	property := func(balances zeroSumBalances) bool {
+		payments := SimplifyDebts(balances)
+
+		nonZero := 0
+		for _, b := range balances {
+			if !b.Amount.IsZero() {
+				nonZero++
+			}
+		}
+
+		if nonZero > 0 && len(payments) > nonZero-1 {
+			return false
+		}
+
+		return settles(balances, payments)
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,128 @@
+package accounting
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type SplitMode string
+
+const (
+	SplitEqual   SplitMode = "equal"
+	SplitExact   SplitMode = "exact"
+	SplitPercent SplitMode = "percent"
+	SplitShares  SplitMode = "shares"
+)
+
+var ErrInvalidSplit = errors.New("invalid split")
+
+// SplitPart is a single member's input to a split. The meaning of Value
+// depends on the split mode: it is ignored for equal splits, an amount for
+// exact splits, a percentage for percent splits and a weight for shares.
+type SplitPart struct {
+	UserID uuid.UUID       `json:"user_id"`
+	Value  decimal.Decimal `json:"value"`
+}
+
+// Share is the portion of an expense that a member is responsible for.
+type Share struct {
+	UserID uuid.UUID       `json:"user_id"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+func ParseSplitMode(s string) (SplitMode, error) {
+	switch mode := SplitMode(s); mode {
+	case SplitEqual, SplitExact, SplitPercent, SplitShares, SplitItems:
+		return mode, nil
+	case "":
+		return SplitEqual, nil
+	default:
+		return "", fmt.Errorf("%w: unknown split mode `%s`", ErrInvalidSplit, s)
+	}
+}
+
+// ComputeShares divides amount between the members in parts according to
+// mode. Every mode allocates whole cents with Allocate, so the shares always
+// sum exactly to amount. It returns an error wrapping ErrInvalidSplit when
+// the parts don't add up to the total.
+func ComputeShares(mode SplitMode, amount decimal.Decimal, parts []SplitPart) ([]Share, error) {
+	if amount.IsNegative() {
+		return nil, fmt.Errorf("%w: amount must not be negative", ErrInvalidSplit)
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: no members to split between", ErrInvalidSplit)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(parts))
+	total := decimal.Zero
+	for _, p := range parts {
+		if seen[p.UserID] {
+			return nil, fmt.Errorf("%w: member listed more than once", ErrInvalidSplit)
+		}
+		seen[p.UserID] = true
+
+		if mode != SplitEqual && p.Value.IsNegative() {
+			return nil, fmt.Errorf("%w: values must not be negative", ErrInvalidSplit)
+		}
+
+		total = total.Add(p.Value)
+	}
+
+	weights := make([]decimal.Decimal, len(parts))
+	userIDs := make([]uuid.UUID, len(parts))
+	for i, p := range parts {
+		userIDs[i] = p.UserID
+	}
+
+	switch mode {
+	case SplitEqual:
+		for i := range parts {
+			weights[i] = decimal.NewFromInt(1)
+		}
+
+	case SplitExact:
+		if !total.Equal(amount) {
+			return nil, fmt.Errorf("%w: amounts add up to %s, expected %s", ErrInvalidSplit, total.String(), amount.String())
+		}
+
+		for i, p := range parts {
+			if !p.Value.Shift(2).IsInteger() {
+				return nil, fmt.Errorf("%w: amount %s has fractional cents", ErrInvalidSplit, p.Value.String())
+			}
+			weights[i] = p.Value
+		}
+
+	case SplitPercent:
+		if !total.Equal(decimal.NewFromInt(100)) {
+			return nil, fmt.Errorf("%w: percentages add up to %s, expected 100", ErrInvalidSplit, total.String())
+		}
+
+		for i, p := range parts {
+			weights[i] = p.Value
+		}
+
+	case SplitShares:
+		for i, p := range parts {
+			weights[i] = p.Value
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: unknown split mode `%s`", ErrInvalidSplit, mode)
+	}
+
+	amounts, err := Allocate(amount, weights, splitSeed(amount, userIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([]Share, len(parts))
+	for i, p := range parts {
+		shares[i] = Share{UserID: p.UserID, Amount: amounts[i]}
+	}
+
+	return shares, nil
+}
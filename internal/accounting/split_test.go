@@ -0,0 +1,161 @@
+package accounting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestComputeShares(t *testing.T) {
+	alice := uuid.New()
+	bob := uuid.New()
+	carol := uuid.New()
+
+	cases := []struct {
+		name        string
+		mode        SplitMode
+		amount      string
+		parts       []SplitPart
+		expectError bool
+		expected    map[uuid.UUID]string
+	}{
+		{
+			name:     "Equal split",
+			mode:     SplitEqual,
+			amount:   "30",
+			parts:    []SplitPart{{UserID: alice}, {UserID: bob}, {UserID: carol}},
+			expected: map[uuid.UUID]string{alice: "10", bob: "10", carol: "10"},
+		},
+		{
+			name:     "Equal split with leftover cents",
+			mode:     SplitEqual,
+			amount:   "0.02",
+			parts:    []SplitPart{{UserID: alice}, {UserID: bob}},
+			expected: map[uuid.UUID]string{alice: "0.01", bob: "0.01"},
+		},
+		{
+			name:   "Exact amounts",
+			mode:   SplitExact,
+			amount: "50",
+			parts: []SplitPart{
+				{UserID: alice, Value: decimal.RequireFromString("20")},
+				{UserID: bob, Value: decimal.RequireFromString("30")},
+			},
+			expected: map[uuid.UUID]string{alice: "20", bob: "30"},
+		},
+		{
+			name:   "Exact amounts not adding up",
+			mode:   SplitExact,
+			amount: "50",
+			parts: []SplitPart{
+				{UserID: alice, Value: decimal.RequireFromString("20")},
+				{UserID: bob, Value: decimal.RequireFromString("20")},
+			},
+			expectError: true,
+		},
+		{
+			name:   "Percentages",
+			mode:   SplitPercent,
+			amount: "80",
+			parts: []SplitPart{
+				{UserID: alice, Value: decimal.RequireFromString("25")},
+				{UserID: bob, Value: decimal.RequireFromString("75")},
+			},
+			expected: map[uuid.UUID]string{alice: "20", bob: "60"},
+		},
+		{
+			name:   "Percentages not adding up",
+			mode:   SplitPercent,
+			amount: "80",
+			parts: []SplitPart{
+				{UserID: alice, Value: decimal.RequireFromString("25")},
+				{UserID: bob, Value: decimal.RequireFromString("50")},
+			},
+			expectError: true,
+		},
+		{
+			name:   "Shares",
+			mode:   SplitShares,
+			amount: "60",
+			parts: []SplitPart{
+				{UserID: alice, Value: decimal.RequireFromString("1")},
+				{UserID: bob, Value: decimal.RequireFromString("2")},
+			},
+			expected: map[uuid.UUID]string{alice: "20", bob: "40"},
+		},
+		{
+			name:   "No shares",
+			mode:   SplitShares,
+			amount: "60",
+			parts: []SplitPart{
+				{UserID: alice, Value: decimal.Zero},
+			},
+			expectError: true,
+		},
+		{
+			name:   "Negative value",
+			mode:   SplitExact,
+			amount: "10",
+			parts: []SplitPart{
+				{UserID: alice, Value: decimal.RequireFromString("20")},
+				{UserID: bob, Value: decimal.RequireFromString("-10")},
+			},
+			expectError: true,
+		},
+		{
+			name:   "Duplicate member",
+			mode:   SplitExact,
+			amount: "10",
+			parts: []SplitPart{
+				{UserID: alice, Value: decimal.RequireFromString("5")},
+				{UserID: alice, Value: decimal.RequireFromString("5")},
+			},
+			expectError: true,
+		},
+		{
+			name:        "No members",
+			mode:        SplitEqual,
+			amount:      "10",
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			shares, err := ComputeShares(c.mode, decimal.RequireFromString(c.amount), c.parts)
+			if (err != nil) != c.expectError {
+				t.Fatalf("ComputeShares() recieved error: %v, expected error: %v", err, c.expectError)
+			}
+
+			if err != nil {
+				if !errors.Is(err, ErrInvalidSplit) {
+					t.Errorf("ComputeShares() error %v does not wrap ErrInvalidSplit", err)
+				}
+				return
+			}
+
+			if len(shares) != len(c.expected) {
+				t.Fatalf("ComputeShares() returned %d shares, expected %d", len(shares), len(c.expected))
+			}
+
+			for _, share := range shares {
+				expected := decimal.RequireFromString(c.expected[share.UserID])
+				if !share.Amount.Equal(expected) {
+					t.Errorf("ComputeShares() share: %v, expected: %v", share.Amount, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSplitMode(t *testing.T) {
+	if mode, err := ParseSplitMode(""); err != nil || mode != SplitEqual {
+		t.Errorf("ParseSplitMode(\"\") = %v, %v, expected %v", mode, err, SplitEqual)
+	}
+
+	if _, err := ParseSplitMode("halves"); err == nil {
+		t.Errorf("ParseSplitMode(\"halves\") expected error")
+	}
+}
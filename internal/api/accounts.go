@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+const (
+	// MaxFailedLogins is how many times in a row a password can be wrong
+	// before the account is locked.
+	MaxFailedLogins = 5
+	// LockoutDuration is how long an account stays locked, unless an admin
+	// unlocks it sooner.
+	LockoutDuration = 15 * time.Minute
+)
+
+var (
+	ErrWrongPassword   = errors.New("wrong password")
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrAccountLocked   = errors.New("account is locked")
+	ErrUserNotFound    = errors.New("user not found")
+	// ErrAdminSelf stops admins from disabling themselves or giving up being
+	// an admin, so that there is always someone left to undo it.
+	ErrAdminSelf = errors.New("admins can't do that to their own account")
+)
+
+// Locked reports whether a user can't log in until a moment after now.
+func Locked(user database.User, now time.Time) bool {
+	return user.LockedUntil.Valid && user.LockedUntil.Time.After(now)
+}
+
+// Login checks a user's password, locking the account after too many wrong
+// ones in a row. Users with two-factor authentication get
+// ErrTwoFactorRequired for the right password, and finish logging in with
+// CompleteLogin.
+func Login(ctx context.Context, queries *database.Queries, user database.User, password string, now time.Time) error {
+	if user.DisabledAt.Valid {
+		return ErrAccountDisabled
+	}
+
+	if Locked(user, now) {
+		return ErrAccountLocked
+	}
+
+	ok, err := auth.CheckPasswordHash(password, user.HashedPassword)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		_, err = queries.RecordFailedLogin(ctx, database.RecordFailedLoginParams{
+			MaxFailedLogins: MaxFailedLogins,
+			LockedUntil:     now.Add(LockoutDuration),
+			ID:              user.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return ErrWrongPassword
+	}
+
+	// Failed logins are only forgotten once the second step is done too, so
+	// that codes can't be guessed by giving the password again and again.
+	twoFactor, err := TwoFactorEnabled(ctx, queries, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if twoFactor {
+		return ErrTwoFactorRequired
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil.Valid {
+		_, err = queries.UnlockUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// userNotFound turns a missing row into ErrUserNotFound.
+func userNotFound(user database.User, err error) (database.User, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// SetDisabled disables or re-enables a user's account for an admin. A
+// disabled user is logged out everywhere and can't log in or use their API
+// tokens.
+func SetDisabled(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, adminID, userID uuid.UUID, disabled bool) (user database.User, err error) {
+	if !disabled {
+		return userNotFound(queries.EnableUser(ctx, userID))
+	}
+
+	if adminID == userID {
+		err = ErrAdminSelf
+		return
+	}
+
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	user, err = userNotFound(queries.DisableUser(ctx, userID))
+	if err != nil {
+		return
+	}
+
+	err = queries.RevokeSessionsByUser(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// SetAdmin makes a user a site admin, or stops them being one.
+func SetAdmin(ctx context.Context, queries *database.Queries, adminID, userID uuid.UUID, isAdmin bool) (database.User, error) {
+	if adminID == userID && !isAdmin {
+		return database.User{}, ErrAdminSelf
+	}
+
+	return userNotFound(queries.SetUserAdmin(ctx, database.SetUserAdminParams{
+		ID:      userID,
+		IsAdmin: isAdmin,
+	}))
+}
+
+// Unlock lets a user who got their password wrong too many times try again
+// straight away.
+func Unlock(ctx context.Context, queries *database.Queries, userID uuid.UUID) (database.User, error) {
+	return userNotFound(queries.UnlockUser(ctx, userID))
+}
+
+// ResetPassword sets a user's password and logs them out everywhere.
+func ResetPassword(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, userID uuid.UUID, hashedPassword string) (err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	err = resetPassword(ctx, queries, userID, hashedPassword)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+func resetPassword(ctx context.Context, queries *database.Queries, userID uuid.UUID, hashedPassword string) error {
+	_, err := userNotFound(queries.UpdatePassword(ctx, database.UpdatePasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	}))
+	if err != nil {
+		return err
+	}
+
+	return queries.RevokeSessionsByUser(ctx, userID)
+}
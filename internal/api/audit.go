@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+// Kinds of entity recorded in the audit log.
+const (
+	AuditTransaction = "transaction"
+	AuditMembership  = "membership"
+	AuditOwnership   = "ownership"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditClaim   = "claim"
+)
+
+// auditEntry is a change to be recorded in the audit log. Before and After
+// are encoded as JSON, with nil for an entity that didn't exist on one side
+// of the change.
+type auditEntry struct {
+	GroupID  uuid.UUID
+	ActorID  uuid.UUID
+	Entity   string
+	EntityID uuid.UUID
+	Action   string
+	Before   any
+	After    any
+}
+
+// recordAudit appends an entry to the audit log. It should be called with
+// the queries of the database transaction making the change, so that the
+// entry is only kept if the change is. A nil ActorID records a change made
+// by the server itself, such as a recurring transaction or a purge.
+func recordAudit(ctx context.Context, queries *database.Queries, entry auditEntry) error {
+	params := database.CreateAuditEntryParams{
+		GroupID:  entry.GroupID,
+		Entity:   entry.Entity,
+		EntityID: entry.EntityID,
+		Action:   entry.Action,
+	}
+
+	if entry.ActorID != uuid.Nil {
+		actor, err := queries.GetUserByID(ctx, entry.ActorID)
+		if err != nil {
+			return err
+		}
+
+		params.ActorID = uuid.NullUUID{UUID: actor.ID, Valid: true}
+		params.ActorName = actor.Username
+	}
+
+	var err error
+	params.Before, err = json.Marshal(entry.Before)
+	if err != nil {
+		return err
+	}
+
+	params.After, err = json.Marshal(entry.After)
+	if err != nil {
+		return err
+	}
+
+	return queries.CreateAuditEntry(ctx, params)
+}
+
+// snapshotTransaction loads a transaction, along with its debts, splits and
+// payers, as it is at this point in the database transaction.
+func snapshotTransaction(ctx context.Context, queries *database.Queries, id uuid.UUID) (*accounting.Transaction, error) {
+	transaction, err := accounting.GetTransaction(queries, ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// membership is how a member of a group is recorded in the audit log.
+type membership struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Role     Role      `json:"role"`
+}
+
+func newMembership(ctx context.Context, queries *database.Queries, userGroup database.UsersGroup) (membership, error) {
+	user, err := queries.GetUserByID(ctx, userGroup.UserID)
+	if err != nil {
+		return membership{}, err
+	}
+
+	return membership{UserID: user.ID, Username: user.Username, Role: Role(userGroup.Role)}, nil
+}
+
+func recordMembership(ctx context.Context, queries *database.Queries, actorID uuid.UUID, userGroup database.UsersGroup, action string) error {
+	m, err := newMembership(ctx, queries, userGroup)
+	if err != nil {
+		return err
+	}
+
+	entry := auditEntry{
+		GroupID:  userGroup.GroupID,
+		ActorID:  actorID,
+		Entity:   AuditMembership,
+		EntityID: userGroup.UserID,
+		Action:   action,
+	}
+
+	if action == AuditDelete {
+		entry.Before = m
+	} else {
+		entry.After = m
+	}
+
+	return recordAudit(ctx, queries, entry)
+}
+
+// recordTransaction records a change to a transaction, taking its state
+// after the change from the database.
+func recordTransaction(ctx context.Context, queries *database.Queries, actorID, groupID, id uuid.UUID, action string, before *accounting.Transaction) error {
+	after, err := snapshotTransaction(ctx, queries, id)
+	if err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, queries, auditEntry{
+		GroupID:  groupID,
+		ActorID:  actorID,
+		Entity:   AuditTransaction,
+		EntityID: id,
+		Action:   action,
+		Before:   before,
+		After:    after,
+	})
+}
+
+// creationActor is who to record as creating a transaction: nobody for
+// occurrences of a recurring transaction, which the server creates itself.
+func creationActor(createdBy uuid.UUID, recurringID uuid.NullUUID) uuid.UUID {
+	if recurringID.Valid {
+		return uuid.Nil
+	}
+
+	return createdBy
+}
@@ -0,0 +1,341 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/matt-horst/split-ways/internal/mail"
+)
+
+// EmailPurpose is what the link in an email is for.
+type EmailPurpose string
+
+const (
+	// PurposeVerify links prove that a user owns their email address.
+	PurposeVerify EmailPurpose = "verify"
+	// PurposeReset links let a user who forgot their password set a new one.
+	PurposeReset EmailPurpose = "reset"
+)
+
+const (
+	// VerifyEmailDuration is how long a link to verify an email address
+	// lasts.
+	VerifyEmailDuration = 48 * time.Hour
+	// PasswordResetDuration is how long a link to reset a password lasts.
+	PasswordResetDuration = time.Hour
+)
+
+var (
+	ErrInvalidEmail      = errors.New("invalid email address")
+	ErrNoEmail           = errors.New("user has no email address")
+	ErrEmailVerified     = errors.New("email address already verified")
+	ErrEmailTaken        = errors.New("email address verified by another user")
+	ErrInvalidEmailToken = errors.New("invalid or expired email link")
+)
+
+// ParseEmail checks that s is a bare email address, like
+// "alice@example.com", returning it in lower case.
+func ParseEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	addr, err := netmail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, s)
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
+
+// CheckEmailAvailable returns ErrEmailTaken if a user other than userID has
+// verified the address email. Unverified addresses don't count, so nobody can
+// keep an address from its owner by adding it and never verifying it.
+func CheckEmailAvailable(ctx context.Context, queries *database.Queries, userID uuid.UUID, email string) error {
+	owner, err := queries.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if owner.ID != userID {
+		return ErrEmailTaken
+	}
+
+	return nil
+}
+
+// SetEmail changes a user's email address, or removes it if email is empty.
+// The new address has to be verified again.
+func SetEmail(ctx context.Context, queries *database.Queries, userID uuid.UUID, email string) (database.User, error) {
+	if email != "" {
+		err := CheckEmailAvailable(ctx, queries, userID, email)
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	return userNotFound(queries.SetUserEmail(ctx, database.SetUserEmailParams{
+		ID:    userID,
+		Email: sql.NullString{String: email, Valid: email != ""},
+	}))
+}
+
+// createEmailToken stores a new single use token for a link sent to email,
+// returning the token itself.
+func createEmailToken(ctx context.Context, queries *database.Queries, userID uuid.UUID, email string, purpose EmailPurpose, expiresAt time.Time) (string, error) {
+	token, err := auth.MakeEmailToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = queries.CreateEmailToken(ctx, database.CreateEmailTokenParams{
+		UserID:    userID,
+		Purpose:   string(purpose),
+		Email:     email,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// SendVerificationEmail emails a user a link to verify their address, which
+// is followed back to the site at baseURL.
+func SendVerificationEmail(ctx context.Context, queries *database.Queries, mailer mail.Mailer, baseURL string, user database.User, now time.Time) error {
+	if !user.Email.Valid {
+		return ErrNoEmail
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		return ErrEmailVerified
+	}
+
+	token, err := createEmailToken(ctx, queries, user.ID, user.Email.String, PurposeVerify, now.Add(VerifyEmailDuration))
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(ctx, mail.Message{
+		To:      user.Email.String,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nFollow this link to verify your email address for SplitWays:\n\n%s/verify-email?token=%s\n\nThe link lasts for %d hours. If you didn't sign up, you can ignore this email.\n",
+			user.Username,
+			baseURL,
+			token,
+			int(VerifyEmailDuration.Hours()),
+		),
+	})
+}
+
+// VerifyEmail uses the token of a verification link, marking the address it
+// was sent to as verified. Links sent to an address the user has since
+// changed no longer work, and ErrEmailTaken is returned if someone else
+// verified the address first. Anyone else who added the address without
+// verifying it loses it.
+func VerifyEmail(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, token string, now time.Time) (user database.User, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	emailToken, err := useEmailToken(ctx, queries, token, PurposeVerify, now)
+	if err != nil {
+		return
+	}
+
+	err = CheckEmailAvailable(ctx, queries, emailToken.UserID, emailToken.Email)
+	if err != nil {
+		return
+	}
+
+	err = queries.ClearUnverifiedEmail(ctx, database.ClearUnverifiedEmailParams{
+		Email: emailToken.Email,
+		ID:    emailToken.UserID,
+	})
+	if err != nil {
+		return
+	}
+
+	user, err = queries.VerifyUserEmail(ctx, database.VerifyUserEmailParams{
+		ID:    emailToken.UserID,
+		Email: emailToken.Email,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrInvalidEmailToken
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// useEmailToken marks a token as used, returning ErrInvalidEmailToken if it
+// was used before, has expired, or never existed.
+func useEmailToken(ctx context.Context, queries *database.Queries, token string, purpose EmailPurpose, now time.Time) (database.EmailToken, error) {
+	emailToken, err := queries.UseEmailToken(ctx, database.UseEmailTokenParams{
+		Now:       now,
+		TokenHash: auth.HashToken(token),
+		Purpose:   string(purpose),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return emailToken, ErrInvalidEmailToken
+	}
+
+	return emailToken, err
+}
+
+// RequestPasswordReset emails a link to reset their password to whoever
+// owns the verified address email. Nothing is sent if nobody does, and no
+// error is returned either, so that the request can't be used to find out
+// who has an account.
+func RequestPasswordReset(ctx context.Context, queries *database.Queries, mailer mail.Mailer, baseURL string, email string, now time.Time) error {
+	email, err := ParseEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := queries.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.EmailVerifiedAt.Valid || user.Placeholder {
+		return nil
+	}
+
+	// Only the newest link works.
+	err = queries.DeleteEmailTokensByUser(ctx, database.DeleteEmailTokensByUserParams{
+		UserID:  user.ID,
+		Purpose: string(PurposeReset),
+	})
+	if err != nil {
+		return err
+	}
+
+	token, err := createEmailToken(ctx, queries, user.ID, email, PurposeReset, now.Add(PasswordResetDuration))
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your SplitWays account. Follow this link to choose a new one:\n\n%s/reset-password?token=%s\n\nThe link can be used once and lasts for %d minutes. If you didn't ask for it, you can ignore this email.\n",
+			user.Username,
+			baseURL,
+			token,
+			int(PasswordResetDuration.Minutes()),
+		),
+	})
+}
+
+// CheckPasswordReset returns ErrInvalidEmailToken unless the token of a
+// password reset link can still be used.
+func CheckPasswordReset(ctx context.Context, queries *database.Queries, token string) error {
+	emailToken, err := queries.GetEmailTokenByHash(ctx, database.GetEmailTokenByHashParams{
+		TokenHash: auth.HashToken(token),
+		Purpose:   string(PurposeReset),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidEmailToken
+	}
+	if err != nil {
+		return err
+	}
+
+	return checkResetEmail(ctx, queries, emailToken)
+}
+
+// checkResetEmail returns ErrInvalidEmailToken unless the address a password
+// reset link was sent to is still the user's verified address, so that a
+// link stops working once the user changes or removes it.
+func checkResetEmail(ctx context.Context, queries *database.Queries, emailToken database.EmailToken) error {
+	user, err := queries.GetUserByID(ctx, emailToken.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidEmailToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.Email.String != emailToken.Email || !user.EmailVerifiedAt.Valid {
+		return ErrInvalidEmailToken
+	}
+
+	return nil
+}
+
+// ResetForgottenPassword uses the token of a password reset link to set the
+// user's password. They are logged out everywhere, and their account is
+// unlocked if they got their password wrong too many times.
+func ResetForgottenPassword(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, token, hashedPassword string, now time.Time) (user database.User, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	emailToken, err := useEmailToken(ctx, queries, token, PurposeReset, now)
+	if err != nil {
+		return
+	}
+
+	err = checkResetEmail(ctx, queries, emailToken)
+	if err != nil {
+		return
+	}
+
+	err = resetPassword(ctx, queries, emailToken.UserID, hashedPassword)
+	if err != nil {
+		return
+	}
+
+	user, err = queries.UnlockUser(ctx, emailToken.UserID)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// ParseExchangeRate validates a rate for converting base into quote.
+func ParseExchangeRate(base, quote, rate string) (database.SetExchangeRateParams, error) {
+	base, err := accounting.ParseCurrency(base)
+	if err != nil {
+		return database.SetExchangeRateParams{}, err
+	}
+
+	quote, err = accounting.ParseCurrency(quote)
+	if err != nil {
+		return database.SetExchangeRateParams{}, err
+	}
+
+	if base == quote {
+		return database.SetExchangeRateParams{}, fmt.Errorf("can't set a rate from %s to itself", base)
+	}
+
+	value, err := decimal.NewFromString(strings.TrimSpace(rate))
+	if err != nil {
+		return database.SetExchangeRateParams{}, fmt.Errorf("couldn't parse rate `%s`: %v", rate, err)
+	}
+
+	if !value.IsPositive() {
+		return database.SetExchangeRateParams{}, fmt.Errorf("rate must be positive, got %s", value)
+	}
+
+	return database.SetExchangeRateParams{Base: base, Quote: quote, Rate: value}, nil
+}
+
+// ImportExchangeRates stores every rate in a CSV file with the columns
+// base, quote and rate, after a header row. Either every rate is stored or
+// none are.
+func ImportExchangeRates(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, r io.Reader) (n int, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	if _, err = reader.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("missing header row")
+		}
+		return
+	}
+
+	for {
+		var record []string
+		record, err = reader.Read()
+		if errors.Is(err, io.EOF) {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+
+		var params database.SetExchangeRateParams
+		params, err = ParseExchangeRate(record[0], record[1], record[2])
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			err = fmt.Errorf("line %d: %w", line, err)
+			return
+		}
+
+		if _, err = queries.SetExchangeRate(ctx, params); err != nil {
+			return
+		}
+
+		n++
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+var ErrExchangeRateInUse = errors.New("exchange rate in use")
+
+// ExchangeRateInUse reports whether any group converts its transactions or
+// recurring transactions with the rate between base and quote, in either
+// direction. Balances are worked out from the stored rates whenever they're
+// needed, rather than the rate on the day, so changing a rate that's in use
+// changes those groups' balances, and groups that had settled up can owe
+// money again.
+func ExchangeRateInUse(ctx context.Context, queries *database.Queries, base, quote string) (bool, error) {
+	pairs, err := queries.GetCurrencyPairsInUse(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, pair := range pairs {
+		// Balances by person are added up in the default currency.
+		for _, target := range []string{pair.GroupCurrency, accounting.DefaultCurrency} {
+			if samePair(pair.Currency, target, base, quote) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// DeleteExchangeRate deletes the rate for converting base into quote, unless
+// a group has transactions or recurring transactions that can't be converted
+// into its currency without it. Transactions in the trash count too, since
+// they can be restored.
+func DeleteExchangeRate(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, base, quote string) (err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	rates, err := accounting.GetExchangeRates(queries, ctx)
+	if err != nil {
+		return
+	}
+
+	pairs, err := queries.GetCurrencyPairsInUse(ctx)
+	if err != nil {
+		return
+	}
+
+	// Balances are only converted with a rate or its inverse, so only the
+	// pair itself can stop working.
+	delete(rates[base], quote)
+
+	for _, pair := range pairs {
+		// Balances by person are added up in the default currency.
+		for _, target := range []string{pair.GroupCurrency, accounting.DefaultCurrency} {
+			if !samePair(pair.Currency, target, base, quote) {
+				continue
+			}
+
+			if !rates.CanConvert(pair.Currency, target) {
+				err = fmt.Errorf("%w: it's needed to convert %s into %s", ErrExchangeRateInUse, pair.Currency, target)
+				return
+			}
+		}
+	}
+
+	err = queries.DeleteExchangeRate(ctx, database.DeleteExchangeRateParams{
+		Base:  base,
+		Quote: quote,
+	})
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// samePair reports whether a and b are the currencies x and y, in either
+// order.
+func samePair(a, b, x, y string) bool {
+	return (a == x && b == y) || (a == y && b == x)
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+type CreateExpenseParams struct {
+	GroupID     uuid.UUID
+	CreatedBy   uuid.UUID
+	Payers      []accounting.Contribution
+	Description string
+	Amount      decimal.Decimal
+	Currency    string
+	SplitMode   accounting.SplitMode
+	Parts       []accounting.SplitPart
+	Shares      []accounting.Share
+	Items       []accounting.Item
+	Charges     []accounting.Charge
+	OccurredOn  sql.NullTime
+	RecurringID uuid.NullUUID
+}
+
+type UpdateExpenseParams struct {
+	TransactionID uuid.UUID
+	ExpenseID     uuid.UUID
+	UpdatedBy     uuid.UUID
+	Payers        []accounting.Contribution
+	Description   string
+	Amount        decimal.Decimal
+	Currency      string
+	SplitMode     accounting.SplitMode
+	Parts         []accounting.SplitPart
+	Shares        []accounting.Share
+	Items         []accounting.Item
+	Charges       []accounting.Charge
+	OccurredOn    sql.NullTime
+}
+
+func CreateExpense(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, params CreateExpenseParams) (expense database.Expense, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	transaction, err := queries.CreateTransaction(ctx, database.CreateTransactionParams{
+		GroupID: params.GroupID,
+		CreatedBy: uuid.NullUUID{
+			UUID:  params.CreatedBy,
+			Valid: params.CreatedBy != uuid.Nil,
+		},
+		Kind:        "expense",
+		OccurredOn:  params.OccurredOn,
+		RecurringID: params.RecurringID,
+	})
+	if err != nil {
+		return
+	}
+
+	expense, err = queries.CreateExpense(ctx, database.CreateExpenseParams{
+		TransactionID: transaction.ID,
+		PaidBy:        primaryPayer(params.Payers),
+		Description:   params.Description,
+		Amount:        params.Amount,
+		SplitMode:     string(params.SplitMode),
+		Currency:      params.Currency,
+	})
+	if err != nil {
+		return
+	}
+
+	err = createSplits(ctx, queries, expense, params.Parts, params.Shares, params.Payers)
+	if err != nil {
+		return
+	}
+
+	err = createItems(ctx, queries, expense, params.Items, params.Charges)
+	if err != nil {
+		return
+	}
+
+	err = recordTransaction(ctx, queries, creationActor(params.CreatedBy, params.RecurringID), params.GroupID, transaction.ID, AuditCreate, nil)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// UpdateExpense replaces the details of an expense along with all of its
+// splits and debts.
+func UpdateExpense(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, params UpdateExpenseParams) (expense database.Expense, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	before, err := snapshotTransaction(ctx, queries, params.TransactionID)
+	if err != nil {
+		return
+	}
+
+	expense, err = queries.UpdateExpense(ctx, database.UpdateExpenseParams{
+		ID:          params.ExpenseID,
+		PaidBy:      primaryPayer(params.Payers),
+		Description: params.Description,
+		Amount:      params.Amount,
+		SplitMode:   string(params.SplitMode),
+		Currency:    params.Currency,
+	})
+	if err != nil {
+		return
+	}
+
+	err = queries.DeleteDebtsByExpense(ctx, expense.ID)
+	if err != nil {
+		return
+	}
+
+	err = queries.DeleteExpenseSplitsByExpense(ctx, expense.ID)
+	if err != nil {
+		return
+	}
+
+	err = queries.DeleteExpensePayersByExpense(ctx, expense.ID)
+	if err != nil {
+		return
+	}
+
+	err = queries.DeleteExpenseItemsByExpense(ctx, expense.ID)
+	if err != nil {
+		return
+	}
+
+	err = createSplits(ctx, queries, expense, params.Parts, params.Shares, params.Payers)
+	if err != nil {
+		return
+	}
+
+	err = createItems(ctx, queries, expense, params.Items, params.Charges)
+	if err != nil {
+		return
+	}
+
+	transaction, err := queries.UpdateTransaction(ctx, database.UpdateTransactionParams{
+		ID:         params.TransactionID,
+		OccurredOn: params.OccurredOn,
+	})
+	if err != nil {
+		return
+	}
+
+	err = recordTransaction(ctx, queries, params.UpdatedBy, transaction.GroupID, transaction.ID, AuditUpdate, before)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// createSplits stores the participants and payers of an expense along with
+// their split inputs and creates the debts that settle each participant's
+// share with the payers.
+func createSplits(ctx context.Context, queries *database.Queries, expense database.Expense, parts []accounting.SplitPart, shares []accounting.Share, payers []accounting.Contribution) error {
+	for _, p := range parts {
+		if _, err := queries.CreateExpenseSplit(
+			ctx,
+			database.CreateExpenseSplitParams{
+				ExpenseID: expense.ID,
+				UserID:    uuid.NullUUID{UUID: p.UserID, Valid: true},
+				Value:     p.Value,
+			},
+		); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range payers {
+		if _, err := queries.CreateExpensePayer(
+			ctx,
+			database.CreateExpensePayerParams{
+				ExpenseID: expense.ID,
+				UserID:    uuid.NullUUID{UUID: p.UserID, Valid: true},
+				Amount:    p.Amount,
+			},
+		); err != nil {
+			return err
+		}
+	}
+
+	debts, err := accounting.ComputeDebts(shares, payers)
+	if err != nil {
+		return err
+	}
+
+	for _, debt := range debts {
+		if _, err := queries.CreateDebt(
+			ctx,
+			database.CreateDebtParams{
+				ExpenseID: expense.ID,
+				OwedTo:    uuid.NullUUID{UUID: debt.OwedTo, Valid: true},
+				OwedBy:    uuid.NullUUID{UUID: debt.OwedBy, Valid: true},
+				Amount:    debt.Amount,
+			},
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createItems stores the line items of an itemized expense along with the
+// members sharing each one, followed by its charges.
+func createItems(ctx context.Context, queries *database.Queries, expense database.Expense, items []accounting.Item, charges []accounting.Charge) error {
+	position := int32(0)
+	for _, item := range items {
+		dbItem, err := queries.CreateExpenseItem(ctx, database.CreateExpenseItemParams{
+			ExpenseID:   expense.ID,
+			Kind:        "item",
+			Description: item.Description,
+			Amount:      item.Amount,
+			Position:    position,
+		})
+		if err != nil {
+			return err
+		}
+		position++
+
+		for _, member := range item.Members {
+			if _, err := queries.CreateExpenseItemMember(ctx, database.CreateExpenseItemMemberParams{
+				ItemID: dbItem.ID,
+				UserID: uuid.NullUUID{UUID: member, Valid: true},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, charge := range charges {
+		if _, err := queries.CreateExpenseItem(ctx, database.CreateExpenseItemParams{
+			ExpenseID: expense.ID,
+			Kind:      string(charge.Kind),
+			Amount:    charge.Amount,
+			Position:  position,
+		}); err != nil {
+			return err
+		}
+		position++
+	}
+
+	return nil
+}
+
+// primaryPayer picks the payer who fronted the most, which is kept on the
+// expense itself for display.
+func primaryPayer(payers []accounting.Contribution) uuid.NullUUID {
+	var primary uuid.NullUUID
+	most := decimal.Zero
+	for _, p := range payers {
+		if !primary.Valid || p.Amount.GreaterThan(most) {
+			primary = uuid.NullUUID{UUID: p.UserID, Valid: true}
+			most = p.Amount
+		}
+	}
+
+	return primary
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+var (
+	ErrAlreadyFriends = errors.New("users are already friends")
+	ErrNotFriends     = errors.New("users are not friends")
+	ErrFriendSelf     = errors.New("users can't be friends with themselves")
+)
+
+// friendshipKey orders a pair of friends the way they are stored, with the
+// lower ID first.
+func friendshipKey(userID, friendID uuid.UUID) database.GetFriendshipParams {
+	if bytes.Compare(userID[:], friendID[:]) > 0 {
+		userID, friendID = friendID, userID
+	}
+
+	return database.GetFriendshipParams{UserID: userID, FriendID: friendID}
+}
+
+// GetFriendship finds the friendship between two users, returning
+// ErrNotFriends if there isn't one.
+func GetFriendship(ctx context.Context, queries *database.Queries, userID, friendID uuid.UUID) (database.Friendship, error) {
+	friendship, err := queries.GetFriendship(ctx, friendshipKey(userID, friendID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return friendship, ErrNotFriends
+	}
+
+	return friendship, err
+}
+
+// AddFriend makes two users friends. Their expenses and payments with each
+// other are kept in a direct group, which works like any other group except
+// that it's hidden from their list of groups and its members can't change.
+func AddFriend(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, user, friend database.User) (friendship database.Friendship, err error) {
+	if user.ID == friend.ID {
+		err = ErrFriendSelf
+		return
+	}
+
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	_, err = GetFriendship(ctx, queries, user.ID, friend.ID)
+	if err == nil {
+		err = ErrAlreadyFriends
+		return
+	}
+	if !errors.Is(err, ErrNotFriends) {
+		return
+	}
+
+	group, err := queries.CreateDirectGroup(ctx, database.CreateDirectGroupParams{
+		Name:     user.Username + " & " + friend.Username,
+		Owner:    user.ID,
+		Currency: accounting.DefaultCurrency,
+	})
+	if err != nil {
+		return
+	}
+
+	for _, id := range []uuid.UUID{user.ID, friend.ID} {
+		var userGroup database.UsersGroup
+		userGroup, err = queries.CreateUserGroup(ctx, database.CreateUserGroupParams{
+			UserID:  id,
+			GroupID: group.ID,
+			Role:    string(RoleAdmin),
+		})
+		if err != nil {
+			return
+		}
+
+		err = recordMembership(ctx, queries, user.ID, userGroup, AuditCreate)
+		if err != nil {
+			return
+		}
+	}
+
+	key := friendshipKey(user.ID, friend.ID)
+	friendship, err = queries.CreateFriendship(ctx, database.CreateFriendshipParams{
+		UserID:   key.UserID,
+		FriendID: key.FriendID,
+		GroupID:  group.ID,
+	})
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// RemoveFriend ends a friendship, deleting its direct group along with every
+// expense and payment in it. That would let either friend wipe out what they
+// owe, so it returns ErrOutstandingBalance until the two are settled up.
+func RemoveFriend(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, userID, friendID uuid.UUID) (err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	friendship, err := GetFriendship(ctx, queries, userID, friendID)
+	if err != nil {
+		return
+	}
+
+	group, err := queries.GetGroup(ctx, friendship.GroupID)
+	if err != nil {
+		return
+	}
+
+	err = checkBalance(ctx, queries, group, userID)
+	if err != nil {
+		return
+	}
+
+	// The friendship goes along with its group.
+	_, err = queries.DeleteGroup(ctx, group.ID)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/accounting"
 	"github.com/matt-horst/split-ways/internal/database"
 )
 
@@ -28,14 +30,20 @@ func CreateGroup(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.
 		return
 	}
 
-	_, err = queries.CreateUserGroup(ctx, database.CreateUserGroupParams{
+	userGroup, err := queries.CreateUserGroup(ctx, database.CreateUserGroupParams{
 		UserID:  group.Owner,
 		GroupID: group.ID,
+		Role:    string(RoleAdmin),
 	})
 	if err != nil {
 		return
 	}
 
+	err = recordMembership(ctx, queries, group.Owner, userGroup, AuditCreate)
+	if err != nil {
+		return
+	}
+
 	if commit {
 		err = tx.Commit()
 	}
@@ -57,3 +65,429 @@ func IsUserInGroup(ctx context.Context, queries *database.Queries, userID, group
 
 	return true, nil
 }
+
+var ErrMemberNameTaken = errors.New("another member of the group has that name")
+
+// checkMemberName returns ErrMemberNameTaken if a member of a group other
+// than userID is called username. Placeholders are only named within their
+// group, so members are looked up by name there rather than across the site.
+func checkMemberName(ctx context.Context, queries *database.Queries, groupID, userID uuid.UUID, username string) error {
+	member, err := queries.GetGroupMemberByUsername(ctx, database.GetGroupMemberByUsernameParams{
+		GroupID:  groupID,
+		Username: username,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if member.ID != userID {
+		return fmt.Errorf("%w: %s", ErrMemberNameTaken, username)
+	}
+
+	return nil
+}
+
+// AddUserToGroup makes a user a member of a group on behalf of actorID. They
+// join as a member unless params gives another role.
+func AddUserToGroup(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, actorID uuid.UUID, params database.CreateUserGroupParams) (userGroup database.UsersGroup, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	if params.Role == "" {
+		params.Role = string(RoleMember)
+	}
+
+	user, err := queries.GetUserByID(ctx, params.UserID)
+	if err != nil {
+		return
+	}
+
+	err = checkMemberName(ctx, queries, params.GroupID, user.ID, user.Username)
+	if err != nil {
+		return
+	}
+
+	userGroup, err = queries.CreateUserGroup(ctx, params)
+	if err != nil {
+		return
+	}
+
+	err = recordMembership(ctx, queries, actorID, userGroup, AuditCreate)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+var ErrRemoveOwner = errors.New("the group owner can't be removed")
+
+// RemoveUserFromGroup removes a user from a group on behalf of actorID. The
+// owner can't be removed, and neither can a member who still owes or is owed
+// money in the group unless force is set.
+func RemoveUserFromGroup(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, actorID uuid.UUID, params database.DeleteUserGroupParams, force bool) (userGroup database.UsersGroup, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	group, err := queries.GetGroup(ctx, params.GroupID)
+	if err != nil {
+		return
+	}
+
+	if group.Owner == params.UserID {
+		err = ErrRemoveOwner
+		return
+	}
+
+	if !force {
+		err = checkBalance(ctx, queries, group, params.UserID)
+		if err != nil {
+			return
+		}
+	}
+
+	userGroup, err = queries.DeleteUserGroup(ctx, params)
+	if err != nil {
+		return
+	}
+
+	err = recordMembership(ctx, queries, actorID, userGroup, AuditDelete)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+var ErrOwnerRole = errors.New("the group owner is always an admin")
+
+// UpdateMemberRole changes the role of a member of a group on behalf of
+// actorID. The owner's role can't be changed.
+func UpdateMemberRole(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, actorID uuid.UUID, params database.UpdateUserGroupRoleParams) (userGroup database.UsersGroup, err error) {
+	role, err := ParseRole(params.Role)
+	if err != nil {
+		return
+	}
+
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	group, err := queries.GetGroup(ctx, params.GroupID)
+	if err != nil {
+		return
+	}
+
+	if group.Owner == params.UserID {
+		err = fmt.Errorf("%w: can't make them %s", ErrOwnerRole, params.Role)
+		return
+	}
+
+	current, err := queries.GetUserGroup(ctx, database.GetUserGroupParams{
+		UserID:  params.UserID,
+		GroupID: params.GroupID,
+	})
+	if err != nil {
+		return
+	}
+
+	userGroup, err = updateRole(ctx, queries, actorID, current, role)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// updateRole changes the role of a member, recording the change if there is
+// one.
+func updateRole(ctx context.Context, queries *database.Queries, actorID uuid.UUID, current database.UsersGroup, role Role) (database.UsersGroup, error) {
+	if Role(current.Role) == role {
+		return current, nil
+	}
+
+	before, err := newMembership(ctx, queries, current)
+	if err != nil {
+		return current, err
+	}
+
+	userGroup, err := queries.UpdateUserGroupRole(ctx, database.UpdateUserGroupRoleParams{
+		GroupID: current.GroupID,
+		UserID:  current.UserID,
+		Role:    string(role),
+	})
+	if err != nil {
+		return current, err
+	}
+
+	after := before
+	after.Role = role
+
+	err = recordAudit(ctx, queries, auditEntry{
+		GroupID:  userGroup.GroupID,
+		ActorID:  actorID,
+		Entity:   AuditMembership,
+		EntityID: userGroup.UserID,
+		Action:   AuditUpdate,
+		Before:   before,
+		After:    after,
+	})
+
+	return userGroup, err
+}
+
+// TransferOwnership makes newOwner, who must already be a member, the owner
+// of a group on behalf of actorID. The new owner becomes an admin if they
+// weren't one already, and the old owner stays on as an admin.
+func TransferOwnership(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, actorID, groupID, newOwner uuid.UUID) (group database.Group, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	group, err = transferOwnership(ctx, queries, actorID, groupID, newOwner)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+func transferOwnership(ctx context.Context, queries *database.Queries, actorID, groupID, newOwner uuid.UUID) (database.Group, error) {
+	group, err := queries.GetGroup(ctx, groupID)
+	if err != nil {
+		return group, err
+	}
+
+	if group.Owner == newOwner {
+		return group, nil
+	}
+
+	oldOwner, err := queries.GetUserGroup(ctx, database.GetUserGroupParams{
+		UserID:  group.Owner,
+		GroupID: groupID,
+	})
+	if err != nil {
+		return group, err
+	}
+
+	successor, err := queries.GetUserGroup(ctx, database.GetUserGroupParams{
+		UserID:  newOwner,
+		GroupID: groupID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return group, fmt.Errorf("%w: can't make them owner", ErrNotMember)
+		}
+
+		return group, err
+	}
+
+	successorUser, err := queries.GetUserByID(ctx, newOwner)
+	if err != nil {
+		return group, err
+	}
+
+	if successorUser.Placeholder {
+		return group, ErrPlaceholderOwner
+	}
+
+	successor, err = updateRole(ctx, queries, actorID, successor, RoleAdmin)
+	if err != nil {
+		return group, err
+	}
+
+	group, err = queries.UpdateGroupOwner(ctx, database.UpdateGroupOwnerParams{
+		ID:    groupID,
+		Owner: newOwner,
+	})
+	if err != nil {
+		return group, err
+	}
+
+	before, err := newMembership(ctx, queries, oldOwner)
+	if err != nil {
+		return group, err
+	}
+
+	after, err := newMembership(ctx, queries, successor)
+	if err != nil {
+		return group, err
+	}
+
+	err = recordAudit(ctx, queries, auditEntry{
+		GroupID:  groupID,
+		ActorID:  actorID,
+		Entity:   AuditOwnership,
+		EntityID: groupID,
+		Action:   AuditUpdate,
+		Before:   before,
+		After:    after,
+	})
+
+	return group, err
+}
+
+var (
+	ErrDirectGroup        = errors.New("members can't leave a direct group")
+	ErrSuccessorRequired  = errors.New("the group owner must choose another member to take over before leaving")
+	ErrOutstandingBalance = errors.New("member still has a balance in the group")
+)
+
+type LeaveGroupParams struct {
+	GroupID uuid.UUID
+	UserID  uuid.UUID
+	// Successor takes over the group when its owner leaves.
+	Successor uuid.NullUUID
+	// Confirm lets a member leave even though they still owe or are owed
+	// money in the group.
+	Confirm bool
+}
+
+// LeaveGroup removes a user from a group at their own request. A member with
+// a balance in the group must confirm that they want to leave, and the owner
+// must hand the group over to a successor.
+func LeaveGroup(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, params LeaveGroupParams) (err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	group, err := queries.GetGroup(ctx, params.GroupID)
+	if err != nil {
+		return
+	}
+
+	if group.Direct {
+		err = ErrDirectGroup
+		return
+	}
+
+	if !params.Confirm {
+		err = checkBalance(ctx, queries, group, params.UserID)
+		if err != nil {
+			return
+		}
+	}
+
+	if group.Owner == params.UserID {
+		if !params.Successor.Valid || params.Successor.UUID == params.UserID {
+			err = ErrSuccessorRequired
+			return
+		}
+
+		_, err = transferOwnership(ctx, queries, params.UserID, group.ID, params.Successor.UUID)
+		if err != nil {
+			return
+		}
+	}
+
+	userGroup, err := queries.DeleteUserGroup(ctx, database.DeleteUserGroupParams{
+		GroupID: params.GroupID,
+		UserID:  params.UserID,
+	})
+	if err != nil {
+		return
+	}
+
+	err = recordMembership(ctx, queries, params.UserID, userGroup, AuditDelete)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// checkBalance returns ErrOutstandingBalance if a user still owes or is owed
+// money by anyone in a group. Debts that happen to cancel out don't count as
+// settled, since they are owed to and by different people.
+func checkBalance(ctx context.Context, queries *database.Queries, group database.Group, userID uuid.UUID) error {
+	matrix, err := accounting.GetBalanceMatrixForGroup(queries, ctx, group.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range matrix.Users() {
+		if other == userID {
+			continue
+		}
+
+		balance := matrix.Between(userID, other)
+		switch {
+		case balance.IsPositive():
+			return fmt.Errorf("%w: they are owed %s", ErrOutstandingBalance, accounting.FormatAmount(group.Currency, balance))
+		case balance.IsNegative():
+			return fmt.Errorf("%w: they owe %s", ErrOutstandingBalance, accounting.FormatAmount(group.Currency, balance.Neg()))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+const (
+	// DefaultInviteDuration is how long an invite lasts unless told otherwise.
+	DefaultInviteDuration = 7 * 24 * time.Hour
+	// MaxInviteDuration is the longest an invite can last.
+	MaxInviteDuration = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrInviteNotFound covers invites that have expired, been revoked or,
+	// for single use invites, already been used.
+	ErrInviteNotFound = errors.New("invite not found")
+	ErrAlreadyMember  = errors.New("user already belongs to group")
+)
+
+// GetInvite finds an invite that can still be accepted.
+func GetInvite(ctx context.Context, queries *database.Queries, inviteID uuid.UUID) (database.GroupInvite, error) {
+	invite, err := queries.GetGroupInvite(ctx, inviteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return invite, ErrInviteNotFound
+		}
+
+		return invite, err
+	}
+
+	if !invite.ExpiresAt.After(time.Now()) {
+		return invite, ErrInviteNotFound
+	}
+
+	return invite, nil
+}
+
+// AcceptInvite adds userID to the invite's group with the invite's role. A
+// single use invite is used up, so nobody else can accept it. An invite for a
+// placeholder member instead has userID take the placeholder's place.
+func AcceptInvite(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, inviteID, userID uuid.UUID) (userGroup database.UsersGroup, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	invite, err := GetInvite(ctx, queries, inviteID)
+	if err != nil {
+		return
+	}
+
+	member, err := IsUserInGroup(ctx, queries, userID, invite.GroupID)
+	if err != nil {
+		return
+	}
+
+	if member {
+		err = ErrAlreadyMember
+		return
+	}
+
+	if invite.PlaceholderID.Valid {
+		// Claiming the placeholder deletes it, and the invite along with it.
+		userGroup, err = claimPlaceholder(ctx, queries, invite.GroupID, invite.PlaceholderID.UUID, userID)
+		if err != nil {
+			return
+		}
+	} else {
+		if invite.SingleUse {
+			// Whoever deletes the invite first gets to use it.
+			_, err = queries.DeleteGroupInvite(ctx, database.DeleteGroupInviteParams{
+				ID:      invite.ID,
+				GroupID: invite.GroupID,
+			})
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					err = ErrInviteNotFound
+				}
+
+				return
+			}
+		}
+
+		userGroup, err = AddUserToGroup(ctx, db, tx, queries, userID, database.CreateUserGroupParams{
+			UserID:  userID,
+			GroupID: invite.GroupID,
+			Role:    invite.Role,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
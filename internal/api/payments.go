@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+type CreatePaymentParams struct {
+	GroupID     uuid.UUID
+	CreatedBy   uuid.UUID
+	PaidBy      uuid.UUID
+	PaidTo      uuid.UUID
+	Amount      decimal.Decimal
+	Currency    string
+	OccurredOn  sql.NullTime
+	RecurringID uuid.NullUUID
+}
+
+type UpdatePaymentParams struct {
+	ID            uuid.UUID
+	TransactionID uuid.UUID
+	UpdatedBy     uuid.UUID
+	PaidBy        uuid.NullUUID
+	PaidTo        uuid.NullUUID
+	Amount        decimal.Decimal
+	Currency      string
+	OccurredOn    sql.NullTime
+}
+
+func CreatePayment(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, params CreatePaymentParams) (payment database.Payment, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	transaction, err := queries.CreateTransaction(ctx, database.CreateTransactionParams{
+		GroupID: params.GroupID,
+		CreatedBy: uuid.NullUUID{
+			UUID:  params.CreatedBy,
+			Valid: params.CreatedBy != uuid.Nil,
+		},
+		Kind:        "payment",
+		OccurredOn:  params.OccurredOn,
+		RecurringID: params.RecurringID,
+	})
+	if err != nil {
+		return
+	}
+
+	payment, err = queries.CreatePayment(ctx, database.CreatePaymentParams{
+		TransactionID: transaction.ID,
+		PaidBy: uuid.NullUUID{
+			UUID:  params.PaidBy,
+			Valid: true,
+		},
+		PaidTo: uuid.NullUUID{
+			UUID:  params.PaidTo,
+			Valid: true,
+		},
+		Amount:   params.Amount,
+		Currency: params.Currency,
+	})
+	if err != nil {
+		return
+	}
+
+	err = recordTransaction(ctx, queries, creationActor(params.CreatedBy, params.RecurringID), params.GroupID, transaction.ID, AuditCreate, nil)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+func UpdatePayment(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, params UpdatePaymentParams) (payment database.Payment, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	before, err := snapshotTransaction(ctx, queries, params.TransactionID)
+	if err != nil {
+		return
+	}
+
+	payment, err = queries.UpdatePayment(ctx, database.UpdatePaymentParams{
+		ID:       params.ID,
+		Amount:   params.Amount,
+		PaidBy:   params.PaidBy,
+		PaidTo:   params.PaidTo,
+		Currency: params.Currency,
+	})
+	if err != nil {
+		return
+	}
+
+	transaction, err := queries.UpdateTransaction(ctx, database.UpdateTransactionParams{
+		ID:         payment.TransactionID,
+		OccurredOn: params.OccurredOn,
+	})
+	if err != nil {
+		return
+	}
+
+	err = recordTransaction(ctx, queries, params.UpdatedBy, transaction.GroupID, transaction.ID, AuditUpdate, before)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+var (
+	ErrNotPlaceholder   = errors.New("user is not a placeholder member")
+	ErrPlaceholderOwner = errors.New("a placeholder member can't own a group")
+)
+
+// AddPlaceholder adds a member without an account to a group on behalf of
+// actorID. Placeholders can't log in, but can take part in transactions
+// until someone claims them.
+func AddPlaceholder(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, actorID, groupID uuid.UUID, name string, role Role) (user database.User, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	user, err = queries.CreatePlaceholderUser(ctx, name)
+	if err != nil {
+		return
+	}
+
+	_, err = AddUserToGroup(ctx, db, tx, queries, actorID, database.CreateUserGroupParams{
+		UserID:  user.ID,
+		GroupID: groupID,
+		Role:    string(role),
+	})
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// claimPlaceholder merges a placeholder member of a group into userID, who
+// takes over their place in the group along with every expense, debt and
+// payment they were part of. The placeholder is then deleted.
+func claimPlaceholder(ctx context.Context, queries *database.Queries, groupID, placeholderID, userID uuid.UUID) (database.UsersGroup, error) {
+	placeholder, err := queries.GetUserByID(ctx, placeholderID)
+	if err != nil {
+		return database.UsersGroup{}, err
+	}
+
+	if !placeholder.Placeholder {
+		return database.UsersGroup{}, ErrNotPlaceholder
+	}
+
+	current, err := queries.GetUserGroup(ctx, database.GetUserGroupParams{
+		UserID:  placeholderID,
+		GroupID: groupID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The placeholder has been removed from the group since.
+			return current, ErrInviteNotFound
+		}
+
+		return current, err
+	}
+
+	user, err := queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return current, err
+	}
+
+	// The user takes the placeholder's place, so only someone else with their
+	// name gets in the way.
+	err = checkMemberName(ctx, queries, groupID, placeholderID, user.Username)
+	if err != nil {
+		return current, err
+	}
+
+	before, err := newMembership(ctx, queries, current)
+	if err != nil {
+		return current, err
+	}
+
+	err = queries.ReassignUser(ctx, database.ReassignUserParams{
+		ToID:   userID,
+		FromID: placeholderID,
+	})
+	if err != nil {
+		return current, err
+	}
+
+	_, err = queries.DeletePlaceholderUser(ctx, placeholderID)
+	if err != nil {
+		return current, err
+	}
+
+	userGroup, err := queries.GetUserGroup(ctx, database.GetUserGroupParams{
+		UserID:  userID,
+		GroupID: groupID,
+	})
+	if err != nil {
+		return userGroup, err
+	}
+
+	after, err := newMembership(ctx, queries, userGroup)
+	if err != nil {
+		return userGroup, err
+	}
+
+	err = recordAudit(ctx, queries, auditEntry{
+		GroupID:  groupID,
+		ActorID:  userID,
+		Entity:   AuditMembership,
+		EntityID: userID,
+		Action:   AuditClaim,
+		Before:   before,
+		After:    after,
+	})
+
+	return userGroup, err
+}
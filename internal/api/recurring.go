@@ -0,0 +1,384 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+type CreateRecurringParams struct {
+	GroupID     uuid.UUID
+	CreatedBy   uuid.UUID
+	Kind        string
+	Frequency   accounting.Frequency
+	StartsOn    time.Time
+	EndsOn      sql.NullTime
+	Description string
+	Amount      decimal.Decimal
+	Currency    string
+	PaidBy      uuid.NullUUID
+	PaidTo      uuid.NullUUID
+	SplitMode   accounting.SplitMode
+	Parts       []accounting.SplitPart
+}
+
+type UpdateRecurringParams struct {
+	ID          uuid.UUID
+	Frequency   accounting.Frequency
+	StartsOn    time.Time
+	EndsOn      sql.NullTime
+	Paused      bool
+	Description string
+	Amount      decimal.Decimal
+	Currency    string
+	PaidBy      uuid.NullUUID
+	PaidTo      uuid.NullUUID
+	SplitMode   accounting.SplitMode
+	Parts       []accounting.SplitPart
+	Today       time.Time
+}
+
+// CreateRecurring stores a schedule along with the members its expenses are
+// split between. Equal splits without any parts are split between whoever is
+// in the group when each occurrence is created.
+func CreateRecurring(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, params CreateRecurringParams) (recurring database.RecurringTransaction, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	recurring, err = queries.CreateRecurringTransaction(ctx, database.CreateRecurringTransactionParams{
+		GroupID: params.GroupID,
+		CreatedBy: uuid.NullUUID{
+			UUID:  params.CreatedBy,
+			Valid: true,
+		},
+		Kind:        params.Kind,
+		Frequency:   string(params.Frequency),
+		StartsOn:    params.StartsOn,
+		EndsOn:      params.EndsOn,
+		Description: params.Description,
+		Amount:      params.Amount,
+		Currency:    params.Currency,
+		PaidBy:      params.PaidBy,
+		PaidTo:      params.PaidTo,
+		SplitMode:   string(params.SplitMode),
+	})
+	if err != nil {
+		return
+	}
+
+	err = createRecurringSplits(ctx, queries, recurring.ID, params.Parts)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// UpdateRecurring replaces the details of a schedule. Occurrences that have
+// already been created are never repeated: the next one is the first date of
+// the new schedule on or after the old next occurrence, and a schedule being
+// resumed skips the dates that passed while it was paused.
+func UpdateRecurring(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, params UpdateRecurringParams) (recurring database.RecurringTransaction, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	current, err := queries.GetRecurringTransaction(ctx, params.ID)
+	if err != nil {
+		return
+	}
+
+	from := current.NextOccurrence
+	if current.Paused && !params.Paused && from.Before(params.Today) {
+		from = params.Today
+	}
+
+	recurring, err = queries.UpdateRecurringTransaction(ctx, database.UpdateRecurringTransactionParams{
+		ID:             params.ID,
+		Frequency:      string(params.Frequency),
+		StartsOn:       params.StartsOn,
+		EndsOn:         params.EndsOn,
+		NextOccurrence: accounting.NextOccurrence(params.Frequency, params.StartsOn, from.AddDate(0, 0, -1)),
+		Paused:         params.Paused,
+		Description:    params.Description,
+		Amount:         params.Amount,
+		Currency:       params.Currency,
+		PaidBy:         params.PaidBy,
+		PaidTo:         params.PaidTo,
+		SplitMode:      string(params.SplitMode),
+	})
+	if err != nil {
+		return
+	}
+
+	err = queries.DeleteRecurringSplitsByRecurring(ctx, recurring.ID)
+	if err != nil {
+		return
+	}
+
+	err = createRecurringSplits(ctx, queries, recurring.ID, params.Parts)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+func createRecurringSplits(ctx context.Context, queries *database.Queries, recurringID uuid.UUID, parts []accounting.SplitPart) error {
+	for _, part := range parts {
+		if _, err := queries.CreateRecurringSplit(ctx, database.CreateRecurringSplitParams{
+			RecurringID: recurringID,
+			UserID: uuid.NullUUID{
+				UUID:  part.UserID,
+				Valid: true,
+			},
+			Value: part.Value,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MaterializeRecurring creates a transaction for every occurrence of every
+// schedule that is due on or before today, returning how many were created.
+// Each occurrence is created in the same database transaction that moves its
+// schedule on to the next date, so running it again, or from another server,
+// never creates an occurrence twice. Schedules that can no longer be created,
+// such as one paid by a member who has left the group, are paused.
+func MaterializeRecurring(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, today time.Time) (n int, err error) {
+	for {
+		created, err := materializeNext(ctx, db, tx, queries, today)
+		if err != nil {
+			return n, err
+		}
+
+		if !created {
+			return n, nil
+		}
+
+		n++
+	}
+}
+
+// materializeNext creates or pauses the next due occurrence, reporting
+// whether there was one.
+func materializeNext(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, today time.Time) (created bool, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	recurring, err := queries.GetDueRecurringTransaction(ctx, today)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		return
+	}
+
+	err = createOccurrence(ctx, tx, queries, recurring)
+	if errors.Is(err, accounting.ErrInvalidSplit) || errors.Is(err, accounting.ErrInvalidSchedule) {
+		log.Printf("Pausing recurring transaction %s: %v\n", recurring.ID, err)
+		err = queries.PauseRecurringTransaction(ctx, recurring.ID)
+	} else if err == nil {
+		err = queries.AdvanceRecurringTransaction(ctx, database.AdvanceRecurringTransactionParams{
+			ID:             recurring.ID,
+			NextOccurrence: accounting.NextOccurrence(accounting.Frequency(recurring.Frequency), recurring.StartsOn, recurring.NextOccurrence),
+		})
+	}
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return true, err
+}
+
+// createOccurrence creates the transaction for the next occurrence of a
+// schedule. Everything is checked before anything is written, so errors
+// wrapping ErrInvalidSplit or ErrInvalidSchedule leave the database as it was.
+func createOccurrence(ctx context.Context, tx *sql.Tx, queries *database.Queries, recurring database.RecurringTransaction) error {
+	occurredOn := sql.NullTime{Time: recurring.NextOccurrence, Valid: true}
+	recurringID := uuid.NullUUID{UUID: recurring.ID, Valid: true}
+
+	paidBy, err := recurringMember(ctx, queries, recurring.GroupID, recurring.PaidBy)
+	if err != nil {
+		return err
+	}
+
+	if recurring.Kind == "payment" {
+		paidTo, err := recurringMember(ctx, queries, recurring.GroupID, recurring.PaidTo)
+		if err != nil {
+			return err
+		}
+
+		_, err = CreatePayment(ctx, nil, tx, queries, CreatePaymentParams{
+			GroupID:     recurring.GroupID,
+			CreatedBy:   recurring.CreatedBy.UUID,
+			PaidBy:      paidBy,
+			PaidTo:      paidTo,
+			Amount:      recurring.Amount,
+			Currency:    recurring.Currency,
+			OccurredOn:  occurredOn,
+			RecurringID: recurringID,
+		})
+		return err
+	}
+
+	mode, err := accounting.ParseSplitMode(recurring.SplitMode)
+	if err != nil {
+		return err
+	}
+
+	parts, err := recurringParts(ctx, queries, recurring, mode)
+	if err != nil {
+		return err
+	}
+
+	shares, err := accounting.ComputeShares(mode, recurring.Amount, parts)
+	if err != nil {
+		return err
+	}
+
+	_, err = CreateExpense(ctx, nil, tx, queries, CreateExpenseParams{
+		GroupID:     recurring.GroupID,
+		CreatedBy:   recurring.CreatedBy.UUID,
+		Payers:      []accounting.Contribution{{UserID: paidBy, Amount: recurring.Amount}},
+		Description: recurring.Description,
+		Amount:      recurring.Amount,
+		Currency:    recurring.Currency,
+		SplitMode:   mode,
+		Parts:       parts,
+		Shares:      shares,
+		OccurredOn:  occurredOn,
+		RecurringID: recurringID,
+	})
+	return err
+}
+
+// recurringMember checks that a user named by a schedule is still in its
+// group.
+func recurringMember(ctx context.Context, queries *database.Queries, groupID uuid.UUID, userID uuid.NullUUID) (uuid.UUID, error) {
+	if !userID.Valid {
+		return uuid.Nil, fmt.Errorf("%w: member no longer exists", accounting.ErrInvalidSchedule)
+	}
+
+	ok, err := IsUserInGroup(ctx, queries, userID.UUID, groupID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !ok {
+		return uuid.Nil, fmt.Errorf("%w: member is no longer in group", accounting.ErrInvalidSchedule)
+	}
+
+	return userID.UUID, nil
+}
+
+// recurringParts rebuilds the split parts of a schedule, using everyone in
+// the group for equal splits that weren't given any.
+func recurringParts(ctx context.Context, queries *database.Queries, recurring database.RecurringTransaction, mode accounting.SplitMode) ([]accounting.SplitPart, error) {
+	splits, err := queries.GetRecurringSplitsByRecurring(ctx, recurring.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(splits) == 0 && mode == accounting.SplitEqual {
+		users, err := queries.GetUsersByGroup(ctx, recurring.GroupID)
+		if err != nil {
+			return nil, err
+		}
+
+		parts := make([]accounting.SplitPart, len(users))
+		for i, u := range users {
+			parts[i] = accounting.SplitPart{UserID: u.ID}
+		}
+
+		return parts, nil
+	}
+
+	parts := make([]accounting.SplitPart, 0, len(splits))
+	for _, split := range splits {
+		userID, err := recurringMember(ctx, queries, recurring.GroupID, split.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, accounting.SplitPart{UserID: userID, Value: split.Value})
+	}
+
+	return parts, nil
+}
+
+// ScheduleRecurring materializes due occurrences straight away and then once
+// every interval until ctx is done.
+func ScheduleRecurring(ctx context.Context, db *sql.DB, queries *database.Queries, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+		n, err := MaterializeRecurring(ctx, db, nil, queries, today)
+		if err != nil {
+			log.Printf("Couldn't create recurring transactions: %v\n", err)
+		} else if n > 0 {
+			log.Printf("Created %d recurring transactions\n", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+// Role is what a member is allowed to do in a group.
+type Role string
+
+const (
+	// RoleAdmin members can do anything in a group except delete it or hand
+	// it over, which are left to its owner.
+	RoleAdmin Role = "admin"
+	// RoleMember members can add transactions and change their own.
+	RoleMember Role = "member"
+	// RoleViewer members can only look at a group.
+	RoleViewer Role = "viewer"
+)
+
+// Roles lists every role, from most to least privileged.
+var Roles = []Role{RoleAdmin, RoleMember, RoleViewer}
+
+var ErrInvalidRole = errors.New("invalid role")
+
+func ParseRole(s string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == s {
+			return role, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrInvalidRole, s)
+}
+
+// Permission is an action in a group that only some members may take.
+type Permission int
+
+const (
+	// PermViewGroup covers the group's pages, transactions, balances,
+	// history and trash.
+	PermViewGroup Permission = iota
+	// PermCreateTransaction covers adding expenses, payments and recurring
+	// schedules.
+	PermCreateTransaction
+	// PermEditOwnTransaction covers editing, deleting and restoring
+	// transactions the member created.
+	PermEditOwnTransaction
+	// PermEditAnyTransaction covers editing, deleting and restoring
+	// transactions created by anyone.
+	PermEditAnyTransaction
+	// PermManageGroup covers renaming the group and changing its currency.
+	PermManageGroup
+	// PermManageMembers covers adding and removing members and changing
+	// their roles.
+	PermManageMembers
+	// PermDeleteGroup covers deleting the group. No role grants it, so only
+	// the owner can.
+	PermDeleteGroup
+	// PermTransferOwnership covers handing the group to another member. No
+	// role grants it, so only the owner can.
+	PermTransferOwnership
+	// PermForceRemoveMember covers removing a member who still has a balance
+	// in the group. No role grants it, so only the owner can.
+	PermForceRemoveMember
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermViewGroup,
+		PermCreateTransaction,
+		PermEditOwnTransaction,
+		PermEditAnyTransaction,
+		PermManageGroup,
+		PermManageMembers,
+	},
+	RoleMember: {
+		PermViewGroup,
+		PermCreateTransaction,
+		PermEditOwnTransaction,
+	},
+	RoleViewer: {
+		PermViewGroup,
+	},
+}
+
+// directPermissions are all anyone has in a direct group, which holds the
+// ledger between two friends. There are no members to manage and nothing to
+// hand over, so not even its owner can do more.
+var directPermissions = []Permission{
+	PermViewGroup,
+	PermCreateTransaction,
+	PermEditOwnTransaction,
+	PermEditAnyTransaction,
+}
+
+var (
+	ErrNotMember = errors.New("user does not belong to group")
+	ErrForbidden = errors.New("user is not allowed to do that")
+)
+
+// Membership is a user's place in a group: their role, and whether they own
+// it. The owner can do everything, unless the group is a direct one.
+type Membership struct {
+	UserID  uuid.UUID
+	GroupID uuid.UUID
+	Role    Role
+	Owner   bool
+	Direct  bool
+}
+
+// Can reports whether the member has permission p.
+func (m Membership) Can(p Permission) bool {
+	permissions := rolePermissions[m.Role]
+
+	switch {
+	case m.Direct:
+		permissions = directPermissions
+	case m.Owner:
+		return true
+	}
+
+	for _, granted := range permissions {
+		if granted == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanEditTransaction reports whether the member may edit, delete or restore
+// a transaction created by createdBy.
+func (m Membership) CanEditTransaction(createdBy uuid.NullUUID) bool {
+	if m.Can(PermEditAnyTransaction) {
+		return true
+	}
+
+	return createdBy.Valid && createdBy.UUID == m.UserID && m.Can(PermEditOwnTransaction)
+}
+
+// GetMembership finds a user's membership of a group, returning ErrNotMember
+// if they don't belong to it.
+func GetMembership(ctx context.Context, queries *database.Queries, userID, groupID uuid.UUID) (Membership, error) {
+	userGroup, err := queries.GetUserGroup(ctx, database.GetUserGroupParams{
+		UserID:  userID,
+		GroupID: groupID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Membership{}, ErrNotMember
+		}
+
+		return Membership{}, err
+	}
+
+	group, err := queries.GetGroup(ctx, groupID)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	return Membership{
+		UserID:  userID,
+		GroupID: groupID,
+		Role:    Role(userGroup.Role),
+		Owner:   group.Owner == userID,
+		Direct:  group.Direct,
+	}, nil
+}
+
+// Authorize checks that a user may take an action in a group, returning
+// ErrNotMember or ErrForbidden if they may not.
+func Authorize(ctx context.Context, queries *database.Queries, userID, groupID uuid.UUID, p Permission) (Membership, error) {
+	m, err := GetMembership(ctx, queries, userID, groupID)
+	if err != nil {
+		return m, err
+	}
+
+	if !m.Can(p) {
+		return m, ErrForbidden
+	}
+
+	return m, nil
+}
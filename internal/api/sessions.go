@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+const (
+	// RefreshTokenDuration is how long a session lasts without being used.
+	RefreshTokenDuration = 30 * 24 * time.Hour
+	// RefreshReuseGrace is how long after a refresh token is used that using
+	// it again is put down to requests racing each other rather than to the
+	// token having been stolen.
+	RefreshReuseGrace = 10 * time.Second
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// Session is a login on one device. Its refresh token can be used once to
+// keep the session going, and is then replaced by a new one.
+type Session struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	RefreshToken string
+}
+
+// StartSession logs a user in, returning their new session.
+func StartSession(ctx context.Context, queries *database.Queries, userID uuid.UUID, now time.Time) (Session, error) {
+	return issueRefreshToken(ctx, queries, userID, uuid.New(), now)
+}
+
+func issueRefreshToken(ctx context.Context, queries *database.Queries, userID, sessionID uuid.UUID, now time.Time) (Session, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return Session{}, err
+	}
+
+	_, err = queries.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: now.Add(RefreshTokenDuration),
+	})
+	if err != nil {
+		return Session{}, err
+	}
+
+	return Session{ID: sessionID, UserID: userID, RefreshToken: token}, nil
+}
+
+// RefreshSession swaps a refresh token for a new one in the same session.
+// Using a token that has already been swapped means it was copied, so the
+// whole session is revoked and ErrRefreshTokenReused is returned.
+func RefreshSession(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, refreshToken string, now time.Time) (session Session, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	hash := auth.HashToken(refreshToken)
+
+	used, err := queries.UseRefreshToken(ctx, database.UseRefreshTokenParams{
+		Now:       now,
+		TokenHash: hash,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		var reused bool
+		reused, err = revokeReusedToken(ctx, queries, hash, now)
+		if err != nil {
+			return
+		}
+
+		if !reused {
+			err = ErrInvalidRefreshToken
+			return
+		}
+
+		if commit {
+			err = tx.Commit()
+			if err != nil {
+				return
+			}
+		}
+
+		err = ErrRefreshTokenReused
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	session, err = issueRefreshToken(ctx, queries, used.UserID, used.SessionID, now)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// revokeReusedToken revokes the session of a refresh token that was used
+// before, unless it was used only a moment ago, reporting whether it did.
+func revokeReusedToken(ctx context.Context, queries *database.Queries, hash string, now time.Time) (bool, error) {
+	token, err := queries.GetRefreshTokenByHash(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !token.UsedAt.Valid || token.RevokedAt.Valid || now.Sub(token.UsedAt.Time) < RefreshReuseGrace {
+		return false, nil
+	}
+
+	log.Printf("Refresh token of session %s reused, revoking it\n", token.SessionID)
+
+	return true, queries.RevokeSession(ctx, token.SessionID)
+}
+
+// EndSession logs out of the session of a refresh token.
+func EndSession(ctx context.Context, queries *database.Queries, refreshToken string) error {
+	token, err := queries.GetRefreshTokenByHash(ctx, auth.HashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return queries.RevokeSession(ctx, token.SessionID)
+}
+
+// SessionActive reports whether a user's session is still going.
+func SessionActive(ctx context.Context, queries *database.Queries, userID, sessionID uuid.UUID) (bool, error) {
+	return queries.IsSessionActive(ctx, database.IsSessionActiveParams{
+		SessionID: sessionID,
+		UserID:    userID,
+	})
+}
+
+// ChangePassword sets a user's password and logs them out everywhere,
+// returning a new session for whoever changed it.
+func ChangePassword(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, userID uuid.UUID, hashedPassword string, now time.Time) (session Session, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	err = resetPassword(ctx, queries, userID, hashedPassword)
+	if err != nil {
+		return
+	}
+
+	session, err = StartSession(ctx, queries, userID, now)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// ScheduleTokenCleanup deletes expired refresh, API and email tokens
+// straight away and then once every interval until ctx is done.
+func ScheduleTokenCleanup(ctx context.Context, queries *database.Queries, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := queries.DeleteExpiredRefreshTokens(ctx, time.Now())
+		if err != nil {
+			log.Printf("Couldn't delete expired refresh tokens: %v\n", err)
+		} else if n > 0 {
+			log.Printf("Deleted %d expired refresh tokens\n", n)
+		}
+
+		n, err = queries.DeleteExpiredAPITokens(ctx, time.Now())
+		if err != nil {
+			log.Printf("Couldn't delete expired API tokens: %v\n", err)
+		} else if n > 0 {
+			log.Printf("Deleted %d expired API tokens\n", n)
+		}
+
+		n, err = queries.DeleteExpiredEmailTokens(ctx, time.Now())
+		if err != nil {
+			log.Printf("Couldn't delete expired email tokens: %v\n", err)
+		} else if n > 0 {
+			log.Printf("Deleted %d expired email tokens\n", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+// TokenScope is what a personal API token is allowed to do.
+type TokenScope string
+
+const (
+	// ScopeRead tokens can only look things up.
+	ScopeRead TokenScope = "read"
+	// ScopeWrite tokens can do anything their user can.
+	ScopeWrite TokenScope = "write"
+)
+
+// TokenScopes lists every scope, from least to most privileged.
+var TokenScopes = []TokenScope{ScopeRead, ScopeWrite}
+
+const (
+	// DefaultTokenDuration is how long an API token lasts unless told
+	// otherwise.
+	DefaultTokenDuration = 30 * 24 * time.Hour
+	// MaxTokenDuration is the longest an API token can last.
+	MaxTokenDuration = 365 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidScope  = errors.New("invalid scope")
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+func ParseTokenScope(s string) (TokenScope, error) {
+	for _, scope := range TokenScopes {
+		if string(scope) == s {
+			return scope, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrInvalidScope, s)
+}
+
+// Allows reports whether a token with the scope may make a request with
+// method.
+func (s TokenScope) Allows(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	default:
+		return s == ScopeWrite
+	}
+}
+
+// CreateAPIToken creates a personal API token for a user, returning it along
+// with the token itself. Only a hash of the token is kept, so it can't be
+// shown again.
+func CreateAPIToken(ctx context.Context, queries *database.Queries, userID uuid.UUID, name string, scope TokenScope, expiresAt time.Time) (database.ApiToken, string, error) {
+	token, err := auth.MakeAPIToken()
+	if err != nil {
+		return database.ApiToken{}, "", err
+	}
+
+	apiToken, err := queries.CreateAPIToken(ctx, database.CreateAPITokenParams{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: auth.HashToken(token),
+		Scope:     string(scope),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return database.ApiToken{}, "", err
+	}
+
+	return apiToken, token, nil
+}
+
+// ValidateAPIToken finds the unexpired API token token, noting that it has
+// been used, or returns ErrTokenNotFound.
+func ValidateAPIToken(ctx context.Context, queries *database.Queries, token string) (database.ApiToken, error) {
+	apiToken, err := queries.GetAPITokenByHash(ctx, auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apiToken, ErrTokenNotFound
+		}
+
+		return apiToken, err
+	}
+
+	err = queries.TouchAPIToken(ctx, apiToken.ID)
+	if err != nil {
+		return apiToken, err
+	}
+
+	return apiToken, nil
+}
+
+// RevokeAPIToken deletes one of a user's API tokens, returning
+// ErrTokenNotFound if they have no such token.
+func RevokeAPIToken(ctx context.Context, queries *database.Queries, userID, tokenID uuid.UUID) error {
+	_, err := queries.DeleteAPIToken(ctx, database.DeleteAPITokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTokenNotFound
+	}
+
+	return err
+}
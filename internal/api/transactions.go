@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+// DeleteTransaction moves a transaction to the trash, where it no longer
+// counts towards balances but can be restored until it is purged.
+func DeleteTransaction(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, id, deletedBy uuid.UUID) (err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	before, err := snapshotTransaction(ctx, queries, id)
+	if err != nil {
+		return
+	}
+
+	err = queries.SoftDeleteTransaction(ctx, id)
+	if err != nil {
+		return
+	}
+
+	transaction, err := queries.GetTransaction(ctx, id)
+	if err != nil {
+		return
+	}
+
+	err = recordTransaction(ctx, queries, deletedBy, transaction.GroupID, id, AuditDelete, before)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// RestoreTransaction takes a transaction back out of the trash.
+func RestoreTransaction(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, id, restoredBy uuid.UUID) (transaction database.Transaction, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	before, err := snapshotTransaction(ctx, queries, id)
+	if err != nil {
+		return
+	}
+
+	transaction, err = queries.RestoreTransaction(ctx, id)
+	if err != nil {
+		return
+	}
+
+	err = recordTransaction(ctx, queries, restoredBy, transaction.GroupID, id, AuditRestore, before)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// PurgeTrash permanently deletes the transactions that were moved to the
+// trash longer than retention before now, returning how many were deleted.
+// Their history is kept, ending with the purge.
+func PurgeTrash(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, retention time.Duration, now time.Time) (n int, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	purged, err := queries.PurgeDeletedTransactions(ctx, now.Add(-retention))
+	if err != nil {
+		return
+	}
+
+	for _, p := range purged {
+		err = recordAudit(ctx, queries, auditEntry{
+			GroupID:  p.GroupID,
+			Entity:   AuditTransaction,
+			EntityID: p.ID,
+			Action:   AuditPurge,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if commit {
+		err = tx.Commit()
+		if err != nil {
+			return
+		}
+	}
+
+	return len(purged), nil
+}
+
+// ScheduleTrashPurge purges the trash straight away and then once every
+// interval until ctx is done.
+func ScheduleTrashPurge(ctx context.Context, db *sql.DB, queries *database.Queries, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := PurgeTrash(ctx, db, nil, queries, retention, time.Now())
+		if err != nil {
+			log.Printf("Couldn't purge deleted transactions: %v\n", err)
+		} else if n > 0 {
+			log.Printf("Purged %d deleted transactions\n", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
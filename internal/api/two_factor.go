@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/matt-horst/split-ways/internal/totp"
+)
+
+const (
+	// TwoFactorIssuer is the name accounts are shown under in authenticator
+	// apps.
+	TwoFactorIssuer = "SplitWays"
+	// RecoveryCodeCount is how many recovery codes a user is given at a time.
+	RecoveryCodeCount = 10
+)
+
+var (
+	ErrTwoFactorRequired   = errors.New("two-factor code required")
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication not enabled")
+	ErrTwoFactorNotStarted = errors.New("two-factor authentication not started")
+	ErrWrongCode           = errors.New("wrong code")
+)
+
+// TwoFactorSetup is what a user needs to add their account to an
+// authenticator app: a URI to show as a QR code, or the secret to type in.
+type TwoFactorSetup struct {
+	Secret string
+	URI    string
+}
+
+// TwoFactorEnabled reports whether a user has to give a code from their
+// authenticator app to log in.
+func TwoFactorEnabled(ctx context.Context, queries *database.Queries, userID uuid.UUID) (bool, error) {
+	credential, err := queries.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return credential.EnabledAt.Valid, nil
+}
+
+// StartTwoFactor makes a new secret for a user to add to their authenticator
+// app, stored encrypted under key. Two-factor authentication isn't turned on
+// until EnableTwoFactor is given a code from the app, so starting again
+// replaces the secret.
+func StartTwoFactor(ctx context.Context, queries *database.Queries, key []byte, user database.User) (TwoFactorSetup, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+
+	encrypted, err := auth.Encrypt(key, secret)
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+
+	_, err = queries.StartTOTPCredential(ctx, database.StartTOTPCredentialParams{
+		UserID:          user.ID,
+		EncryptedSecret: encrypted,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return TwoFactorSetup{}, ErrTwoFactorEnabled
+	}
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+
+	return TwoFactorSetup{
+		Secret: totp.EncodeSecret(secret),
+		URI:    totp.URI(TwoFactorIssuer, user.Username, secret),
+	}, nil
+}
+
+// EnableTwoFactor turns on two-factor authentication once the user shows
+// that their authenticator app has the secret from StartTwoFactor by giving
+// a code from it. It returns the user's recovery codes, which can't be shown
+// again.
+func EnableTwoFactor(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, key []byte, userID uuid.UUID, code string, now time.Time) (codes []string, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	credential, err := queries.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrTwoFactorNotStarted
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if credential.EnabledAt.Valid {
+		err = ErrTwoFactorEnabled
+		return
+	}
+
+	secret, err := auth.Decrypt(key, credential.EncryptedSecret)
+	if err != nil {
+		return
+	}
+
+	counter, ok := totp.Validate(secret, normalizeCode(code), now, 0)
+	if !ok {
+		err = ErrWrongCode
+		return
+	}
+
+	_, err = queries.EnableTOTPCredential(ctx, database.EnableTOTPCredentialParams{
+		UserID:      userID,
+		LastCounter: int64(counter),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrTwoFactorEnabled
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	codes, err = replaceRecoveryCodes(ctx, queries, userID)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// replaceRecoveryCodes gives a user a new set of recovery codes, so the old
+// ones stop working.
+func replaceRecoveryCodes(ctx context.Context, queries *database.Queries, userID uuid.UUID) ([]string, error) {
+	err := queries.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codes[i], err = auth.MakeRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		err = queries.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(normalizeCode(codes[i])),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// normalizeCode lets codes be typed with spaces, and recovery codes without
+// their dash or in upper case.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// useCode checks a code from a user's authenticator app, or one of their
+// recovery codes, using it up so that it can't be used again.
+func useCode(ctx context.Context, queries *database.Queries, key []byte, userID uuid.UUID, code string, now time.Time) (bool, error) {
+	code = normalizeCode(code)
+
+	credential, err := queries.GetTOTPCredential(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !credential.EnabledAt.Valid {
+		return false, ErrTwoFactorNotEnabled
+	}
+
+	if len(code) == totp.Digits && strings.Trim(code, "0123456789") == "" {
+		secret, err := auth.Decrypt(key, credential.EncryptedSecret)
+		if err != nil {
+			return false, err
+		}
+
+		counter, ok := totp.Validate(secret, code, now, uint64(credential.LastCounter))
+		if !ok {
+			return false, nil
+		}
+
+		// Only one request can move the counter on, so a code can't be used
+		// twice even at the same time.
+		_, err = queries.UseTOTPCounter(ctx, database.UseTOTPCounterParams{
+			UserID:      userID,
+			LastCounter: int64(counter),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	_, err = queries.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: auth.HashToken(code),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// CompleteLogin is the second step of logging in for a user with two-factor
+// authentication, after Login has checked their password. Wrong codes count
+// towards locking the account just like wrong passwords.
+func CompleteLogin(ctx context.Context, queries *database.Queries, key []byte, user database.User, code string, now time.Time) error {
+	if user.DisabledAt.Valid {
+		return ErrAccountDisabled
+	}
+
+	if Locked(user, now) {
+		return ErrAccountLocked
+	}
+
+	ok, err := useCode(ctx, queries, key, user.ID, code, now)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		_, err = queries.RecordFailedLogin(ctx, database.RecordFailedLoginParams{
+			MaxFailedLogins: MaxFailedLogins,
+			LockedUntil:     now.Add(LockoutDuration),
+			ID:              user.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return ErrWrongCode
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil.Valid {
+		_, err = queries.UnlockUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes gives a user a new set of recovery codes, if they
+// can give a code from their authenticator app or one of their old recovery
+// codes.
+func RegenerateRecoveryCodes(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, key []byte, userID uuid.UUID, code string, now time.Time) (codes []string, err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	ok, err := useCode(ctx, queries, key, userID, code, now)
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		err = ErrWrongCode
+		return
+	}
+
+	codes, err = replaceRecoveryCodes(ctx, queries, userID)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// DisableTwoFactor turns off two-factor authentication for a user, if they
+// can give a code from their authenticator app or a recovery code.
+func DisableTwoFactor(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, key []byte, userID uuid.UUID, code string, now time.Time) (err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	ok, err := useCode(ctx, queries, key, userID, code, now)
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		err = ErrWrongCode
+		return
+	}
+
+	err = removeTwoFactor(ctx, queries, userID)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+// ResetTwoFactor turns off two-factor authentication for a user who has lost
+// their authenticator app and recovery codes, for an admin.
+func ResetTwoFactor(ctx context.Context, db *sql.DB, tx *sql.Tx, queries *database.Queries, userID uuid.UUID) (err error) {
+	commit := false
+	if tx == nil {
+		tx, err = db.Begin()
+		if err != nil {
+			return
+		}
+		defer tx.Rollback()
+
+		queries = queries.WithTx(tx)
+
+		commit = true
+	}
+
+	enabled, err := TwoFactorEnabled(ctx, queries, userID)
+	if err != nil {
+		return
+	}
+
+	if !enabled {
+		err = ErrTwoFactorNotEnabled
+		return
+	}
+
+	err = removeTwoFactor(ctx, queries, userID)
+	if err != nil {
+		return
+	}
+
+	if commit {
+		err = tx.Commit()
+	}
+
+	return
+}
+
+func removeTwoFactor(ctx context.Context, queries *database.Queries, userID uuid.UUID) error {
+	err := queries.DeleteTOTPCredential(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return queries.DeleteRecoveryCodes(ctx, userID)
+}
@@ -1,8 +1,15 @@
 package auth
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/alexedwards/argon2id"
@@ -11,6 +18,8 @@ import (
 	"github.com/gorilla/sessions"
 )
 
+var ErrNoAuthorization = errors.New("no authorization header")
+
 func HashPassword(password string) (string, error) {
 	return argon2id.CreateHash(password, argon2id.DefaultParams)
 }
@@ -19,7 +28,9 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return argon2id.ComparePasswordAndHash(password, hash)
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+// MakeJWT issues an access token for a user in one of their sessions. It's
+// only accepted while the session hasn't been revoked.
+func MakeJWT(userID, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	now := time.Now()
 
 	token := jwt.NewWithClaims(
@@ -29,31 +40,114 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
 			Subject:   userID.String(),
+			ID:        sessionID.String(),
 		},
 	)
 
 	return token.SignedString([]byte(tokenSecret))
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(
+// ValidateJWT returns the user and session of an unexpired access token.
+func ValidateJWT(tokenString, tokenSecret string) (userID, sessionID uuid.UUID, err error) {
+	claims := jwt.RegisteredClaims{}
+
+	_, err = jwt.ParseWithClaims(
 		tokenString,
-		&jwt.RegisteredClaims{},
+		&claims,
 		func(t *jwt.Token) (any, error) {
 			return []byte(tokenSecret), nil
 		},
 	)
+	if err != nil {
+		return
+	}
 
+	userID, err = uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.UUID{}, err
+		return
 	}
 
-	id, err := token.Claims.GetSubject()
+	sessionID, err = uuid.Parse(claims.ID)
 	if err != nil {
-		return uuid.UUID{}, err
+		userID = uuid.UUID{}
 	}
 
-	return uuid.Parse(id)
+	return
+}
+
+// MakeRefreshToken returns a random token that can be swapped for a new
+// access token.
+func MakeRefreshToken() (string, error) {
+	return randomToken()
+}
+
+// MakeEmailToken returns a random token for a link sent by email, to verify
+// an address or reset a password.
+func MakeEmailToken() (string, error) {
+	return randomToken()
+}
+
+// MakeRecoveryCode returns a random code that can be used once instead of a
+// code from an authenticator app, like "k3jd-9x2q".
+func MakeRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+
+	return code[:4] + "-" + code[4:], nil
+}
+
+// APITokenPrefix starts every personal API token, so they are easy to spot
+// if they leak.
+const APITokenPrefix = "swt_"
+
+// MakeAPIToken returns a random personal API token.
+func MakeAPIToken() (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	return APITokenPrefix + token, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken is how refresh, API and email tokens are stored, so that the
+// tokens can't be used by anyone who reads the database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetAuthorizationToken returns the token of an `Authorization: Bearer`
+// header, or ErrNoAuthorization if there isn't one.
+func GetAuthorizationToken(headers http.Header) (string, error) {
+	header := headers.Get("Authorization")
+	if header == "" {
+		return "", ErrNoAuthorization
+	}
+
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return "", errors.New("malformed authorization header")
+	}
+
+	return strings.TrimSpace(token), nil
 }
 
 func GetBearerToken(store *sessions.CookieStore, r *http.Request) (string, error) {
@@ -74,13 +168,37 @@ func GetBearerToken(store *sessions.CookieStore, r *http.Request) (string, error
 	return token, nil
 }
 
-func SetBearerToken(store *sessions.CookieStore, w http.ResponseWriter, r *http.Request, token string) error {
+// GetRefreshToken returns the refresh token of the session of r.
+func GetRefreshToken(store *sessions.CookieStore, r *http.Request) (string, error) {
+	session, err := store.Get(r, "user-session")
+	if err != nil {
+		return "", err
+	}
+
+	if session.IsNew {
+		return "", errors.New("no session found")
+	}
+
+	token, ok := session.Values["refresh"].(string)
+	if !ok {
+		return "", errors.New("no refresh token found")
+	}
+
+	return token, nil
+}
+
+// SetSessionTokens stores the access and refresh tokens of a session in its
+// cookie.
+func SetSessionTokens(store *sessions.CookieStore, w http.ResponseWriter, r *http.Request, accessToken, refreshToken string) error {
 	session, err := store.Get(r, "user-session")
 	if err != nil {
 		return err
 	}
 
-	session.Values["jwt"] = token
+	session.Values["jwt"] = accessToken
+	session.Values["refresh"] = refreshToken
+	// Starting a session finishes any login waiting for its second step.
+	delete(session.Values, "two-factor")
 	err = session.Save(r, w)
 	if err != nil {
 		return err
@@ -88,3 +206,193 @@ func SetBearerToken(store *sessions.CookieStore, w http.ResponseWriter, r *http.
 
 	return nil
 }
+
+// inviteAudience keeps invite tokens from being mistaken for session tokens.
+const inviteAudience = "invite"
+
+// MakeInviteToken signs the ID of a group invite so it can be shared as a
+// link. The token for an invite is always the same, so it can be shown again
+// later.
+func MakeInviteToken(inviteID uuid.UUID, tokenSecret string, issuedAt, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(
+		jwt.SigningMethodHS256,
+		jwt.RegisteredClaims{
+			Issuer:    "mini-url",
+			Audience:  jwt.ClaimStrings{inviteAudience},
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Subject:   inviteID.String(),
+		},
+	)
+
+	return token.SignedString([]byte(tokenSecret))
+}
+
+// ValidateInviteToken returns the ID of the invite signed by an unexpired
+// invite token.
+func ValidateInviteToken(tokenString, tokenSecret string) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		&jwt.RegisteredClaims{},
+		func(t *jwt.Token) (any, error) {
+			return []byte(tokenSecret), nil
+		},
+		jwt.WithAudience(inviteAudience),
+	)
+
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	id, err := token.Claims.GetSubject()
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return uuid.Parse(id)
+}
+
+// twoFactorAudience keeps tokens for logins waiting on their second step
+// from being mistaken for session tokens.
+const twoFactorAudience = "two-factor"
+
+// MakeTwoFactorToken issues a token for a user who has given the right
+// password, but still has to give a code from their authenticator app.
+func MakeTwoFactorToken(userID uuid.UUID, tokenSecret string, issuedAt, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(
+		jwt.SigningMethodHS256,
+		jwt.RegisteredClaims{
+			Issuer:    "mini-url",
+			Audience:  jwt.ClaimStrings{twoFactorAudience},
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Subject:   userID.String(),
+		},
+	)
+
+	return token.SignedString([]byte(tokenSecret))
+}
+
+// ValidateTwoFactorToken returns the user of an unexpired two-factor token,
+// and when it was issued, to the nearest second.
+func ValidateTwoFactorToken(tokenString, tokenSecret string) (userID uuid.UUID, issuedAt time.Time, err error) {
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		&jwt.RegisteredClaims{},
+		func(t *jwt.Token) (any, error) {
+			return []byte(tokenSecret), nil
+		},
+		jwt.WithAudience(twoFactorAudience),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return
+	}
+
+	iat, err := token.Claims.GetIssuedAt()
+	if err != nil {
+		return
+	}
+	if iat == nil {
+		err = errors.New("two-factor token has no issue time")
+		return
+	}
+
+	id, err := token.Claims.GetSubject()
+	if err != nil {
+		return
+	}
+
+	userID, err = uuid.Parse(id)
+	if err != nil {
+		return
+	}
+
+	return userID, iat.Time, nil
+}
+
+// GetTwoFactorToken returns the two-factor token of the login waiting on its
+// second step in the session of r.
+func GetTwoFactorToken(store *sessions.CookieStore, r *http.Request) (string, error) {
+	session, err := store.Get(r, "user-session")
+	if err != nil {
+		return "", err
+	}
+
+	token, ok := session.Values["two-factor"].(string)
+	if !ok {
+		return "", errors.New("no two-factor token found")
+	}
+
+	return token, nil
+}
+
+// SetTwoFactorToken stores the two-factor token of a login waiting on its
+// second step in the session cookie.
+func SetTwoFactorToken(store *sessions.CookieStore, w http.ResponseWriter, r *http.Request, token string) error {
+	session, err := store.Get(r, "user-session")
+	if err != nil {
+		return err
+	}
+
+	session.Values["two-factor"] = token
+
+	return session.Save(r, w)
+}
+
+// DeriveKey turns a secret of any length into a key for Encrypt.
+func DeriveKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+var ErrDecrypt = errors.New("couldn't decrypt")
+
+// Encrypt seals plaintext with AES-GCM under a 32 byte key, so that secrets
+// which have to be read back, unlike passwords, aren't stored in the clear.
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens ciphertext sealed by Encrypt under the same key, returning
+// ErrDecrypt if it was sealed under another key or has been changed.
+func Decrypt(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
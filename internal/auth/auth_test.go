@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -60,24 +64,28 @@ func TestCheckPasswordHash(t *testing.T) {
 
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
-	shortToken, _ := MakeJWT(userID, "secret", time.Nanosecond)
+	sessionID := uuid.New()
+	validToken, _ := MakeJWT(userID, sessionID, "secret", time.Hour)
+	shortToken, _ := MakeJWT(userID, sessionID, "secret", time.Nanosecond)
+	inviteToken, _ := MakeInviteToken(userID, "secret", time.Now(), time.Now().Add(time.Hour))
 
 	cases := []struct {
-		name           string
-		tokenString    string
-		tokenSecret    string
-		delay          bool
-		expectedUserID uuid.UUID
-		expectError    bool
+		name              string
+		tokenString       string
+		tokenSecret       string
+		delay             bool
+		expectedUserID    uuid.UUID
+		expectedSessionID uuid.UUID
+		expectError       bool
 	}{
 		{
-			name:           "Valid token",
-			tokenString:    validToken,
-			tokenSecret:    "secret",
-			delay:          false,
-			expectedUserID: userID,
-			expectError:    false,
+			name:              "Valid token",
+			tokenString:       validToken,
+			tokenSecret:       "secret",
+			delay:             false,
+			expectedUserID:    userID,
+			expectedSessionID: sessionID,
+			expectError:       false,
 		},
 		{
 			name:           "Invalid secret",
@@ -103,6 +111,14 @@ func TestValidateJWT(t *testing.T) {
 			expectedUserID: uuid.UUID{},
 			expectError:    true,
 		},
+		{
+			name:           "Token without session",
+			tokenString:    inviteToken,
+			tokenSecret:    "secret",
+			delay:          false,
+			expectedUserID: uuid.UUID{},
+			expectError:    true,
+		},
 	}
 
 	for _, c := range cases {
@@ -110,7 +126,7 @@ func TestValidateJWT(t *testing.T) {
 			if c.delay {
 				time.Sleep(time.Millisecond)
 			}
-			userID, err := ValidateJWT(c.tokenString, c.tokenSecret)
+			userID, sessionID, err := ValidateJWT(c.tokenString, c.tokenSecret)
 			if (err != nil) != c.expectError {
 				t.Errorf("ValidateJWT() recieved error = %v, expects error = %v", err, c.expectError)
 			}
@@ -118,6 +134,266 @@ func TestValidateJWT(t *testing.T) {
 			if userID != c.expectedUserID {
 				t.Errorf("ValidateJWT() recieved userID = %v, expects userID = %v", userID, c.expectedUserID)
 			}
+
+			if sessionID != c.expectedSessionID {
+				t.Errorf("ValidateJWT() recieved sessionID = %v, expects sessionID = %v", sessionID, c.expectedSessionID)
+			}
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	token1, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken() recieved error = %v", err)
+	}
+
+	token2, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken() recieved error = %v", err)
+	}
+
+	if token1 == token2 {
+		t.Errorf("MakeRefreshToken() returned %v twice", token1)
+	}
+
+	if HashToken(token1) != HashToken(token1) {
+		t.Errorf("HashToken() isn't deterministic")
+	}
+
+	if HashToken(token1) == token1 || HashToken(token1) == HashToken(token2) {
+		t.Errorf("HashToken() doesn't hide the token")
+	}
+}
+
+func TestValidateInviteToken(t *testing.T) {
+	inviteID := uuid.New()
+	now := time.Now()
+	validToken, _ := MakeInviteToken(inviteID, "secret", now, now.Add(time.Hour))
+	expiredToken, _ := MakeInviteToken(inviteID, "secret", now.Add(-time.Hour), now.Add(-time.Minute))
+	sessionToken, _ := MakeJWT(inviteID, uuid.New(), "secret", time.Hour)
+
+	cases := []struct {
+		name             string
+		tokenString      string
+		tokenSecret      string
+		expectedInviteID uuid.UUID
+		expectError      bool
+	}{
+		{
+			name:             "Valid token",
+			tokenString:      validToken,
+			tokenSecret:      "secret",
+			expectedInviteID: inviteID,
+			expectError:      false,
+		},
+		{
+			name:             "Invalid secret",
+			tokenString:      validToken,
+			tokenSecret:      "invalid secret",
+			expectedInviteID: uuid.UUID{},
+			expectError:      true,
+		},
+		{
+			name:             "Expired token",
+			tokenString:      expiredToken,
+			tokenSecret:      "secret",
+			expectedInviteID: uuid.UUID{},
+			expectError:      true,
+		},
+		{
+			name:             "Session token",
+			tokenString:      sessionToken,
+			tokenSecret:      "secret",
+			expectedInviteID: uuid.UUID{},
+			expectError:      true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			inviteID, err := ValidateInviteToken(c.tokenString, c.tokenSecret)
+			if (err != nil) != c.expectError {
+				t.Errorf("ValidateInviteToken() recieved error = %v, expects error = %v", err, c.expectError)
+			}
+
+			if inviteID != c.expectedInviteID {
+				t.Errorf("ValidateInviteToken() recieved inviteID = %v, expects inviteID = %v", inviteID, c.expectedInviteID)
+			}
+		})
+	}
+
+	again, _ := MakeInviteToken(inviteID, "secret", now, now.Add(time.Hour))
+	if again != validToken {
+		t.Errorf("MakeInviteToken() isn't repeatable")
+	}
+}
+
+func TestGetAuthorizationToken(t *testing.T) {
+	cases := []struct {
+		name          string
+		header        string
+		expectedToken string
+		expectError   bool
+	}{
+		{
+			name:          "Bearer token",
+			header:        "Bearer swt_token",
+			expectedToken: "swt_token",
+			expectError:   false,
+		},
+		{
+			name:          "No header",
+			header:        "",
+			expectedToken: "",
+			expectError:   true,
+		},
+		{
+			name:          "Other scheme",
+			header:        "Basic dXNlcjpwYXNz",
+			expectedToken: "",
+			expectError:   true,
+		},
+		{
+			name:          "Empty token",
+			header:        "Bearer ",
+			expectedToken: "",
+			expectError:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			headers := http.Header{}
+			if c.header != "" {
+				headers.Set("Authorization", c.header)
+			}
+
+			token, err := GetAuthorizationToken(headers)
+			if (err != nil) != c.expectError {
+				t.Errorf("GetAuthorizationToken() recieved error = %v, expects error = %v", err, c.expectError)
+			}
+
+			if token != c.expectedToken {
+				t.Errorf("GetAuthorizationToken() recieved token = %v, expects token = %v", token, c.expectedToken)
+			}
+		})
+	}
+}
+
+func TestValidateTwoFactorToken(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+	validToken, _ := MakeTwoFactorToken(userID, "secret", now, now.Add(time.Minute))
+	expiredToken, _ := MakeTwoFactorToken(userID, "secret", now.Add(-time.Hour), now.Add(-time.Minute))
+	sessionToken, _ := MakeJWT(userID, uuid.New(), "secret", time.Hour)
+
+	cases := []struct {
+		name           string
+		tokenString    string
+		tokenSecret    string
+		expectedUserID uuid.UUID
+		expectError    bool
+	}{
+		{
+			name:           "Valid token",
+			tokenString:    validToken,
+			tokenSecret:    "secret",
+			expectedUserID: userID,
+			expectError:    false,
+		},
+		{
+			name:           "Invalid secret",
+			tokenString:    validToken,
+			tokenSecret:    "invalid secret",
+			expectedUserID: uuid.UUID{},
+			expectError:    true,
+		},
+		{
+			name:           "Expired token",
+			tokenString:    expiredToken,
+			tokenSecret:    "secret",
+			expectedUserID: uuid.UUID{},
+			expectError:    true,
+		},
+		{
+			name:           "Session token",
+			tokenString:    sessionToken,
+			tokenSecret:    "secret",
+			expectedUserID: uuid.UUID{},
+			expectError:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			userID, issuedAt, err := ValidateTwoFactorToken(c.tokenString, c.tokenSecret)
+			if (err != nil) != c.expectError {
+				t.Errorf("ValidateTwoFactorToken() recieved error = %v, expects error = %v", err, c.expectError)
+			}
+
+			if userID != c.expectedUserID {
+				t.Errorf("ValidateTwoFactorToken() recieved userID = %v, expects userID = %v", userID, c.expectedUserID)
+			}
+
+			if !c.expectError && !issuedAt.Equal(now.Truncate(time.Second)) {
+				t.Errorf("ValidateTwoFactorToken() recieved issuedAt = %v, expects issuedAt = %v", issuedAt, now.Truncate(time.Second))
+			}
 		})
 	}
+
+	// A two-factor token is no good as an access token.
+	if _, _, err := ValidateJWT(validToken, "secret"); err == nil {
+		t.Errorf("ValidateJWT() accepted a two-factor token")
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	key := DeriveKey("secret")
+	plaintext := []byte("12345678901234567890")
+
+	ciphertext, err := Encrypt(key, plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt() recieved error = %v", err)
+	}
+
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Errorf("Encrypt() doesn't hide the plaintext")
+	}
+
+	again, _ := Encrypt(key, plaintext)
+	if bytes.Equal(ciphertext, again) {
+		t.Errorf("Encrypt() reuses nonces")
+	}
+
+	decrypted, err := Decrypt(key, ciphertext)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypt() = %q, %v, expects %q", decrypted, err, plaintext)
+	}
+
+	if _, err := Decrypt(DeriveKey("other secret"), ciphertext); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Decrypt() with the wrong key recieved error = %v, expects %v", err, ErrDecrypt)
+	}
+
+	ciphertext[len(ciphertext)-1] ^= 1
+	if _, err := Decrypt(key, ciphertext); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Decrypt() of changed ciphertext recieved error = %v, expects %v", err, ErrDecrypt)
+	}
+}
+
+func TestMakeRecoveryCode(t *testing.T) {
+	code1, err := MakeRecoveryCode()
+	if err != nil {
+		t.Fatalf("MakeRecoveryCode() recieved error = %v", err)
+	}
+
+	code2, _ := MakeRecoveryCode()
+
+	if len(code1) != 9 || code1[4] != '-' || code1 != strings.ToLower(code1) {
+		t.Errorf("MakeRecoveryCode() = %q, expects a code like xxxx-xxxx", code1)
+	}
+
+	if code1 == code2 {
+		t.Errorf("MakeRecoveryCode() isn't random")
+	}
 }
@@ -7,6 +7,10 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 const deleteAllGroups = `-- name: DeleteAllGroups :exec
@@ -26,3 +30,168 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, deleteAllUsers)
 	return err
 }
+
+const getSiteStats = `-- name: GetSiteStats :one
+SELECT
+    (SELECT COUNT(*) FROM users WHERE NOT placeholder) AS users,
+    (SELECT COUNT(*) FROM users WHERE placeholder) AS placeholders,
+    (SELECT COUNT(*) FROM users WHERE is_admin) AS admins,
+    (SELECT COUNT(*) FROM users WHERE disabled_at IS NOT NULL) AS disabled_users,
+    (SELECT COUNT(*) FROM groups WHERE NOT direct) AS groups,
+    (SELECT COUNT(*) FROM friendships) AS friendships,
+    (SELECT COUNT(*) FROM transactions WHERE deleted_at IS NULL) AS transactions,
+    (
+        SELECT COUNT(DISTINCT session_id) FROM refresh_tokens
+        WHERE revoked_at IS NULL AND expires_at > NOW()
+    ) AS active_sessions
+`
+
+type GetSiteStatsRow struct {
+	Users          int64
+	Placeholders   int64
+	Admins         int64
+	DisabledUsers  int64
+	Groups         int64
+	Friendships    int64
+	Transactions   int64
+	ActiveSessions int64
+}
+
+func (q *Queries) GetSiteStats(ctx context.Context) (GetSiteStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getSiteStats)
+	var i GetSiteStatsRow
+	err := row.Scan(
+		&i.Users,
+		&i.Placeholders,
+		&i.Admins,
+		&i.DisabledUsers,
+		&i.Groups,
+		&i.Friendships,
+		&i.Transactions,
+		&i.ActiveSessions,
+	)
+	return i, err
+}
+
+const listGroups = `-- name: ListGroups :many
+SELECT groups.id, groups.name, groups.created_at, groups.updated_at, groups.owner, groups.currency, groups.direct, owners.username AS owner_name, COUNT(users_groups.id) AS member_count
+FROM groups
+JOIN users owners ON owners.id = groups.owner
+LEFT JOIN users_groups ON users_groups.group_id = groups.id
+WHERE NOT groups.direct
+GROUP BY groups.id, owners.username
+ORDER BY groups.created_at
+`
+
+type ListGroupsRow struct {
+	ID          uuid.UUID
+	Name        string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Owner       uuid.UUID
+	Currency    string
+	Direct      bool
+	OwnerName   string
+	MemberCount int64
+}
+
+func (q *Queries) ListGroups(ctx context.Context) ([]ListGroupsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listGroups)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListGroupsRow
+	for rows.Next() {
+		var i ListGroupsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Owner,
+			&i.Currency,
+			&i.Direct,
+			&i.OwnerName,
+			&i.MemberCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT users.id, users.username, users.hashed_password, users.created_at, users.updated_at, users.placeholder, users.is_admin, users.disabled_at, users.failed_logins, users.locked_until, users.email, users.email_verified_at, users.password_changed_at, COUNT(users_groups.id) AS group_count, EXISTS (
+    SELECT 1 FROM totp_credentials
+    WHERE totp_credentials.user_id = users.id AND totp_credentials.enabled_at IS NOT NULL
+) AS two_factor
+FROM users
+LEFT JOIN users_groups ON users_groups.user_id = users.id
+GROUP BY users.id
+ORDER BY users.username
+`
+
+type ListUsersRow struct {
+	ID                uuid.UUID
+	Username          string
+	HashedPassword    string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Placeholder       bool
+	IsAdmin           bool
+	DisabledAt        sql.NullTime
+	FailedLogins      int32
+	LockedUntil       sql.NullTime
+	Email             sql.NullString
+	EmailVerifiedAt   sql.NullTime
+	PasswordChangedAt time.Time
+	GroupCount        int64
+	TwoFactor         bool
+}
+
+func (q *Queries) ListUsers(ctx context.Context) ([]ListUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersRow
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.HashedPassword,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Placeholder,
+			&i.IsAdmin,
+			&i.DisabledAt,
+			&i.FailedLogins,
+			&i.LockedUntil,
+			&i.Email,
+			&i.EmailVerifiedAt,
+			&i.PasswordChangedAt,
+			&i.GroupCount,
+			&i.TwoFactor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
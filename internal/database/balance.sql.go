@@ -9,9 +9,43 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
+const getCurrenciesByGroup = `-- name: GetCurrenciesByGroup :many
+SELECT expenses.currency FROM transactions
+INNER JOIN expenses ON transactions.id = expenses.transaction_id
+WHERE transactions.group_id = $1
+UNION
+SELECT payments.currency FROM transactions
+INNER JOIN payments ON transactions.id = payments.transaction_id
+WHERE transactions.group_id = $1
+`
+
+func (q *Queries) GetCurrenciesByGroup(ctx context.Context, groupID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getCurrenciesByGroup, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return nil, err
+		}
+		items = append(items, currency)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getDebtsByTransaction = `-- name: GetDebtsByTransaction :many
 SELECT debts.id, debts.expense_id, debts.owed_by, debts.owed_to, debts.amount FROM expenses
 INNER JOIN debts ON expenses.id = debts.expense_id
@@ -47,8 +81,43 @@ func (q *Queries) GetDebtsByTransaction(ctx context.Context, transactionID uuid.
 	return items, nil
 }
 
+const getDebtsByTransactions = `-- name: GetDebtsByTransactions :many
+SELECT debts.id, debts.expense_id, debts.owed_by, debts.owed_to, debts.amount FROM expenses
+INNER JOIN debts ON expenses.id = debts.expense_id
+WHERE expenses.transaction_id = ANY($1::UUID[])
+`
+
+func (q *Queries) GetDebtsByTransactions(ctx context.Context, transactionIds []uuid.UUID) ([]Debt, error) {
+	rows, err := q.db.QueryContext(ctx, getDebtsByTransactions, pq.Array(transactionIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Debt
+	for rows.Next() {
+		var i Debt
+		if err := rows.Scan(
+			&i.ID,
+			&i.ExpenseID,
+			&i.OwedBy,
+			&i.OwedTo,
+			&i.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getExpenseByTransaction = `-- name: GetExpenseByTransaction :one
-SELECT id, paid_by, description, transaction_id, amount FROM expenses
+SELECT id, paid_by, description, transaction_id, amount, split_mode, currency FROM expenses
 WHERE expenses.transaction_id = $1
 `
 
@@ -61,88 +130,268 @@ func (q *Queries) GetExpenseByTransaction(ctx context.Context, transactionID uui
 		&i.Description,
 		&i.TransactionID,
 		&i.Amount,
+		&i.SplitMode,
+		&i.Currency,
 	)
 	return i, err
 }
 
-const getPaymentByTransaction = `-- name: GetPaymentByTransaction :one
-SELECT payments.id, payments.paid_by, payments.paid_to, payments.amount, payments.transaction_id FROM payments
-WHERE payments.transaction_id = $1
+const getExpensePayersByTransactions = `-- name: GetExpensePayersByTransactions :many
+SELECT expense_payers.id, expense_payers.expense_id, expense_payers.user_id, expense_payers.amount FROM expenses
+INNER JOIN expense_payers ON expenses.id = expense_payers.expense_id
+WHERE expenses.transaction_id = ANY($1::UUID[])
 `
 
-func (q *Queries) GetPaymentByTransaction(ctx context.Context, transactionID uuid.UUID) (Payment, error) {
-	row := q.db.QueryRowContext(ctx, getPaymentByTransaction, transactionID)
-	var i Payment
-	err := row.Scan(
-		&i.ID,
-		&i.PaidBy,
-		&i.PaidTo,
-		&i.Amount,
-		&i.TransactionID,
-	)
-	return i, err
+func (q *Queries) GetExpensePayersByTransactions(ctx context.Context, transactionIds []uuid.UUID) ([]ExpensePayer, error) {
+	rows, err := q.db.QueryContext(ctx, getExpensePayersByTransactions, pq.Array(transactionIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpensePayer
+	for rows.Next() {
+		var i ExpensePayer
+		if err := rows.Scan(
+			&i.ID,
+			&i.ExpenseID,
+			&i.UserID,
+			&i.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getSumOfDebts = `-- name: GetSumOfDebts :one
-SELECT CAST(COALESCE(SUM(debts.amount), 0) AS NUMERIC(12, 2)) AS total FROM transactions
-INNER JOIN expenses ON transactions.id = expenses.transaction_id
-INNER JOIN debts ON expenses.id = debts.expense_id
-WHERE transactions.group_id = $1 AND debts.owed_by = $2 AND debts.owed_to = $3
+const getExpenseSplitsByTransactions = `-- name: GetExpenseSplitsByTransactions :many
+SELECT expense_splits.id, expense_splits.expense_id, expense_splits.user_id, expense_splits.value FROM expenses
+INNER JOIN expense_splits ON expenses.id = expense_splits.expense_id
+WHERE expenses.transaction_id = ANY($1::UUID[])
+`
+
+func (q *Queries) GetExpenseSplitsByTransactions(ctx context.Context, transactionIds []uuid.UUID) ([]ExpenseSplit, error) {
+	rows, err := q.db.QueryContext(ctx, getExpenseSplitsByTransactions, pq.Array(transactionIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpenseSplit
+	for rows.Next() {
+		var i ExpenseSplit
+		if err := rows.Scan(
+			&i.ID,
+			&i.ExpenseID,
+			&i.UserID,
+			&i.Value,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpensesByTransactions = `-- name: GetExpensesByTransactions :many
+SELECT id, paid_by, description, transaction_id, amount, split_mode, currency FROM expenses
+WHERE expenses.transaction_id = ANY($1::UUID[])
+`
+
+func (q *Queries) GetExpensesByTransactions(ctx context.Context, transactionIds []uuid.UUID) ([]Expense, error) {
+	rows, err := q.db.QueryContext(ctx, getExpensesByTransactions, pq.Array(transactionIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Expense
+	for rows.Next() {
+		var i Expense
+		if err := rows.Scan(
+			&i.ID,
+			&i.PaidBy,
+			&i.Description,
+			&i.TransactionID,
+			&i.Amount,
+			&i.SplitMode,
+			&i.Currency,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPairwiseBalancesByGroup = `-- name: GetPairwiseBalancesByGroup :many
+SELECT ledger.creditor, ledger.debtor, ledger.currency, CAST(SUM(ledger.amount) AS NUMERIC(12, 2)) AS total FROM (
+    SELECT debts.owed_to AS creditor, debts.owed_by AS debtor, expenses.currency, debts.amount FROM transactions
+    INNER JOIN expenses ON transactions.id = expenses.transaction_id
+    INNER JOIN debts ON expenses.id = debts.expense_id
+    WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
+    UNION ALL
+    SELECT payments.paid_by AS creditor, payments.paid_to AS debtor, payments.currency, payments.amount FROM transactions
+    INNER JOIN payments ON transactions.id = payments.transaction_id
+    WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
+) AS ledger
+WHERE ledger.creditor IS NOT NULL AND ledger.debtor IS NOT NULL
+GROUP BY ledger.creditor, ledger.debtor, ledger.currency
 `
 
-type GetSumOfDebtsParams struct {
-	GroupID uuid.UUID
-	OwedBy  uuid.NullUUID
-	OwedTo  uuid.NullUUID
+type GetPairwiseBalancesByGroupRow struct {
+	Creditor uuid.NullUUID
+	Debtor   uuid.NullUUID
+	Currency string
+	Total    decimal.Decimal
 }
 
-func (q *Queries) GetSumOfDebts(ctx context.Context, arg GetSumOfDebtsParams) (decimal.Decimal, error) {
-	row := q.db.QueryRowContext(ctx, getSumOfDebts, arg.GroupID, arg.OwedBy, arg.OwedTo)
-	var total decimal.Decimal
-	err := row.Scan(&total)
-	return total, err
+func (q *Queries) GetPairwiseBalancesByGroup(ctx context.Context, groupID uuid.UUID) ([]GetPairwiseBalancesByGroupRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPairwiseBalancesByGroup, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPairwiseBalancesByGroupRow
+	for rows.Next() {
+		var i GetPairwiseBalancesByGroupRow
+		if err := rows.Scan(
+			&i.Creditor,
+			&i.Debtor,
+			&i.Currency,
+			&i.Total,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getSumOfPayments = `-- name: GetSumOfPayments :one
-SELECT CAST(COALESCE(SUM(payments.amount), 0) AS NUMERIC(12, 2)) AS total FROM transactions
-INNER JOIN payments ON transactions.id = payments.transaction_id
-WHERE transactions.group_id = $1 AND payments.paid_by = $2 AND payments.paid_to = $3
+const getPairwiseBalancesByUser = `-- name: GetPairwiseBalancesByUser :many
+SELECT ledger.creditor, ledger.debtor, ledger.currency, CAST(SUM(ledger.amount) AS NUMERIC(12, 2)) AS total FROM (
+    SELECT debts.owed_to AS creditor, debts.owed_by AS debtor, expenses.currency, debts.amount FROM transactions
+    INNER JOIN expenses ON transactions.id = expenses.transaction_id
+    INNER JOIN debts ON expenses.id = debts.expense_id
+    WHERE transactions.deleted_at IS NULL
+    AND (debts.owed_to = $1::UUID OR debts.owed_by = $1::UUID)
+    UNION ALL
+    SELECT payments.paid_by AS creditor, payments.paid_to AS debtor, payments.currency, payments.amount FROM transactions
+    INNER JOIN payments ON transactions.id = payments.transaction_id
+    WHERE transactions.deleted_at IS NULL
+    AND (payments.paid_by = $1::UUID OR payments.paid_to = $1::UUID)
+) AS ledger
+WHERE ledger.creditor IS NOT NULL AND ledger.debtor IS NOT NULL
+GROUP BY ledger.creditor, ledger.debtor, ledger.currency
 `
 
-type GetSumOfPaymentsParams struct {
-	GroupID uuid.UUID
-	PaidBy  uuid.NullUUID
-	PaidTo  uuid.NullUUID
+type GetPairwiseBalancesByUserRow struct {
+	Creditor uuid.NullUUID
+	Debtor   uuid.NullUUID
+	Currency string
+	Total    decimal.Decimal
 }
 
-func (q *Queries) GetSumOfPayments(ctx context.Context, arg GetSumOfPaymentsParams) (decimal.Decimal, error) {
-	row := q.db.QueryRowContext(ctx, getSumOfPayments, arg.GroupID, arg.PaidBy, arg.PaidTo)
-	var total decimal.Decimal
-	err := row.Scan(&total)
-	return total, err
+func (q *Queries) GetPairwiseBalancesByUser(ctx context.Context, userID uuid.UUID) ([]GetPairwiseBalancesByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPairwiseBalancesByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPairwiseBalancesByUserRow
+	for rows.Next() {
+		var i GetPairwiseBalancesByUserRow
+		if err := rows.Scan(
+			&i.Creditor,
+			&i.Debtor,
+			&i.Currency,
+			&i.Total,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getUserBalanceByGroup = `-- name: GetUserBalanceByGroup :one
-SELECT SUM(payments.amount) - SUM(debts.amount) AS balance FROM transactions
-INNER JOIN expenses ON transactions.id = expenses.transaction_id
-INNER JOIN debts ON expenses.id = debts.expense_id
-INNER JOIN payments ON transactions.id = payments.transaction_id
-WHERE transactions.group_id = $1
-AND debts.owed_to = $3
-AND debts.owed_by = $2
-AND payments.paid_to = $3
-AND payments.paid_by = $2
+const getPaymentByTransaction = `-- name: GetPaymentByTransaction :one
+SELECT payments.id, payments.paid_by, payments.paid_to, payments.amount, payments.transaction_id, payments.currency FROM payments
+WHERE payments.transaction_id = $1
 `
 
-type GetUserBalanceByGroupParams struct {
-	GroupID uuid.UUID
-	OwedBy  uuid.NullUUID
-	OwedTo  uuid.NullUUID
+func (q *Queries) GetPaymentByTransaction(ctx context.Context, transactionID uuid.UUID) (Payment, error) {
+	row := q.db.QueryRowContext(ctx, getPaymentByTransaction, transactionID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.PaidBy,
+		&i.PaidTo,
+		&i.Amount,
+		&i.TransactionID,
+		&i.Currency,
+	)
+	return i, err
 }
 
-func (q *Queries) GetUserBalanceByGroup(ctx context.Context, arg GetUserBalanceByGroupParams) (int32, error) {
-	row := q.db.QueryRowContext(ctx, getUserBalanceByGroup, arg.GroupID, arg.OwedBy, arg.OwedTo)
-	var balance int32
-	err := row.Scan(&balance)
-	return balance, err
+const getPaymentsByTransactions = `-- name: GetPaymentsByTransactions :many
+SELECT payments.id, payments.paid_by, payments.paid_to, payments.amount, payments.transaction_id, payments.currency FROM payments
+WHERE payments.transaction_id = ANY($1::UUID[])
+`
+
+func (q *Queries) GetPaymentsByTransactions(ctx context.Context, transactionIds []uuid.UUID) ([]Payment, error) {
+	rows, err := q.db.QueryContext(ctx, getPaymentsByTransactions, pq.Array(transactionIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Payment
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PaidBy,
+			&i.PaidTo,
+			&i.Amount,
+			&i.TransactionID,
+			&i.Currency,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
@@ -7,24 +7,53 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const createDirectGroup = `-- name: CreateDirectGroup :one
+INSERT INTO groups (name, owner, currency, direct)
+VALUES ($1, $2, $3, TRUE)
+RETURNING id, name, created_at, updated_at, owner, currency, direct
+`
+
+type CreateDirectGroupParams struct {
+	Name     string
+	Owner    uuid.UUID
+	Currency string
+}
+
+func (q *Queries) CreateDirectGroup(ctx context.Context, arg CreateDirectGroupParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, createDirectGroup, arg.Name, arg.Owner, arg.Currency)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Owner,
+		&i.Currency,
+		&i.Direct,
+	)
+	return i, err
+}
+
 const createGroup = `-- name: CreateGroup :one
-INSERT INTO groups (name, owner)
-VALUES ($1, $2)
-RETURNING id, name, created_at, updated_at, owner
+INSERT INTO groups (name, owner, currency)
+VALUES ($1, $2, $3)
+RETURNING id, name, created_at, updated_at, owner, currency, direct
 `
 
 type CreateGroupParams struct {
-	Name  string
-	Owner uuid.UUID
+	Name     string
+	Owner    uuid.UUID
+	Currency string
 }
 
 func (q *Queries) CreateGroup(ctx context.Context, arg CreateGroupParams) (Group, error) {
-	row := q.db.QueryRowContext(ctx, createGroup, arg.Name, arg.Owner)
+	row := q.db.QueryRowContext(ctx, createGroup, arg.Name, arg.Owner, arg.Currency)
 	var i Group
 	err := row.Scan(
 		&i.ID,
@@ -32,6 +61,8 @@ func (q *Queries) CreateGroup(ctx context.Context, arg CreateGroupParams) (Group
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Owner,
+		&i.Currency,
+		&i.Direct,
 	)
 	return i, err
 }
@@ -39,7 +70,7 @@ func (q *Queries) CreateGroup(ctx context.Context, arg CreateGroupParams) (Group
 const deleteGroup = `-- name: DeleteGroup :one
 DELETE FROM groups
 WHERE id = $1
-RETURNING id, name, created_at, updated_at, owner
+RETURNING id, name, created_at, updated_at, owner, currency, direct
 `
 
 func (q *Queries) DeleteGroup(ctx context.Context, id uuid.UUID) (Group, error) {
@@ -51,12 +82,14 @@ func (q *Queries) DeleteGroup(ctx context.Context, id uuid.UUID) (Group, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Owner,
+		&i.Currency,
+		&i.Direct,
 	)
 	return i, err
 }
 
 const getGroup = `-- name: GetGroup :one
-SELECT id, name, created_at, updated_at, owner FROM groups
+SELECT id, name, created_at, updated_at, owner, currency, direct FROM groups
 WHERE id = $1
 `
 
@@ -69,14 +102,48 @@ func (q *Queries) GetGroup(ctx context.Context, id uuid.UUID) (Group, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Owner,
+		&i.Currency,
+		&i.Direct,
+	)
+	return i, err
+}
+
+const getGroupMemberByUsername = `-- name: GetGroupMemberByUsername :one
+SELECT users.id, users.username, users.hashed_password, users.created_at, users.updated_at, users.placeholder, users.is_admin, users.disabled_at, users.failed_logins, users.locked_until, users.email, users.email_verified_at, users.password_changed_at FROM users
+INNER JOIN users_groups ON users.id = users_groups.user_id
+WHERE users_groups.group_id = $1 AND users.username = $2
+`
+
+type GetGroupMemberByUsernameParams struct {
+	GroupID  uuid.UUID
+	Username string
+}
+
+func (q *Queries) GetGroupMemberByUsername(ctx context.Context, arg GetGroupMemberByUsernameParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, getGroupMemberByUsername, arg.GroupID, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
 	)
 	return i, err
 }
 
 const getGroupsByUser = `-- name: GetGroupsByUser :many
-SELECT groups.id, groups.name, groups.created_at, groups.updated_at, groups.owner FROM groups
+SELECT groups.id, groups.name, groups.created_at, groups.updated_at, groups.owner, groups.currency, groups.direct FROM groups
 INNER JOIN users_groups ON groups.id = users_groups.group_id
-WHERE users_groups.user_id = $1
+WHERE users_groups.user_id = $1 AND NOT groups.direct
 `
 
 func (q *Queries) GetGroupsByUser(ctx context.Context, userID uuid.UUID) ([]Group, error) {
@@ -94,6 +161,8 @@ func (q *Queries) GetGroupsByUser(ctx context.Context, userID uuid.UUID) ([]Grou
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Owner,
+			&i.Currency,
+			&i.Direct,
 		); err != nil {
 			return nil, err
 		}
@@ -109,7 +178,7 @@ func (q *Queries) GetGroupsByUser(ctx context.Context, userID uuid.UUID) ([]Grou
 }
 
 const getOtherUsersInGroup = `-- name: GetOtherUsersInGroup :many
-SELECT users_groups.group_id AS group_id, users.id, users.username, users.hashed_password, users.created_at, users.updated_at FROM users
+SELECT users_groups.group_id AS group_id, users.id, users.username, users.hashed_password, users.created_at, users.updated_at, users.placeholder, users.is_admin, users.disabled_at, users.failed_logins, users.locked_until, users.email, users.email_verified_at, users.password_changed_at FROM users
 INNER JOIN users_groups ON users.id = users_groups.user_id
 WHERE group_id = $1 AND users.id != $2
 `
@@ -120,12 +189,20 @@ type GetOtherUsersInGroupParams struct {
 }
 
 type GetOtherUsersInGroupRow struct {
-	GroupID        uuid.UUID
-	ID             uuid.UUID
-	Username       string
-	HashedPassword string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	GroupID           uuid.UUID
+	ID                uuid.UUID
+	Username          string
+	HashedPassword    string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Placeholder       bool
+	IsAdmin           bool
+	DisabledAt        sql.NullTime
+	FailedLogins      int32
+	LockedUntil       sql.NullTime
+	Email             sql.NullString
+	EmailVerifiedAt   sql.NullTime
+	PasswordChangedAt time.Time
 }
 
 func (q *Queries) GetOtherUsersInGroup(ctx context.Context, arg GetOtherUsersInGroupParams) ([]GetOtherUsersInGroupRow, error) {
@@ -144,6 +221,14 @@ func (q *Queries) GetOtherUsersInGroup(ctx context.Context, arg GetOtherUsersInG
 			&i.HashedPassword,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Placeholder,
+			&i.IsAdmin,
+			&i.DisabledAt,
+			&i.FailedLogins,
+			&i.LockedUntil,
+			&i.Email,
+			&i.EmailVerifiedAt,
+			&i.PasswordChangedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -159,7 +244,7 @@ func (q *Queries) GetOtherUsersInGroup(ctx context.Context, arg GetOtherUsersInG
 }
 
 const getUserGroup = `-- name: GetUserGroup :one
-SELECT id, user_id, group_id FROM users_groups
+SELECT id, user_id, group_id, role FROM users_groups
 WHERE user_id = $1 AND group_id = $2
 `
 
@@ -171,12 +256,17 @@ type GetUserGroupParams struct {
 func (q *Queries) GetUserGroup(ctx context.Context, arg GetUserGroupParams) (UsersGroup, error) {
 	row := q.db.QueryRowContext(ctx, getUserGroup, arg.UserID, arg.GroupID)
 	var i UsersGroup
-	err := row.Scan(&i.ID, &i.UserID, &i.GroupID)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+	)
 	return i, err
 }
 
 const getUsersByGroup = `-- name: GetUsersByGroup :many
-SELECT users.id, users.username, users.hashed_password, users.created_at, users.updated_at FROM users
+SELECT users.id, users.username, users.hashed_password, users.created_at, users.updated_at, users.placeholder, users.is_admin, users.disabled_at, users.failed_logins, users.locked_until, users.email, users.email_verified_at, users.password_changed_at FROM users
 INNER JOIN users_groups ON users.id = users_groups.user_id
 WHERE users_groups.group_id = $1
 `
@@ -196,6 +286,14 @@ func (q *Queries) GetUsersByGroup(ctx context.Context, groupID uuid.UUID) ([]Use
 			&i.HashedPassword,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Placeholder,
+			&i.IsAdmin,
+			&i.DisabledAt,
+			&i.FailedLogins,
+			&i.LockedUntil,
+			&i.Email,
+			&i.EmailVerifiedAt,
+			&i.PasswordChangedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -210,20 +308,48 @@ func (q *Queries) GetUsersByGroup(ctx context.Context, groupID uuid.UUID) ([]Use
 	return items, nil
 }
 
-const updateGroupName = `-- name: UpdateGroupName :one
+const updateGroup = `-- name: UpdateGroup :one
 UPDATE groups
-SET name = $2, updated_at = NOW()
+SET name = $2, currency = $3, updated_at = NOW()
 WHERE id = $1
-RETURNING id, name, created_at, updated_at, owner
+RETURNING id, name, created_at, updated_at, owner, currency, direct
 `
 
-type UpdateGroupNameParams struct {
-	ID   uuid.UUID
-	Name string
+type UpdateGroupParams struct {
+	ID       uuid.UUID
+	Name     string
+	Currency string
+}
+
+func (q *Queries) UpdateGroup(ctx context.Context, arg UpdateGroupParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, updateGroup, arg.ID, arg.Name, arg.Currency)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Owner,
+		&i.Currency,
+		&i.Direct,
+	)
+	return i, err
+}
+
+const updateGroupOwner = `-- name: UpdateGroupOwner :one
+UPDATE groups
+SET owner = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, name, created_at, updated_at, owner, currency, direct
+`
+
+type UpdateGroupOwnerParams struct {
+	ID    uuid.UUID
+	Owner uuid.UUID
 }
 
-func (q *Queries) UpdateGroupName(ctx context.Context, arg UpdateGroupNameParams) (Group, error) {
-	row := q.db.QueryRowContext(ctx, updateGroupName, arg.ID, arg.Name)
+func (q *Queries) UpdateGroupOwner(ctx context.Context, arg UpdateGroupOwnerParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, updateGroupOwner, arg.ID, arg.Owner)
 	var i Group
 	err := row.Scan(
 		&i.ID,
@@ -231,6 +357,8 @@ func (q *Queries) UpdateGroupName(ctx context.Context, arg UpdateGroupNameParams
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Owner,
+		&i.Currency,
+		&i.Direct,
 	)
 	return i, err
 }
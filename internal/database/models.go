@@ -5,12 +5,38 @@
 package database
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scope      string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+}
+
+type AuditLog struct {
+	ID        uuid.UUID
+	GroupID   uuid.UUID
+	ActorID   uuid.NullUUID
+	ActorName string
+	Entity    string
+	EntityID  uuid.UUID
+	Action    string
+	Before    json.RawMessage
+	After     json.RawMessage
+	CreatedAt time.Time
+}
+
 type Debt struct {
 	ID        uuid.UUID
 	ExpenseID uuid.UUID
@@ -19,12 +45,70 @@ type Debt struct {
 	Amount    decimal.Decimal
 }
 
+type EmailToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type ExchangeRate struct {
+	ID        uuid.UUID
+	Base      string
+	Quote     string
+	Rate      decimal.Decimal
+	UpdatedAt time.Time
+}
+
 type Expense struct {
 	ID            uuid.UUID
 	PaidBy        uuid.NullUUID
 	Description   string
 	TransactionID uuid.UUID
 	Amount        decimal.Decimal
+	SplitMode     string
+	Currency      string
+}
+
+type ExpenseItem struct {
+	ID          uuid.UUID
+	ExpenseID   uuid.UUID
+	Kind        string
+	Description string
+	Amount      decimal.Decimal
+	Position    int32
+}
+
+type ExpenseItemMember struct {
+	ID     uuid.UUID
+	ItemID uuid.UUID
+	UserID uuid.NullUUID
+}
+
+type ExpensePayer struct {
+	ID        uuid.UUID
+	ExpenseID uuid.UUID
+	UserID    uuid.NullUUID
+	Amount    decimal.Decimal
+}
+
+type ExpenseSplit struct {
+	ID        uuid.UUID
+	ExpenseID uuid.UUID
+	UserID    uuid.NullUUID
+	Value     decimal.Decimal
+}
+
+type Friendship struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	FriendID  uuid.UUID
+	GroupID   uuid.UUID
+	CreatedAt time.Time
 }
 
 type Group struct {
@@ -33,6 +117,19 @@ type Group struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Owner     uuid.UUID
+	Currency  string
+	Direct    bool
+}
+
+type GroupInvite struct {
+	ID            uuid.UUID
+	GroupID       uuid.UUID
+	CreatedBy     uuid.NullUUID
+	Role          string
+	SingleUse     bool
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	PlaceholderID uuid.NullUUID
 }
 
 type Payment struct {
@@ -41,27 +138,93 @@ type Payment struct {
 	PaidTo        uuid.NullUUID
 	Amount        decimal.Decimal
 	TransactionID uuid.UUID
+	Currency      string
 }
 
-type Transaction struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	CreatedBy uuid.NullUUID
-	GroupID   uuid.UUID
-	Kind      string
+type RecoveryCode struct {
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	CodeHash string
+	UsedAt   sql.NullTime
 }
 
-type User struct {
+type RecurringSplit struct {
+	ID          uuid.UUID
+	RecurringID uuid.UUID
+	UserID      uuid.NullUUID
+	Value       decimal.Decimal
+}
+
+type RecurringTransaction struct {
 	ID             uuid.UUID
-	Username       string
-	HashedPassword string
+	GroupID        uuid.UUID
+	CreatedBy      uuid.NullUUID
+	Kind           string
+	Frequency      string
+	StartsOn       time.Time
+	EndsOn         sql.NullTime
+	NextOccurrence time.Time
+	Paused         bool
+	Description    string
+	Amount         decimal.Decimal
+	Currency       string
+	PaidBy         uuid.NullUUID
+	PaidTo         uuid.NullUUID
+	SplitMode      string
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
 
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+	RevokedAt sql.NullTime
+}
+
+type TotpCredential struct {
+	UserID          uuid.UUID
+	EncryptedSecret []byte
+	EnabledAt       sql.NullTime
+	LastCounter     int64
+	CreatedAt       time.Time
+}
+
+type Transaction struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CreatedBy   uuid.NullUUID
+	GroupID     uuid.UUID
+	Kind        string
+	OccurredOn  time.Time
+	RecurringID uuid.NullUUID
+	DeletedAt   sql.NullTime
+}
+
+type User struct {
+	ID                uuid.UUID
+	Username          string
+	HashedPassword    string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Placeholder       bool
+	IsAdmin           bool
+	DisabledAt        sql.NullTime
+	FailedLogins      int32
+	LockedUntil       sql.NullTime
+	Email             sql.NullString
+	EmailVerifiedAt   sql.NullTime
+	PasswordChangedAt time.Time
+}
+
 type UsersGroup struct {
 	ID      uuid.UUID
 	UserID  uuid.UUID
 	GroupID uuid.UUID
+	Role    string
 }
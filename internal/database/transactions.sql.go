@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -45,9 +46,9 @@ func (q *Queries) CreateDebt(ctx context.Context, arg CreateDebtParams) (Debt, e
 }
 
 const createExpense = `-- name: CreateExpense :one
-INSERT INTO expenses (transaction_id, paid_by, description, amount)
-VALUES ($1, $2, $3, $4)
-RETURNING id, paid_by, description, transaction_id, amount
+INSERT INTO expenses (transaction_id, paid_by, description, amount, split_mode, currency)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, paid_by, description, transaction_id, amount, split_mode, currency
 `
 
 type CreateExpenseParams struct {
@@ -55,6 +56,8 @@ type CreateExpenseParams struct {
 	PaidBy        uuid.NullUUID
 	Description   string
 	Amount        decimal.Decimal
+	SplitMode     string
+	Currency      string
 }
 
 func (q *Queries) CreateExpense(ctx context.Context, arg CreateExpenseParams) (Expense, error) {
@@ -63,6 +66,8 @@ func (q *Queries) CreateExpense(ctx context.Context, arg CreateExpenseParams) (E
 		arg.PaidBy,
 		arg.Description,
 		arg.Amount,
+		arg.SplitMode,
+		arg.Currency,
 	)
 	var i Expense
 	err := row.Scan(
@@ -71,14 +76,116 @@ func (q *Queries) CreateExpense(ctx context.Context, arg CreateExpenseParams) (E
 		&i.Description,
 		&i.TransactionID,
 		&i.Amount,
+		&i.SplitMode,
+		&i.Currency,
+	)
+	return i, err
+}
+
+const createExpenseItem = `-- name: CreateExpenseItem :one
+INSERT INTO expense_items (expense_id, kind, description, amount, position)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, expense_id, kind, description, amount, position
+`
+
+type CreateExpenseItemParams struct {
+	ExpenseID   uuid.UUID
+	Kind        string
+	Description string
+	Amount      decimal.Decimal
+	Position    int32
+}
+
+func (q *Queries) CreateExpenseItem(ctx context.Context, arg CreateExpenseItemParams) (ExpenseItem, error) {
+	row := q.db.QueryRowContext(ctx, createExpenseItem,
+		arg.ExpenseID,
+		arg.Kind,
+		arg.Description,
+		arg.Amount,
+		arg.Position,
+	)
+	var i ExpenseItem
+	err := row.Scan(
+		&i.ID,
+		&i.ExpenseID,
+		&i.Kind,
+		&i.Description,
+		&i.Amount,
+		&i.Position,
+	)
+	return i, err
+}
+
+const createExpenseItemMember = `-- name: CreateExpenseItemMember :one
+INSERT INTO expense_item_members (item_id, user_id)
+VALUES ($1, $2)
+RETURNING id, item_id, user_id
+`
+
+type CreateExpenseItemMemberParams struct {
+	ItemID uuid.UUID
+	UserID uuid.NullUUID
+}
+
+func (q *Queries) CreateExpenseItemMember(ctx context.Context, arg CreateExpenseItemMemberParams) (ExpenseItemMember, error) {
+	row := q.db.QueryRowContext(ctx, createExpenseItemMember, arg.ItemID, arg.UserID)
+	var i ExpenseItemMember
+	err := row.Scan(&i.ID, &i.ItemID, &i.UserID)
+	return i, err
+}
+
+const createExpensePayer = `-- name: CreateExpensePayer :one
+INSERT INTO expense_payers (expense_id, user_id, amount)
+VALUES ($1, $2, $3)
+RETURNING id, expense_id, user_id, amount
+`
+
+type CreateExpensePayerParams struct {
+	ExpenseID uuid.UUID
+	UserID    uuid.NullUUID
+	Amount    decimal.Decimal
+}
+
+func (q *Queries) CreateExpensePayer(ctx context.Context, arg CreateExpensePayerParams) (ExpensePayer, error) {
+	row := q.db.QueryRowContext(ctx, createExpensePayer, arg.ExpenseID, arg.UserID, arg.Amount)
+	var i ExpensePayer
+	err := row.Scan(
+		&i.ID,
+		&i.ExpenseID,
+		&i.UserID,
+		&i.Amount,
+	)
+	return i, err
+}
+
+const createExpenseSplit = `-- name: CreateExpenseSplit :one
+INSERT INTO expense_splits (expense_id, user_id, value)
+VALUES ($1, $2, $3)
+RETURNING id, expense_id, user_id, value
+`
+
+type CreateExpenseSplitParams struct {
+	ExpenseID uuid.UUID
+	UserID    uuid.NullUUID
+	Value     decimal.Decimal
+}
+
+func (q *Queries) CreateExpenseSplit(ctx context.Context, arg CreateExpenseSplitParams) (ExpenseSplit, error) {
+	row := q.db.QueryRowContext(ctx, createExpenseSplit, arg.ExpenseID, arg.UserID, arg.Value)
+	var i ExpenseSplit
+	err := row.Scan(
+		&i.ID,
+		&i.ExpenseID,
+		&i.UserID,
+		&i.Value,
 	)
 	return i, err
 }
 
 const createPayment = `-- name: CreatePayment :one
-INSERT INTO payments (transaction_id, paid_by, paid_to, amount)
-VALUES ($1, $2, $3, $4)
-RETURNING id, paid_by, paid_to, amount, transaction_id
+INSERT INTO payments (transaction_id, paid_by, paid_to, amount, currency)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, paid_by, paid_to, amount, transaction_id, currency
 `
 
 type CreatePaymentParams struct {
@@ -86,6 +193,7 @@ type CreatePaymentParams struct {
 	PaidBy        uuid.NullUUID
 	PaidTo        uuid.NullUUID
 	Amount        decimal.Decimal
+	Currency      string
 }
 
 func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
@@ -94,6 +202,7 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (P
 		arg.PaidBy,
 		arg.PaidTo,
 		arg.Amount,
+		arg.Currency,
 	)
 	var i Payment
 	err := row.Scan(
@@ -102,24 +211,33 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (P
 		&i.PaidTo,
 		&i.Amount,
 		&i.TransactionID,
+		&i.Currency,
 	)
 	return i, err
 }
 
 const createTransaction = `-- name: CreateTransaction :one
-INSERT INTO transactions (group_id, created_by, kind)
-VALUES ($1, $2, $3)
-RETURNING id, created_at, updated_at, created_by, group_id, kind
+INSERT INTO transactions (group_id, created_by, kind, occurred_on, recurring_id)
+VALUES ($1, $2, $3, COALESCE($4::DATE, CURRENT_DATE), $5)
+RETURNING id, created_at, updated_at, created_by, group_id, kind, occurred_on, recurring_id, deleted_at
 `
 
 type CreateTransactionParams struct {
-	GroupID   uuid.UUID
-	CreatedBy uuid.NullUUID
-	Kind      string
+	GroupID     uuid.UUID
+	CreatedBy   uuid.NullUUID
+	Kind        string
+	OccurredOn  sql.NullTime
+	RecurringID uuid.NullUUID
 }
 
 func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionParams) (Transaction, error) {
-	row := q.db.QueryRowContext(ctx, createTransaction, arg.GroupID, arg.CreatedBy, arg.Kind)
+	row := q.db.QueryRowContext(ctx, createTransaction,
+		arg.GroupID,
+		arg.CreatedBy,
+		arg.Kind,
+		arg.OccurredOn,
+		arg.RecurringID,
+	)
 	var i Transaction
 	err := row.Scan(
 		&i.ID,
@@ -128,6 +246,9 @@ func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionPa
 		&i.CreatedBy,
 		&i.GroupID,
 		&i.Kind,
+		&i.OccurredOn,
+		&i.RecurringID,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -142,6 +263,36 @@ func (q *Queries) DeleteDebtsByExpense(ctx context.Context, expenseID uuid.UUID)
 	return err
 }
 
+const deleteExpenseItemsByExpense = `-- name: DeleteExpenseItemsByExpense :exec
+DELETE FROM expense_items
+WHERE expense_id = $1
+`
+
+func (q *Queries) DeleteExpenseItemsByExpense(ctx context.Context, expenseID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteExpenseItemsByExpense, expenseID)
+	return err
+}
+
+const deleteExpensePayersByExpense = `-- name: DeleteExpensePayersByExpense :exec
+DELETE FROM expense_payers
+WHERE expense_id = $1
+`
+
+func (q *Queries) DeleteExpensePayersByExpense(ctx context.Context, expenseID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteExpensePayersByExpense, expenseID)
+	return err
+}
+
+const deleteExpenseSplitsByExpense = `-- name: DeleteExpenseSplitsByExpense :exec
+DELETE FROM expense_splits
+WHERE expense_id = $1
+`
+
+func (q *Queries) DeleteExpenseSplitsByExpense(ctx context.Context, expenseID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteExpenseSplitsByExpense, expenseID)
+	return err
+}
+
 const deleteTransaction = `-- name: DeleteTransaction :exec
 DELETE FROM transactions
 WHERE id = $1
@@ -152,10 +303,180 @@ func (q *Queries) DeleteTransaction(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const getDeletedTransactionsByGroup = `-- name: GetDeletedTransactionsByGroup :many
+SELECT id, created_at, updated_at, created_by, group_id, kind, occurred_on, recurring_id, deleted_at FROM transactions
+WHERE group_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+`
+
+func (q *Queries) GetDeletedTransactionsByGroup(ctx context.Context, groupID uuid.UUID) ([]Transaction, error) {
+	rows, err := q.db.QueryContext(ctx, getDeletedTransactionsByGroup, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Transaction
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.CreatedBy,
+			&i.GroupID,
+			&i.Kind,
+			&i.OccurredOn,
+			&i.RecurringID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpenseItemMembersByExpense = `-- name: GetExpenseItemMembersByExpense :many
+SELECT expense_item_members.id, expense_item_members.item_id, expense_item_members.user_id FROM expense_items
+INNER JOIN expense_item_members ON expense_items.id = expense_item_members.item_id
+WHERE expense_items.expense_id = $1
+`
+
+func (q *Queries) GetExpenseItemMembersByExpense(ctx context.Context, expenseID uuid.UUID) ([]ExpenseItemMember, error) {
+	rows, err := q.db.QueryContext(ctx, getExpenseItemMembersByExpense, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpenseItemMember
+	for rows.Next() {
+		var i ExpenseItemMember
+		if err := rows.Scan(&i.ID, &i.ItemID, &i.UserID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpenseItemsByExpense = `-- name: GetExpenseItemsByExpense :many
+SELECT id, expense_id, kind, description, amount, position FROM expense_items
+WHERE expense_id = $1
+ORDER BY position
+`
+
+func (q *Queries) GetExpenseItemsByExpense(ctx context.Context, expenseID uuid.UUID) ([]ExpenseItem, error) {
+	rows, err := q.db.QueryContext(ctx, getExpenseItemsByExpense, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpenseItem
+	for rows.Next() {
+		var i ExpenseItem
+		if err := rows.Scan(
+			&i.ID,
+			&i.ExpenseID,
+			&i.Kind,
+			&i.Description,
+			&i.Amount,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpensePayersByExpense = `-- name: GetExpensePayersByExpense :many
+SELECT id, expense_id, user_id, amount FROM expense_payers
+WHERE expense_id = $1
+`
+
+func (q *Queries) GetExpensePayersByExpense(ctx context.Context, expenseID uuid.UUID) ([]ExpensePayer, error) {
+	rows, err := q.db.QueryContext(ctx, getExpensePayersByExpense, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpensePayer
+	for rows.Next() {
+		var i ExpensePayer
+		if err := rows.Scan(
+			&i.ID,
+			&i.ExpenseID,
+			&i.UserID,
+			&i.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpenseSplitsByExpense = `-- name: GetExpenseSplitsByExpense :many
+SELECT id, expense_id, user_id, value FROM expense_splits
+WHERE expense_id = $1
+`
+
+func (q *Queries) GetExpenseSplitsByExpense(ctx context.Context, expenseID uuid.UUID) ([]ExpenseSplit, error) {
+	rows, err := q.db.QueryContext(ctx, getExpenseSplitsByExpense, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpenseSplit
+	for rows.Next() {
+		var i ExpenseSplit
+		if err := rows.Scan(
+			&i.ID,
+			&i.ExpenseID,
+			&i.UserID,
+			&i.Value,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getExpensesByGroup = `-- name: GetExpensesByGroup :many
-SELECT expenses.id, expenses.paid_by, expenses.description, expenses.transaction_id, expenses.amount FROM expenses
+SELECT expenses.id, expenses.paid_by, expenses.description, expenses.transaction_id, expenses.amount, expenses.split_mode, expenses.currency FROM expenses
 INNER JOIN transactions ON expenses.transaction_id = transactions.id
-WHERE transactions.group_id = $1
+WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
 ORDER BY transactions.updated_at
 `
 
@@ -174,6 +495,8 @@ func (q *Queries) GetExpensesByGroup(ctx context.Context, groupID uuid.UUID) ([]
 			&i.Description,
 			&i.TransactionID,
 			&i.Amount,
+			&i.SplitMode,
+			&i.Currency,
 		); err != nil {
 			return nil, err
 		}
@@ -189,9 +512,9 @@ func (q *Queries) GetExpensesByGroup(ctx context.Context, groupID uuid.UUID) ([]
 }
 
 const getPaymentsByGroup = `-- name: GetPaymentsByGroup :many
-SELECT payments.id, paid_by, paid_to, amount, transaction_id, transactions.id, created_at, updated_at, created_by, group_id, kind FROM payments
+SELECT payments.id, paid_by, paid_to, amount, transaction_id, currency, transactions.id, created_at, updated_at, created_by, group_id, kind, occurred_on, recurring_id, deleted_at FROM payments
 INNER JOIN transactions ON payments.transaction_id = transactions.id
-WHERE transactions.group_id = $1
+WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
 ORDER BY transactions.updated_at
 `
 
@@ -201,12 +524,16 @@ type GetPaymentsByGroupRow struct {
 	PaidTo        uuid.NullUUID
 	Amount        decimal.Decimal
 	TransactionID uuid.UUID
+	Currency      string
 	ID_2          uuid.UUID
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	CreatedBy     uuid.NullUUID
 	GroupID       uuid.UUID
 	Kind          string
+	OccurredOn    time.Time
+	RecurringID   uuid.NullUUID
+	DeletedAt     sql.NullTime
 }
 
 func (q *Queries) GetPaymentsByGroup(ctx context.Context, groupID uuid.UUID) ([]GetPaymentsByGroupRow, error) {
@@ -224,12 +551,16 @@ func (q *Queries) GetPaymentsByGroup(ctx context.Context, groupID uuid.UUID) ([]
 			&i.PaidTo,
 			&i.Amount,
 			&i.TransactionID,
+			&i.Currency,
 			&i.ID_2,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.CreatedBy,
 			&i.GroupID,
 			&i.Kind,
+			&i.OccurredOn,
+			&i.RecurringID,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -245,7 +576,7 @@ func (q *Queries) GetPaymentsByGroup(ctx context.Context, groupID uuid.UUID) ([]
 }
 
 const getTransaction = `-- name: GetTransaction :one
-SELECT id, created_at, updated_at, created_by, group_id, kind FROM transactions
+SELECT id, created_at, updated_at, created_by, group_id, kind, occurred_on, recurring_id, deleted_at FROM transactions
 WHERE id = $1
 `
 
@@ -259,14 +590,17 @@ func (q *Queries) GetTransaction(ctx context.Context, id uuid.UUID) (Transaction
 		&i.CreatedBy,
 		&i.GroupID,
 		&i.Kind,
+		&i.OccurredOn,
+		&i.RecurringID,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getTransactionsByGroup = `-- name: GetTransactionsByGroup :many
-SELECT id, created_at, updated_at, created_by, group_id, kind FROM transactions
-WHERE group_id = $1
-ORDER BY updated_at DESC
+SELECT id, created_at, updated_at, created_by, group_id, kind, occurred_on, recurring_id, deleted_at FROM transactions
+WHERE group_id = $1 AND deleted_at IS NULL
+ORDER BY occurred_on DESC, created_at DESC
 `
 
 func (q *Queries) GetTransactionsByGroup(ctx context.Context, groupID uuid.UUID) ([]Transaction, error) {
@@ -285,6 +619,55 @@ func (q *Queries) GetTransactionsByGroup(ctx context.Context, groupID uuid.UUID)
 			&i.CreatedBy,
 			&i.GroupID,
 			&i.Kind,
+			&i.OccurredOn,
+			&i.RecurringID,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTransactionsByGroupPage = `-- name: GetTransactionsByGroupPage :many
+SELECT id, created_at, updated_at, created_by, group_id, kind, occurred_on, recurring_id, deleted_at FROM transactions
+WHERE group_id = $1 AND deleted_at IS NULL
+ORDER BY occurred_on DESC, created_at DESC, id
+LIMIT $2 OFFSET $3
+`
+
+type GetTransactionsByGroupPageParams struct {
+	GroupID uuid.UUID
+	Limit   int32
+	Offset  int32
+}
+
+func (q *Queries) GetTransactionsByGroupPage(ctx context.Context, arg GetTransactionsByGroupPageParams) ([]Transaction, error) {
+	rows, err := q.db.QueryContext(ctx, getTransactionsByGroupPage, arg.GroupID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Transaction
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.CreatedBy,
+			&i.GroupID,
+			&i.Kind,
+			&i.OccurredOn,
+			&i.RecurringID,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -299,6 +682,75 @@ func (q *Queries) GetTransactionsByGroup(ctx context.Context, groupID uuid.UUID)
 	return items, nil
 }
 
+const purgeDeletedTransactions = `-- name: PurgeDeletedTransactions :many
+DELETE FROM transactions
+WHERE deleted_at < $1::TIMESTAMPTZ
+RETURNING id, group_id
+`
+
+type PurgeDeletedTransactionsRow struct {
+	ID      uuid.UUID
+	GroupID uuid.UUID
+}
+
+func (q *Queries) PurgeDeletedTransactions(ctx context.Context, before time.Time) ([]PurgeDeletedTransactionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, purgeDeletedTransactions, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PurgeDeletedTransactionsRow
+	for rows.Next() {
+		var i PurgeDeletedTransactionsRow
+		if err := rows.Scan(&i.ID, &i.GroupID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreTransaction = `-- name: RestoreTransaction :one
+UPDATE transactions
+SET deleted_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, created_by, group_id, kind, occurred_on, recurring_id, deleted_at
+`
+
+func (q *Queries) RestoreTransaction(ctx context.Context, id uuid.UUID) (Transaction, error) {
+	row := q.db.QueryRowContext(ctx, restoreTransaction, id)
+	var i Transaction
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatedBy,
+		&i.GroupID,
+		&i.Kind,
+		&i.OccurredOn,
+		&i.RecurringID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const softDeleteTransaction = `-- name: SoftDeleteTransaction :exec
+UPDATE transactions
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteTransaction(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteTransaction, id)
+	return err
+}
+
 const updateDebt = `-- name: UpdateDebt :one
 UPDATE debts
 SET amount = $2
@@ -326,9 +778,9 @@ func (q *Queries) UpdateDebt(ctx context.Context, arg UpdateDebtParams) (Debt, e
 
 const updateExpense = `-- name: UpdateExpense :one
 UPDATE expenses
-SET paid_by = $2, description = $3, amount = $4
+SET paid_by = $2, description = $3, amount = $4, split_mode = $5, currency = $6
 WHERE id = $1
-RETURNING id, paid_by, description, transaction_id, amount
+RETURNING id, paid_by, description, transaction_id, amount, split_mode, currency
 `
 
 type UpdateExpenseParams struct {
@@ -336,6 +788,8 @@ type UpdateExpenseParams struct {
 	PaidBy      uuid.NullUUID
 	Description string
 	Amount      decimal.Decimal
+	SplitMode   string
+	Currency    string
 }
 
 func (q *Queries) UpdateExpense(ctx context.Context, arg UpdateExpenseParams) (Expense, error) {
@@ -344,6 +798,8 @@ func (q *Queries) UpdateExpense(ctx context.Context, arg UpdateExpenseParams) (E
 		arg.PaidBy,
 		arg.Description,
 		arg.Amount,
+		arg.SplitMode,
+		arg.Currency,
 	)
 	var i Expense
 	err := row.Scan(
@@ -352,22 +808,25 @@ func (q *Queries) UpdateExpense(ctx context.Context, arg UpdateExpenseParams) (E
 		&i.Description,
 		&i.TransactionID,
 		&i.Amount,
+		&i.SplitMode,
+		&i.Currency,
 	)
 	return i, err
 }
 
 const updatePayment = `-- name: UpdatePayment :one
 UPDATE payments
-SET amount = $2, paid_by = $3, paid_to = $4
+SET amount = $2, paid_by = $3, paid_to = $4, currency = $5
 WHERE id = $1
-RETURNING id, paid_by, paid_to, amount, transaction_id
+RETURNING id, paid_by, paid_to, amount, transaction_id, currency
 `
 
 type UpdatePaymentParams struct {
-	ID     uuid.UUID
-	Amount decimal.Decimal
-	PaidBy uuid.NullUUID
-	PaidTo uuid.NullUUID
+	ID       uuid.UUID
+	Amount   decimal.Decimal
+	PaidBy   uuid.NullUUID
+	PaidTo   uuid.NullUUID
+	Currency string
 }
 
 func (q *Queries) UpdatePayment(ctx context.Context, arg UpdatePaymentParams) (Payment, error) {
@@ -376,6 +835,7 @@ func (q *Queries) UpdatePayment(ctx context.Context, arg UpdatePaymentParams) (P
 		arg.Amount,
 		arg.PaidBy,
 		arg.PaidTo,
+		arg.Currency,
 	)
 	var i Payment
 	err := row.Scan(
@@ -384,19 +844,25 @@ func (q *Queries) UpdatePayment(ctx context.Context, arg UpdatePaymentParams) (P
 		&i.PaidTo,
 		&i.Amount,
 		&i.TransactionID,
+		&i.Currency,
 	)
 	return i, err
 }
 
 const updateTransaction = `-- name: UpdateTransaction :one
 UPDATE transactions
-SET updated_at = NOW()
-WHERE id = $1
-RETURNING id, created_at, updated_at, created_by, group_id, kind
+SET updated_at = NOW(), occurred_on = COALESCE($1::DATE, occurred_on)
+WHERE id = $2
+RETURNING id, created_at, updated_at, created_by, group_id, kind, occurred_on, recurring_id, deleted_at
 `
 
-func (q *Queries) UpdateTransaction(ctx context.Context, id uuid.UUID) (Transaction, error) {
-	row := q.db.QueryRowContext(ctx, updateTransaction, id)
+type UpdateTransactionParams struct {
+	OccurredOn sql.NullTime
+	ID         uuid.UUID
+}
+
+func (q *Queries) UpdateTransaction(ctx context.Context, arg UpdateTransactionParams) (Transaction, error) {
+	row := q.db.QueryRowContext(ctx, updateTransaction, arg.OccurredOn, arg.ID)
 	var i Transaction
 	err := row.Scan(
 		&i.ID,
@@ -405,6 +871,9 @@ func (q *Queries) UpdateTransaction(ctx context.Context, id uuid.UUID) (Transact
 		&i.CreatedBy,
 		&i.GroupID,
 		&i.Kind,
+		&i.OccurredOn,
+		&i.RecurringID,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -7,23 +7,97 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const clearUnverifiedEmail = `-- name: ClearUnverifiedEmail :exec
+UPDATE users
+SET email = NULL, updated_at = NOW()
+WHERE email = $1::TEXT AND id <> $2 AND email_verified_at IS NULL
+`
+
+type ClearUnverifiedEmailParams struct {
+	Email string
+	ID    uuid.UUID
+}
+
+func (q *Queries) ClearUnverifiedEmail(ctx context.Context, arg ClearUnverifiedEmailParams) error {
+	_, err := q.db.ExecContext(ctx, clearUnverifiedEmail, arg.Email, arg.ID)
+	return err
+}
+
+const createPlaceholderUser = `-- name: CreatePlaceholderUser :one
+INSERT INTO users (username, hashed_password, placeholder)
+VALUES ($1, '', TRUE)
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+func (q *Queries) CreatePlaceholderUser(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, createPlaceholderUser, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (username, hashed_password)
-VALUES ($1, $2)
-RETURNING id, username, hashed_password, created_at, updated_at
+INSERT INTO users (username, hashed_password, email)
+VALUES ($1, $2, $3)
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
 `
 
 type CreateUserParams struct {
 	Username       string
 	HashedPassword string
+	Email          sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Username, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Username, arg.HashedPassword, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const deletePlaceholderUser = `-- name: DeletePlaceholderUser :one
+DELETE FROM users
+WHERE id = $1 AND placeholder
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+func (q *Queries) DeletePlaceholderUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, deletePlaceholderUser, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -31,12 +105,102 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const disableUser = `-- name: DisableUser :one
+UPDATE users
+SET disabled_at = COALESCE(disabled_at, NOW()), updated_at = NOW()
+WHERE id = $1
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+func (q *Queries) DisableUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, disableUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const enableUser = `-- name: EnableUser :one
+UPDATE users
+SET disabled_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+func (q *Queries) EnableUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at FROM users
+WHERE email = $1::TEXT AND email_verified_at IS NOT NULL
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, username, hashed_password, created_at, updated_at FROM users
+SELECT id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at FROM users
 WHERE id = $1
 `
 
@@ -49,13 +213,21 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, username, hashed_password, created_at, updated_at FROM users
-WHERE username = $1
+SELECT id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at FROM users
+WHERE username = $1 AND NOT placeholder
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -67,15 +239,255 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const getUsersByIDs = `-- name: GetUsersByIDs :many
+SELECT id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at FROM users
+WHERE id = ANY($1::UUID[])
+`
+
+func (q *Queries) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.HashedPassword,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Placeholder,
+			&i.IsAdmin,
+			&i.DisabledAt,
+			&i.FailedLogins,
+			&i.LockedUntil,
+			&i.Email,
+			&i.EmailVerifiedAt,
+			&i.PasswordChangedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reassignUser = `-- name: ReassignUser :exec
+WITH
+    memberships AS (
+        UPDATE users_groups SET user_id = $1::UUID
+        WHERE user_id = $2::UUID
+    ),
+    expenses_paid AS (
+        UPDATE expenses SET paid_by = $1::UUID
+        WHERE paid_by = $2::UUID
+    ),
+    payers AS (
+        UPDATE expense_payers SET user_id = $1::UUID
+        WHERE user_id = $2::UUID
+    ),
+    splits AS (
+        UPDATE expense_splits SET user_id = $1::UUID
+        WHERE user_id = $2::UUID
+    ),
+    item_members AS (
+        UPDATE expense_item_members SET user_id = $1::UUID
+        WHERE user_id = $2::UUID
+    ),
+    debts_moved AS (
+        UPDATE debts SET
+            owed_by = CASE WHEN owed_by = $2::UUID THEN $1::UUID ELSE owed_by END,
+            owed_to = CASE WHEN owed_to = $2::UUID THEN $1::UUID ELSE owed_to END
+        WHERE owed_by = $2::UUID OR owed_to = $2::UUID
+    ),
+    payments_moved AS (
+        UPDATE payments SET
+            paid_by = CASE WHEN paid_by = $2::UUID THEN $1::UUID ELSE paid_by END,
+            paid_to = CASE WHEN paid_to = $2::UUID THEN $1::UUID ELSE paid_to END
+        WHERE paid_by = $2::UUID OR paid_to = $2::UUID
+    ),
+    recurring_moved AS (
+        UPDATE recurring_transactions SET
+            paid_by = CASE WHEN paid_by = $2::UUID THEN $1::UUID ELSE paid_by END,
+            paid_to = CASE WHEN paid_to = $2::UUID THEN $1::UUID ELSE paid_to END
+        WHERE paid_by = $2::UUID OR paid_to = $2::UUID
+    )
+UPDATE recurring_splits SET user_id = $1::UUID
+WHERE user_id = $2::UUID
+`
+
+type ReassignUserParams struct {
+	ToID   uuid.UUID
+	FromID uuid.UUID
+}
+
+func (q *Queries) ReassignUser(ctx context.Context, arg ReassignUserParams) error {
+	_, err := q.db.ExecContext(ctx, reassignUser, arg.ToID, arg.FromID)
+	return err
+}
+
+const recordFailedLogin = `-- name: RecordFailedLogin :one
+UPDATE users
+SET
+    failed_logins = CASE
+        WHEN failed_logins + 1 >= $1::INTEGER THEN 0
+        ELSE failed_logins + 1
+    END,
+    locked_until = CASE
+        WHEN failed_logins + 1 >= $1::INTEGER THEN $2::TIMESTAMPTZ
+        ELSE locked_until
+    END
+WHERE id = $3::UUID
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+type RecordFailedLoginParams struct {
+	MaxFailedLogins int32
+	LockedUntil     time.Time
+	ID              uuid.UUID
+}
+
+func (q *Queries) RecordFailedLogin(ctx context.Context, arg RecordFailedLoginParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, recordFailedLogin, arg.MaxFailedLogins, arg.LockedUntil, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const setUserAdmin = `-- name: SetUserAdmin :one
+UPDATE users
+SET is_admin = $2, updated_at = NOW()
+WHERE id = $1 AND NOT placeholder
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+type SetUserAdminParams struct {
+	ID      uuid.UUID
+	IsAdmin bool
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserAdmin, arg.ID, arg.IsAdmin)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const setUserEmail = `-- name: SetUserEmail :one
+UPDATE users
+SET email = $2, email_verified_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+type SetUserEmailParams struct {
+	ID    uuid.UUID
+	Email sql.NullString
+}
+
+func (q *Queries) SetUserEmail(ctx context.Context, arg SetUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const unlockUser = `-- name: UnlockUser :one
+UPDATE users
+SET failed_logins = 0, locked_until = NULL
+WHERE id = $1
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+func (q *Queries) UnlockUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unlockUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
 	)
 	return i, err
 }
 
 const updatePassword = `-- name: UpdatePassword :one
 UPDATE users
-SET hashed_password = $2, updated_at = NOW()
+SET hashed_password = $2, password_changed_at = NOW(), updated_at = NOW()
 WHERE id = $1
-RETURNING id, username, hashed_password, created_at, updated_at
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
 `
 
 type UpdatePasswordParams struct {
@@ -92,6 +504,47 @@ func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams)
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
+	)
+	return i, err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND email = $2::TEXT
+RETURNING id, username, hashed_password, created_at, updated_at, placeholder, is_admin, disabled_at, failed_logins, locked_until, email, email_verified_at, password_changed_at
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Placeholder,
+		&i.IsAdmin,
+		&i.DisabledAt,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.PasswordChangedAt,
 	)
 	return i, err
 }
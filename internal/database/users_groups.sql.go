@@ -12,27 +12,33 @@ import (
 )
 
 const createUserGroup = `-- name: CreateUserGroup :one
-INSERT INTO users_groups (id, user_id, group_id)
-VALUES (GEN_RANDOM_UUID(), $1, $2)
-RETURNING id, user_id, group_id
+INSERT INTO users_groups (id, user_id, group_id, role)
+VALUES (GEN_RANDOM_UUID(), $1, $2, $3)
+RETURNING id, user_id, group_id, role
 `
 
 type CreateUserGroupParams struct {
 	UserID  uuid.UUID
 	GroupID uuid.UUID
+	Role    string
 }
 
 func (q *Queries) CreateUserGroup(ctx context.Context, arg CreateUserGroupParams) (UsersGroup, error) {
-	row := q.db.QueryRowContext(ctx, createUserGroup, arg.UserID, arg.GroupID)
+	row := q.db.QueryRowContext(ctx, createUserGroup, arg.UserID, arg.GroupID, arg.Role)
 	var i UsersGroup
-	err := row.Scan(&i.ID, &i.UserID, &i.GroupID)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+	)
 	return i, err
 }
 
 const deleteUserGroup = `-- name: DeleteUserGroup :one
 DELETE FROM users_groups
 WHERE group_id = $1 AND user_id = $2
-RETURNING id, user_id, group_id
+RETURNING id, user_id, group_id, role
 `
 
 type DeleteUserGroupParams struct {
@@ -43,6 +49,69 @@ type DeleteUserGroupParams struct {
 func (q *Queries) DeleteUserGroup(ctx context.Context, arg DeleteUserGroupParams) (UsersGroup, error) {
 	row := q.db.QueryRowContext(ctx, deleteUserGroup, arg.GroupID, arg.UserID)
 	var i UsersGroup
-	err := row.Scan(&i.ID, &i.UserID, &i.GroupID)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getUserGroupsByGroup = `-- name: GetUserGroupsByGroup :many
+SELECT id, user_id, group_id, role FROM users_groups
+WHERE group_id = $1
+`
+
+func (q *Queries) GetUserGroupsByGroup(ctx context.Context, groupID uuid.UUID) ([]UsersGroup, error) {
+	rows, err := q.db.QueryContext(ctx, getUserGroupsByGroup, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UsersGroup
+	for rows.Next() {
+		var i UsersGroup
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.GroupID,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUserGroupRole = `-- name: UpdateUserGroupRole :one
+UPDATE users_groups
+SET role = $3
+WHERE group_id = $1 AND user_id = $2
+RETURNING id, user_id, group_id, role
+`
+
+type UpdateUserGroupRoleParams struct {
+	GroupID uuid.UUID
+	UserID  uuid.UUID
+	Role    string
+}
+
+func (q *Queries) UpdateUserGroupRole(ctx context.Context, arg UpdateUserGroupRoleParams) (UsersGroup, error) {
+	row := q.db.QueryRowContext(ctx, updateUserGroupRole, arg.GroupID, arg.UserID, arg.Role)
+	var i UsersGroup
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+	)
 	return i, err
 }
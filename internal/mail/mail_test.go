@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		msg       Message
+		expectErr error
+		expect    []string
+	}{
+		{
+			name: "Plain message",
+			msg:  Message{To: "alice@example.com", Subject: "Hello", Body: "Line one\nLine two"},
+			expect: []string{
+				"From: noreply@example.com\r\n",
+				"To: alice@example.com\r\n",
+				"Subject: Hello\r\n",
+				"Date: Sat, 01 Mar 2025 12:00:00 +0000\r\n",
+				"\r\n\r\nLine one\r\nLine two\r\n",
+			},
+		},
+		{
+			name:   "Non-ASCII subject",
+			msg:    Message{To: "alice@example.com", Subject: "Café", Body: "Hi"},
+			expect: []string{"Subject: =?utf-8?q?Caf=C3=A9?=\r\n"},
+		},
+		{
+			name:      "Header injection in subject",
+			msg:       Message{To: "alice@example.com", Subject: "Hi\r\nBcc: eve@example.com", Body: "Hi"},
+			expectErr: ErrInvalidHeader,
+		},
+		{
+			name:      "Header injection in recipient",
+			msg:       Message{To: "alice@example.com\nBcc: eve@example.com", Subject: "Hi", Body: "Hi"},
+			expectErr: ErrInvalidHeader,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := format("noreply@example.com", c.msg, date)
+			if !errors.Is(err, c.expectErr) {
+				t.Fatalf("expected error %v, got %v", c.expectErr, err)
+			}
+
+			for _, s := range c.expect {
+				if !bytes.Contains(data, []byte(s)) {
+					t.Errorf("expected %q in:\n%s", s, data)
+				}
+			}
+		})
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var b strings.Builder
+	mailer := &LogMailer{W: &b, From: "noreply@example.com"}
+
+	err := mailer.Send(t.Context(), Message{To: "alice@example.com", Subject: "Reset", Body: "http://localhost/reset"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(b.String(), "To: alice@example.com\r\n") || !strings.Contains(b.String(), "http://localhost/reset") {
+		t.Errorf("email not written out:\n%s", b.String())
+	}
+}
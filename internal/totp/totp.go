@@ -0,0 +1,124 @@
+// Package totp implements time-based one-time passwords (RFC 6238), the six
+// digit codes shown by authenticator apps, on top of HOTP (RFC 4226).
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// Period is how long each code lasts.
+	Period = 30 * time.Second
+	// Digits is how long each code is.
+	Digits = 6
+	// Skew is how many periods either side of now a code is still accepted,
+	// to allow for clocks being a little out.
+	Skew = 1
+	// SecretSize is how many random bytes a secret has, which is the size of
+	// a SHA-1 HMAC as RFC 4226 recommends.
+	SecretSize = 20
+)
+
+// encoding is how secrets are written for people and authenticator apps.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random secret to share with an authenticator app.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// EncodeSecret writes secret in base32, for typing into an authenticator app
+// that can't scan a QR code.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// URI is the otpauth URI of secret, which authenticator apps read from a QR
+// code. The account is shown under issuer in the app.
+func URI(issuer, account string, secret []byte) string {
+	query := url.Values{}
+	query.Set("secret", EncodeSecret(secret))
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
+
+// Counter is the number of the period that t falls in.
+func Counter(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(Period.Seconds()))
+}
+
+// HOTP is the code with digits digits for counter.
+func HOTP(secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// Code is the code for secret at t.
+func Code(secret []byte, t time.Time) string {
+	return HOTP(secret, Counter(t), Digits)
+}
+
+// Validate checks code against secret at t, allowing for Skew. Codes from
+// periods up to and including after are refused, so that each code can only
+// be used once. It returns the period of the code, to be passed as after
+// next time.
+func Validate(secret []byte, code string, t time.Time, after uint64) (uint64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	now := Counter(t)
+
+	for counter := now - Skew; counter <= now+Skew; counter++ {
+		if counter <= after {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(HOTP(secret, counter, Digits)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,151 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the test vectors in RFC 4226 and RFC 6238.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D.
+	expected := []string{
+		"755224",
+		"287082",
+		"359152",
+		"969429",
+		"338314",
+		"254676",
+		"287922",
+		"162583",
+		"399871",
+		"520489",
+	}
+
+	for counter, want := range expected {
+		if got := HOTP(rfcSecret, uint64(counter), 6); got != want {
+			t.Errorf("HOTP(%d) = %s, expected %s", counter, got, want)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1 only.
+	cases := []struct {
+		unix  int64
+		eight string
+	}{
+		{unix: 59, eight: "94287082"},
+		{unix: 1111111109, eight: "07081804"},
+		{unix: 1111111111, eight: "14050471"},
+		{unix: 1234567890, eight: "89005924"},
+		{unix: 2000000000, eight: "69279037"},
+		{unix: 20000000000, eight: "65353130"},
+	}
+
+	for _, c := range cases {
+		now := time.Unix(c.unix, 0)
+
+		if got := HOTP(rfcSecret, Counter(now), 8); got != c.eight {
+			t.Errorf("8 digit code at %d = %s, expected %s", c.unix, got, c.eight)
+		}
+
+		if got := Code(rfcSecret, now); got != c.eight[2:] {
+			t.Errorf("Code at %d = %s, expected %s", c.unix, got, c.eight[2:])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	counter := Counter(now)
+
+	cases := []struct {
+		name        string
+		code        string
+		after       uint64
+		expectOk    bool
+		expectCount uint64
+	}{
+		{
+			name:        "Current code",
+			code:        Code(rfcSecret, now),
+			expectOk:    true,
+			expectCount: counter,
+		},
+		{
+			name:        "Previous code",
+			code:        Code(rfcSecret, now.Add(-Period)),
+			expectOk:    true,
+			expectCount: counter - 1,
+		},
+		{
+			name:        "Next code",
+			code:        Code(rfcSecret, now.Add(Period)),
+			expectOk:    true,
+			expectCount: counter + 1,
+		},
+		{
+			name:     "Too old",
+			code:     Code(rfcSecret, now.Add(-2*Period)),
+			expectOk: false,
+		},
+		{
+			name:     "Too new",
+			code:     Code(rfcSecret, now.Add(2*Period)),
+			expectOk: false,
+		},
+		{
+			name:     "Already used",
+			code:     Code(rfcSecret, now),
+			after:    counter,
+			expectOk: false,
+		},
+		{
+			name:     "Wrong length",
+			code:     "1234567",
+			expectOk: false,
+		},
+		{
+			name:     "Wrong code",
+			code:     "000000",
+			expectOk: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, c.code, now, c.after)
+			if ok != c.expectOk {
+				t.Fatalf("expected ok to be %v, got %v", c.expectOk, ok)
+			}
+
+			if ok && got != c.expectCount {
+				t.Errorf("expected counter %d, got %d", c.expectCount, got)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	u, err := url.Parse(URI("SplitWays", "alice", secret))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/SplitWays:alice" {
+		t.Errorf("unexpected URI %s", u)
+	}
+
+	if got := u.Query().Get("secret"); got != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("unexpected secret %s", got)
+	}
+
+	if got := u.Query().Get("issuer"); got != "SplitWays" {
+		t.Errorf("unexpected issuer %s", got)
+	}
+}
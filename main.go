@@ -7,6 +7,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/a-h/templ"
@@ -15,7 +17,10 @@ import (
 	"github.com/joho/godotenv"
 
 	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/auth"
 	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/matt-horst/split-ways/internal/mail"
 	"github.com/matt-horst/split-ways/web/pages"
 
 	_ "github.com/lib/pq"
@@ -58,13 +63,90 @@ func main() {
 	}
 	cancel()
 
+	trashRetentionDays := 30
+	if s, ok := os.LookupEnv("TRASH_RETENTION_DAYS"); ok {
+		trashRetentionDays, err = strconv.Atoi(s)
+		if err != nil || trashRetentionDays < 0 {
+			log.Fatalf("Couldn't parse trash retention days `%s`\n", s)
+		}
+	}
+
+	allowReset := false
+	if s, ok := os.LookupEnv("ALLOW_RESET"); ok {
+		allowReset, err = strconv.ParseBool(s)
+		if err != nil {
+			log.Fatalf("Couldn't parse allow reset `%s`\n", s)
+		}
+	}
+
+	baseURL, ok := os.LookupEnv("BASE_URL")
+	if !ok {
+		baseURL = "http://localhost:" + port
+	}
+
+	var mailer mail.Mailer
+	if addr, ok := os.LookupEnv("SMTP_ADDR"); ok {
+		from, ok := os.LookupEnv("MAIL_FROM")
+		if !ok {
+			log.Fatalln("Couldn't find mail from address in ENV, which is needed with SMTP_ADDR")
+		}
+
+		mailer = &mail.SMTPMailer{
+			Addr:     addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	} else {
+		out := os.Stdout
+		if path, ok := os.LookupEnv("MAIL_FILE"); ok {
+			out, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+			if err != nil {
+				log.Fatalf("Couldn't open mail file: %v\n", err)
+			}
+			defer out.Close()
+		}
+
+		log.Printf("SMTP_ADDR isn't set, so emails are written to %s instead of being sent\n", out.Name())
+
+		mailer = &mail.LogMailer{W: out, From: "noreply@localhost"}
+	}
+
+	var twoFactorKey []byte
+	if s, ok := os.LookupEnv("TOTP_KEY"); ok && s != "" {
+		twoFactorKey = auth.DeriveKey(s)
+	} else {
+		log.Println("TOTP_KEY isn't set, so two-factor authentication can't be set up")
+	}
+
 	queries := database.New(db)
 
 	cfg := &handlers.Config{
-		DB:      db,
-		Queries: queries,
-		Store:   sessions.NewCookieStore([]byte(sessionKey)),
-		JwtKey:  jwtKey,
+		DB:             db,
+		Queries:        queries,
+		Store:          sessions.NewCookieStore([]byte(sessionKey)),
+		JwtKey:         jwtKey,
+		TrashRetention: time.Duration(trashRetentionDays) * 24 * time.Hour,
+		Mailer:         mailer,
+		BaseURL:        strings.TrimSuffix(baseURL, "/"),
+		TwoFactorKey:   twoFactorKey,
+		AllowReset:     allowReset,
+	}
+
+	recurringInterval := time.Hour
+	if s, ok := os.LookupEnv("RECURRING_INTERVAL"); ok {
+		recurringInterval, err = time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Couldn't parse recurring interval: %v\n", err)
+		}
+	}
+
+	go api.ScheduleRecurring(context.Background(), db, queries, recurringInterval)
+
+	go api.ScheduleTokenCleanup(context.Background(), queries, time.Hour)
+
+	if cfg.TrashRetention > 0 {
+		go api.ScheduleTrashPurge(context.Background(), db, queries, cfg.TrashRetention, time.Hour)
 	}
 
 	router := mux.NewRouter()
@@ -72,9 +154,40 @@ func main() {
 	router.HandleFunc("/api/healthcheck", handlers.HandlerHealthCheck)
 	router.HandleFunc("/api/users", cfg.HandlerCreateUser).Methods("POST")
 	router.Handle("/api/users", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerUpdateUser))).Methods("PUT")
+	router.Handle("/api/users/email", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerUpdateEmail))).Methods("PUT")
+	router.Handle("/api/users/email/verification", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerResendVerification))).Methods("POST")
+	router.Handle("/api/users/two-factor", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerStartTwoFactor))).Methods("POST")
+	router.Handle("/api/users/two-factor", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerEnableTwoFactor))).Methods("PUT")
+	router.Handle("/api/users/two-factor", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerDisableTwoFactor))).Methods("DELETE")
+	router.Handle("/api/users/two-factor/recovery-codes", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerRegenerateRecoveryCodes))).Methods("POST")
+	router.HandleFunc("/api/password-reset", cfg.HandlerForgotPassword).Methods("POST")
+	router.HandleFunc("/api/password-reset", cfg.HandlerPasswordReset).Methods("PUT")
 	router.HandleFunc("/api/login", cfg.HandlerLogin).Methods("POST")
+	router.HandleFunc("/api/login/two-factor", cfg.HandlerLoginTwoFactor).Methods("POST")
 	router.HandleFunc("/api/logout", cfg.HandlerLogout).Methods("POST")
-	router.HandleFunc("/api/reset", cfg.HandlerReset).Methods("POST")
+	if cfg.AllowReset {
+		log.Println("ALLOW_RESET is set, so admins can delete everything. Never set it in production.")
+		router.Handle("/api/reset", cfg.AdminMiddleware(http.HandlerFunc(cfg.HandlerReset))).Methods("POST")
+	}
+	router.Handle("/api/invite/{token}", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerAcceptInvite))).Methods("POST")
+
+	router.Handle("/api/friends", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerAddFriend))).Methods("POST")
+	router.Handle("/api/friends/{id}", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerRemoveFriend))).Methods("DELETE")
+	router.Handle("/api/balances", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerGetBalances))).Methods("GET")
+	router.Handle("/api/tokens", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerGetAPITokens))).Methods("GET")
+	router.Handle("/api/tokens", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerCreateAPIToken))).Methods("POST")
+	router.Handle("/api/tokens/{id}", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerRevokeAPIToken))).Methods("DELETE")
+
+	admin := router.NewRoute().PathPrefix("/api/admin").Subrouter()
+	admin.Use(cfg.AdminMiddleware)
+	admin.HandleFunc("/stats", cfg.HandlerAdminGetStats).Methods("GET")
+	admin.HandleFunc("/users", cfg.HandlerAdminGetUsers).Methods("GET")
+	admin.HandleFunc("/groups", cfg.HandlerAdminGetGroups).Methods("GET")
+	admin.HandleFunc("/users/{id}/disabled", cfg.HandlerAdminSetDisabled).Methods("PUT")
+	admin.HandleFunc("/users/{id}/admin", cfg.HandlerAdminSetAdmin).Methods("PUT")
+	admin.HandleFunc("/users/{id}/unlock", cfg.HandlerAdminUnlock).Methods("POST")
+	admin.HandleFunc("/users/{id}/password", cfg.HandlerAdminResetPassword).Methods("PUT")
+	admin.HandleFunc("/users/{id}/two-factor", cfg.HandlerAdminResetTwoFactor).Methods("DELETE")
 
 	groups := router.NewRoute().PathPrefix("/api/groups").Subrouter()
 	groups.Use(cfg.AuthenticatedUserMiddleware)
@@ -84,22 +197,42 @@ func main() {
 	groups.HandleFunc("/{group_id}/users", cfg.HandlerGetGroupUsers).Methods("GET")
 	groups.HandleFunc("/{group_id}/users", cfg.HandlerAddUserToGroup).Methods("POST")
 	groups.HandleFunc("/{group_id}/users", cfg.HandlerRemoveUserFromGroup).Methods("DELETE")
+	groups.HandleFunc("/{group_id}/users/{user_id}", cfg.HandlerUpdateMemberRole).Methods("PUT")
+	groups.HandleFunc("/{group_id}/placeholders", cfg.HandlerAddPlaceholder).Methods("POST")
+	groups.HandleFunc("/{group_id}/owner", cfg.HandlerTransferOwnership).Methods("PUT")
+	groups.HandleFunc("/{group_id}/leave", cfg.HandlerLeaveGroup).Methods("POST")
+	groups.HandleFunc("/{group_id}/invites", cfg.HandlerCreateInvite).Methods("POST")
+	groups.HandleFunc("/{group_id}/invites/{id}", cfg.HandlerRevokeInvite).Methods("DELETE")
 	groups.HandleFunc("/{group_id}/expenses", cfg.HandlerCreateExpense).Methods("POST")
 	groups.HandleFunc("/{group_id}/expenses", cfg.HandlerUpdateExpense).Queries("id", "{id}").Methods("PUT")
 	groups.HandleFunc("/{group_id}/payments", cfg.HandlerCreatePayment).Methods("POST")
 	groups.HandleFunc("/{group_id}/payments", cfg.HandlerUpdatePayment).Methods("PUT")
 	groups.HandleFunc("/{group_id}/transactions", cfg.HandlerDeleteTransaction).Methods("DELETE")
+	groups.HandleFunc("/{group_id}/transactions/{id}/restore", cfg.HandlerRestoreTransaction).Methods("POST")
+	groups.HandleFunc("/{group_id}/transactions/{id}/history", cfg.HandlerGetTransactionHistory).Methods("GET")
+	groups.HandleFunc("/{group_id}/settle-up", cfg.HandlerGetSettleUp).Methods("GET")
+	groups.HandleFunc("/{group_id}/recurring", cfg.HandlerCreateRecurring).Methods("POST")
+	groups.HandleFunc("/{group_id}/recurring/{id}", cfg.HandlerUpdateRecurring).Methods("PUT")
+	groups.HandleFunc("/{group_id}/recurring/{id}", cfg.HandlerDeleteRecurring).Methods("DELETE")
 
 	router.Handle("/", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerDashboard))).Methods("GET")
 	router.Handle("/signup", templ.Handler(pages.Signup())).Methods("GET")
 	router.Handle("/login", templ.Handler(pages.Login())).Methods("GET")
 	router.Handle("/logout", templ.Handler(pages.Logout())).Methods("GET")
+	router.Handle("/forgot-password", templ.Handler(pages.ForgotPassword())).Methods("GET")
+	router.HandleFunc("/reset-password", cfg.HandlerResetPasswordPage).Methods("GET")
+	router.HandleFunc("/verify-email", cfg.HandlerVerifyEmailPage).Methods("GET")
+	router.HandleFunc("/invite/{token}", cfg.HandlerInvitePage).Methods("GET")
 	router.Handle("/edit", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerEditPage))).Queries("id", "{id}").Methods("GET")
 	router.Handle("/groups/{group_id}", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerGroupPage))).Methods("GET")
+	router.Handle("/admin", cfg.AdminMiddleware(http.HandlerFunc(cfg.HandlerAdminPage))).Methods("GET")
+	router.Handle("/settings", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerSettingsPage))).Methods("GET")
 	router.Handle("/create-group", cfg.AuthenticatedUserMiddleware(templ.Handler(pages.CreateGroup())))
 	router.Handle("/groups/{group_id}/manage", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerManageGroupPage)))
 	router.Handle("/groups/{group_id}/create-expense", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerCreateExpensePage)))
 	router.Handle("/groups/{group_id}/create-payment", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerCreatePaymentPage)))
+	router.Handle("/groups/{group_id}/recurring", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerRecurringPage)))
+	router.Handle("/groups/{group_id}/trash", cfg.AuthenticatedUserMiddleware(http.HandlerFunc(cfg.HandlerTrashPage)))
 
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./web/static"))))
 
@@ -3,3 +3,36 @@ DELETE FROM groups;
 
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
+
+-- name: ListUsers :many
+SELECT users.*, COUNT(users_groups.id) AS group_count, EXISTS (
+    SELECT 1 FROM totp_credentials
+    WHERE totp_credentials.user_id = users.id AND totp_credentials.enabled_at IS NOT NULL
+) AS two_factor
+FROM users
+LEFT JOIN users_groups ON users_groups.user_id = users.id
+GROUP BY users.id
+ORDER BY users.username;
+
+-- name: ListGroups :many
+SELECT groups.*, owners.username AS owner_name, COUNT(users_groups.id) AS member_count
+FROM groups
+JOIN users owners ON owners.id = groups.owner
+LEFT JOIN users_groups ON users_groups.group_id = groups.id
+WHERE NOT groups.direct
+GROUP BY groups.id, owners.username
+ORDER BY groups.created_at;
+
+-- name: GetSiteStats :one
+SELECT
+    (SELECT COUNT(*) FROM users WHERE NOT placeholder) AS users,
+    (SELECT COUNT(*) FROM users WHERE placeholder) AS placeholders,
+    (SELECT COUNT(*) FROM users WHERE is_admin) AS admins,
+    (SELECT COUNT(*) FROM users WHERE disabled_at IS NOT NULL) AS disabled_users,
+    (SELECT COUNT(*) FROM groups WHERE NOT direct) AS groups,
+    (SELECT COUNT(*) FROM friendships) AS friendships,
+    (SELECT COUNT(*) FROM transactions WHERE deleted_at IS NULL) AS transactions,
+    (
+        SELECT COUNT(DISTINCT session_id) FROM refresh_tokens
+        WHERE revoked_at IS NULL AND expires_at > NOW()
+    ) AS active_sessions;
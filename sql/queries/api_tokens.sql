@@ -0,0 +1,27 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash, scope, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens
+WHERE token_hash = $1 AND expires_at > NOW();
+
+-- name: GetAPITokensByUser :many
+SELECT * FROM api_tokens
+WHERE user_id = $1 AND expires_at > NOW()
+ORDER BY created_at;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteAPIToken :one
+DELETE FROM api_tokens
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteExpiredAPITokens :execrows
+DELETE FROM api_tokens
+WHERE expires_at <= $1;
@@ -0,0 +1,8 @@
+-- name: CreateAuditEntry :exec
+INSERT INTO audit_log (group_id, actor_id, actor_name, entity, entity_id, action, before, after)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: GetAuditEntriesByEntity :many
+SELECT * FROM audit_log
+WHERE entity = $1 AND entity_id = $2
+ORDER BY created_at, id;
@@ -1,25 +1,3 @@
--- name: GetUserBalanceByGroup :one
-SELECT SUM(payments.amount) - SUM(debts.amount) AS balance FROM transactions
-INNER JOIN expenses ON transactions.id = expenses.transaction_id
-INNER JOIN debts ON expenses.id = debts.expense_id
-INNER JOIN payments ON transactions.id = payments.transaction_id
-WHERE transactions.group_id = $1
-AND debts.owed_to = $3
-AND debts.owed_by = $2
-AND payments.paid_to = $3
-AND payments.paid_by = $2;
-
--- name: GetSumOfDebts :one
-SELECT CAST(COALESCE(SUM(debts.amount), 0) AS NUMERIC(12, 2)) AS total FROM transactions
-INNER JOIN expenses ON transactions.id = expenses.transaction_id
-INNER JOIN debts ON expenses.id = debts.expense_id
-WHERE transactions.group_id = $1 AND debts.owed_by = $2 AND debts.owed_to = $3;
-
--- name: GetSumOfPayments :one
-SELECT CAST(COALESCE(SUM(payments.amount), 0) AS NUMERIC(12, 2)) AS total FROM transactions
-INNER JOIN payments ON transactions.id = payments.transaction_id
-WHERE transactions.group_id = $1 AND payments.paid_by = $2 AND payments.paid_to = $3;
-
 -- name: GetExpenseByTransaction :one
 SELECT * FROM expenses
 WHERE expenses.transaction_id = $1;
@@ -32,3 +10,65 @@ WHERE expenses.transaction_id = $1;
 -- name: GetPaymentByTransaction :one
 SELECT payments.* FROM payments
 WHERE payments.transaction_id = $1;
+
+-- name: GetExpensesByTransactions :many
+SELECT * FROM expenses
+WHERE expenses.transaction_id = ANY(sqlc.arg(transaction_ids)::UUID[]);
+
+-- name: GetDebtsByTransactions :many
+SELECT debts.* FROM expenses
+INNER JOIN debts ON expenses.id = debts.expense_id
+WHERE expenses.transaction_id = ANY(sqlc.arg(transaction_ids)::UUID[]);
+
+-- name: GetExpenseSplitsByTransactions :many
+SELECT expense_splits.* FROM expenses
+INNER JOIN expense_splits ON expenses.id = expense_splits.expense_id
+WHERE expenses.transaction_id = ANY(sqlc.arg(transaction_ids)::UUID[]);
+
+-- name: GetExpensePayersByTransactions :many
+SELECT expense_payers.* FROM expenses
+INNER JOIN expense_payers ON expenses.id = expense_payers.expense_id
+WHERE expenses.transaction_id = ANY(sqlc.arg(transaction_ids)::UUID[]);
+
+-- name: GetPaymentsByTransactions :many
+SELECT payments.* FROM payments
+WHERE payments.transaction_id = ANY(sqlc.arg(transaction_ids)::UUID[]);
+
+-- name: GetPairwiseBalancesByGroup :many
+SELECT ledger.creditor, ledger.debtor, ledger.currency, CAST(SUM(ledger.amount) AS NUMERIC(12, 2)) AS total FROM (
+    SELECT debts.owed_to AS creditor, debts.owed_by AS debtor, expenses.currency, debts.amount FROM transactions
+    INNER JOIN expenses ON transactions.id = expenses.transaction_id
+    INNER JOIN debts ON expenses.id = debts.expense_id
+    WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
+    UNION ALL
+    SELECT payments.paid_by AS creditor, payments.paid_to AS debtor, payments.currency, payments.amount FROM transactions
+    INNER JOIN payments ON transactions.id = payments.transaction_id
+    WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
+) AS ledger
+WHERE ledger.creditor IS NOT NULL AND ledger.debtor IS NOT NULL
+GROUP BY ledger.creditor, ledger.debtor, ledger.currency;
+
+-- name: GetPairwiseBalancesByUser :many
+SELECT ledger.creditor, ledger.debtor, ledger.currency, CAST(SUM(ledger.amount) AS NUMERIC(12, 2)) AS total FROM (
+    SELECT debts.owed_to AS creditor, debts.owed_by AS debtor, expenses.currency, debts.amount FROM transactions
+    INNER JOIN expenses ON transactions.id = expenses.transaction_id
+    INNER JOIN debts ON expenses.id = debts.expense_id
+    WHERE transactions.deleted_at IS NULL
+    AND (debts.owed_to = sqlc.arg(user_id)::UUID OR debts.owed_by = sqlc.arg(user_id)::UUID)
+    UNION ALL
+    SELECT payments.paid_by AS creditor, payments.paid_to AS debtor, payments.currency, payments.amount FROM transactions
+    INNER JOIN payments ON transactions.id = payments.transaction_id
+    WHERE transactions.deleted_at IS NULL
+    AND (payments.paid_by = sqlc.arg(user_id)::UUID OR payments.paid_to = sqlc.arg(user_id)::UUID)
+) AS ledger
+WHERE ledger.creditor IS NOT NULL AND ledger.debtor IS NOT NULL
+GROUP BY ledger.creditor, ledger.debtor, ledger.currency;
+
+-- name: GetCurrenciesByGroup :many
+SELECT expenses.currency FROM transactions
+INNER JOIN expenses ON transactions.id = expenses.transaction_id
+WHERE transactions.group_id = $1
+UNION
+SELECT payments.currency FROM transactions
+INNER JOIN payments ON transactions.id = payments.transaction_id
+WHERE transactions.group_id = $1;
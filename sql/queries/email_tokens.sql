@@ -0,0 +1,28 @@
+-- name: CreateEmailToken :one
+INSERT INTO email_tokens (user_id, purpose, email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetEmailTokenByHash :one
+SELECT * FROM email_tokens
+WHERE token_hash = $1
+AND purpose = $2
+AND used_at IS NULL
+AND expires_at > NOW();
+
+-- name: UseEmailToken :one
+UPDATE email_tokens
+SET used_at = sqlc.arg(now)::TIMESTAMPTZ
+WHERE token_hash = sqlc.arg(token_hash)
+AND purpose = sqlc.arg(purpose)
+AND used_at IS NULL
+AND expires_at > sqlc.arg(now)::TIMESTAMPTZ
+RETURNING *;
+
+-- name: DeleteEmailTokensByUser :exec
+DELETE FROM email_tokens
+WHERE user_id = $1 AND purpose = $2;
+
+-- name: DeleteExpiredEmailTokens :execrows
+DELETE FROM email_tokens
+WHERE expires_at <= $1;
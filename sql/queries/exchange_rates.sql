@@ -0,0 +1,26 @@
+-- name: SetExchangeRate :one
+INSERT INTO exchange_rates (base, quote, rate)
+VALUES ($1, $2, $3)
+ON CONFLICT (base, quote) DO UPDATE
+SET rate = EXCLUDED.rate, updated_at = NOW()
+RETURNING *;
+
+-- name: GetExchangeRates :many
+SELECT * FROM exchange_rates
+ORDER BY base, quote;
+
+-- name: DeleteExchangeRate :exec
+DELETE FROM exchange_rates
+WHERE base = $1 AND quote = $2;
+
+-- name: GetCurrencyPairsInUse :many
+SELECT expenses.currency, groups.currency AS group_currency FROM transactions
+INNER JOIN expenses ON transactions.id = expenses.transaction_id
+INNER JOIN groups ON transactions.group_id = groups.id
+UNION
+SELECT payments.currency, groups.currency AS group_currency FROM transactions
+INNER JOIN payments ON transactions.id = payments.transaction_id
+INNER JOIN groups ON transactions.group_id = groups.id
+UNION
+SELECT recurring_transactions.currency, groups.currency AS group_currency FROM recurring_transactions
+INNER JOIN groups ON recurring_transactions.group_id = groups.id;
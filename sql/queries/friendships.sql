@@ -0,0 +1,17 @@
+-- name: CreateFriendship :one
+INSERT INTO friendships (user_id, friend_id, group_id)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetFriendship :one
+SELECT * FROM friendships
+WHERE user_id = $1 AND friend_id = $2;
+
+-- name: GetFriendsByUser :many
+SELECT friendships.group_id, users.* FROM friendships
+INNER JOIN users ON users.id = CASE
+    WHEN friendships.user_id = sqlc.arg(user_id)::UUID THEN friendships.friend_id
+    ELSE friendships.user_id
+END
+WHERE friendships.user_id = sqlc.arg(user_id)::UUID OR friendships.friend_id = sqlc.arg(user_id)::UUID
+ORDER BY users.username;
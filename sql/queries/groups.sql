@@ -1,11 +1,17 @@
 -- name: CreateGroup :one
-INSERT INTO groups (name, owner)
-VALUES ($1, $2)
+INSERT INTO groups (name, owner, currency)
+VALUES ($1, $2, $3)
 RETURNING *;
 
--- name: UpdateGroupName :one
+-- name: UpdateGroup :one
 UPDATE groups
-SET name = $2, updated_at = NOW()
+SET name = $2, currency = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateGroupOwner :one
+UPDATE groups
+SET owner = $2, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
@@ -13,10 +19,15 @@ RETURNING *;
 SELECT * FROM groups
 WHERE id = $1;
 
+-- name: CreateDirectGroup :one
+INSERT INTO groups (name, owner, currency, direct)
+VALUES ($1, $2, $3, TRUE)
+RETURNING *;
+
 -- name: GetGroupsByUser :many
 SELECT groups.* FROM groups
 INNER JOIN users_groups ON groups.id = users_groups.group_id
-WHERE users_groups.user_id = $1;
+WHERE users_groups.user_id = $1 AND NOT groups.direct;
 
 -- name: GetUserGroup :one
 SELECT * FROM users_groups
@@ -27,6 +38,11 @@ SELECT users.* FROM users
 INNER JOIN users_groups ON users.id = users_groups.user_id
 WHERE users_groups.group_id = $1;
 
+-- name: GetGroupMemberByUsername :one
+SELECT users.* FROM users
+INNER JOIN users_groups ON users.id = users_groups.user_id
+WHERE users_groups.group_id = $1 AND users.username = $2;
+
 -- name: GetOtherUsersInGroup :many
 SELECT users_groups.group_id AS group_id, users.* FROM users
 INNER JOIN users_groups ON users.id = users_groups.user_id
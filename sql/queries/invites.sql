@@ -0,0 +1,18 @@
+-- name: CreateGroupInvite :one
+INSERT INTO group_invites (group_id, created_by, role, single_use, expires_at, placeholder_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetGroupInvite :one
+SELECT * FROM group_invites
+WHERE id = $1;
+
+-- name: GetPendingInvitesByGroup :many
+SELECT * FROM group_invites
+WHERE group_id = $1 AND expires_at > NOW()
+ORDER BY created_at;
+
+-- name: DeleteGroupInvite :one
+DELETE FROM group_invites
+WHERE id = $1 AND group_id = $2
+RETURNING *;
@@ -0,0 +1,60 @@
+-- name: CreateRecurringTransaction :one
+INSERT INTO recurring_transactions (
+    group_id, created_by, kind, frequency, starts_on, ends_on, next_occurrence,
+    description, amount, currency, paid_by, paid_to, split_mode
+)
+VALUES ($1, $2, $3, $4, $5, $6, $5, $7, $8, $9, $10, $11, $12)
+RETURNING *;
+
+-- name: GetRecurringTransaction :one
+SELECT * FROM recurring_transactions
+WHERE id = $1;
+
+-- name: GetRecurringTransactionsByGroup :many
+SELECT * FROM recurring_transactions
+WHERE group_id = $1
+ORDER BY next_occurrence, created_at;
+
+-- name: UpdateRecurringTransaction :one
+UPDATE recurring_transactions
+SET frequency = $2, starts_on = $3, ends_on = $4, next_occurrence = $5, paused = $6,
+    description = $7, amount = $8, currency = $9, paid_by = $10, paid_to = $11,
+    split_mode = $12, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: AdvanceRecurringTransaction :exec
+UPDATE recurring_transactions
+SET next_occurrence = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: PauseRecurringTransaction :exec
+UPDATE recurring_transactions
+SET paused = TRUE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteRecurringTransaction :exec
+DELETE FROM recurring_transactions
+WHERE id = $1;
+
+-- name: GetDueRecurringTransaction :one
+SELECT * FROM recurring_transactions
+WHERE NOT paused
+AND next_occurrence <= sqlc.arg(today)::DATE
+AND (ends_on IS NULL OR next_occurrence <= ends_on)
+ORDER BY next_occurrence
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: CreateRecurringSplit :one
+INSERT INTO recurring_splits (recurring_id, user_id, value)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetRecurringSplitsByRecurring :many
+SELECT * FROM recurring_splits
+WHERE recurring_id = $1;
+
+-- name: DeleteRecurringSplitsByRecurring :exec
+DELETE FROM recurring_splits
+WHERE recurring_id = $1;
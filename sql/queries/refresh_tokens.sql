@@ -0,0 +1,40 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, session_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetRefreshTokenByHash :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
+
+-- name: UseRefreshToken :one
+UPDATE refresh_tokens
+SET used_at = sqlc.arg(now)::TIMESTAMPTZ
+WHERE token_hash = sqlc.arg(token_hash)
+AND used_at IS NULL
+AND revoked_at IS NULL
+AND expires_at > sqlc.arg(now)::TIMESTAMPTZ
+RETURNING *;
+
+-- name: IsSessionActive :one
+SELECT EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE session_id = $1
+    AND user_id = $2
+    AND revoked_at IS NULL
+    AND expires_at > NOW()
+);
+
+-- name: RevokeSession :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE session_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeSessionsByUser :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at <= $1;
@@ -1,18 +1,68 @@
 -- name: CreateExpense :one
-INSERT INTO expenses (transaction_id, paid_by, description, amount)
-VALUES ($1, $2, $3, $4)
+INSERT INTO expenses (transaction_id, paid_by, description, amount, split_mode, currency)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: UpdateExpense :one
 UPDATE expenses
-SET paid_by = $2, description = $3, amount = $4
+SET paid_by = $2, description = $3, amount = $4, split_mode = $5, currency = $6
 WHERE id = $1
 RETURNING *;
 
+-- name: CreateExpenseSplit :one
+INSERT INTO expense_splits (expense_id, user_id, value)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetExpenseSplitsByExpense :many
+SELECT * FROM expense_splits
+WHERE expense_id = $1;
+
+-- name: DeleteExpenseSplitsByExpense :exec
+DELETE FROM expense_splits
+WHERE expense_id = $1;
+
+-- name: CreateExpensePayer :one
+INSERT INTO expense_payers (expense_id, user_id, amount)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetExpensePayersByExpense :many
+SELECT * FROM expense_payers
+WHERE expense_id = $1;
+
+-- name: DeleteExpensePayersByExpense :exec
+DELETE FROM expense_payers
+WHERE expense_id = $1;
+
+-- name: CreateExpenseItem :one
+INSERT INTO expense_items (expense_id, kind, description, amount, position)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: CreateExpenseItemMember :one
+INSERT INTO expense_item_members (item_id, user_id)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: GetExpenseItemsByExpense :many
+SELECT * FROM expense_items
+WHERE expense_id = $1
+ORDER BY position;
+
+-- name: GetExpenseItemMembersByExpense :many
+SELECT expense_item_members.* FROM expense_items
+INNER JOIN expense_item_members ON expense_items.id = expense_item_members.item_id
+WHERE expense_items.expense_id = $1;
+
+-- name: DeleteExpenseItemsByExpense :exec
+DELETE FROM expense_items
+WHERE expense_id = $1;
+
 -- name: GetExpensesByGroup :many
 SELECT expenses.* FROM expenses
 INNER JOIN transactions ON expenses.transaction_id = transactions.id
-WHERE transactions.group_id = $1
+WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
 ORDER BY transactions.updated_at;
 
 -- name: CreateDebt :one
@@ -31,8 +81,8 @@ DELETE FROM debts
 WHERE expense_id = $1;
 
 -- name: CreateTransaction :one
-INSERT INTO transactions (group_id, created_by, kind)
-VALUES ($1, $2, $3)
+INSERT INTO transactions (group_id, created_by, kind, occurred_on, recurring_id)
+VALUES ($1, $2, $3, COALESCE(sqlc.narg(occurred_on)::DATE, CURRENT_DATE), sqlc.narg(recurring_id))
 RETURNING *;
 
 -- name: GetTransaction :one
@@ -41,32 +91,59 @@ WHERE id = $1;
 
 -- name: GetTransactionsByGroup :many
 SELECT * FROM transactions
-WHERE group_id = $1
-ORDER BY updated_at DESC;
+WHERE group_id = $1 AND deleted_at IS NULL
+ORDER BY occurred_on DESC, created_at DESC;
+
+-- name: GetTransactionsByGroupPage :many
+SELECT * FROM transactions
+WHERE group_id = $1 AND deleted_at IS NULL
+ORDER BY occurred_on DESC, created_at DESC, id
+LIMIT $2 OFFSET $3;
 
 -- name: UpdateTransaction :one
 UPDATE transactions
-SET updated_at = NOW()
-WHERE id = $1
+SET updated_at = NOW(), occurred_on = COALESCE(sqlc.narg(occurred_on)::DATE, occurred_on)
+WHERE id = sqlc.arg(id)
 RETURNING *;
 
 -- name: DeleteTransaction :exec
 DELETE FROM transactions
 WHERE id = $1;
 
+-- name: SoftDeleteTransaction :exec
+UPDATE transactions
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreTransaction :one
+UPDATE transactions
+SET deleted_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: GetDeletedTransactionsByGroup :many
+SELECT * FROM transactions
+WHERE group_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: PurgeDeletedTransactions :many
+DELETE FROM transactions
+WHERE deleted_at < sqlc.arg(before)::TIMESTAMPTZ
+RETURNING id, group_id;
+
 -- name: CreatePayment :one
-INSERT INTO payments (transaction_id, paid_by, paid_to, amount)
-VALUES ($1, $2, $3, $4)
+INSERT INTO payments (transaction_id, paid_by, paid_to, amount, currency)
+VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
 -- name: UpdatePayment :one
 UPDATE payments
-SET amount = $2, paid_by = $3, paid_to = $4
+SET amount = $2, paid_by = $3, paid_to = $4, currency = $5
 WHERE id = $1
 RETURNING *;
 
 -- name: GetPaymentsByGroup :many
 SELECT * FROM payments
 INNER JOIN transactions ON payments.transaction_id = transactions.id
-WHERE transactions.group_id = $1
+WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
 ORDER BY transactions.updated_at;
@@ -0,0 +1,45 @@
+-- name: StartTOTPCredential :one
+INSERT INTO totp_credentials (user_id, encrypted_secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET encrypted_secret = EXCLUDED.encrypted_secret, last_counter = 0, created_at = NOW()
+WHERE totp_credentials.enabled_at IS NULL
+RETURNING *;
+
+-- name: GetTOTPCredential :one
+SELECT * FROM totp_credentials
+WHERE user_id = $1;
+
+-- name: EnableTOTPCredential :one
+UPDATE totp_credentials
+SET enabled_at = NOW(), last_counter = $2
+WHERE user_id = $1 AND enabled_at IS NULL
+RETURNING *;
+
+-- name: UseTOTPCounter :one
+UPDATE totp_credentials
+SET last_counter = $2
+WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_counter < $2
+RETURNING *;
+
+-- name: DeleteTOTPCredential :exec
+DELETE FROM totp_credentials
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+RETURNING *;
+
+-- name: CountRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
@@ -1,18 +1,134 @@
 -- name: CreateUser :one
-INSERT INTO users (username, hashed_password)
-VALUES ($1, $2)
+INSERT INTO users (username, hashed_password, email)
+VALUES ($1, $2, $3)
 RETURNING *;
 
 -- name: GetUserByID :one
 SELECT * FROM users
 WHERE id = $1;
 
+-- name: GetUsersByIDs :many
+SELECT * FROM users
+WHERE id = ANY(sqlc.arg(ids)::UUID[]);
+
 -- name: GetUserByUsername :one
 SELECT * FROM users
-WHERE username = $1;
+WHERE username = $1 AND NOT placeholder;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = sqlc.arg(email)::TEXT AND email_verified_at IS NOT NULL;
 
 -- name: UpdatePassword :one
 UPDATE users
-SET hashed_password = $2, updated_at = NOW()
+SET hashed_password = $2, password_changed_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CreatePlaceholderUser :one
+INSERT INTO users (username, hashed_password, placeholder)
+VALUES ($1, '', TRUE)
+RETURNING *;
+
+-- name: DeletePlaceholderUser :one
+DELETE FROM users
+WHERE id = $1 AND placeholder
+RETURNING *;
+
+-- name: ReassignUser :exec
+WITH
+    memberships AS (
+        UPDATE users_groups SET user_id = sqlc.arg(to_id)::UUID
+        WHERE user_id = sqlc.arg(from_id)::UUID
+    ),
+    expenses_paid AS (
+        UPDATE expenses SET paid_by = sqlc.arg(to_id)::UUID
+        WHERE paid_by = sqlc.arg(from_id)::UUID
+    ),
+    payers AS (
+        UPDATE expense_payers SET user_id = sqlc.arg(to_id)::UUID
+        WHERE user_id = sqlc.arg(from_id)::UUID
+    ),
+    splits AS (
+        UPDATE expense_splits SET user_id = sqlc.arg(to_id)::UUID
+        WHERE user_id = sqlc.arg(from_id)::UUID
+    ),
+    item_members AS (
+        UPDATE expense_item_members SET user_id = sqlc.arg(to_id)::UUID
+        WHERE user_id = sqlc.arg(from_id)::UUID
+    ),
+    debts_moved AS (
+        UPDATE debts SET
+            owed_by = CASE WHEN owed_by = sqlc.arg(from_id)::UUID THEN sqlc.arg(to_id)::UUID ELSE owed_by END,
+            owed_to = CASE WHEN owed_to = sqlc.arg(from_id)::UUID THEN sqlc.arg(to_id)::UUID ELSE owed_to END
+        WHERE owed_by = sqlc.arg(from_id)::UUID OR owed_to = sqlc.arg(from_id)::UUID
+    ),
+    payments_moved AS (
+        UPDATE payments SET
+            paid_by = CASE WHEN paid_by = sqlc.arg(from_id)::UUID THEN sqlc.arg(to_id)::UUID ELSE paid_by END,
+            paid_to = CASE WHEN paid_to = sqlc.arg(from_id)::UUID THEN sqlc.arg(to_id)::UUID ELSE paid_to END
+        WHERE paid_by = sqlc.arg(from_id)::UUID OR paid_to = sqlc.arg(from_id)::UUID
+    ),
+    recurring_moved AS (
+        UPDATE recurring_transactions SET
+            paid_by = CASE WHEN paid_by = sqlc.arg(from_id)::UUID THEN sqlc.arg(to_id)::UUID ELSE paid_by END,
+            paid_to = CASE WHEN paid_to = sqlc.arg(from_id)::UUID THEN sqlc.arg(to_id)::UUID ELSE paid_to END
+        WHERE paid_by = sqlc.arg(from_id)::UUID OR paid_to = sqlc.arg(from_id)::UUID
+    )
+UPDATE recurring_splits SET user_id = sqlc.arg(to_id)::UUID
+WHERE user_id = sqlc.arg(from_id)::UUID;
+
+-- name: SetUserAdmin :one
+UPDATE users
+SET is_admin = $2, updated_at = NOW()
+WHERE id = $1 AND NOT placeholder
+RETURNING *;
+
+-- name: DisableUser :one
+UPDATE users
+SET disabled_at = COALESCE(disabled_at, NOW()), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: EnableUser :one
+UPDATE users
+SET disabled_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RecordFailedLogin :one
+UPDATE users
+SET
+    failed_logins = CASE
+        WHEN failed_logins + 1 >= sqlc.arg(max_failed_logins)::INTEGER THEN 0
+        ELSE failed_logins + 1
+    END,
+    locked_until = CASE
+        WHEN failed_logins + 1 >= sqlc.arg(max_failed_logins)::INTEGER THEN sqlc.arg(locked_until)::TIMESTAMPTZ
+        ELSE locked_until
+    END
+WHERE id = sqlc.arg(id)::UUID
+RETURNING *;
+
+-- name: UnlockUser :one
+UPDATE users
+SET failed_logins = 0, locked_until = NULL
 WHERE id = $1
 RETURNING *;
+
+-- name: SetUserEmail :one
+UPDATE users
+SET email = $2, email_verified_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg(id) AND email = sqlc.arg(email)::TEXT
+RETURNING *;
+
+-- name: ClearUnverifiedEmail :exec
+UPDATE users
+SET email = NULL, updated_at = NOW()
+WHERE email = sqlc.arg(email)::TEXT AND id <> sqlc.arg(id) AND email_verified_at IS NULL;
@@ -1,8 +1,18 @@
 -- name: CreateUserGroup :one
-INSERT INTO users_groups (id, user_id, group_id)
-VALUES (GEN_RANDOM_UUID(), $1, $2)
+INSERT INTO users_groups (id, user_id, group_id, role)
+VALUES (GEN_RANDOM_UUID(), $1, $2, $3)
 RETURNING *;
 
+-- name: UpdateUserGroupRole :one
+UPDATE users_groups
+SET role = $3
+WHERE group_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: GetUserGroupsByGroup :many
+SELECT * FROM users_groups
+WHERE group_id = $1;
+
 -- name: DeleteUserGroup :one
 DELETE FROM users_groups
 WHERE group_id = $1 AND user_id = $2
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE expenses
+ADD COLUMN split_mode TEXT NOT NULL DEFAULT 'equal';
+
+CREATE TABLE expense_splits (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    value NUMERIC(12, 4) NOT NULL,
+    UNIQUE (expense_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE expense_splits;
+
+ALTER TABLE expenses
+DROP COLUMN split_mode;
+-- +goose StatementEnd
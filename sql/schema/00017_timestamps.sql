@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The dates of existing rows were never stored, so assume they happened
+-- today at the time of day that was recorded.
+ALTER TABLE groups
+ALTER COLUMN created_at TYPE TIMESTAMPTZ USING CURRENT_DATE + created_at,
+ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING CURRENT_DATE + updated_at;
+
+ALTER TABLE transactions
+ALTER COLUMN created_at TYPE TIMESTAMPTZ USING CURRENT_DATE + created_at,
+ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING CURRENT_DATE + updated_at,
+ADD COLUMN occurred_on DATE NOT NULL DEFAULT CURRENT_DATE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions
+DROP COLUMN occurred_on,
+ALTER COLUMN created_at TYPE TIME USING created_at::TIME,
+ALTER COLUMN updated_at TYPE TIME USING updated_at::TIME;
+
+ALTER TABLE groups
+ALTER COLUMN created_at TYPE TIME USING created_at::TIME,
+ALTER COLUMN updated_at TYPE TIME USING updated_at::TIME;
+-- +goose StatementEnd
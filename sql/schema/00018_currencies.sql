@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE groups
+ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+ALTER TABLE expenses
+ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+ALTER TABLE payments
+ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+CREATE TABLE exchange_rates (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    base TEXT NOT NULL,
+    quote TEXT NOT NULL,
+    rate NUMERIC(18, 8) NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (base, quote)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE exchange_rates;
+
+ALTER TABLE payments
+DROP COLUMN currency;
+
+ALTER TABLE expenses
+DROP COLUMN currency;
+
+ALTER TABLE groups
+DROP COLUMN currency;
+-- +goose StatementEnd
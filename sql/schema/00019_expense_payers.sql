@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE expense_payers (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+    UNIQUE (expense_id, user_id)
+);
+
+INSERT INTO expense_payers (expense_id, user_id, amount)
+SELECT id, paid_by, amount FROM expenses
+WHERE paid_by IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE expense_payers;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE expense_items (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    expense_id UUID NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL DEFAULT 'item',
+    description TEXT NOT NULL DEFAULT '',
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+    position INTEGER NOT NULL
+);
+
+CREATE TABLE expense_item_members (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    item_id UUID NOT NULL REFERENCES expense_items(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE (item_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE expense_item_members;
+
+DROP TABLE expense_items;
+-- +goose StatementEnd
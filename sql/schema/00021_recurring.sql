@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE recurring_transactions (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    kind TEXT NOT NULL,
+    frequency TEXT NOT NULL,
+    starts_on DATE NOT NULL,
+    ends_on DATE,
+    next_occurrence DATE NOT NULL,
+    paused BOOLEAN NOT NULL DEFAULT FALSE,
+    description TEXT NOT NULL DEFAULT '',
+    amount NUMERIC(12, 2) NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'USD',
+    paid_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    paid_to UUID REFERENCES users(id) ON DELETE SET NULL,
+    split_mode TEXT NOT NULL DEFAULT 'equal',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE recurring_splits (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    recurring_id UUID NOT NULL REFERENCES recurring_transactions(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    value NUMERIC(12, 4) NOT NULL,
+    UNIQUE (recurring_id, user_id)
+);
+
+ALTER TABLE transactions
+ADD COLUMN recurring_id UUID REFERENCES recurring_transactions(id) ON DELETE SET NULL;
+
+-- Each occurrence of a schedule is only ever created once.
+CREATE UNIQUE INDEX transactions_recurring_occurrence ON transactions (recurring_id, occurred_on);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX transactions_recurring_occurrence;
+
+ALTER TABLE transactions
+DROP COLUMN recurring_id;
+
+DROP TABLE recurring_splits;
+
+DROP TABLE recurring_transactions;
+-- +goose StatementEnd
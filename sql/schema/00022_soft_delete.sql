@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transactions
+ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX transactions_deleted_at ON transactions (deleted_at)
+WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX transactions_deleted_at;
+
+ALTER TABLE transactions
+DROP COLUMN deleted_at;
+-- +goose StatementEnd
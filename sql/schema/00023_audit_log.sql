@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Entries keep the actor's name and have no foreign keys on the actor or the
+-- entity, so that they still make sense after either has been deleted.
+CREATE TABLE audit_log (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    actor_id UUID,
+    actor_name TEXT NOT NULL DEFAULT '',
+    entity TEXT NOT NULL,
+    entity_id UUID NOT NULL,
+    action TEXT NOT NULL,
+    before JSONB NOT NULL DEFAULT 'null',
+    after JSONB NOT NULL DEFAULT 'null',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CLOCK_TIMESTAMP()
+);
+
+CREATE INDEX audit_log_entity ON audit_log (entity, entity_id, created_at);
+
+CREATE FUNCTION audit_log_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log entries can not be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_immutable
+BEFORE UPDATE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER audit_log_immutable ON audit_log;
+
+DROP FUNCTION audit_log_immutable;
+
+DROP TABLE audit_log;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users_groups
+ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+CHECK (role IN ('admin', 'member', 'viewer'));
+
+UPDATE users_groups
+SET role = 'admin'
+FROM groups
+WHERE groups.id = users_groups.group_id AND groups.owner = users_groups.user_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users_groups
+DROP COLUMN role;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE group_invites (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    role TEXT NOT NULL DEFAULT 'member'
+    CHECK (role IN ('admin', 'member', 'viewer')),
+    single_use BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE group_invites;
+-- +goose StatementEnd
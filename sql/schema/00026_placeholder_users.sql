@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN placeholder BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE group_invites
+ADD COLUMN placeholder_id UUID REFERENCES users(id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE group_invites
+DROP COLUMN placeholder_id;
+
+ALTER TABLE users
+DROP COLUMN placeholder;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A direct group holds the ledger between two friends. It's hidden from the
+-- list of groups and its members can't change.
+ALTER TABLE groups
+ADD COLUMN direct BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE friendships (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    friend_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Each pair of friends is stored once, with the lower ID first.
+    CHECK (user_id < friend_id),
+    UNIQUE (user_id, friend_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE friendships;
+
+ALTER TABLE groups
+DROP COLUMN direct;
+-- +goose StatementEnd
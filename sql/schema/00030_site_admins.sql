@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN disabled_at TIMESTAMPTZ,
+ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN locked_until TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN is_admin,
+DROP COLUMN disabled_at,
+DROP COLUMN failed_logins,
+DROP COLUMN locked_until;
+-- +goose StatementEnd
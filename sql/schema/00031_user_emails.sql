@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN email TEXT UNIQUE,
+ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+CREATE TABLE email_tokens (
+    id UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL CHECK (purpose IN ('verify', 'reset')),
+    email TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX email_tokens_user_id_idx ON email_tokens (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE email_tokens;
+
+ALTER TABLE users
+DROP COLUMN email,
+DROP COLUMN email_verified_at;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Placeholders are only named within their group, so they don't take the
+-- username from anyone else.
+ALTER TABLE users
+DROP CONSTRAINT users_username_key;
+
+CREATE UNIQUE INDEX users_username_key ON users (username)
+WHERE NOT placeholder;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_username_key;
+
+ALTER TABLE users
+ADD CONSTRAINT users_username_key UNIQUE (username);
+-- +goose StatementEnd
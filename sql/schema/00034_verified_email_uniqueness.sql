@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Only verified addresses are unique, so nobody can keep an address from
+-- its owner by adding it to their account and never verifying it.
+ALTER TABLE users
+DROP CONSTRAINT users_email_key;
+
+CREATE UNIQUE INDEX users_email_key ON users (email)
+WHERE email_verified_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_email_key;
+
+ALTER TABLE users
+ADD CONSTRAINT users_email_key UNIQUE (email);
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Logins waiting on their second step are refused if the password has
+-- changed since the right one was given.
+ALTER TABLE users
+ADD COLUMN password_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN password_changed_at;
+-- +goose StatementEnd
@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+// adminRequest sends payload, if any, to an admin only handler as the user of
+// cookie.
+func adminRequest(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, method, path string, vars map[string]string, handler http.HandlerFunc, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	if payload != nil {
+		require.NoError(t, json.NewEncoder(&body).Encode(payload))
+	}
+
+	r := httptest.NewRequest(method, path, &body)
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, vars)
+	rr := httptest.NewRecorder()
+
+	cfg.AdminMiddleware(handler).ServeHTTP(rr, r)
+
+	return rr
+}
+
+// signupAdmin creates a site admin and returns it along with its session
+// cookie.
+func signupAdmin(t testing.TB, cfg *handlers.Config, username string) (handlers.ExportUser, *http.Cookie) {
+	t.Helper()
+
+	admin, cookie := signup(t, cfg, username)
+
+	_, err := cfg.Queries.SetUserAdmin(t.Context(), database.SetUserAdminParams{ID: admin.ID, IsAdmin: true})
+	require.NoError(t, err)
+
+	return admin, cookie
+}
+
+func tryLogin(cfg *handlers.Config, username, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(handlers.LoginUserData{Username: username, Password: password})
+
+	r := httptest.NewRequest("POST", "/api/login", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	cfg.HandlerLogin(rr, r)
+
+	return rr
+}
+
+func TestAdminOnly(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, userCookie := signup(t, cfg, "user")
+	_, adminCookie := signupAdmin(t, cfg, "admin")
+	createGroupWithMembers(t, cfg, userCookie)
+
+	rr := adminRequest(t, cfg, userCookie, "GET", "/api/admin/stats", nil, cfg.HandlerAdminGetStats, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = adminRequest(t, cfg, adminCookie, "GET", "/api/admin/stats", nil, cfg.HandlerAdminGetStats, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	stats := handlers.ExportSiteStats{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&stats))
+	assert.EqualValues(t, 2, stats.Users)
+	assert.EqualValues(t, 1, stats.Admins)
+	assert.EqualValues(t, 1, stats.Groups)
+
+	rr = adminRequest(t, cfg, adminCookie, "GET", "/api/admin/users", nil, cfg.HandlerAdminGetUsers, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	users := []handlers.ExportAdminUser{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&users))
+	require.Len(t, users, 2)
+	assert.Equal(t, "admin", users[0].Username)
+	assert.True(t, users[0].Admin)
+	assert.EqualValues(t, 1, users[1].GroupCount)
+
+	rr = adminRequest(t, cfg, adminCookie, "GET", "/api/admin/groups", nil, cfg.HandlerAdminGetGroups, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	groups := []handlers.ExportAdminGroup{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&groups))
+	require.Len(t, groups, 1)
+	assert.Equal(t, "user", groups[0].OwnerName)
+
+	// Admins can't use API tokens for the console.
+	apiToken := createAPIToken(t, cfg, adminCookie, handlers.CreateAPITokenData{Name: "admin", Scope: "write"})
+	r := httptest.NewRequest("GET", "/api/admin/stats", nil)
+	r.Header.Set("Authorization", "Bearer "+apiToken.Token)
+	rr = httptest.NewRecorder()
+	cfg.AdminMiddleware(http.HandlerFunc(cfg.HandlerAdminGetStats)).ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	// Reset is refused unless the server allows it.
+	rr = adminRequest(t, cfg, adminCookie, "POST", "/api/reset", nil, cfg.HandlerReset, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
+
+func TestDisableUser(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	user, userCookie := signup(t, cfg, "user")
+	admin, adminCookie := signupAdmin(t, cfg, "admin")
+	userToken := createAPIToken(t, cfg, userCookie, handlers.CreateAPITokenData{Name: "script"})
+
+	setDisabled := func(id string, disabled bool) *httptest.ResponseRecorder {
+		return adminRequest(
+			t,
+			cfg,
+			adminCookie,
+			"PUT",
+			"/api/admin/users/"+id+"/disabled",
+			map[string]string{"id": id},
+			cfg.HandlerAdminSetDisabled,
+			handlers.SetDisabledData{Disabled: disabled},
+		)
+	}
+
+	rr := setDisabled(admin.ID.String(), true)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = setDisabled(user.ID.String(), true)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// Disabled users are logged out, and can't log in or use their tokens.
+	rr = getBalances(t, cfg, userCookie)
+	assert.Equal(t, http.StatusSeeOther, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = tokenRequest(cfg, userToken.Token, "GET", "/api/balances", nil, cfg.HandlerGetBalances, "")
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = setDisabled(user.ID.String(), false)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+}
+
+func TestLoginLockout(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	user, _ := signup(t, cfg, "user")
+	_, adminCookie := signupAdmin(t, cfg, "admin")
+
+	for range api.MaxFailedLogins {
+		rr := tryLogin(cfg, "user", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+	}
+
+	rr := tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, rr.Body.String())
+
+	rr = adminRequest(
+		t,
+		cfg,
+		adminCookie,
+		"POST",
+		"/api/admin/users/"+user.ID.String()+"/unlock",
+		map[string]string{"id": user.ID.String()},
+		cfg.HandlerAdminUnlock,
+		nil,
+	)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+}
+
+func TestAdminResetPassword(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	user, userCookie := signup(t, cfg, "user")
+	_, adminCookie := signupAdmin(t, cfg, "admin")
+
+	rr := adminRequest(
+		t,
+		cfg,
+		adminCookie,
+		"PUT",
+		"/api/admin/users/"+user.ID.String()+"/password",
+		map[string]string{"id": user.ID.String()},
+		cfg.HandlerAdminResetPassword,
+		handlers.ResetPasswordData{Password: "temporary"},
+	)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = getBalances(t, cfg, userCookie)
+	assert.Equal(t, http.StatusSeeOther, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "temporary")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func TestGetSettleUp(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, cookie := signup(t, cfg, "owner")
+	signup(t, cfg, "first")
+	signup(t, cfg, "second")
+
+	group := createGroupWithMembers(t, cfg, cookie, "first", "second")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Rent",
+		"amount":      "30.00",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	r := httptest.NewRequest("GET", "/api/groups/"+group.ID.String()+"/settle-up", nil)
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String()})
+	rr = httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerGetSettleUp),
+	).ServeHTTP(rr, r)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	payments := []accounting.SuggestedPayment{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&payments))
+
+	require.Len(t, payments, 2)
+	for _, p := range payments {
+		assert.Equal(t, owner.ID, p.To.ID)
+		assert.True(t, decimal.RequireFromString("10").Equal(p.Amount))
+	}
+}
+
+func TestSettleUpIncludesFormerMembers(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, cookie := signup(t, cfg, "owner")
+	member, _ := signup(t, cfg, "member")
+	group := createGroupWithMembers(t, cfg, cookie, "member")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Dinner",
+		"amount":      "20.00",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = groupRequest(
+		t,
+		cfg,
+		cookie,
+		"DELETE",
+		"/api/groups/"+group.ID.String()+"/users",
+		map[string]string{"group_id": group.ID.String()},
+		cfg.HandlerRemoveUserFromGroup,
+		handlers.RemoveUserFromGroupData{ID: member.ID, Force: true},
+	)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	// The removed member still owes the owner for dinner.
+	balances, err := accounting.GetNetBalancesForGroup(cfg.Queries, t.Context(), group.ID)
+	require.NoError(t, err)
+
+	total := decimal.Zero
+	for _, b := range balances {
+		total = total.Add(b.Amount)
+	}
+	assert.True(t, total.IsZero(), "balances sum to %v", total)
+
+	payments, err := accounting.GetSettleUpForGroup(cfg.Queries, t.Context(), group.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, member.ID, payments[0].From.ID)
+	assert.Equal(t, owner.ID, payments[0].To.ID)
+	assert.True(t, decimal.RequireFromString("10").Equal(payments[0].Amount))
+}
+
+// setupBenchmarkGroup creates a group of n members where every member has
+// paid for a few expenses split across the whole group.
+func setupBenchmarkGroup(b *testing.B, n int) (*handlers.Config, database.Group, uuid.UUID) {
+	cfg := newTestConfig(b)
+
+	owner, cookie := signup(b, cfg, "owner")
+
+	members := make([]string, n-1)
+	cookies := []*http.Cookie{cookie}
+	for i := range members {
+		members[i] = fmt.Sprintf("member%d", i)
+		_, c := signup(b, cfg, members[i])
+		cookies = append(cookies, c)
+	}
+
+	group := createGroupWithMembers(b, cfg, cookie, members...)
+
+	for _, c := range cookies {
+		for i := 0; i < 3; i++ {
+			rr := postExpense(b, cfg, c, group, map[string]any{
+				"description": "Expense",
+				"amount":      "12.34",
+			})
+			require.Equal(b, http.StatusCreated, rr.Code)
+		}
+	}
+
+	return cfg, group, owner.ID
+}
+
+func BenchmarkGetBalanceForGroup(b *testing.B) {
+	cfg, group, userID := setupBenchmarkGroup(b, 20)
+
+	for b.Loop() {
+		_, err := accounting.GetBalanceForGroup(cfg.Queries, b.Context(), group.ID, userID)
+		require.NoError(b, err)
+	}
+}
+
+// sumOfDebts and sumOfPayments are the queries GetBalanceForGroup used to
+// run for every pair of members, kept here as the baseline for
+// BenchmarkGetBalanceBetweenEachUser.
+const (
+	sumOfDebts = `SELECT CAST(COALESCE(SUM(debts.amount), 0) AS NUMERIC(12, 2)) AS total FROM transactions
+INNER JOIN expenses ON transactions.id = expenses.transaction_id
+INNER JOIN debts ON expenses.id = debts.expense_id
+WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
+AND debts.owed_by = $2 AND debts.owed_to = $3`
+
+	sumOfPayments = `SELECT CAST(COALESCE(SUM(payments.amount), 0) AS NUMERIC(12, 2)) AS total FROM transactions
+INNER JOIN payments ON transactions.id = payments.transaction_id
+WHERE transactions.group_id = $1 AND transactions.deleted_at IS NULL
+AND payments.paid_by = $2 AND payments.paid_to = $3`
+)
+
+// balanceBetweenUsers is how GetBalanceForGroup used to work out each
+// balance, with four queries per pair of members.
+func balanceBetweenUsers(b *testing.B, cfg *handlers.Config, groupID, thisUserID, otherUserID uuid.UUID) decimal.Decimal {
+	b.Helper()
+
+	sum := func(query string, from, to uuid.UUID) decimal.Decimal {
+		var total decimal.Decimal
+		require.NoError(b, cfg.Tx.QueryRowContext(b.Context(), query, groupID, from, to).Scan(&total))
+		return total
+	}
+
+	debtToOther := sum(sumOfDebts, thisUserID, otherUserID)
+	debtToThis := sum(sumOfDebts, otherUserID, thisUserID)
+	paymentsToOther := sum(sumOfPayments, thisUserID, otherUserID)
+	paymentsToThis := sum(sumOfPayments, otherUserID, thisUserID)
+
+	return debtToThis.Sub(debtToOther).Add(paymentsToOther).Sub(paymentsToThis)
+}
+
+// BenchmarkGetBalanceBetweenEachUser measures the per-pair approach that
+// GetBalanceForGroup used before balances were aggregated in one query.
+func BenchmarkGetBalanceBetweenEachUser(b *testing.B) {
+	cfg, group, userID := setupBenchmarkGroup(b, 20)
+
+	users, err := cfg.Queries.GetUsersByGroup(b.Context(), group.ID)
+	require.NoError(b, err)
+
+	for b.Loop() {
+		for _, u := range users {
+			if u.ID == userID {
+				continue
+			}
+
+			balanceBetweenUsers(b, cfg, group.ID, userID, u.ID)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func TestBalanceConvertsCurrencies(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, cookie := signup(t, cfg, "owner")
+	guest, _ := signup(t, cfg, "guest")
+
+	group := createGroupWithMembers(t, cfg, cookie, "guest")
+	require.Equal(t, accounting.DefaultCurrency, group.Currency)
+
+	// Without a rate the expense can't be added to a USD group
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Museum",
+		"amount":      "20.00",
+		"currency":    "EUR",
+	})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	_, err := cfg.Queries.SetExchangeRate(t.Context(), database.SetExchangeRateParams{
+		Base:  "EUR",
+		Quote: "USD",
+		Rate:  decimal.RequireFromString("1.10"),
+	})
+	require.NoError(t, err)
+
+	rr = postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Museum",
+		"amount":      "20.00",
+		"currency":    "EUR",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Taxi",
+		"amount":      "10.00",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	balances, err := accounting.GetBalanceForGroup(cfg.Queries, t.Context(), group.ID, owner.ID)
+	require.NoError(t, err)
+	require.Len(t, balances, 1)
+	assert.Equal(t, guest.ID, balances[0].Other.ID)
+
+	// Half of €20 at 1.10 plus half of $10
+	assert.True(t, decimal.RequireFromString("16").Equal(balances[0].Amount), balances[0].Amount.String())
+
+	transactions, err := accounting.GetTransationsByGroup(cfg.Queries, t.Context(), group.ID)
+	require.NoError(t, err)
+
+	currencies := map[string]bool{}
+	for _, transaction := range transactions {
+		currencies[transaction.Expense.Currency] = true
+	}
+	assert.Equal(t, map[string]bool{"EUR": true, "USD": true}, currencies)
+
+	// Unknown currencies are rejected
+	rr = postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Souvenir",
+		"amount":      "5.00",
+		"currency":    "XYZ",
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestImportExchangeRates(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	n, err := api.ImportExchangeRates(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, strings.NewReader(
+		"base,quote,rate\nEUR,USD,1.10\ngbp, usd, 1.25\n",
+	))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	rates, err := accounting.GetExchangeRates(cfg.Queries, t.Context())
+	require.NoError(t, err)
+	assert.True(t, decimal.RequireFromString("1.25").Equal(rates["GBP"]["USD"]))
+
+	for _, input := range []string{
+		"base,quote,rate\nEUR,USD,-1\n",
+		"base,quote,rate\nEUR,XYZ,1\n",
+		"base,quote,rate\nEUR,USD\n",
+	} {
+		_, err := api.ImportExchangeRates(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestDeleteExchangeRateInUse(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "owner")
+	group := createGroupWithMembers(t, cfg, cookie, "guest")
+
+	for _, params := range []database.SetExchangeRateParams{
+		{Base: "EUR", Quote: "USD", Rate: decimal.RequireFromString("1.10")},
+		{Base: "GBP", Quote: "USD", Rate: decimal.RequireFromString("1.25")},
+	} {
+		_, err := cfg.Queries.SetExchangeRate(t.Context(), params)
+		require.NoError(t, err)
+	}
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Museum",
+		"amount":      "20.00",
+		"currency":    "EUR",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// Deleting the rate would leave the group's balances unconvertible, in
+	// either direction.
+	err := api.DeleteExchangeRate(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, "EUR", "USD")
+	assert.ErrorIs(t, err, api.ErrExchangeRateInUse)
+
+	_, err = cfg.Queries.SetExchangeRate(t.Context(), database.SetExchangeRateParams{
+		Base:  "USD",
+		Quote: "EUR",
+		Rate:  decimal.RequireFromString("0.90"),
+	})
+	require.NoError(t, err)
+
+	// With the inverse rate stored, either one can go, but not both.
+	err = api.DeleteExchangeRate(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, "EUR", "USD")
+	require.NoError(t, err)
+
+	err = api.DeleteExchangeRate(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, "USD", "EUR")
+	assert.ErrorIs(t, err, api.ErrExchangeRateInUse)
+
+	// Rates nobody uses can be deleted.
+	err = api.DeleteExchangeRate(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, "GBP", "USD")
+	require.NoError(t, err)
+
+	_, err = accounting.GetBalanceMatrixForGroup(cfg.Queries, t.Context(), group.ID)
+	assert.NoError(t, err)
+}
+
+func TestExchangeRateInUse(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "owner")
+	group := createGroupWithMembers(t, cfg, cookie, "guest")
+
+	_, err := cfg.Queries.SetExchangeRate(t.Context(), database.SetExchangeRateParams{
+		Base:  "EUR",
+		Quote: "USD",
+		Rate:  decimal.RequireFromString("1.10"),
+	})
+	require.NoError(t, err)
+
+	inUse, err := api.ExchangeRateInUse(t.Context(), cfg.Queries, "EUR", "USD")
+	require.NoError(t, err)
+	assert.False(t, inUse)
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Museum",
+		"amount":      "20.00",
+		"currency":    "EUR",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// Either direction counts, since the inverse is used when there's no
+	// direct rate.
+	for _, pair := range [][2]string{{"EUR", "USD"}, {"USD", "EUR"}} {
+		inUse, err = api.ExchangeRateInUse(t.Context(), cfg.Queries, pair[0], pair[1])
+		require.NoError(t, err)
+		assert.True(t, inUse, pair)
+	}
+
+	inUse, err = api.ExchangeRateInUse(t.Context(), cfg.Queries, "GBP", "USD")
+	require.NoError(t, err)
+	assert.False(t, inUse)
+}
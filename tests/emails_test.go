@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/mail"
+)
+
+// outbox keeps the emails sent in a test instead of sending them.
+type outbox struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mail.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+func sentMail(cfg *handlers.Config) []mail.Message {
+	o := cfg.Mailer.(*outbox)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]mail.Message(nil), o.messages...)
+}
+
+var linkToken = regexp.MustCompile(`\?token=([0-9a-f]+)`)
+
+// lastLink returns the token of the link in the last email sent to email.
+func lastLink(t testing.TB, cfg *handlers.Config, email string) string {
+	t.Helper()
+
+	messages := sentMail(cfg)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To == email {
+			match := linkToken.FindStringSubmatch(messages[i].Body)
+			require.NotNil(t, match, messages[i].Body)
+
+			return match[1]
+		}
+	}
+
+	require.FailNow(t, "no email sent", email)
+	return ""
+}
+
+func signupWithEmail(cfg *handlers.Config, username, email string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(handlers.CreateUserData{Username: username, Password: "password", Email: email})
+
+	r := httptest.NewRequest("POST", "/api/users", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	cfg.HandlerCreateUser(rr, r)
+
+	return rr
+}
+
+func verifyEmail(cfg *handlers.Config, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/verify-email?token="+token, nil)
+	rr := httptest.NewRecorder()
+	cfg.HandlerVerifyEmailPage(rr, r)
+
+	return rr
+}
+
+func forgotPassword(cfg *handlers.Config, email string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(handlers.ForgotPasswordData{Email: email})
+
+	r := httptest.NewRequest("POST", "/api/password-reset", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	cfg.HandlerForgotPassword(rr, r)
+
+	return rr
+}
+
+func resetPassword(cfg *handlers.Config, token, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(handlers.PasswordResetData{Token: token, Password: password})
+
+	r := httptest.NewRequest("PUT", "/api/password-reset", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	cfg.HandlerPasswordReset(rr, r)
+
+	return rr
+}
+
+func TestEmailVerification(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	rr := signupWithEmail(cfg, "bad", "not an email")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = signupWithEmail(cfg, "user", "User@Example.com")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	cookie := rr.Result().Cookies()[0]
+
+	first := lastLink(t, cfg, "user@example.com")
+
+	// Changing address sends a new link, and the old one no longer works.
+	rr = groupRequest(t, cfg, cookie, "PUT", "/api/users/email", nil, cfg.HandlerUpdateEmail, handlers.UpdateEmailData{Email: "new@example.com"})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	email := handlers.ExportEmail{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&email))
+	assert.Equal(t, handlers.ExportEmail{Email: "new@example.com", Verified: false}, email)
+
+	rr = verifyEmail(cfg, first)
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+	second := lastLink(t, cfg, "new@example.com")
+
+	rr = verifyEmail(cfg, second)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	user, err := cfg.Queries.GetUserByUsername(t.Context(), "user")
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", user.Email.String)
+	assert.True(t, user.EmailVerifiedAt.Valid)
+
+	// Links only work once.
+	rr = verifyEmail(cfg, second)
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+	rr = groupRequest(t, cfg, cookie, "POST", "/api/users/email/verification", nil, cfg.HandlerResendVerification, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	// Removing the address.
+	rr = groupRequest(t, cfg, cookie, "PUT", "/api/users/email", nil, cfg.HandlerUpdateEmail, handlers.UpdateEmailData{})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = groupRequest(t, cfg, cookie, "POST", "/api/users/email/verification", nil, cfg.HandlerResendVerification, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+}
+
+func TestVerifiedEmailsAreUnique(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	// Anyone can add an address, but only verifying it keeps it.
+	rr := signupWithEmail(cfg, "squatter", "owner@example.com")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	squatter := rr.Result().Cookies()[0]
+	squatterLink := lastLink(t, cfg, "owner@example.com")
+
+	rr = signupWithEmail(cfg, "owner", "owner@example.com")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = verifyEmail(cfg, lastLink(t, cfg, "owner@example.com"))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	user, err := cfg.Queries.GetUserByUsername(t.Context(), "squatter")
+	require.NoError(t, err)
+	assert.False(t, user.Email.Valid)
+
+	rr = verifyEmail(cfg, squatterLink)
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	rr = signupWithEmail(cfg, "other", "Owner@Example.com")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "Email address already in use")
+
+	rr = groupRequest(t, cfg, squatter, "PUT", "/api/users/email", nil, cfg.HandlerUpdateEmail, handlers.UpdateEmailData{Email: "owner@example.com"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "Email address already in use")
+}
+
+func TestForgotPassword(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	rr := signupWithEmail(cfg, "user", "user@example.com")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	cookie := rr.Result().Cookies()[0]
+
+	// Unverified addresses can't be used to reset a password.
+	sent := len(sentMail(cfg))
+	rr = forgotPassword(cfg, "user@example.com")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Len(t, sentMail(cfg), sent)
+
+	rr = verifyEmail(cfg, lastLink(t, cfg, "user@example.com"))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// Nobody can tell whether an address has an account.
+	rr = forgotPassword(cfg, "nobody@example.com")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Len(t, sentMail(cfg), sent)
+
+	// Only the newest link works.
+	rr = forgotPassword(cfg, "user@example.com")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	first := lastLink(t, cfg, "user@example.com")
+
+	rr = forgotPassword(cfg, "user@example.com")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	token := lastLink(t, cfg, "user@example.com")
+
+	rr = resetPassword(cfg, first, "new password")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	// Resetting also unlocks an account locked by wrong passwords.
+	for range api.MaxFailedLogins {
+		tryLogin(cfg, "user", "wrong")
+	}
+
+	r := httptest.NewRequest("GET", "/reset-password?token="+token, nil)
+	rr = httptest.NewRecorder()
+	cfg.HandlerResetPasswordPage(rr, r)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = resetPassword(cfg, token, "new password")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = resetPassword(cfg, token, "another password")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	r = httptest.NewRequest("GET", "/reset-password?token="+token, nil)
+	rr = httptest.NewRecorder()
+	cfg.HandlerResetPasswordPage(rr, r)
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+	rr = getBalances(t, cfg, cookie)
+	assert.Equal(t, http.StatusSeeOther, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "new password")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+}
+
+func TestPasswordResetAfterEmailChange(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	rr := signupWithEmail(cfg, "user", "user@example.com")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	cookie := rr.Result().Cookies()[0]
+
+	rr = verifyEmail(cfg, lastLink(t, cfg, "user@example.com"))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = forgotPassword(cfg, "user@example.com")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	token := lastLink(t, cfg, "user@example.com")
+
+	// Links stop working once the address they were sent to is changed,
+	// even if it's changed back without being verified again.
+	for _, email := range []string{"new@example.com", "user@example.com"} {
+		rr = groupRequest(t, cfg, cookie, "PUT", "/api/users/email", nil, cfg.HandlerUpdateEmail, handlers.UpdateEmailData{Email: email})
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		r := httptest.NewRequest("GET", "/reset-password?token="+token, nil)
+		rr = httptest.NewRecorder()
+		cfg.HandlerResetPasswordPage(rr, r)
+		assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+		rr = resetPassword(cfg, token, "new password")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+}
@@ -0,0 +1,356 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func newTestConfig(t testing.TB) *handlers.Config {
+	t.Helper()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	t.Cleanup(func() { tx.Rollback() })
+
+	return &handlers.Config{
+		DB:           db,
+		Tx:           tx,
+		Queries:      queries.WithTx(tx),
+		Store:        sessions.NewCookieStore([]byte(sessionKey)),
+		JwtKey:       jwtKey,
+		Mailer:       &outbox{},
+		BaseURL:      "http://localhost:8080",
+		TwoFactorKey: auth.DeriveKey("two-factor"),
+	}
+}
+
+// signup creates a user and returns it along with its session cookie.
+func signup(t testing.TB, cfg *handlers.Config, username string) (handlers.ExportUser, *http.Cookie) {
+	t.Helper()
+
+	body, err := json.Marshal(handlers.CreateUserData{
+		Username: username,
+		Password: "password",
+	})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/api/users", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+
+	cfg.HandlerCreateUser(rr, r)
+
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	user := handlers.ExportUser{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&user))
+
+	cookies := rr.Result().Cookies()
+	require.NotEmpty(t, cookies)
+
+	return user, cookies[0]
+}
+
+// createGroupWithMembers creates a group owned by the user of cookie and adds
+// each of the named members to it.
+func createGroupWithMembers(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, members ...string) database.Group {
+	t.Helper()
+
+	body, err := json.Marshal(handlers.CreateGroupData{Name: "Group"})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/api/groups", bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	rr := httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerCreateGroup),
+	).ServeHTTP(rr, r)
+
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	group := database.Group{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&group))
+
+	for _, member := range members {
+		body, err := json.Marshal(handlers.AddUserToGroupData{Username: member})
+		require.NoError(t, err)
+
+		r := httptest.NewRequest("POST", "/api/groups/"+group.ID.String()+"/users", bytes.NewBuffer(body))
+		r.AddCookie(cookie)
+		r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String()})
+		rr := httptest.NewRecorder()
+
+		cfg.AuthenticatedUserMiddleware(
+			http.HandlerFunc(cfg.HandlerAddUserToGroup),
+		).ServeHTTP(rr, r)
+
+		require.Equal(t, http.StatusNoContent, rr.Code)
+	}
+
+	return group
+}
+
+func postExpense(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, group database.Group, payload map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/api/groups/"+group.ID.String()+"/expenses", bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String()})
+	rr := httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerCreateExpense),
+	).ServeHTTP(rr, r)
+
+	return rr
+}
+
+func TestCreateExpenseWithParticipants(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, cookie := signup(t, cfg, "owner")
+	guest, _ := signup(t, cfg, "guest")
+	signup(t, cfg, "absent")
+
+	group := createGroupWithMembers(t, cfg, cookie, "guest", "absent")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description":  "Dinner",
+		"amount":       "30.00",
+		"participants": []string{"owner", "guest"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	expense := database.Expense{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&expense))
+
+	debts, err := cfg.Queries.GetDebtsByTransaction(t.Context(), expense.TransactionID)
+	require.NoError(t, err)
+	require.Len(t, debts, 1)
+	assert.Equal(t, guest.ID, debts[0].OwedBy.UUID)
+	assert.Equal(t, owner.ID, debts[0].OwedTo.UUID)
+	assert.True(t, decimal.RequireFromString("15").Equal(debts[0].Amount))
+
+	splits, err := cfg.Queries.GetExpenseSplitsByExpense(t.Context(), expense.ID)
+	require.NoError(t, err)
+	assert.Len(t, splits, 2)
+
+	// Participants outside the group are rejected
+	signup(t, cfg, "stranger")
+
+	rr = postExpense(t, cfg, cookie, group, map[string]any{
+		"description":  "Dinner",
+		"amount":       "30.00",
+		"participants": []string{"owner", "stranger"},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCreateExpenseWithSplitMode(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "owner")
+	guest, _ := signup(t, cfg, "guest")
+
+	group := createGroupWithMembers(t, cfg, cookie, "guest")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Groceries",
+		"amount":      "40.00",
+		"split_mode":  "exact",
+		"splits": []map[string]string{
+			{"username": "owner", "value": "10"},
+			{"username": "guest", "value": "30"},
+		},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	expense := database.Expense{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&expense))
+	assert.Equal(t, "exact", expense.SplitMode)
+
+	debts, err := cfg.Queries.GetDebtsByTransaction(t.Context(), expense.TransactionID)
+	require.NoError(t, err)
+	require.Len(t, debts, 1)
+	assert.Equal(t, guest.ID, debts[0].OwedBy.UUID)
+	assert.True(t, decimal.RequireFromString("30").Equal(debts[0].Amount))
+
+	// Amounts that don't add up are rejected
+	rr = postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Groceries",
+		"amount":      "40.00",
+		"split_mode":  "exact",
+		"splits": []map[string]string{
+			{"username": "owner", "value": "10"},
+			{"username": "guest", "value": "20"},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCreateExpenseWithPayers(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, cookie := signup(t, cfg, "owner")
+	guest, _ := signup(t, cfg, "guest")
+	third, _ := signup(t, cfg, "third")
+
+	group := createGroupWithMembers(t, cfg, cookie, "guest", "third")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Dinner",
+		"amount":      "60.00",
+		"payers": []map[string]string{
+			{"username": "owner", "amount": "40"},
+			{"username": "guest", "amount": "20"},
+		},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	expense := database.Expense{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&expense))
+	assert.Equal(t, owner.ID, expense.PaidBy.UUID)
+
+	payers, err := cfg.Queries.GetExpensePayersByExpense(t.Context(), expense.ID)
+	require.NoError(t, err)
+	assert.Len(t, payers, 2)
+
+	// Everyone's share is 20, so the owner is owed 20 and the guest is even.
+	debts, err := cfg.Queries.GetDebtsByTransaction(t.Context(), expense.TransactionID)
+	require.NoError(t, err)
+
+	net := map[uuid.UUID]decimal.Decimal{}
+	for _, debt := range debts {
+		net[debt.OwedTo.UUID] = net[debt.OwedTo.UUID].Add(debt.Amount)
+		net[debt.OwedBy.UUID] = net[debt.OwedBy.UUID].Sub(debt.Amount)
+	}
+	assert.True(t, decimal.RequireFromString("20").Equal(net[owner.ID]), net[owner.ID].String())
+	assert.True(t, net[guest.ID].IsZero(), net[guest.ID].String())
+	assert.True(t, decimal.RequireFromString("-20").Equal(net[third.ID]), net[third.ID].String())
+
+	// Payments that don't add up to the amount are rejected
+	rr = postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Dinner",
+		"amount":      "60.00",
+		"payers": []map[string]string{
+			{"username": "owner", "amount": "40"},
+			{"username": "guest", "amount": "10"},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func putExpense(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, group database.Group, transactionID uuid.UUID, payload map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("PUT", "/api/groups/"+group.ID.String()+"/expenses?id="+transactionID.String(), bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String()})
+	rr := httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerUpdateExpense),
+	).ServeHTTP(rr, r)
+
+	return rr
+}
+
+func TestCreateItemizedExpense(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, cookie := signup(t, cfg, "owner")
+	guest, _ := signup(t, cfg, "guest")
+
+	group := createGroupWithMembers(t, cfg, cookie, "guest")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Dinner",
+		"split_mode":  "items",
+		"items": []map[string]any{
+			{"description": "Steak", "amount": "20.00", "members": []string{"owner"}},
+			{"description": "Pasta", "amount": "10.00", "members": []string{"guest"}},
+			{"description": "Wine", "amount": "10.00", "members": []string{"owner", "guest"}},
+		},
+		"charges": []map[string]string{
+			{"kind": "tax", "amount": "4.00"},
+			{"kind": "tip", "amount": "6.00"},
+		},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	expense := database.Expense{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&expense))
+	assert.Equal(t, "items", expense.SplitMode)
+	assert.True(t, decimal.RequireFromString("50").Equal(expense.Amount), expense.Amount.String())
+
+	// The guest's items come to 15 of 40, so they also owe 15/40 of the 10
+	// in charges.
+	debts, err := cfg.Queries.GetDebtsByTransaction(t.Context(), expense.TransactionID)
+	require.NoError(t, err)
+	require.Len(t, debts, 1)
+	assert.Equal(t, guest.ID, debts[0].OwedBy.UUID)
+	assert.Equal(t, owner.ID, debts[0].OwedTo.UUID)
+	assert.True(t, decimal.RequireFromString("18.75").Equal(debts[0].Amount), debts[0].Amount.String())
+
+	items, charges, err := accounting.GetExpenseItems(cfg.Queries, t.Context(), expense.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+	assert.Equal(t, "Steak", items[0].Description)
+	assert.Equal(t, []uuid.UUID{owner.ID}, items[0].Members)
+	assert.Len(t, items[2].Members, 2)
+	require.Len(t, charges, 2)
+	assert.Equal(t, accounting.ChargeTax, charges[0].Kind)
+
+	// Correcting an item re-derives the debts
+	rr = putExpense(t, cfg, cookie, group, expense.TransactionID, map[string]any{
+		"amount":     "-1",
+		"split_mode": "items",
+		"items": []map[string]any{
+			{"description": "Steak", "amount": "20.00", "members": []string{"owner"}},
+			{"description": "Pasta", "amount": "20.00", "members": []string{"guest"}},
+		},
+	})
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	debts, err = cfg.Queries.GetDebtsByTransaction(t.Context(), expense.TransactionID)
+	require.NoError(t, err)
+	require.Len(t, debts, 1)
+	assert.True(t, decimal.RequireFromString("20").Equal(debts[0].Amount), debts[0].Amount.String())
+
+	items, charges, err = accounting.GetExpenseItems(cfg.Queries, t.Context(), expense.ID)
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Empty(t, charges)
+
+	// Items that don't add up to the amount are rejected
+	rr = postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Dinner",
+		"amount":      "30.00",
+		"split_mode":  "items",
+		"items": []map[string]any{
+			{"description": "Steak", "amount": "20.00", "members": []string{"owner"}},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
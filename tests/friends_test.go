@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func addFriend(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, username string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return groupRequest(t, cfg, cookie, "POST", "/api/friends", nil, cfg.HandlerAddFriend, handlers.AddFriendData{Username: username})
+}
+
+func TestFriends(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	alice, aliceCookie := signup(t, cfg, "alice")
+	bob, bobCookie := signup(t, cfg, "bob")
+
+	rr := addFriend(t, cfg, aliceCookie, "alice")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = addFriend(t, cfg, aliceCookie, "bob")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	friend := handlers.ExportFriend{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&friend))
+	assert.Equal(t, bob.ID, friend.ID)
+
+	rr = addFriend(t, cfg, bobCookie, "alice")
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	friends, err := cfg.Queries.GetFriendsByUser(t.Context(), bob.ID)
+	require.NoError(t, err)
+	require.Len(t, friends, 1)
+	assert.Equal(t, alice.ID, friends[0].ID)
+	assert.Equal(t, friend.GroupID, friends[0].GroupID)
+
+	// The ledger between friends isn't listed with their groups.
+	groups, err := cfg.Queries.GetGroupsByUser(t.Context(), alice.ID)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	direct, err := cfg.Queries.GetGroup(t.Context(), friend.GroupID)
+	require.NoError(t, err)
+	assert.True(t, direct.Direct)
+
+	// Either friend can record expenses with the other.
+	rr = postExpense(t, cfg, bobCookie, direct, map[string]any{
+		"description":  "Lunch",
+		"amount":       "20.00",
+		"participants": []string{"alice", "bob"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// But no one can change who's in it or leave it.
+	signup(t, cfg, "carol")
+	vars := map[string]string{"group_id": direct.ID.String()}
+	rr = groupRequest(
+		t,
+		cfg,
+		aliceCookie,
+		"POST",
+		"/api/groups/"+direct.ID.String()+"/users",
+		vars,
+		cfg.HandlerAddUserToGroup,
+		handlers.AddUserToGroupData{Username: "carol"},
+	)
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = leaveGroup(t, cfg, bobCookie, direct, handlers.LeaveGroupData{Confirm: true})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	// Removing a friend would delete what they owe, so they have to settle
+	// up first.
+	remove := func() *httptest.ResponseRecorder {
+		return groupRequest(
+			t,
+			cfg,
+			aliceCookie,
+			"DELETE",
+			"/api/friends/"+bob.ID.String(),
+			map[string]string{"id": bob.ID.String()},
+			cfg.HandlerRemoveFriend,
+			nil,
+		)
+	}
+
+	rr = remove()
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	friends, err = cfg.Queries.GetFriendsByUser(t.Context(), alice.ID)
+	require.NoError(t, err)
+	assert.Len(t, friends, 1)
+
+	rr = postPayment(t, cfg, aliceCookie, direct, map[string]any{"paid_by": "alice", "paid_to": "bob", "amount": "10.00"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = remove()
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	friends, err = cfg.Queries.GetFriendsByUser(t.Context(), alice.ID)
+	require.NoError(t, err)
+	assert.Empty(t, friends)
+
+	rr = remove()
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
+
+func TestBalancesByPerson(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	alice, aliceCookie := signup(t, cfg, "alice")
+	_, bobCookie := signup(t, cfg, "bob")
+	signup(t, cfg, "carol")
+
+	group := createGroupWithMembers(t, cfg, aliceCookie, "bob", "carol")
+
+	// bob owes alice 10.00 in the group...
+	rr := postExpense(t, cfg, aliceCookie, group, map[string]any{
+		"description":  "Dinner",
+		"amount":       "30.00",
+		"participants": []string{"alice", "bob", "carol"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = addFriend(t, cfg, aliceCookie, "bob")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	friend := handlers.ExportFriend{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&friend))
+
+	direct := database.Group{ID: friend.GroupID}
+
+	// ...and alice owes bob 4.00 between the two of them.
+	rr = postExpense(t, cfg, bobCookie, direct, map[string]any{
+		"description":  "Coffee",
+		"amount":       "8.00",
+		"participants": []string{"alice", "bob"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	balances, err := accounting.GetBalancesByPerson(cfg.Queries, t.Context(), alice.ID)
+	require.NoError(t, err)
+
+	amounts := map[string]string{}
+	for _, b := range balances {
+		amounts[b.Other.Username] = b.Amount.StringFixed(2)
+	}
+
+	assert.Equal(t, map[string]string{"bob": "6.00", "carol": "10.00"}, amounts)
+
+	rr = groupRequest(t, cfg, aliceCookie, "GET", "/api/balances", nil, cfg.HandlerGetBalances, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	export := handlers.ExportBalances{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&export))
+	assert.Equal(t, accounting.DefaultCurrency, export.Currency)
+	assert.Len(t, export.Balances, 2)
+}
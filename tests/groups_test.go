@@ -5,15 +5,19 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 
 	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
 	"github.com/matt-horst/split-ways/internal/database"
 )
 
@@ -193,3 +197,418 @@ func TestUpdateGroup(t *testing.T) {
 	assert.Equal(t, group.CreatedAt, newGroup.CreatedAt)
 	assert.Equal(t, user.ID, newGroup.Owner)
 }
+
+// groupRequest sends payload, if any, to handler as the user of cookie with
+// the given path variables.
+func groupRequest(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, method, path string, vars map[string]string, handler http.HandlerFunc, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	if payload != nil {
+		require.NoError(t, json.NewEncoder(&body).Encode(payload))
+	}
+
+	r := httptest.NewRequest(method, path, &body)
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, vars)
+	rr := httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(handler).ServeHTTP(rr, r)
+
+	return rr
+}
+
+func setRole(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, group database.Group, userID uuid.UUID, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return groupRequest(
+		t,
+		cfg,
+		cookie,
+		"PUT",
+		"/api/groups/"+group.ID.String()+"/users/"+userID.String(),
+		map[string]string{"group_id": group.ID.String(), "user_id": userID.String()},
+		cfg.HandlerUpdateMemberRole,
+		handlers.UpdateMemberRoleData{Role: role},
+	)
+}
+
+func TestGroupPermissions(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, ownerCookie := signup(t, cfg, "owner")
+	admin, adminCookie := signup(t, cfg, "admin")
+	member, memberCookie := signup(t, cfg, "member")
+	viewer, viewerCookie := signup(t, cfg, "viewer")
+	_, strangerCookie := signup(t, cfg, "stranger")
+
+	group := createGroupWithMembers(t, cfg, ownerCookie, "admin", "member", "viewer")
+
+	rr := setRole(t, cfg, ownerCookie, group, admin.ID, "admin")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = setRole(t, cfg, ownerCookie, group, viewer.ID, "viewer")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = postExpense(t, cfg, ownerCookie, group, map[string]any{"description": "Dinner", "amount": "20.00"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	expense := database.Expense{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&expense))
+
+	actors := []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{"owner", ownerCookie},
+		{"admin", adminCookie},
+		{"member", memberCookie},
+		{"viewer", viewerCookie},
+		{"stranger", strangerCookie},
+	}
+
+	for _, actor := range actors {
+		signup(t, cfg, "extra-"+actor.name)
+	}
+
+	vars := map[string]string{"group_id": group.ID.String()}
+	path := "/api/groups/" + group.ID.String()
+
+	cases := []struct {
+		name    string
+		allowed []string
+		do      func(actor string, cookie *http.Cookie) *httptest.ResponseRecorder
+	}{
+		{
+			name:    "view settle up",
+			allowed: []string{"owner", "admin", "member", "viewer"},
+			do: func(_ string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return groupRequest(t, cfg, cookie, "GET", path+"/settle-up", vars, cfg.HandlerGetSettleUp, nil)
+			},
+		},
+		{
+			name:    "list members",
+			allowed: []string{"owner", "admin", "member", "viewer"},
+			do: func(_ string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return groupRequest(t, cfg, cookie, "GET", path+"/users", vars, cfg.HandlerGetGroupUsers, nil)
+			},
+		},
+		{
+			name:    "create expense",
+			allowed: []string{"owner", "admin", "member"},
+			do: func(_ string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return postExpense(t, cfg, cookie, group, map[string]any{"description": "Lunch", "amount": "10.00"})
+			},
+		},
+		{
+			name:    "create payment",
+			allowed: []string{"owner", "admin", "member"},
+			do: func(_ string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return postPayment(t, cfg, cookie, group, map[string]any{"paid_by": "member", "paid_to": "owner", "amount": "5.00"})
+			},
+		},
+		{
+			name:    "edit another member's expense",
+			allowed: []string{"owner", "admin"},
+			do: func(_ string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return putExpense(t, cfg, cookie, group, expense.TransactionID, map[string]any{"description": "Dinner", "amount": "25.00"})
+			},
+		},
+		{
+			name:    "rename group",
+			allowed: []string{"owner", "admin"},
+			do: func(actor string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return groupRequest(t, cfg, cookie, "PUT", path, vars, cfg.HandlerUpdateGroup, handlers.UpdateGroupData{Name: "Renamed by " + actor})
+			},
+		},
+		{
+			name:    "add member",
+			allowed: []string{"owner", "admin"},
+			do: func(actor string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return groupRequest(t, cfg, cookie, "POST", path+"/users", vars, cfg.HandlerAddUserToGroup, handlers.AddUserToGroupData{Username: "extra-" + actor})
+			},
+		},
+		{
+			name:    "change role",
+			allowed: []string{"owner", "admin"},
+			do: func(_ string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return setRole(t, cfg, cookie, group, member.ID, "member")
+			},
+		},
+		{
+			name:    "remove member",
+			allowed: []string{"owner", "admin"},
+			do: func(actor string, cookie *http.Cookie) *httptest.ResponseRecorder {
+				extra, err := cfg.Queries.GetUserByUsername(t.Context(), "extra-"+actor)
+				require.NoError(t, err)
+
+				return groupRequest(t, cfg, cookie, "DELETE", path+"/users", vars, cfg.HandlerRemoveUserFromGroup, handlers.RemoveUserFromGroupData{ID: extra.ID})
+			},
+		},
+	}
+
+	for _, c := range cases {
+		for _, actor := range actors {
+			t.Run(c.name+"/"+actor.name, func(t *testing.T) {
+				rr := c.do(actor.name, actor.cookie)
+
+				if slices.Contains(c.allowed, actor.name) {
+					assert.Less(t, rr.Code, 300, rr.Body.String())
+				} else {
+					assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+				}
+			})
+		}
+	}
+
+	// Only the owner can delete the group, so they go last.
+	for _, actor := range actors {
+		rr := groupRequest(t, cfg, actor.cookie, "DELETE", path, vars, cfg.HandlerDeleteGroup, nil)
+
+		if actor.name == "owner" {
+			assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+		} else {
+			assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestOwnerStaysAdmin(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, ownerCookie := signup(t, cfg, "owner")
+	admin, adminCookie := signup(t, cfg, "admin")
+	group := createGroupWithMembers(t, cfg, ownerCookie, "admin")
+
+	rr := setRole(t, cfg, ownerCookie, group, admin.ID, "admin")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = setRole(t, cfg, adminCookie, group, owner.ID, "viewer")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = setRole(t, cfg, ownerCookie, group, admin.ID, "superuser")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = groupRequest(
+		t,
+		cfg,
+		adminCookie,
+		"DELETE",
+		"/api/groups/"+group.ID.String()+"/users",
+		map[string]string{"group_id": group.ID.String()},
+		cfg.HandlerRemoveUserFromGroup,
+		handlers.RemoveUserFromGroupData{ID: owner.ID},
+	)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	membership, err := api.GetMembership(t.Context(), cfg.Queries, owner.ID, group.ID)
+	require.NoError(t, err)
+	assert.Equal(t, api.RoleAdmin, membership.Role)
+	assert.True(t, membership.Owner)
+}
+
+func leaveGroup(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, group database.Group, data handlers.LeaveGroupData) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return groupRequest(
+		t,
+		cfg,
+		cookie,
+		"POST",
+		"/api/groups/"+group.ID.String()+"/leave",
+		map[string]string{"group_id": group.ID.String()},
+		cfg.HandlerLeaveGroup,
+		data,
+	)
+}
+
+func TestTransferOwnership(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, ownerCookie := signup(t, cfg, "owner")
+	member, memberCookie := signup(t, cfg, "member")
+	signup(t, cfg, "stranger")
+	group := createGroupWithMembers(t, cfg, ownerCookie, "member")
+
+	path := "/api/groups/" + group.ID.String() + "/owner"
+	vars := map[string]string{"group_id": group.ID.String()}
+
+	// Only the owner can hand the group over.
+	rr := groupRequest(t, cfg, memberCookie, "PUT", path, vars, cfg.HandlerTransferOwnership, handlers.TransferOwnershipData{ID: member.ID})
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	stranger, err := cfg.Queries.GetUserByUsername(t.Context(), "stranger")
+	require.NoError(t, err)
+
+	rr = groupRequest(t, cfg, ownerCookie, "PUT", path, vars, cfg.HandlerTransferOwnership, handlers.TransferOwnershipData{ID: stranger.ID})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = groupRequest(t, cfg, ownerCookie, "PUT", path, vars, cfg.HandlerTransferOwnership, handlers.TransferOwnershipData{ID: member.ID})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	updated := database.Group{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&updated))
+	assert.Equal(t, member.ID, updated.Owner)
+
+	// The new owner is an admin and the old owner stays on as one.
+	newOwner, err := api.GetMembership(t.Context(), cfg.Queries, member.ID, group.ID)
+	require.NoError(t, err)
+	assert.True(t, newOwner.Owner)
+	assert.Equal(t, api.RoleAdmin, newOwner.Role)
+
+	oldOwner, err := api.GetMembership(t.Context(), cfg.Queries, owner.ID, group.ID)
+	require.NoError(t, err)
+	assert.False(t, oldOwner.Owner)
+	assert.Equal(t, api.RoleAdmin, oldOwner.Role)
+	assert.False(t, oldOwner.Can(api.PermDeleteGroup))
+}
+
+func TestLeaveGroup(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, ownerCookie := signup(t, cfg, "owner")
+	member, memberCookie := signup(t, cfg, "member")
+	group := createGroupWithMembers(t, cfg, ownerCookie, "member")
+
+	rr := postExpense(t, cfg, ownerCookie, group, map[string]any{"description": "Dinner", "amount": "20.00"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// The member owes the owner for dinner, so has to confirm.
+	rr = leaveGroup(t, cfg, memberCookie, group, handlers.LeaveGroupData{})
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	_, err := api.GetMembership(t.Context(), cfg.Queries, member.ID, group.ID)
+	require.NoError(t, err)
+
+	rr = leaveGroup(t, cfg, memberCookie, group, handlers.LeaveGroupData{Confirm: true})
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	_, err = api.GetMembership(t.Context(), cfg.Queries, member.ID, group.ID)
+	assert.ErrorIs(t, err, api.ErrNotMember)
+}
+
+func TestOwnerLeavesWithSuccessor(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, ownerCookie := signup(t, cfg, "owner")
+	member, _ := signup(t, cfg, "member")
+	group := createGroupWithMembers(t, cfg, ownerCookie, "member")
+
+	rr := leaveGroup(t, cfg, ownerCookie, group, handlers.LeaveGroupData{})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = leaveGroup(t, cfg, ownerCookie, group, handlers.LeaveGroupData{
+		Successor: uuid.NullUUID{UUID: owner.ID, Valid: true},
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = leaveGroup(t, cfg, ownerCookie, group, handlers.LeaveGroupData{
+		Successor: uuid.NullUUID{UUID: member.ID, Valid: true},
+	})
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	updated, err := cfg.Queries.GetGroup(t.Context(), group.ID)
+	require.NoError(t, err)
+	assert.Equal(t, member.ID, updated.Owner)
+
+	_, err = api.GetMembership(t.Context(), cfg.Queries, owner.ID, group.ID)
+	assert.ErrorIs(t, err, api.ErrNotMember)
+}
+
+func TestRemoveMemberWithBalance(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, ownerCookie := signup(t, cfg, "owner")
+	admin, adminCookie := signup(t, cfg, "admin")
+	member, _ := signup(t, cfg, "member")
+	group := createGroupWithMembers(t, cfg, ownerCookie, "admin", "member")
+
+	rr := setRole(t, cfg, ownerCookie, group, admin.ID, "admin")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = postExpense(t, cfg, ownerCookie, group, map[string]any{
+		"description":  "Dinner",
+		"amount":       "20.00",
+		"participants": []string{"owner", "member"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	remove := func(cookie *http.Cookie, force bool) *httptest.ResponseRecorder {
+		return groupRequest(
+			t,
+			cfg,
+			cookie,
+			"DELETE",
+			"/api/groups/"+group.ID.String()+"/users",
+			map[string]string{"group_id": group.ID.String()},
+			cfg.HandlerRemoveUserFromGroup,
+			handlers.RemoveUserFromGroupData{ID: member.ID, Force: force},
+		)
+	}
+
+	rr = remove(adminCookie, false)
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	// Only the owner can remove a member who still owes money.
+	rr = remove(adminCookie, true)
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = remove(ownerCookie, true)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	// The removed member keeps their name in the group's history.
+	txs, err := accounting.GetTransationsByGroup(cfg.Queries, t.Context(), group.ID)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Len(t, txs[0].Expense.Debts, 1)
+	require.NotNil(t, txs[0].Expense.Debts[0].OwedBy)
+	assert.Equal(t, "member", txs[0].Expense.Debts[0].OwedBy.Username)
+
+	names := make([]string, len(txs[0].Expense.Participants))
+	for i, p := range txs[0].Expense.Participants {
+		names[i] = p.Username
+	}
+	assert.ElementsMatch(t, []string{"owner", "member"}, names)
+}
+
+func TestRemoveMemberWithOffsettingDebts(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, ownerCookie := signup(t, cfg, "owner")
+	middle, middleCookie := signup(t, cfg, "middle")
+	signup(t, cfg, "last")
+	group := createGroupWithMembers(t, cfg, ownerCookie, "middle", "last")
+
+	// The middle member owes the owner 10.00 and is owed 10.00 by the last
+	// member, so their net balance is zero.
+	rr := postExpense(t, cfg, ownerCookie, group, map[string]any{
+		"description":  "Dinner",
+		"amount":       "20.00",
+		"participants": []string{"owner", "middle"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = postExpense(t, cfg, middleCookie, group, map[string]any{
+		"description":  "Taxi",
+		"amount":       "20.00",
+		"participants": []string{"middle", "last"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = groupRequest(
+		t,
+		cfg,
+		ownerCookie,
+		"DELETE",
+		"/api/groups/"+group.ID.String()+"/users",
+		map[string]string{"group_id": group.ID.String()},
+		cfg.HandlerRemoveUserFromGroup,
+		handlers.RemoveUserFromGroupData{ID: middle.ID},
+	)
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	rr = leaveGroup(t, cfg, middleCookie, group, handlers.LeaveGroupData{})
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	_, err := api.GetMembership(t.Context(), cfg.Queries, middle.ID, group.ID)
+	assert.NoError(t, err)
+}
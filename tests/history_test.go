@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func TestTransactionHistory(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := t.Context()
+
+	alice, cookie := signup(t, cfg, "alice")
+	signup(t, cfg, "bob")
+	group := createGroupWithMembers(t, cfg, cookie, "bob")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Dinner",
+		"amount":      "20.00",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	expense := database.Expense{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&expense))
+
+	rr = putExpense(t, cfg, cookie, group, expense.TransactionID, map[string]any{
+		"description": "Dinner",
+		"amount":      "30.00",
+	})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	require.NoError(t, api.DeleteTransaction(ctx, cfg.DB, cfg.Tx, cfg.Queries, expense.TransactionID, alice.ID))
+
+	r := httptest.NewRequest("GET", "/api/groups/"+group.ID.String()+"/transactions/"+expense.TransactionID.String()+"/history", nil)
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String(), "id": expense.TransactionID.String()})
+	rr = httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerGetTransactionHistory),
+	).ServeHTTP(rr, r)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	history := []handlers.HistoryEntry{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&history))
+	require.Len(t, history, 3)
+
+	actions := make([]string, len(history))
+	for i, entry := range history {
+		actions[i] = entry.Action
+		require.NotNil(t, entry.Actor)
+		require.Equal(t, "alice", entry.Actor.Username)
+	}
+	require.Equal(t, []string{api.AuditCreate, api.AuditUpdate, api.AuditDelete}, actions)
+
+	before, after := accounting.Transaction{}, accounting.Transaction{}
+	require.NoError(t, json.Unmarshal(history[1].Before, &before))
+	require.NoError(t, json.Unmarshal(history[1].After, &after))
+	require.Equal(t, "20.00", before.Expense.Amount.StringFixed(2))
+	require.Equal(t, "30.00", after.Expense.Amount.StringFixed(2))
+}
+
+func TestAuditLogIsImmutable(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "alice")
+	group := createGroupWithMembers(t, cfg, cookie)
+
+	_, err := cfg.Tx.ExecContext(t.Context(), "UPDATE audit_log SET action = 'tampered' WHERE group_id = $1", group.ID)
+	require.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func createInvite(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, group database.Group, data handlers.CreateInviteData) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return groupRequest(
+		t,
+		cfg,
+		cookie,
+		"POST",
+		"/api/groups/"+group.ID.String()+"/invites",
+		map[string]string{"group_id": group.ID.String()},
+		cfg.HandlerCreateInvite,
+		data,
+	)
+}
+
+func acceptInvite(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, invite handlers.ExportInvite) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return groupRequest(
+		t,
+		cfg,
+		cookie,
+		"POST",
+		"/api"+invite.Path,
+		map[string]string{"token": strings.TrimPrefix(invite.Path, "/invite/")},
+		cfg.HandlerAcceptInvite,
+		nil,
+	)
+}
+
+func TestGroupInvites(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, ownerCookie := signup(t, cfg, "owner")
+	_, memberCookie := signup(t, cfg, "member")
+	guest, guestCookie := signup(t, cfg, "guest")
+	_, latecomerCookie := signup(t, cfg, "latecomer")
+	group := createGroupWithMembers(t, cfg, ownerCookie, "member")
+
+	// Members can't invite people unless their role lets them manage members.
+	rr := createInvite(t, cfg, memberCookie, group, handlers.CreateInviteData{})
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = createInvite(t, cfg, ownerCookie, group, handlers.CreateInviteData{ExpiresInDays: 31})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = createInvite(t, cfg, ownerCookie, group, handlers.CreateInviteData{Role: "viewer", SingleUse: true})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	invite := handlers.ExportInvite{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&invite))
+	assert.Equal(t, api.RoleViewer, invite.Role)
+
+	rr = acceptInvite(t, cfg, memberCookie, invite)
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	rr = acceptInvite(t, cfg, guestCookie, invite)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	userGroup, err := cfg.Queries.GetUserGroup(t.Context(), database.GetUserGroupParams{
+		UserID:  guest.ID,
+		GroupID: group.ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, string(api.RoleViewer), userGroup.Role)
+
+	// A single use invite is used up once accepted.
+	rr = acceptInvite(t, cfg, latecomerCookie, invite)
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+	rr = createInvite(t, cfg, ownerCookie, group, handlers.CreateInviteData{})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&invite))
+
+	rr = groupRequest(
+		t,
+		cfg,
+		ownerCookie,
+		"DELETE",
+		"/api/groups/"+group.ID.String()+"/invites/"+invite.ID.String(),
+		map[string]string{"group_id": group.ID.String(), "id": invite.ID.String()},
+		cfg.HandlerRevokeInvite,
+		nil,
+	)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = acceptInvite(t, cfg, latecomerCookie, invite)
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
+
+func TestInvitePage(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, ownerCookie := signup(t, cfg, "owner")
+	group := createGroupWithMembers(t, cfg, ownerCookie)
+
+	rr := createInvite(t, cfg, ownerCookie, group, handlers.CreateInviteData{})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	invite := handlers.ExportInvite{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&invite))
+
+	page := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r = mux.SetURLVars(r, map[string]string{"token": strings.TrimPrefix(path, "/invite/")})
+		rr := httptest.NewRecorder()
+
+		cfg.HandlerInvitePage(rr, r)
+
+		return rr
+	}
+
+	// Logged out users are sent through signup or login and back again.
+	rr = page(invite.Path)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "/signup?next="+url.QueryEscape(invite.Path))
+	assert.Contains(t, rr.Body.String(), "/login?next="+url.QueryEscape(invite.Path))
+
+	rr = page("/invite/invalid")
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
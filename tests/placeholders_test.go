@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func addPlaceholder(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, group database.Group, name string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return groupRequest(
+		t,
+		cfg,
+		cookie,
+		"POST",
+		"/api/groups/"+group.ID.String()+"/placeholders",
+		map[string]string{"group_id": group.ID.String()},
+		cfg.HandlerAddPlaceholder,
+		handlers.AddPlaceholderData{Name: name},
+	)
+}
+
+func TestPlaceholderMembers(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, ownerCookie := signup(t, cfg, "owner")
+	_, memberCookie := signup(t, cfg, "member")
+	group := createGroupWithMembers(t, cfg, ownerCookie, "member")
+
+	rr := addPlaceholder(t, cfg, memberCookie, group, "alex")
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = addPlaceholder(t, cfg, ownerCookie, group, "alex")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	placeholder := handlers.GroupMember{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&placeholder))
+	assert.True(t, placeholder.Placeholder)
+	assert.Equal(t, api.RoleMember, placeholder.Role)
+
+	// Placeholders can take part in expenses and payments.
+	rr = postExpense(t, cfg, ownerCookie, group, map[string]any{
+		"description":  "Dinner",
+		"amount":       "30.00",
+		"paid_by":      "alex",
+		"participants": []string{"owner", "alex"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = postPayment(t, cfg, ownerCookie, group, map[string]any{"paid_by": "owner", "paid_to": "alex", "amount": "5.00"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// But they can't log in or own the group.
+	body, err := json.Marshal(handlers.LoginUserData{Username: "alex", Password: ""})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/api/login", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	cfg.HandlerLogin(rr, r)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+
+	rr = groupRequest(
+		t,
+		cfg,
+		ownerCookie,
+		"PUT",
+		"/api/groups/"+group.ID.String()+"/owner",
+		map[string]string{"group_id": group.ID.String()},
+		cfg.HandlerTransferOwnership,
+		handlers.TransferOwnershipData{ID: placeholder.ID},
+	)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	// Nor can they be added to other groups.
+	other := createGroupWithMembers(t, cfg, memberCookie)
+	rr = groupRequest(
+		t,
+		cfg,
+		memberCookie,
+		"POST",
+		"/api/groups/"+other.ID.String()+"/users",
+		map[string]string{"group_id": other.ID.String()},
+		cfg.HandlerAddUserToGroup,
+		handlers.AddUserToGroupData{Username: "alex"},
+	)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+}
+
+func TestClaimPlaceholder(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	owner, ownerCookie := signup(t, cfg, "owner")
+	group := createGroupWithMembers(t, cfg, ownerCookie)
+
+	rr := addPlaceholder(t, cfg, ownerCookie, group, "alex")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	placeholder := handlers.GroupMember{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&placeholder))
+
+	rr = setRole(t, cfg, ownerCookie, group, placeholder.ID, "viewer")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = postExpense(t, cfg, ownerCookie, group, map[string]any{
+		"description":  "Dinner",
+		"amount":       "30.00",
+		"paid_by":      "alex",
+		"participants": []string{"owner", "alex"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = postPayment(t, cfg, ownerCookie, group, map[string]any{"paid_by": "owner", "paid_to": "alex", "amount": "5.00"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// Only placeholders can be claimed.
+	rr = createInvite(t, cfg, ownerCookie, group, handlers.CreateInviteData{PlaceholderID: uuid.NullUUID{UUID: owner.ID, Valid: true}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = createInvite(t, cfg, ownerCookie, group, handlers.CreateInviteData{PlaceholderID: uuid.NullUUID{UUID: placeholder.ID, Valid: true}})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	invite := handlers.ExportInvite{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&invite))
+	assert.True(t, invite.SingleUse)
+
+	alexandra, alexandraCookie := signup(t, cfg, "alexandra")
+	rr = acceptInvite(t, cfg, alexandraCookie, invite)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// The placeholder is gone, and alexandra has taken their place.
+	_, err := cfg.Queries.GetUserByID(t.Context(), placeholder.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	userGroup, err := cfg.Queries.GetUserGroup(t.Context(), database.GetUserGroupParams{
+		UserID:  alexandra.ID,
+		GroupID: group.ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, string(api.RoleViewer), userGroup.Role)
+
+	txs, err := accounting.GetTransationsByGroup(cfg.Queries, t.Context(), group.ID)
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+
+	for _, tx := range txs {
+		switch tx.Kind {
+		case accounting.ExpenseKind:
+			require.NotNil(t, tx.Expense.PaidBy)
+			assert.Equal(t, alexandra.ID, tx.Expense.PaidBy.ID)
+			require.Len(t, tx.Expense.Debts, 1)
+			require.NotNil(t, tx.Expense.Debts[0].OwedTo)
+			assert.Equal(t, alexandra.ID, tx.Expense.Debts[0].OwedTo.ID)
+		case accounting.PaymentKind:
+			require.NotNil(t, tx.Payment.PaidTo)
+			assert.Equal(t, alexandra.ID, tx.Payment.PaidTo.ID)
+		}
+	}
+
+	// The claim link is used up with the placeholder.
+	_, otherCookie := signup(t, cfg, "other")
+	rr = acceptInvite(t, cfg, otherCookie, invite)
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
+
+func TestPlaceholderNamesAreScopedToGroup(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, ownerCookie := signup(t, cfg, "owner")
+	first := createGroupWithMembers(t, cfg, ownerCookie)
+	second := createGroupWithMembers(t, cfg, ownerCookie)
+
+	rr := addPlaceholder(t, cfg, ownerCookie, first, "alex")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// The same name can be used in another group, but not twice in one.
+	rr = addPlaceholder(t, cfg, ownerCookie, second, "alex")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = addPlaceholder(t, cfg, ownerCookie, first, "alex")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = addPlaceholder(t, cfg, ownerCookie, first, "owner")
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	// Nor does a placeholder take the username from someone signing up.
+	alex, _ := signup(t, cfg, "alex")
+
+	rr = postExpense(t, cfg, ownerCookie, first, map[string]any{
+		"description":  "Dinner",
+		"amount":       "30.00",
+		"participants": []string{"owner", "alex"},
+	})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	balances, err := accounting.GetBalanceForGroup(cfg.Queries, t.Context(), first.ID, alex.ID)
+	require.NoError(t, err)
+	assert.Empty(t, balances, "the expense is shared with the placeholder, not the user")
+
+	// But the user can't join a group where a placeholder has their name,
+	// except by claiming it.
+	rr = groupRequest(
+		t,
+		cfg,
+		ownerCookie,
+		"POST",
+		"/api/groups/"+first.ID.String()+"/users",
+		map[string]string{"group_id": first.ID.String()},
+		cfg.HandlerAddUserToGroup,
+		handlers.AddUserToGroupData{Username: "alex"},
+	)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func postRecurring(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, group database.Group, payload handlers.RecurringData) database.RecurringTransaction {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/api/groups/"+group.ID.String()+"/recurring", bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String()})
+	rr := httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerCreateRecurring),
+	).ServeHTTP(rr, r)
+
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	recurring := database.RecurringTransaction{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&recurring))
+
+	return recurring
+}
+
+func TestMaterializeRecurring(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+
+	_, cookie := signup(t, cfg, "alice")
+	signup(t, cfg, "bob")
+	group := createGroupWithMembers(t, cfg, cookie, "bob")
+
+	recurring := postRecurring(t, cfg, cookie, group, handlers.RecurringData{
+		Kind:        "expense",
+		Frequency:   "monthly",
+		StartsOn:    "2025-01-31",
+		EndsOn:      "2025-04-30",
+		Description: "Rent",
+		Amount:      decimal.RequireFromString("30"),
+	})
+
+	n, err := api.MaterializeRecurring(ctx, cfg.DB, cfg.Tx, cfg.Queries, time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	// Running again on the same day creates nothing new.
+	n, err = api.MaterializeRecurring(ctx, cfg.DB, cfg.Tx, cfg.Queries, time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// The schedule ends after April.
+	n, err = api.MaterializeRecurring(ctx, cfg.DB, cfg.Tx, cfg.Queries, time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	txs, err := accounting.GetTransationsByGroup(cfg.Queries, ctx, group.ID)
+	require.NoError(t, err)
+	require.Len(t, txs, 4)
+
+	dates := make([]string, len(txs))
+	for i, tx := range txs {
+		dates[i] = tx.OccurredOn.Format(time.DateOnly)
+		require.Equal(t, "Rent", tx.Expense.Description)
+		require.Len(t, tx.Expense.Participants, 2)
+	}
+	require.ElementsMatch(t, []string{"2025-01-31", "2025-02-28", "2025-03-31", "2025-04-30"}, dates)
+
+	stored, err := cfg.Queries.GetRecurringTransaction(ctx, recurring.ID)
+	require.NoError(t, err)
+	require.Equal(t, "2025-05-31", stored.NextOccurrence.Format(time.DateOnly))
+}
+
+func TestPausedRecurringIsSkipped(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+
+	_, cookie := signup(t, cfg, "alice")
+	signup(t, cfg, "bob")
+	group := createGroupWithMembers(t, cfg, cookie, "bob")
+
+	data := handlers.RecurringData{
+		Kind:      "payment",
+		Frequency: "weekly",
+		StartsOn:  "2025-01-01",
+		Amount:    decimal.RequireFromString("10"),
+		PaidBy:    "alice",
+		PaidTo:    "bob",
+	}
+	recurring := postRecurring(t, cfg, cookie, group, data)
+
+	data.Paused = true
+	body, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("PUT", "/api/groups/"+group.ID.String()+"/recurring/"+recurring.ID.String(), bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String(), "id": recurring.ID.String()})
+	rr := httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerUpdateRecurring),
+	).ServeHTTP(rr, r)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	_, err = api.MaterializeRecurring(ctx, cfg.DB, cfg.Tx, cfg.Queries, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	txs, err := accounting.GetTransationsByGroup(cfg.Queries, ctx, group.ID)
+	require.NoError(t, err)
+	require.Empty(t, txs)
+}
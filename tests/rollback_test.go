@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+// createCommittedGroup creates a group with an owner and one other member
+// outside of any test transaction, so that the service functions under test
+// begin and roll back transactions of their own. The group and both users
+// are deleted when the test finishes.
+func createCommittedGroup(t *testing.T) (database.Group, database.User, database.User) {
+	t.Helper()
+
+	owner, err := queries.CreateUser(t.Context(), database.CreateUserParams{
+		Username:       "owner-" + uuid.NewString(),
+		HashedPassword: "password",
+	})
+	require.NoError(t, err)
+
+	// Cleanups run last first, so the users are deleted after the group
+	// that refers to them.
+	t.Cleanup(func() { deleteCommittedUser(owner.ID) })
+
+	member, err := queries.CreateUser(t.Context(), database.CreateUserParams{
+		Username:       "member-" + uuid.NewString(),
+		HashedPassword: "password",
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { deleteCommittedUser(member.ID) })
+
+	group, err := api.CreateGroup(t.Context(), db, nil, queries, database.CreateGroupParams{
+		Name:  "Group",
+		Owner: owner.ID,
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { queries.DeleteGroup(context.Background(), group.ID) })
+
+	_, err = api.AddUserToGroup(t.Context(), db, nil, queries, owner.ID, database.CreateUserGroupParams{
+		UserID:  member.ID,
+		GroupID: group.ID,
+	})
+	require.NoError(t, err)
+
+	return group, owner, member
+}
+
+// deleteCommittedUser deletes a user created outside of any test
+// transaction. There's no query for it, since users are never deleted
+// otherwise.
+func deleteCommittedUser(id uuid.UUID) {
+	db.ExecContext(context.Background(), "DELETE FROM users WHERE id = $1", id)
+}
+
+func expenseParams(group database.Group, owner database.User, amount string, participants ...uuid.UUID) api.CreateExpenseParams {
+	parts := make([]accounting.SplitPart, len(participants))
+	for i, id := range participants {
+		parts[i] = accounting.SplitPart{UserID: id}
+	}
+
+	total := decimal.RequireFromString(amount)
+	shares, _ := accounting.ComputeShares(accounting.SplitEqual, total, parts)
+
+	return api.CreateExpenseParams{
+		GroupID:     group.ID,
+		CreatedBy:   owner.ID,
+		Payers:      []accounting.Contribution{{UserID: owner.ID, Amount: total}},
+		Description: "Expense",
+		Amount:      total,
+		SplitMode:   accounting.SplitEqual,
+		Parts:       parts,
+		Shares:      shares,
+	}
+}
+
+func TestCreateExpenseRollsBack(t *testing.T) {
+	group, owner, member := createCommittedGroup(t)
+
+	// The last participant doesn't exist, so creating their split fails
+	// after the transaction, the expense and the first split were created.
+	params := expenseParams(group, owner, "30.00", owner.ID, member.ID, uuid.New())
+
+	_, err := api.CreateExpense(t.Context(), db, nil, queries, params)
+	require.Error(t, err)
+
+	transactions, err := queries.GetTransactionsByGroup(t.Context(), group.ID)
+	require.NoError(t, err)
+	assert.Empty(t, transactions)
+
+	expenses, err := queries.GetExpensesByGroup(t.Context(), group.ID)
+	require.NoError(t, err)
+	assert.Empty(t, expenses)
+
+	balances, err := queries.GetPairwiseBalancesByGroup(t.Context(), group.ID)
+	require.NoError(t, err)
+	assert.Empty(t, balances)
+}
+
+func TestUpdateExpenseRollsBack(t *testing.T) {
+	group, owner, member := createCommittedGroup(t)
+
+	expense, err := api.CreateExpense(t.Context(), db, nil, queries, expenseParams(group, owner, "30.00", owner.ID, member.ID))
+	require.NoError(t, err)
+
+	// Re-splitting with a participant that doesn't exist fails after the
+	// old splits and debts were deleted.
+	updated := expenseParams(group, owner, "60.00", owner.ID, member.ID, uuid.New())
+	_, err = api.UpdateExpense(t.Context(), db, nil, queries, api.UpdateExpenseParams{
+		TransactionID: expense.TransactionID,
+		ExpenseID:     expense.ID,
+		UpdatedBy:     owner.ID,
+		Payers:        updated.Payers,
+		Description:   "Updated",
+		Amount:        updated.Amount,
+		SplitMode:     updated.SplitMode,
+		Parts:         updated.Parts,
+		Shares:        updated.Shares,
+	})
+	require.Error(t, err)
+
+	stored, err := queries.GetExpenseByTransaction(t.Context(), expense.TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, "Expense", stored.Description)
+	assert.True(t, decimal.RequireFromString("30").Equal(stored.Amount))
+
+	splits, err := queries.GetExpenseSplitsByExpense(t.Context(), expense.ID)
+	require.NoError(t, err)
+	assert.Len(t, splits, 2)
+
+	debts, err := queries.GetDebtsByTransaction(t.Context(), expense.TransactionID)
+	require.NoError(t, err)
+	require.Len(t, debts, 1)
+	assert.Equal(t, member.ID, debts[0].OwedBy.UUID)
+	assert.True(t, decimal.RequireFromString("15").Equal(debts[0].Amount))
+}
+
+func TestCreatePaymentRollsBack(t *testing.T) {
+	group, owner, _ := createCommittedGroup(t)
+
+	// Paying a user that doesn't exist fails after the transaction was
+	// created.
+	_, err := api.CreatePayment(t.Context(), db, nil, queries, api.CreatePaymentParams{
+		GroupID:   group.ID,
+		CreatedBy: owner.ID,
+		PaidBy:    owner.ID,
+		PaidTo:    uuid.New(),
+		Amount:    decimal.RequireFromString("10"),
+	})
+	require.Error(t, err)
+
+	transactions, err := queries.GetTransactionsByGroup(t.Context(), group.ID)
+	require.NoError(t, err)
+	assert.Empty(t, transactions)
+}
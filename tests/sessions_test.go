@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/auth"
+)
+
+// login logs in as username and returns the new session's cookie.
+func login(t testing.TB, cfg *handlers.Config, username string) *http.Cookie {
+	t.Helper()
+
+	body, err := json.Marshal(handlers.LoginUserData{Username: username, Password: "password"})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/api/login", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	cfg.HandlerLogin(rr, r)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	cookies := rr.Result().Cookies()
+	require.NotEmpty(t, cookies)
+
+	return cookies[0]
+}
+
+// getBalances makes an authenticated request as the user of cookie.
+func getBalances(t testing.TB, cfg *handlers.Config, cookie *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return groupRequest(t, cfg, cookie, "GET", "/api/balances", nil, cfg.HandlerGetBalances, nil)
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "user")
+	other := login(t, cfg, "user")
+
+	rr := getBalances(t, cfg, cookie)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	r := httptest.NewRequest("POST", "/api/logout", nil)
+	r.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	cfg.HandlerLogout(rr, r)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	// A copy of the logged out cookie no longer works...
+	rr = getBalances(t, cfg, cookie)
+	assert.Equal(t, http.StatusSeeOther, rr.Code, rr.Body.String())
+
+	// ...but sessions on other devices carry on.
+	rr = getBalances(t, cfg, other)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestExpiredAccessTokenIsRefreshed(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	user, _ := signup(t, cfg, "user")
+
+	session, err := api.StartSession(t.Context(), cfg.Queries, user.ID, time.Now())
+	require.NoError(t, err)
+
+	expired, err := auth.MakeJWT(user.ID, session.ID, cfg.JwtKey, -time.Minute)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	require.NoError(t, auth.SetSessionTokens(cfg.Store, rr, r, expired, session.RefreshToken))
+	cookie := rr.Result().Cookies()[0]
+
+	rr = getBalances(t, cfg, cookie)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	cookies := rr.Result().Cookies()
+	require.NotEmpty(t, cookies)
+
+	rr = getBalances(t, cfg, cookies[0])
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	user, _ := signup(t, cfg, "user")
+	now := time.Now()
+
+	first, err := api.StartSession(t.Context(), cfg.Queries, user.ID, now)
+	require.NoError(t, err)
+
+	second, err := api.RefreshSession(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, first.RefreshToken, now)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	// Requests racing each other with the same token aren't a theft...
+	_, err = api.RefreshSession(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, first.RefreshToken, now.Add(time.Second))
+	assert.ErrorIs(t, err, api.ErrInvalidRefreshToken)
+
+	active, err := api.SessionActive(t.Context(), cfg.Queries, user.ID, first.ID)
+	require.NoError(t, err)
+	assert.True(t, active)
+
+	// ...but using it again later is, so the whole session is revoked.
+	_, err = api.RefreshSession(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, first.RefreshToken, now.Add(time.Minute))
+	assert.ErrorIs(t, err, api.ErrRefreshTokenReused)
+
+	_, err = api.RefreshSession(t.Context(), cfg.DB, cfg.Tx, cfg.Queries, second.RefreshToken, now.Add(time.Minute))
+	assert.ErrorIs(t, err, api.ErrInvalidRefreshToken)
+
+	active, err = api.SessionActive(t.Context(), cfg.Queries, user.ID, first.ID)
+	require.NoError(t, err)
+	assert.False(t, active)
+}
+
+func TestPasswordChangeRevokesSessions(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "user")
+	other := login(t, cfg, "user")
+
+	rr := groupRequest(t, cfg, cookie, "PUT", "/api/users", nil, cfg.HandlerUpdateUser, handlers.UpdateUserData{Password: "new password"})
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	cookies := rr.Result().Cookies()
+	require.NotEmpty(t, cookies)
+
+	rr = getBalances(t, cfg, other)
+	assert.Equal(t, http.StatusSeeOther, rr.Code, rr.Body.String())
+
+	rr = getBalances(t, cfg, cookie)
+	assert.Equal(t, http.StatusSeeOther, rr.Code, rr.Body.String())
+
+	// Whoever changed the password stays logged in.
+	rr = getBalances(t, cfg, cookies[0])
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
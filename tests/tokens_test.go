@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/auth"
+)
+
+func createAPIToken(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, data handlers.CreateAPITokenData) handlers.ExportAPIToken {
+	t.Helper()
+
+	rr := groupRequest(t, cfg, cookie, "POST", "/api/tokens", nil, cfg.HandlerCreateAPIToken, data)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	apiToken := handlers.ExportAPIToken{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&apiToken))
+
+	return apiToken
+}
+
+// tokenRequest sends body to handler with token in an Authorization header.
+func tokenRequest(cfg *handlers.Config, token, method, path string, vars map[string]string, handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	r = mux.SetURLVars(r, vars)
+	rr := httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(handler).ServeHTTP(rr, r)
+
+	return rr
+}
+
+func TestAPITokens(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "user")
+	group := createGroupWithMembers(t, cfg, cookie)
+	vars := map[string]string{"group_id": group.ID.String()}
+
+	rr := groupRequest(t, cfg, cookie, "POST", "/api/tokens", nil, cfg.HandlerCreateAPIToken, handlers.CreateAPITokenData{Name: " "})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = groupRequest(t, cfg, cookie, "POST", "/api/tokens", nil, cfg.HandlerCreateAPIToken, handlers.CreateAPITokenData{Name: "script", Scope: "admin"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	read := createAPIToken(t, cfg, cookie, handlers.CreateAPITokenData{Name: "reports"})
+	assert.Equal(t, api.ScopeRead, read.Scope)
+	assert.True(t, strings.HasPrefix(read.Token, auth.APITokenPrefix))
+
+	write := createAPIToken(t, cfg, cookie, handlers.CreateAPITokenData{Name: "import", Scope: "write", ExpiresInDays: 7})
+
+	// Only a hash of the token is kept.
+	_, err := cfg.Queries.GetAPITokenByHash(t.Context(), read.Token)
+	assert.Error(t, err)
+
+	// Read tokens can look things up, but not change anything.
+	rr = tokenRequest(cfg, read.Token, "GET", "/api/groups/"+group.ID.String()+"/settle-up", vars, cfg.HandlerGetSettleUp, "")
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	expense := `{"description": "Dinner", "amount": "10.00"}`
+
+	rr = tokenRequest(cfg, read.Token, "POST", "/api/groups/"+group.ID.String()+"/expenses", vars, cfg.HandlerCreateExpense, expense)
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = tokenRequest(cfg, write.Token, "POST", "/api/groups/"+group.ID.String()+"/expenses", vars, cfg.HandlerCreateExpense, expense)
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// Tokens can't make more tokens or change the password.
+	rr = tokenRequest(cfg, write.Token, "POST", "/api/tokens", nil, cfg.HandlerCreateAPIToken, `{"name": "another"}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = tokenRequest(cfg, write.Token, "PUT", "/api/users", nil, cfg.HandlerUpdateUser, `{"password": "hijacked"}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+
+	rr = tokenRequest(cfg, "swt_unknown", "GET", "/api/balances", nil, cfg.HandlerGetBalances, "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+
+	rr = groupRequest(t, cfg, cookie, "GET", "/api/tokens", nil, cfg.HandlerGetAPITokens, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	tokens := []handlers.ExportAPIToken{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&tokens))
+	require.Len(t, tokens, 2)
+	assert.Empty(t, tokens[0].Token)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	// Revoked tokens stop working.
+	revoke := func() *httptest.ResponseRecorder {
+		return groupRequest(
+			t,
+			cfg,
+			cookie,
+			"DELETE",
+			"/api/tokens/"+write.ID.String(),
+			map[string]string{"id": write.ID.String()},
+			cfg.HandlerRevokeAPIToken,
+			nil,
+		)
+	}
+
+	rr = revoke()
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = revoke()
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+	rr = tokenRequest(cfg, write.Token, "GET", "/api/balances", nil, cfg.HandlerGetBalances, "")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+
+	// Nobody else can revoke a user's tokens.
+	_, otherCookie := signup(t, cfg, "other")
+	rr = groupRequest(
+		t,
+		cfg,
+		otherCookie,
+		"DELETE",
+		"/api/tokens/"+read.ID.String(),
+		map[string]string{"id": read.ID.String()},
+		cfg.HandlerRevokeAPIToken,
+		nil,
+	)
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
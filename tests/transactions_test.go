@@ -0,0 +1,279 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func postPayment(t testing.TB, cfg *handlers.Config, cookie *http.Cookie, group database.Group, payload map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/api/groups/"+group.ID.String()+"/payments", bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String()})
+	rr := httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerCreatePayment),
+	).ServeHTTP(rr, r)
+
+	return rr
+}
+
+// loadTransactionsOneByOne builds the group's transactions with separate
+// queries for each transaction, the way GetTransationsByGroup used to.
+func loadTransactionsOneByOne(t *testing.T, queries *database.Queries, ctx context.Context, groupID uuid.UUID) []accounting.Transaction {
+	t.Helper()
+
+	dbUsers, err := queries.GetUsersByGroup(ctx, groupID)
+	require.NoError(t, err)
+
+	users := make(map[uuid.UUID]*accounting.User)
+	for _, u := range dbUsers {
+		users[u.ID] = &accounting.User{ID: u.ID, Username: u.Username}
+	}
+
+	lookup := func(id uuid.NullUUID) *accounting.User {
+		if !id.Valid {
+			return nil
+		}
+		return users[id.UUID]
+	}
+
+	dbTransactions, err := queries.GetTransactionsByGroup(ctx, groupID)
+	require.NoError(t, err)
+
+	var transactions []accounting.Transaction
+	for _, dbTransaction := range dbTransactions {
+		transaction := accounting.Transaction{
+			ID:         dbTransaction.ID,
+			CreatedAt:  dbTransaction.CreatedAt,
+			UpdatedAt:  dbTransaction.UpdatedAt,
+			OccurredOn: dbTransaction.OccurredOn,
+			CreatedBy:  lookup(dbTransaction.CreatedBy),
+			Kind:       accounting.TransactionKind(dbTransaction.Kind),
+		}
+
+		switch transaction.Kind {
+		case accounting.ExpenseKind:
+			dbExpense, err := queries.GetExpenseByTransaction(ctx, dbTransaction.ID)
+			require.NoError(t, err)
+
+			dbDebts, err := queries.GetDebtsByTransaction(ctx, dbTransaction.ID)
+			require.NoError(t, err)
+
+			dbSplits, err := queries.GetExpenseSplitsByExpense(ctx, dbExpense.ID)
+			require.NoError(t, err)
+
+			dbPayers, err := queries.GetExpensePayersByExpense(ctx, dbExpense.ID)
+			require.NoError(t, err)
+
+			expense := &accounting.Expense{
+				Description: dbExpense.Description,
+				PaidBy:      lookup(dbExpense.PaidBy),
+				Amount:      dbExpense.Amount,
+				Currency:    dbExpense.Currency,
+				SplitMode:   accounting.SplitMode(dbExpense.SplitMode),
+				Debts:       make([]accounting.Debt, len(dbDebts)),
+			}
+
+			for _, dbPayer := range dbPayers {
+				expense.Payers = append(expense.Payers, accounting.Payer{
+					User:   lookup(dbPayer.UserID),
+					Amount: dbPayer.Amount,
+				})
+			}
+
+			for _, dbSplit := range dbSplits {
+				if u := lookup(dbSplit.UserID); u != nil {
+					expense.Participants = append(expense.Participants, u)
+				}
+			}
+
+			for i, dbDebt := range dbDebts {
+				expense.Debts[i] = accounting.Debt{
+					Amount: dbDebt.Amount,
+					OwedBy: lookup(dbDebt.OwedBy),
+					OwedTo: lookup(dbDebt.OwedTo),
+				}
+			}
+
+			transaction.Expense = expense
+		case accounting.PaymentKind:
+			dbPayment, err := queries.GetPaymentByTransaction(ctx, dbTransaction.ID)
+			require.NoError(t, err)
+
+			transaction.Payment = &accounting.Payment{
+				PaidBy:   lookup(dbPayment.PaidBy),
+				PaidTo:   lookup(dbPayment.PaidTo),
+				Amount:   dbPayment.Amount,
+				Currency: dbPayment.Currency,
+			}
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions
+}
+
+// normalizeTransactions sorts transactions and their nested rows so that
+// results can be compared regardless of the order rows came back in.
+func normalizeTransactions(transactions []accounting.Transaction) {
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].ID.String() < transactions[j].ID.String()
+	})
+
+	for _, transaction := range transactions {
+		if transaction.Expense == nil {
+			continue
+		}
+
+		participants := transaction.Expense.Participants
+		sort.Slice(participants, func(i, j int) bool {
+			return participants[i].Username < participants[j].Username
+		})
+
+		debts := transaction.Expense.Debts
+		sort.Slice(debts, func(i, j int) bool {
+			return debts[i].OwedBy.Username < debts[j].OwedBy.Username
+		})
+	}
+}
+
+func seedTransactions(t *testing.T, cfg *handlers.Config) database.Group {
+	t.Helper()
+
+	_, cookie := signup(t, cfg, "owner")
+	signup(t, cfg, "first")
+	signup(t, cfg, "second")
+
+	group := createGroupWithMembers(t, cfg, cookie, "first", "second")
+
+	expenses := []map[string]any{
+		{"description": "Rent", "amount": "30.00"},
+		{"description": "Dinner", "amount": "20.00", "participants": []string{"owner", "first"}},
+		{
+			"description": "Groceries",
+			"amount":      "10.00",
+			"split_mode":  "shares",
+			"splits": []map[string]string{
+				{"username": "owner", "value": "1"},
+				{"username": "first", "value": "2"},
+				{"username": "second", "value": "2"},
+			},
+		},
+	}
+	for _, expense := range expenses {
+		rr := postExpense(t, cfg, cookie, group, expense)
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	rr := postPayment(t, cfg, cookie, group, map[string]any{
+		"paid_by": "first",
+		"paid_to": "owner",
+		"amount":  "5.00",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	return group
+}
+
+func TestGetTransactionsByGroup(t *testing.T) {
+	cfg := newTestConfig(t)
+	group := seedTransactions(t, cfg)
+
+	expected := loadTransactionsOneByOne(t, cfg.Queries, t.Context(), group.ID)
+
+	actual, err := accounting.GetTransationsByGroup(cfg.Queries, t.Context(), group.ID)
+	require.NoError(t, err)
+	require.Len(t, actual, 4)
+
+	normalizeTransactions(expected)
+	normalizeTransactions(actual)
+	assert.Equal(t, expected, actual)
+}
+
+func TestGetTransactionsPageByGroup(t *testing.T) {
+	cfg := newTestConfig(t)
+	group := seedTransactions(t, cfg)
+
+	all, err := accounting.GetTransationsByGroup(cfg.Queries, t.Context(), group.ID)
+	require.NoError(t, err)
+
+	var paged []accounting.Transaction
+	for offset := int32(0); ; offset += 3 {
+		page, err := accounting.GetTransactionsPageByGroup(cfg.Queries, t.Context(), group.ID, 3, offset)
+		require.NoError(t, err)
+
+		if len(page) == 0 {
+			break
+		}
+
+		assert.LessOrEqual(t, len(page), 3)
+		paged = append(paged, page...)
+	}
+
+	normalizeTransactions(all)
+	normalizeTransactions(paged)
+	assert.Equal(t, all, paged)
+}
+
+func TestTransactionsOrderedByOccurredOn(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "owner")
+	group := createGroupWithMembers(t, cfg, cookie)
+
+	for _, date := range []string{"2024-03-02", "2024-03-05", "2024-03-01"} {
+		rr := postExpense(t, cfg, cookie, group, map[string]any{
+			"description": date,
+			"amount":      "10.00",
+			"occurred_on": date,
+		})
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	rr := postPayment(t, cfg, cookie, group, map[string]any{
+		"paid_by":     "owner",
+		"paid_to":     "owner",
+		"amount":      "1.00",
+		"occurred_on": "2024-03-04",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	transactions, err := accounting.GetTransationsByGroup(cfg.Queries, t.Context(), group.ID)
+	require.NoError(t, err)
+
+	dates := make([]string, len(transactions))
+	for i, transaction := range transactions {
+		dates[i] = transaction.OccurredOn.Format(time.DateOnly)
+	}
+	assert.Equal(t, []string{"2024-03-05", "2024-03-04", "2024-03-02", "2024-03-01"}, dates)
+
+	// Dates that can't be parsed are rejected
+	rr = postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Bad date",
+		"amount":      "10.00",
+		"occurred_on": "03/01/2024",
+	})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
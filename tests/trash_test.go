@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/internal/accounting"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/database"
+)
+
+func TestDeleteAndRestoreTransaction(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := t.Context()
+
+	_, cookie := signup(t, cfg, "alice")
+	signup(t, cfg, "bob")
+	group := createGroupWithMembers(t, cfg, cookie, "bob")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Dinner",
+		"amount":      "20.00",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	expense := database.Expense{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&expense))
+
+	body, err := json.Marshal(map[string]any{"id": expense.TransactionID})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("DELETE", "/api/groups/"+group.ID.String()+"/transactions", bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String()})
+	rr = httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerDeleteTransaction),
+	).ServeHTTP(rr, r)
+
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	// The expense leaves the list and the balances but stays in the trash.
+	txs, err := accounting.GetTransationsByGroup(cfg.Queries, ctx, group.ID)
+	require.NoError(t, err)
+	require.Empty(t, txs)
+
+	suggestions, err := accounting.GetSettleUpForGroup(cfg.Queries, ctx, group.ID)
+	require.NoError(t, err)
+	require.Empty(t, suggestions)
+
+	deleted, err := accounting.GetDeletedTransactionsByGroup(cfg.Queries, ctx, group.ID)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.NotNil(t, deleted[0].DeletedAt)
+
+	r = httptest.NewRequest("POST", "/api/groups/"+group.ID.String()+"/transactions/"+expense.TransactionID.String()+"/restore", nil)
+	r.AddCookie(cookie)
+	r = mux.SetURLVars(r, map[string]string{"group_id": group.ID.String(), "id": expense.TransactionID.String()})
+	rr = httptest.NewRecorder()
+
+	cfg.AuthenticatedUserMiddleware(
+		http.HandlerFunc(cfg.HandlerRestoreTransaction),
+	).ServeHTTP(rr, r)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	txs, err = accounting.GetTransationsByGroup(cfg.Queries, ctx, group.ID)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+
+	suggestions, err = accounting.GetSettleUpForGroup(cfg.Queries, ctx, group.ID)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := t.Context()
+
+	_, cookie := signup(t, cfg, "alice")
+	signup(t, cfg, "bob")
+	group := createGroupWithMembers(t, cfg, cookie, "bob")
+
+	rr := postExpense(t, cfg, cookie, group, map[string]any{
+		"description": "Dinner",
+		"amount":      "20.00",
+	})
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	expense := database.Expense{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&expense))
+
+	require.NoError(t, cfg.Queries.SoftDeleteTransaction(ctx, expense.TransactionID))
+
+	// Nothing has been in the trash for long enough yet.
+	_, err := api.PurgeTrash(ctx, cfg.DB, cfg.Tx, cfg.Queries, 24*time.Hour, time.Now())
+	require.NoError(t, err)
+
+	_, err = cfg.Queries.GetTransaction(ctx, expense.TransactionID)
+	require.NoError(t, err)
+
+	n, err := api.PurgeTrash(ctx, cfg.DB, cfg.Tx, cfg.Queries, 24*time.Hour, time.Now().Add(48*time.Hour))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, 1)
+
+	_, err = cfg.Queries.GetTransaction(ctx, expense.TransactionID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
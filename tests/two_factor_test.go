@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/matt-horst/split-ways/handlers"
+	"github.com/matt-horst/split-ways/internal/api"
+	"github.com/matt-horst/split-ways/internal/auth"
+	"github.com/matt-horst/split-ways/internal/totp"
+)
+
+// enableTwoFactor turns on two-factor authentication for the user of cookie,
+// returning their secret and recovery codes.
+func enableTwoFactor(t testing.TB, cfg *handlers.Config, cookie *http.Cookie) ([]byte, []string) {
+	t.Helper()
+
+	rr := groupRequest(t, cfg, cookie, "POST", "/api/users/two-factor", nil, cfg.HandlerStartTwoFactor, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	setup := handlers.ExportTwoFactorSetup{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&setup))
+	assert.Contains(t, setup.URI, "otpauth://totp/")
+	assert.Contains(t, setup.QRCode, "data:image/png;base64,")
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(setup.Secret)
+	require.NoError(t, err)
+
+	rr = groupRequest(t, cfg, cookie, "PUT", "/api/users/two-factor", nil, cfg.HandlerEnableTwoFactor, handlers.TwoFactorCodeData{Code: totp.Code(secret, time.Now().Add(-time.Hour))})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = groupRequest(t, cfg, cookie, "PUT", "/api/users/two-factor", nil, cfg.HandlerEnableTwoFactor, handlers.TwoFactorCodeData{Code: totp.Code(secret, time.Now())})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	codes := handlers.ExportRecoveryCodes{}
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&codes))
+	require.Len(t, codes.RecoveryCodes, api.RecoveryCodeCount)
+
+	return secret, codes.RecoveryCodes
+}
+
+// loginTwoFactor gives code as the second step of logging in, with the
+// cookie from the first.
+func loginTwoFactor(cfg *handlers.Config, cookie *http.Cookie, code string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(handlers.TwoFactorCodeData{Code: code})
+
+	r := httptest.NewRequest("POST", "/api/login/two-factor", bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	rr := httptest.NewRecorder()
+	cfg.HandlerLoginTwoFactor(rr, r)
+
+	return rr
+}
+
+// startLogin gives the right password of a user with two-factor
+// authentication, returning the cookie for the second step.
+func startLogin(t testing.TB, cfg *handlers.Config, username string) *http.Cookie {
+	t.Helper()
+
+	rr := tryLogin(cfg, username, "password")
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+
+	cookies := rr.Result().Cookies()
+	require.NotEmpty(t, cookies)
+
+	return cookies[0]
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "user")
+	secret, recoveryCodes := enableTwoFactor(t, cfg, cookie)
+
+	rr := loginTwoFactor(cfg, cookie, totp.Code(secret, time.Now()))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "a session cookie isn't a password")
+
+	pending := startLogin(t, cfg, "user")
+
+	rr = getBalances(t, cfg, pending)
+	assert.Equal(t, http.StatusSeeOther, rr.Code, "giving the password alone doesn't log in")
+
+	rr = loginTwoFactor(cfg, pending, totp.Code(secret, time.Now().Add(-time.Hour)))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "old codes don't work")
+
+	code := totp.Code(secret, time.Now().Add(totp.Period))
+
+	rr = loginTwoFactor(cfg, pending, code)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	cookies := rr.Result().Cookies()
+	require.NotEmpty(t, cookies)
+
+	rr = getBalances(t, cfg, cookies[0])
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = loginTwoFactor(cfg, cookies[0], recoveryCodes[0])
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "logging in finishes the pending login")
+
+	rr = loginTwoFactor(cfg, startLogin(t, cfg, "user"), code)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "codes can't be replayed")
+
+	rr = loginTwoFactor(cfg, startLogin(t, cfg, "user"), recoveryCodes[0])
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = loginTwoFactor(cfg, startLogin(t, cfg, "user"), recoveryCodes[0])
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "recovery codes can only be used once")
+}
+
+func TestTwoFactorLoginAfterPasswordChange(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	user, cookie := signup(t, cfg, "user")
+	secret, _ := enableTwoFactor(t, cfg, cookie)
+
+	// Someone gave the old password a minute ago...
+	now := time.Now()
+	token, err := auth.MakeTwoFactorToken(user.ID, cfg.JwtKey, now.Add(-time.Minute), now.Add(time.Minute))
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	require.NoError(t, auth.SetTwoFactorToken(cfg.Store, rr, r, token))
+	pending := rr.Result().Cookies()[0]
+
+	// ...and the password was changed since.
+	rr = groupRequest(t, cfg, cookie, "PUT", "/api/users", nil, cfg.HandlerUpdateUser, handlers.UpdateUserData{Password: "new password"})
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = loginTwoFactor(cfg, pending, totp.Code(secret, time.Now()))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+}
+
+func TestTwoFactorLockout(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	_, cookie := signup(t, cfg, "user")
+	secret, _ := enableTwoFactor(t, cfg, cookie)
+
+	pending := startLogin(t, cfg, "user")
+
+	for range api.MaxFailedLogins {
+		rr := loginTwoFactor(cfg, pending, "not-a-code")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+	}
+
+	rr := loginTwoFactor(cfg, pending, totp.Code(secret, time.Now().Add(totp.Period)))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, rr.Body.String())
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	user, cookie := signup(t, cfg, "user")
+	_, adminCookie := signupAdmin(t, cfg, "admin")
+	secret, recoveryCodes := enableTwoFactor(t, cfg, cookie)
+
+	rr := groupRequest(t, cfg, cookie, "POST", "/api/users/two-factor", nil, cfg.HandlerStartTwoFactor, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code, "starting again can't replace an enabled secret")
+
+	rr = groupRequest(t, cfg, cookie, "POST", "/api/users/two-factor/recovery-codes", nil, cfg.HandlerRegenerateRecoveryCodes, handlers.TwoFactorCodeData{Code: recoveryCodes[0]})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = groupRequest(t, cfg, cookie, "DELETE", "/api/users/two-factor", nil, cfg.HandlerDisableTwoFactor, handlers.TwoFactorCodeData{Code: recoveryCodes[1]})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "old recovery codes stop working")
+
+	rr = groupRequest(t, cfg, cookie, "DELETE", "/api/users/two-factor", nil, cfg.HandlerDisableTwoFactor, handlers.TwoFactorCodeData{Code: totp.Code(secret, time.Now().Add(totp.Period))})
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	enableTwoFactor(t, cfg, cookie)
+
+	rr = adminRequest(
+		t,
+		cfg,
+		adminCookie,
+		"DELETE",
+		"/api/admin/users/"+user.ID.String()+"/two-factor",
+		map[string]string{"id": user.ID.String()},
+		cfg.HandlerAdminResetTwoFactor,
+		nil,
+	)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = tryLogin(cfg, "user", "password")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+}
+
+func TestTwoFactorUnavailable(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.TwoFactorKey = nil
+
+	_, cookie := signup(t, cfg, "user")
+
+	rr := groupRequest(t, cfg, cookie, "POST", "/api/users/two-factor", nil, cfg.HandlerStartTwoFactor, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, rr.Body.String())
+}
@@ -42,7 +42,7 @@ func Navbar(isLoggedIn bool) templ.Component {
 			return templ_7745c5c3_Err
 		}
 		if isLoggedIn {
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<a href=\"/logout\" class=\"nav-item nav-logout\"><span>Logout</span></a>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<a href=\"/settings\" class=\"nav-item\">Settings</a> <a href=\"/logout\" class=\"nav-item nav-logout\"><span>Logout</span></a>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
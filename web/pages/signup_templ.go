@@ -47,7 +47,7 @@ func Signup() templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<h1>Signup</h1><form id=\"form\"><input id=\"input-username\" type=\"text\" placeholder=\"username\" required> <input id=\"input-password\" type=\"password\" placeholder=\"password\" required> <button id=\"button-submit\" type=\"submit\">Submit</button></form>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<h1>Signup</h1><form id=\"form\"><input id=\"input-username\" type=\"text\" placeholder=\"username\" required> <input id=\"input-password\" type=\"password\" placeholder=\"password\" required> <input id=\"input-email\" type=\"email\" placeholder=\"email (optional)\"> <button id=\"button-submit\" type=\"submit\">Submit</button></form><p class=\"section-hint\">An email address is only used to reset your password if you forget it.</p>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -0,0 +1,77 @@
+import { showError, hide } from "./status.js"
+
+const status = document.getElementById("status");
+
+// adminRequest changes a user from the admin console, reloading the page
+// once it's done.
+async function adminRequest(userID, action, method, data) {
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            `/api/admin/users/${userID}/${action}`,
+            {
+                method: method,
+                header: {"Content-Type": "application/json"},
+                body: data === undefined ? undefined : JSON.stringify(data),
+                credentials: "same-origin"
+            }
+        );
+
+        if (resp.ok) {
+            window.location.reload();
+        } else {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        }
+    } catch (e) {
+        console.log(e);
+    }
+}
+
+document.querySelectorAll(".btn-set-disabled").forEach(btn => {
+    btn.addEventListener("click", async (event) => {
+        const disabled = btn.dataset.disabled == "true";
+
+        if (disabled && !confirm("Disable this account? They will be logged out everywhere.")) {
+            return;
+        }
+
+        await adminRequest(btn.dataset.id, "disabled", "PUT", {"disabled": disabled});
+    });
+});
+
+document.querySelectorAll(".btn-set-admin").forEach(btn => {
+    btn.addEventListener("click", async (event) => {
+        await adminRequest(btn.dataset.id, "admin", "PUT", {"admin": btn.dataset.admin == "true"});
+    });
+});
+
+document.querySelectorAll(".btn-unlock").forEach(btn => {
+    btn.addEventListener("click", async (event) => {
+        await adminRequest(btn.dataset.id, "unlock", "POST");
+    });
+});
+
+document.querySelectorAll(".btn-reset-password").forEach(btn => {
+    btn.addEventListener("click", async (event) => {
+        const password = prompt(`New password for ${btn.dataset.username}. They will be logged out everywhere.`);
+
+        if (!password) {
+            return;
+        }
+
+        await adminRequest(btn.dataset.id, "password", "PUT", {"password": password});
+    });
+});
+
+document.querySelectorAll(".btn-reset-two-factor").forEach(btn => {
+    btn.addEventListener("click", async (event) => {
+        if (!confirm(`Turn off two-factor authentication for ${btn.dataset.username}? Only do this if you're sure they lost their authenticator app and recovery codes.`)) {
+            return;
+        }
+
+        await adminRequest(btn.dataset.id, "two-factor", "DELETE");
+    });
+});
@@ -1,11 +1,19 @@
 import { showError, showResult, hide } from "./status.js"
+import { readSplit } from "./split.js"
+import { readPayers } from "./payers.js"
 
 const inputDescription = document.getElementById("input-description");
 const inputAmount = document.getElementById("input-amount");
-const inputPaidBy = document.getElementById("input-paid-by");
+const inputOccurredOn = document.getElementById("input-occurred-on");
+const inputCurrency = document.getElementById("input-currency");
 const form = document.getElementById("form");
 const status = document.getElementById("status")
 
+// Default to today in the user's time zone
+const today = new Date();
+today.setMinutes(today.getMinutes() - today.getTimezoneOffset());
+inputOccurredOn.value = today.toISOString().slice(0, 10);
+
 form.addEventListener("submit", async (event) => {
     event.preventDefault();
 
@@ -13,7 +21,6 @@ form.addEventListener("submit", async (event) => {
 
     const amount = inputAmount.value.replace('$', '').trim();
     const description = inputDescription.value.trim();
-    const paidBy = inputPaidBy.value.trim();
 
     try {
         const resp = await fetch(
@@ -25,7 +32,10 @@ form.addEventListener("submit", async (event) => {
                     {
                         "description": description,
                         "amount": amount,
-                        "paid_by": paidBy,
+                        "currency": inputCurrency.value,
+                        "occurred_on": inputOccurredOn.value,
+                        ...readPayers(),
+                        ...readSplit(),
                     }
                 ),
                 credentials: "same-origin"
@@ -1,6 +1,7 @@
 import { showError, showResult, hide } from "./status.js"
 
 const inputName = document.getElementById("input-name");
+const inputCurrency = document.getElementById("input-currency");
 const form = document.getElementById("form");
 const status = document.getElementById("status")
 
@@ -17,7 +18,7 @@ form.addEventListener("submit", async (event) => {
             {
                 method: "POST",
                 header: {"Content-Type": "application/json"},
-                body: JSON.stringify({"name": name}),
+                body: JSON.stringify({"name": name, "currency": inputCurrency.value}),
                 credentials: "same-origin"
             }
         );
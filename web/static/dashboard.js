@@ -0,0 +1,69 @@
+import { showError, hide } from "./status.js"
+
+const addFriendForm = document.getElementById("add-friend-form");
+const inputFriendUsername = document.getElementById("input-friend-username");
+const removeFriendButtons = document.querySelectorAll(".btn-remove-friend");
+const status = document.getElementById("status");
+
+addFriendForm.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/friends",
+            {
+                method: "POST",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"username": inputFriendUsername.value.trim()}),
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            window.location.reload();
+        }
+    } catch (e) {
+        console.log(e)
+    }
+});
+
+// removeFriend ends a friendship, which only works once the user has settled
+// up with the friend.
+async function removeFriend(friendID) {
+    const resp = await fetch(
+        `/api/friends/${friendID}`,
+        {
+            method: "DELETE",
+            credentials: "same-origin"
+        }
+    );
+
+    if (resp.ok) {
+        window.location.reload();
+        return;
+    }
+
+    const msg = await resp.text();
+    showError(status, msg);
+    console.log(`${resp.status}: ${msg}`);
+}
+
+removeFriendButtons.forEach(btn => {
+    const friendID = btn.dataset.id;
+
+    btn.addEventListener("click", async (event) => {
+        hide(status)
+
+        try {
+            await removeFriend(friendID);
+        } catch (e) {
+            console.log(e);
+        }
+    });
+});
@@ -1,8 +1,11 @@
 import { showError, showResult, hide } from "./status.js"
+import { readSplit } from "./split.js"
+import { readPayers } from "./payers.js"
 
 const inputDescription = document.getElementById("input-description");
 const inputAmount = document.getElementById("input-amount");
-const inputPaidBy = document.getElementById("input-paid-by");
+const inputOccurredOn = document.getElementById("input-occurred-on");
+const inputCurrency = document.getElementById("input-currency");
 const form = document.getElementById("form");
 const status = document.getElementById("status")
 
@@ -13,7 +16,6 @@ form.addEventListener("submit", async (event) => {
 
     var amount = inputAmount.value.replace('$', '').trim();
     const description = inputDescription.value.trim();
-    const paidBy = inputPaidBy.value.trim();
 
     amount = amount ? amount : "-1"
 
@@ -27,7 +29,10 @@ form.addEventListener("submit", async (event) => {
                     {
                         "description": description,
                         "amount": amount,
-                        "paid_by": paidBy,
+                        "currency": inputCurrency.value,
+                        "occurred_on": inputOccurredOn.value,
+                        ...readPayers(),
+                        ...readSplit(),
                     }
                 ),
                 credentials: "same-origin"
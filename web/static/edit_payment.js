@@ -3,6 +3,8 @@ import { showError, showResult, hide } from "./status.js"
 const inputPaidBy = document.getElementById("input-paid-by");
 const inputPaidTo = document.getElementById("input-paid-to");
 const inputAmount = document.getElementById("input-amount");
+const inputOccurredOn = document.getElementById("input-occurred-on");
+const inputCurrency = document.getElementById("input-currency");
 const form = document.getElementById("form");
 const status = document.getElementById("status")
 
@@ -27,7 +29,9 @@ form.addEventListener("submit", async (event) => {
                     {
                         "paid_by": paidBy,
                         "paid_to": paidTo,
-                        "amount": amount
+                        "amount": amount,
+                        "currency": inputCurrency.value,
+                        "occurred_on": inputOccurredOn.value,
                     }
                 ),
                 credentials: "same-origin"
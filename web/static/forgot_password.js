@@ -0,0 +1,33 @@
+import { showError, showResult, hide } from "./status.js"
+
+const inputEmail = document.getElementById("input-email");
+const form = document.getElementById("form");
+const status = document.getElementById("status")
+
+form.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/password-reset",
+            {
+                method: "POST",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"email": inputEmail.value.trim()}),
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            showResult(status, "If an account has that verified email address, a link to reset its password is on its way.");
+            form.reset();
+        }
+    } catch (e) {
+        console.log(e)
+    }
+});
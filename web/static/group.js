@@ -1,5 +1,10 @@
+import { showResult, showError } from "./status.js"
+import { leaveGroup } from "./leave.js"
+
+const status = document.getElementById("status");
 const editButtons = document.querySelectorAll(".btn-edit");
 const deleteButtons = document.querySelectorAll(".btn-delete");
+const settleButtons = document.querySelectorAll(".btn-settle");
 
 editButtons.forEach(btn => {
     const txID = btn.dataset.id;
@@ -24,6 +29,63 @@ deleteButtons.forEach(btn => {
                 }
             );
 
+            if (resp.ok) {
+                // Offer to undo the delete once the page has reloaded
+                sessionStorage.setItem("deleted-transaction", txID);
+                window.location.href = `/groups/${groupID}`
+            } else {
+                console.log(await resp.text());
+            }
+        } catch (e) {
+            console.log(e);
+        }
+    });
+});
+
+const deletedID = sessionStorage.getItem("deleted-transaction");
+if (deletedID) {
+    sessionStorage.removeItem("deleted-transaction");
+
+    showResult(status, 'Moved to trash. <button id="button-undo" class="action-btn accent">Undo</button>');
+
+    document.getElementById("button-undo").addEventListener("click", async (event) => {
+        try {
+            const resp = await fetch(
+                `/api/groups/${groupID}/transactions/${deletedID}/restore`,
+                {
+                    method: "POST",
+                    credentials: "same-origin"
+                }
+            );
+
+            if (resp.ok) {
+                window.location.href = `/groups/${groupID}`
+            } else {
+                const msg = await resp.text();
+                showError(status, msg);
+                console.log(`${resp.status}: ${msg}`);
+            }
+        } catch (e) {
+            console.log(e);
+        }
+    });
+}
+
+settleButtons.forEach(btn => {
+    const { from, to, amount } = btn.dataset;
+
+    btn.addEventListener("click", async (event) => {
+        try {
+            const resp = await fetch(
+                `/api/groups/${groupID}/payments`,
+                {
+                    method: "POST",
+                    header: {"Content-Type": "application/json"},
+                    body: JSON.stringify({"paid_by": from, "paid_to": to, "amount": amount}),
+                    credentials: "same-origin"
+                }
+            );
+
             if (resp.ok) {
                 window.location.href = `/groups/${groupID}`
             } else {
@@ -35,3 +97,11 @@ deleteButtons.forEach(btn => {
     });
 });
 
+// The owner leaves from the manage page, where they pick a successor
+document.getElementById("button-leave")?.addEventListener("click", async (event) => {
+    if (!confirm("Leave this group?")) {
+        return;
+    }
+
+    await leaveGroup(groupID, null, status);
+});
@@ -0,0 +1,136 @@
+const drawer = document.getElementById("history-drawer");
+const historyList = document.getElementById("history-list");
+const closeButton = document.getElementById("history-close");
+const historyButtons = document.querySelectorAll(".btn-history");
+
+const actions = {
+    "create": "created",
+    "update": "edited",
+    "delete": "deleted",
+    "restore": "restored",
+    "purge": "purged",
+};
+
+// formatAmount shows as many decimal places as the currency's smallest unit,
+// so yen have none.
+const formatAmount = (amount, currency) => {
+    const places = new Intl.NumberFormat("en-US", { style: "currency", currency })
+        .resolvedOptions().maximumFractionDigits;
+    return `${Number(amount).toFixed(places)} ${currency}`;
+};
+
+const name = (user) => user ? user.username : "Deleted User";
+
+// describe picks out the fields of a transaction that are worth showing,
+// keyed by a label for each.
+function describe(tx) {
+    if (!tx) {
+        return {};
+    }
+
+    const fields = { "Date": tx.occurred_on.slice(0, 10) };
+
+    if (tx.expense) {
+        const e = tx.expense;
+        fields["Description"] = e.description;
+        fields["Amount"] = formatAmount(e.amount, e.currency);
+        fields["Paid by"] = (e.payers || [])
+            .map(p => `${name(p.user)} ${formatAmount(p.amount, e.currency)}`)
+            .join(", ");
+        fields["Split"] = e.split_mode;
+        fields["Owed"] = (e.debts || [])
+            .map(d => `${name(d.owed_by)} owes ${name(d.owed_to)} ${formatAmount(d.amount, e.currency)}`)
+            .join(", ");
+    }
+
+    if (tx.payment) {
+        const p = tx.payment;
+        fields["Amount"] = formatAmount(p.amount, p.currency);
+        fields["Paid by"] = name(p.paid_by);
+        fields["Paid to"] = name(p.paid_to);
+    }
+
+    return fields;
+}
+
+function renderEntry(entry) {
+    const item = document.createElement("li");
+    item.className = "history-entry";
+
+    const heading = document.createElement("div");
+    heading.className = "history-heading";
+    const actor = entry.actor ? entry.actor.username : "SplitWays";
+    heading.textContent = `${actor} ${actions[entry.action] || entry.action} this`;
+    item.appendChild(heading);
+
+    const time = document.createElement("div");
+    time.className = "history-time";
+    time.textContent = new Date(entry.created_at).toLocaleString();
+    item.appendChild(time);
+
+    const before = describe(entry.before);
+    const after = describe(entry.after);
+
+    const changes = document.createElement("ul");
+    changes.className = "history-changes";
+
+    for (const label of Object.keys({ ...before, ...after })) {
+        if (entry.action != "create" && entry.action != "update") {
+            break;
+        }
+
+        if (before[label] === after[label]) {
+            continue;
+        }
+
+        const change = document.createElement("li");
+        if (entry.action == "create") {
+            change.textContent = `${label}: ${after[label]}`;
+        } else {
+            change.textContent = `${label}: ${before[label] ?? ""} → ${after[label] ?? ""}`;
+        }
+        changes.appendChild(change);
+    }
+
+    if (changes.children.length > 0) {
+        item.appendChild(changes);
+    }
+
+    return item;
+}
+
+historyButtons.forEach(btn => {
+    const txID = btn.dataset.id;
+
+    btn.addEventListener("click", async (event) => {
+        historyList.replaceChildren();
+
+        try {
+            const resp = await fetch(
+                `/api/groups/${groupID}/transactions/${txID}/history`,
+                {
+                    method: "GET",
+                    credentials: "same-origin"
+                }
+            );
+
+            if (!resp.ok) {
+                console.log(await resp.text());
+                return;
+            }
+
+            const history = await resp.json();
+
+            // Most recent change first
+            history.reverse().forEach(entry => historyList.appendChild(renderEntry(entry)));
+
+            drawer.hidden = false;
+        } catch (e) {
+            console.log(e);
+        }
+    });
+});
+
+closeButton.addEventListener("click", () => {
+    drawer.hidden = true;
+});
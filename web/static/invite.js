@@ -0,0 +1,29 @@
+import { showError, hide } from "./status.js"
+
+const buttonJoin = document.getElementById("button-join");
+const status = document.getElementById("status")
+
+buttonJoin.addEventListener("click", async (event) => {
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api" + buttonJoin.dataset.path,
+            {
+                method: "POST",
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            const group = await resp.json();
+            window.location.href = `/groups/${group.ID}`;
+        }
+    } catch (e) {
+        console.log(e)
+    }
+});
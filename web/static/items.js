@@ -0,0 +1,73 @@
+const inputAmount = document.getElementById("input-amount");
+const itemList = document.getElementById("item-list");
+const itemTemplate = document.getElementById("item-template");
+const addItemButton = document.getElementById("button-add-item");
+const chargeInputs = document.querySelectorAll(".item-charge");
+
+const toCents = (value) => Math.round(parseFloat(value || "0") * 100) || 0;
+
+// updateTotal keeps the amount of the expense in line with the receipt.
+const updateTotal = () => {
+    let cents = 0;
+
+    itemList.querySelectorAll(".item-amount").forEach(input => {
+        cents += toCents(input.value);
+    });
+
+    chargeInputs.forEach(input => {
+        cents += toCents(input.value);
+    });
+
+    inputAmount.value = `$${(cents / 100).toFixed(2)}`;
+};
+
+const onlyNumbers = (e) => {
+    e.target.value = e.target.value.replace(/[^\d.]/g, '');
+    updateTotal();
+};
+
+const setupRow = (row) => {
+    row.querySelector(".item-amount").addEventListener("input", onlyNumbers);
+    row.querySelector(".item-remove").addEventListener("click", () => {
+        row.remove();
+        updateTotal();
+    });
+};
+
+itemList.querySelectorAll(".item-row").forEach(setupRow);
+chargeInputs.forEach(input => input.addEventListener("input", onlyNumbers));
+
+addItemButton.addEventListener("click", () => {
+    const row = itemTemplate.content.firstElementChild.cloneNode(true);
+    itemList.appendChild(row);
+    setupRow(row);
+});
+
+export const readItems = () => {
+    const items = [];
+
+    itemList.querySelectorAll(".item-row").forEach(row => {
+        const members = [];
+        row.querySelectorAll(".item-member").forEach(member => {
+            if (member.checked) {
+                members.push(member.dataset.username);
+            }
+        });
+
+        items.push({
+            "description": row.querySelector(".item-description").value.trim(),
+            "amount": row.querySelector(".item-amount").value.trim() || "0",
+            "members": members,
+        });
+    });
+
+    const charges = [];
+    chargeInputs.forEach(input => {
+        const amount = input.value.trim();
+        if (amount) {
+            charges.push({"kind": input.dataset.kind, "amount": amount});
+        }
+    });
+
+    return {"items": items, "charges": charges};
+};
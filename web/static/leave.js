@@ -0,0 +1,41 @@
+import { showError } from "./status.js"
+
+// leaveGroup takes the user out of the group, asking them to confirm first if
+// they still have a balance in it. successor is the member who takes over
+// when the owner leaves.
+export async function leaveGroup(groupID, successor, status) {
+    let confirmed = false;
+
+    for (;;) {
+        try {
+            const resp = await fetch(
+                `/api/groups/${groupID}/leave`,
+                {
+                    method: "POST",
+                    header: {"Content-Type": "application/json"},
+                    body: JSON.stringify({"successor": successor, "confirm": confirmed}),
+                    credentials: "same-origin"
+                }
+            );
+
+            if (resp.ok) {
+                window.location.href = "/";
+                return;
+            }
+
+            const msg = await resp.text();
+
+            if (resp.status == 409 && !confirmed && confirm(msg)) {
+                confirmed = true;
+                continue;
+            }
+
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } catch (e) {
+            console.log(e);
+        }
+
+        return;
+    }
+}
@@ -1,8 +1,11 @@
 import { showError, showResult, hide } from "./status.js"
+import { nextPage } from "./next.js"
 
 const inputUsername = document.getElementById("input-username");
 const inputPassword = document.getElementById("input-password");
 const form = document.getElementById("form");
+const twoFactorForm = document.getElementById("two-factor-form");
+const inputCode = document.getElementById("input-code");
 const status = document.getElementById("status")
 
 form.addEventListener("submit", async (event) => {
@@ -28,8 +31,41 @@ form.addEventListener("submit", async (event) => {
             const msg = await resp.text();
             showError(status, msg);
             console.log(`${resp.status}: ${msg}`);
+        } else if (resp.status === 202) {
+            // The password was right, but a code is needed too
+            form.hidden = true;
+            twoFactorForm.hidden = false;
+            inputCode.focus();
         } else {
-            window.location.href = "/";
+            window.location.href = nextPage();
+        }
+    } catch (e) {
+        console.log(e)
+    }
+});
+
+twoFactorForm.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/login/two-factor",
+            {
+                method: "POST",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"code": inputCode.value.trim()}),
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            window.location.href = nextPage();
         }
     } catch (e) {
         console.log(e)
@@ -1,12 +1,24 @@
 import { showError, showResult, hide } from "./status.js"
+import { leaveGroup } from "./leave.js"
 
 const inputUsername = document.getElementById("input-username");
+const inputRole = document.getElementById("input-role");
 const inputNewName = document.getElementById("input-new-name");
+const inputCurrency = document.getElementById("input-currency");
 const addUserForm = document.getElementById("add-user-form");
 const renameGroupForm = document.getElementById("rename-group-form");
+const currencyGroupForm = document.getElementById("currency-group-form");
 const status = document.getElementById("status")
 const deleteButtons = document.querySelectorAll(".btn-delete");
+const roleSelects = document.querySelectorAll(".role-select");
 const deleteGroupForm = document.getElementById("delete-group-form");
+const transferGroupForm = document.getElementById("transfer-group-form");
+const leaveGroupForm = document.getElementById("leave-group-form");
+const inviteForm = document.getElementById("invite-form");
+const addPlaceholderForm = document.getElementById("add-placeholder-form");
+const claimLinkButtons = document.querySelectorAll(".btn-claim-link");
+const copyInviteButtons = document.querySelectorAll(".btn-copy-invite");
+const revokeInviteButtons = document.querySelectorAll(".btn-revoke-invite");
 
 addUserForm.addEventListener("submit", async (event) => {
     event.preventDefault();
@@ -21,7 +33,7 @@ addUserForm.addEventListener("submit", async (event) => {
             {
                 method: "POST",
                 header: {"Content-Type": "application/json"},
-                body: JSON.stringify({"username": username}),
+                body: JSON.stringify({"username": username, "role": inputRole.value}),
                 credentials: "same-origin"
             }
         );
@@ -39,25 +51,200 @@ addUserForm.addEventListener("submit", async (event) => {
 });
 
 
+addPlaceholderForm.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            `/api/groups/${groupID}/placeholders`,
+            {
+                method: "POST",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({
+                    "name": document.getElementById("input-placeholder-name").value.trim(),
+                    "role": document.getElementById("input-placeholder-role").value,
+                }),
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            window.location.reload();
+        }
+    } catch (e) {
+        console.log(e)
+    }
+});
+
+async function createInvite(data) {
+    try {
+        const resp = await fetch(
+            `/api/groups/${groupID}/invites`,
+            {
+                method: "POST",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify(data),
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            const invite = await resp.json();
+            await copyInvite(invite.path);
+            window.location.reload();
+        }
+    } catch (e) {
+        console.log(e)
+    }
+}
+
+inviteForm.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    await createInvite({
+        "role": document.getElementById("input-invite-role").value,
+        "expires_in_days": parseInt(document.getElementById("input-invite-expiry").value),
+        "single_use": document.getElementById("input-invite-single-use").checked,
+    });
+});
+
+// A claim link lets someone who signs up take over a placeholder member
+claimLinkButtons.forEach(btn => {
+    btn.addEventListener("click", async (event) => {
+        hide(status)
+
+        await createInvite({"placeholder_id": btn.dataset.id});
+    });
+});
+
+async function copyInvite(path) {
+    const link = window.location.origin + path;
+
+    try {
+        await navigator.clipboard.writeText(link);
+        showResult(status, "Invite link copied");
+    } catch (e) {
+        // The clipboard isn't available outside secure contexts
+        prompt("Copy this invite link", link);
+    }
+}
+
+copyInviteButtons.forEach(btn => {
+    btn.addEventListener("click", async (event) => {
+        hide(status)
+
+        await copyInvite(btn.dataset.path);
+    });
+});
+
+revokeInviteButtons.forEach(btn => {
+    const inviteID = btn.dataset.id;
+
+    btn.addEventListener("click", async (event) => {
+        hide(status)
+
+        try {
+            const resp = await fetch(
+                `/api/groups/${groupID}/invites/${inviteID}`,
+                {
+                    method: "DELETE",
+                    credentials: "same-origin"
+                }
+            );
+
+            if (resp.ok) {
+                btn.closest(".member-item").remove();
+            } else {
+                const msg = await resp.text();
+                showError(status, msg);
+                console.log(`${resp.status}: ${msg}`);
+            }
+        } catch (e) {
+            console.log(e);
+        }
+    });
+});
+
+// Members who still owe or are owed money can only be removed by the owner,
+// after confirming.
+const canForce = document.querySelector(".members-list").dataset.canForce == "true";
+
+async function removeMember(userID, force) {
+    const resp = await fetch(
+        `/api/groups/${groupID}/users`,
+        {
+            method: "DELETE",
+            header: {"Content-Type": "application/json"},
+            body: JSON.stringify({"id": userID, "force": force}),
+            credentials: "same-origin"
+        }
+    );
+
+    if (resp.ok) {
+        window.location.href = `/groups/${groupID}`
+        return;
+    }
+
+    const msg = await resp.text();
+
+    if (resp.status == 409 && !force && canForce && confirm(`${msg} Remove them anyway?`)) {
+        await removeMember(userID, true);
+        return;
+    }
+
+    showError(status, msg);
+    console.log(`${resp.status}: ${msg}`);
+}
+
 deleteButtons.forEach(btn => {
     const userID = btn.dataset.id;
 
     btn.addEventListener("click", async (event) => {
+        hide(status)
+
+        try {
+            await removeMember(userID, false);
+        } catch (e) {
+            console.log(e);
+        }
+    });
+});
+
+roleSelects.forEach(select => {
+    const userID = select.dataset.id;
+
+    select.addEventListener("change", async (event) => {
+        hide(status)
+
         try {
             const resp = await fetch(
-                `/api/groups/${groupID}/users`,
+                `/api/groups/${groupID}/users/${userID}`,
                 {
-                    method: "DELETE",
+                    method: "PUT",
                     header: {"Content-Type": "application/json"},
-                    body: JSON.stringify({"id": userID}),
+                    body: JSON.stringify({"role": select.value}),
                     credentials: "same-origin"
                 }
             );
 
             if (resp.ok) {
-                window.location.href = `/groups/${groupID}`
+                showResult(status, "Role changed");
             } else {
-                console.log(await resp.text());
+                const msg = await resp.text();
+                showError(status, msg);
+                console.log(`${resp.status}: ${msg}`);
             }
         } catch (e) {
             console.log(e);
@@ -91,7 +278,82 @@ renameGroupForm.addEventListener("submit", async (event) => {
     }
 });
 
-deleteGroupForm.addEventListener("submit", async (event) => {
+currencyGroupForm.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/groups/" + groupID,
+            {
+                method: "PUT",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"currency": inputCurrency.value}),
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            window.location.href = `/groups/${groupID}`;
+        }
+    } catch (e) {
+        console.log(e)
+    }
+});
+
+// Only the owner can hand the group over, leave it from here or delete it
+transferGroupForm?.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    const newOwner = document.getElementById("input-new-owner");
+    if (!confirm(`Make ${newOwner.selectedOptions[0].text} the owner of this group?`)) {
+        return;
+    }
+
+    try {
+        const resp = await fetch(
+            `/api/groups/${groupID}/owner`,
+            {
+                method: "PUT",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"id": newOwner.value}),
+                credentials: "same-origin"
+            }
+        );
+
+        if (resp.ok) {
+            window.location.href = `/groups/${groupID}`;
+        } else {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        }
+    } catch (e) {
+        console.log(e);
+    }
+});
+
+leaveGroupForm?.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    const successor = document.getElementById("input-successor");
+    if (!confirm(`Leave this group and make ${successor.selectedOptions[0].text} its owner?`)) {
+        return;
+    }
+
+    await leaveGroup(groupID, successor.value, status);
+});
+
+deleteGroupForm?.addEventListener("submit", async (event) => {
     event.preventDefault();
 
     try {
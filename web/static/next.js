@@ -0,0 +1,11 @@
+// nextPage is where to go after logging in or signing up: the page named by
+// the `next` query parameter, or the dashboard. Only pages on this site are
+// allowed.
+export const nextPage = () => {
+    const next = new URLSearchParams(window.location.search).get("next");
+    if (next && next.startsWith("/") && !next.startsWith("//") && !next.startsWith("/\\")) {
+        return next;
+    }
+
+    return "/";
+};
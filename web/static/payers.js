@@ -0,0 +1,33 @@
+const payerIncludes = document.querySelectorAll(".payer-include");
+const payerInputs = document.querySelectorAll(".payer-input");
+
+payerInputs.forEach(input => {
+    input.addEventListener("input", (e) => {
+        e.target.value = e.target.value.replace(/[^\d.]/g, '');
+    });
+});
+
+// readPayers returns the payers of the expense. A single payer without an
+// amount is taken to have paid for everything.
+export const readPayers = () => {
+    const payers = [];
+
+    payerIncludes.forEach(include => {
+        if (!include.checked) {
+            return;
+        }
+
+        const input = Array.from(payerInputs).find(input => input.dataset.username === include.dataset.username);
+        payers.push({"username": include.dataset.username, "amount": input.value.trim()});
+    });
+
+    if (payers.length === 0) {
+        return {};
+    }
+
+    if (payers.length === 1 && !payers[0].amount) {
+        return {"paid_by": payers[0].username};
+    }
+
+    return {"payers": payers};
+};
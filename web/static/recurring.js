@@ -0,0 +1,173 @@
+import { showError, hide } from "./status.js"
+
+const form = document.getElementById("form");
+const formHeading = document.getElementById("form-heading");
+const inputKind = document.getElementById("input-kind");
+const inputDescription = document.getElementById("input-description");
+const inputAmount = document.getElementById("input-amount");
+const inputCurrency = document.getElementById("input-currency");
+const inputFrequency = document.getElementById("input-frequency");
+const inputStartsOn = document.getElementById("input-starts-on");
+const inputEndsOn = document.getElementById("input-ends-on");
+const inputPaidBy = document.getElementById("input-paid-by");
+const inputPaidTo = document.getElementById("input-paid-to");
+const inputParticipants = document.getElementById("input-participants");
+const buttonSubmit = document.getElementById("button-submit");
+const buttonCancel = document.getElementById("button-cancel");
+const status = document.getElementById("status");
+
+// The schedule being edited, or null when creating a new one
+let editing = null;
+
+// Default to today in the user's time zone
+const today = new Date();
+today.setMinutes(today.getMinutes() - today.getTimezoneOffset());
+inputStartsOn.value = today.toISOString().slice(0, 10);
+
+function showKindFields() {
+    const isPayment = inputKind.value == "payment";
+    inputDescription.hidden = isPayment;
+    inputPaidTo.hidden = !isPayment;
+    inputPaidTo.required = isPayment;
+    inputParticipants.hidden = isPayment || (editing != null && editing.dataset.splitMode != "equal");
+}
+
+inputKind.addEventListener("change", showKindFields);
+showKindFields();
+
+// scheduleData reads a schedule back from the data attributes of its item in
+// the list, so that it can be sent again with only some fields changed.
+function scheduleData(item) {
+    const splits = JSON.parse(item.dataset.splits);
+
+    const data = {
+        "kind": item.dataset.kind,
+        "frequency": item.dataset.frequency,
+        "starts_on": item.dataset.startsOn,
+        "ends_on": item.dataset.endsOn,
+        "paused": item.dataset.paused == "true",
+        "description": item.dataset.description,
+        "amount": item.dataset.amount,
+        "currency": item.dataset.currency,
+        "paid_by": item.dataset.paidBy,
+        "paid_to": item.dataset.paidTo,
+        "split_mode": item.dataset.splitMode,
+    };
+
+    if (data["split_mode"] == "equal") {
+        data["participants"] = splits.map(s => s.username);
+    } else {
+        data["splits"] = splits;
+    }
+
+    return data;
+}
+
+function formData() {
+    const data = editing ? scheduleData(editing) : { "split_mode": "equal" };
+
+    data["kind"] = inputKind.value;
+    data["description"] = inputDescription.value;
+    data["amount"] = inputAmount.value.replace('$', '');
+    data["currency"] = inputCurrency.value;
+    data["frequency"] = inputFrequency.value;
+    data["starts_on"] = inputStartsOn.value;
+    data["ends_on"] = inputEndsOn.value;
+    data["paid_by"] = inputPaidBy.value.trim();
+    data["paid_to"] = inputPaidTo.value.trim();
+
+    if (data["split_mode"] == "equal") {
+        data["participants"] = inputParticipants.value
+            .split(",")
+            .map(s => s.trim())
+            .filter(s => s);
+    }
+
+    return data;
+}
+
+async function send(method, url, body) {
+    hide(status);
+
+    try {
+        const resp = await fetch(
+            url,
+            {
+                method: method,
+                header: {"Content-Type": "application/json"},
+                body: body ? JSON.stringify(body) : undefined,
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            window.location.reload();
+        }
+    } catch (e) {
+        console.log(e)
+    }
+}
+
+form.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    if (editing) {
+        await send("PUT", `/api/groups/${groupID}/recurring/${editing.dataset.id}`, formData());
+    } else {
+        await send("POST", `/api/groups/${groupID}/recurring`, formData());
+    }
+});
+
+buttonCancel.addEventListener("click", () => {
+    window.location.reload();
+});
+
+document.querySelectorAll(".recurring-item").forEach(item => {
+    // Schedules created by other members can only be changed by admins
+    if (!item.querySelector(".btn-edit")) {
+        return;
+    }
+
+    item.querySelector(".btn-pause").addEventListener("click", async () => {
+        const data = scheduleData(item);
+        data["paused"] = !data["paused"];
+
+        await send("PUT", `/api/groups/${groupID}/recurring/${item.dataset.id}`, data);
+    });
+
+    item.querySelector(".btn-edit").addEventListener("click", () => {
+        editing = item;
+
+        const data = scheduleData(item);
+        inputKind.value = data["kind"];
+        inputKind.disabled = true;
+        inputDescription.value = data["description"];
+        inputAmount.value = data["amount"];
+        inputCurrency.value = data["currency"];
+        inputFrequency.value = data["frequency"];
+        inputStartsOn.value = data["starts_on"];
+        inputEndsOn.value = data["ends_on"];
+        inputPaidBy.value = data["paid_by"];
+        inputPaidTo.value = data["paid_to"];
+        inputParticipants.value = (data["participants"] || []).join(", ");
+
+        formHeading.textContent = "Edit Schedule";
+        buttonSubmit.textContent = "Save";
+        buttonCancel.hidden = false;
+        showKindFields();
+
+        form.scrollIntoView({ behavior: "smooth" });
+    });
+
+    item.querySelector(".btn-delete").addEventListener("click", async () => {
+        if (!confirm("Delete this schedule? Transactions it already created are kept.")) {
+            return;
+        }
+
+        await send("DELETE", `/api/groups/${groupID}/recurring/${item.dataset.id}`);
+    });
+});
@@ -0,0 +1,41 @@
+import { showError, showResult, hide } from "./status.js"
+
+const inputPassword = document.getElementById("input-password");
+const inputConfirm = document.getElementById("input-confirm");
+const form = document.getElementById("form");
+const status = document.getElementById("status")
+
+const token = new URLSearchParams(window.location.search).get("token");
+
+form.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    if (inputPassword.value !== inputConfirm.value) {
+        showError(status, "Passwords don't match");
+        return;
+    }
+
+    try {
+        const resp = await fetch(
+            "/api/password-reset",
+            {
+                method: "PUT",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"token": token, "password": inputPassword.value}),
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        } else {
+            form.hidden = true;
+            showResult(status, 'Your password has been reset. <a href="/login">Log in</a> with your new password.');
+        }
+    } catch (e) {
+        console.log(e)
+    }
+});
@@ -0,0 +1,293 @@
+import { showError, showResult, hide } from "./status.js"
+
+const emailForm = document.getElementById("email-form");
+const resendVerificationButton = document.getElementById("button-resend-verification");
+const tokenForm = document.getElementById("token-form");
+const newToken = document.getElementById("new-token");
+const newTokenValue = document.getElementById("new-token-value");
+const copyTokenButton = document.getElementById("button-copy-token");
+const tokenDoneButton = document.getElementById("button-token-done");
+const revokeTokenButtons = document.querySelectorAll(".btn-revoke-token");
+const status = document.getElementById("status");
+
+emailForm.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/users/email",
+            {
+                method: "PUT",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"email": document.getElementById("input-email").value.trim()}),
+                credentials: "same-origin"
+            }
+        );
+
+        if (resp.ok) {
+            window.location.reload();
+        } else {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        }
+    } catch (e) {
+        console.log(e)
+    }
+});
+
+resendVerificationButton?.addEventListener("click", async (event) => {
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/users/email/verification",
+            {
+                method: "POST",
+                credentials: "same-origin"
+            }
+        );
+
+        if (resp.ok) {
+            showResult(status, "Verification link sent");
+        } else {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        }
+    } catch (e) {
+        console.log(e);
+    }
+});
+
+tokenForm.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/tokens",
+            {
+                method: "POST",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({
+                    "name": document.getElementById("input-token-name").value.trim(),
+                    "scope": document.getElementById("input-token-scope").value,
+                    "expires_in_days": parseInt(document.getElementById("input-token-expiry").value),
+                }),
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+            return;
+        }
+
+        const token = await resp.json();
+
+        newTokenValue.textContent = token.token;
+        newToken.hidden = false;
+        tokenForm.hidden = true;
+    } catch (e) {
+        console.log(e)
+    }
+});
+
+copyTokenButton.addEventListener("click", async (event) => {
+    try {
+        await navigator.clipboard.writeText(newTokenValue.textContent);
+        showResult(status, "Token copied");
+    } catch (e) {
+        // The clipboard isn't available outside secure contexts
+        prompt("Copy this token", newTokenValue.textContent);
+    }
+});
+
+tokenDoneButton.addEventListener("click", (event) => {
+    window.location.reload();
+});
+
+revokeTokenButtons.forEach(btn => {
+    const tokenID = btn.dataset.id;
+
+    btn.addEventListener("click", async (event) => {
+        hide(status)
+
+        try {
+            const resp = await fetch(
+                `/api/tokens/${tokenID}`,
+                {
+                    method: "DELETE",
+                    credentials: "same-origin"
+                }
+            );
+
+            if (resp.ok) {
+                window.location.reload();
+            } else {
+                const msg = await resp.text();
+                showError(status, msg);
+                console.log(`${resp.status}: ${msg}`);
+            }
+        } catch (e) {
+            console.log(e);
+        }
+    });
+});
+
+const startTwoFactorButton = document.getElementById("button-start-two-factor");
+const twoFactorSetup = document.getElementById("two-factor-setup");
+const enableTwoFactorForm = document.getElementById("enable-two-factor-form");
+const twoFactorForm = document.getElementById("two-factor-form");
+const disableTwoFactorButton = document.getElementById("button-disable-two-factor");
+const recoveryCodes = document.getElementById("recovery-codes");
+const recoveryCodesValue = document.getElementById("recovery-codes-value");
+const copyRecoveryCodesButton = document.getElementById("button-copy-recovery-codes");
+const recoveryCodesDoneButton = document.getElementById("button-recovery-codes-done");
+
+async function showRecoveryCodes(resp) {
+    const body = await resp.json();
+
+    recoveryCodesValue.textContent = body.recovery_codes.join("\n");
+    recoveryCodes.hidden = false;
+    twoFactorSetup?.setAttribute("hidden", "");
+    twoFactorForm?.setAttribute("hidden", "");
+}
+
+startTwoFactorButton?.addEventListener("click", async (event) => {
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/users/two-factor",
+            {
+                method: "POST",
+                credentials: "same-origin"
+            }
+        );
+
+        if (!resp.ok) {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+            return;
+        }
+
+        const setup = await resp.json();
+
+        document.getElementById("two-factor-qr-code").src = setup.qr_code;
+        document.getElementById("two-factor-secret").textContent = setup.secret;
+        twoFactorSetup.hidden = false;
+        startTwoFactorButton.hidden = true;
+    } catch (e) {
+        console.log(e);
+    }
+});
+
+enableTwoFactorForm?.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/users/two-factor",
+            {
+                method: "PUT",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"code": document.getElementById("input-enable-code").value.trim()}),
+                credentials: "same-origin"
+            }
+        );
+
+        if (resp.ok) {
+            await showRecoveryCodes(resp);
+        } else {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        }
+    } catch (e) {
+        console.log(e);
+    }
+});
+
+twoFactorForm?.addEventListener("submit", async (event) => {
+    event.preventDefault();
+
+    hide(status)
+
+    try {
+        const resp = await fetch(
+            "/api/users/two-factor/recovery-codes",
+            {
+                method: "POST",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"code": document.getElementById("input-two-factor-code").value.trim()}),
+                credentials: "same-origin"
+            }
+        );
+
+        if (resp.ok) {
+            await showRecoveryCodes(resp);
+        } else {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        }
+    } catch (e) {
+        console.log(e);
+    }
+});
+
+disableTwoFactorButton?.addEventListener("click", async (event) => {
+    hide(status)
+
+    const code = document.getElementById("input-two-factor-code").value.trim();
+    if (code === "") {
+        showError(status, "Enter a code to turn off two-factor authentication");
+        return;
+    }
+
+    try {
+        const resp = await fetch(
+            "/api/users/two-factor",
+            {
+                method: "DELETE",
+                header: {"Content-Type": "application/json"},
+                body: JSON.stringify({"code": code}),
+                credentials: "same-origin"
+            }
+        );
+
+        if (resp.ok) {
+            window.location.reload();
+        } else {
+            const msg = await resp.text();
+            showError(status, msg);
+            console.log(`${resp.status}: ${msg}`);
+        }
+    } catch (e) {
+        console.log(e);
+    }
+});
+
+copyRecoveryCodesButton.addEventListener("click", async (event) => {
+    try {
+        await navigator.clipboard.writeText(recoveryCodesValue.textContent);
+        showResult(status, "Recovery codes copied");
+    } catch (e) {
+        // The clipboard isn't available outside secure contexts
+        prompt("Copy these recovery codes", recoveryCodesValue.textContent);
+    }
+});
+
+recoveryCodesDoneButton.addEventListener("click", (event) => {
+    window.location.reload();
+});
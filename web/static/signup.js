@@ -1,7 +1,9 @@
 import { showError, showResult, hide } from "./status.js"
+import { nextPage } from "./next.js"
 
 const inputUsername = document.getElementById("input-username");
 const inputPassword = document.getElementById("input-password");
+const inputEmail = document.getElementById("input-email");
 const form = document.getElementById("form");
 const status = document.getElementById("status")
 
@@ -12,6 +14,7 @@ form.addEventListener("submit", async (event) => {
 
     const username = inputUsername.value;
     const password = inputPassword.value;
+    const email = inputEmail.value.trim();
 
     try {
         const resp = await fetch(
@@ -19,7 +22,7 @@ form.addEventListener("submit", async (event) => {
             {
                 method: "POST",
                 header: {"Content-Type": "application/json"},
-                body: JSON.stringify({"username": username, "password": password}),
+                body: JSON.stringify({"username": username, "password": password, "email": email}),
                 credentials: "same-origin"
             }
         );
@@ -29,7 +32,7 @@ form.addEventListener("submit", async (event) => {
             showError(status, msg);
             console.log(`${resp.status}: ${msg}`);
         } else {
-            window.location.href = "/";
+            window.location.href = nextPage();
         }
     } catch (e) {
         console.log(e)
@@ -0,0 +1,54 @@
+import { readItems } from "./items.js"
+
+const inputSplitMode = document.getElementById("input-split-mode");
+const splitMembers = document.getElementById("split-members");
+const itemEditor = document.getElementById("item-editor");
+const splitIncludes = document.querySelectorAll(".split-include");
+const splitInputs = document.querySelectorAll(".split-input");
+
+inputSplitMode.addEventListener("change", () => {
+    splitMembers.hidden = inputSplitMode.value === "items";
+    itemEditor.hidden = inputSplitMode.value !== "items";
+
+    splitInputs.forEach(input => {
+        input.hidden = inputSplitMode.value === "equal";
+    });
+});
+
+splitInputs.forEach(input => {
+    input.addEventListener("input", (e) => {
+        e.target.value = e.target.value.replace(/[^\d.]/g, '');
+    });
+});
+
+const isIncluded = (username) => {
+    return Array.from(splitIncludes).some(include => include.dataset.username === username && include.checked);
+};
+
+export const readSplit = () => {
+    const mode = inputSplitMode.value;
+
+    if (mode === "items") {
+        return {"split_mode": mode, ...readItems()};
+    }
+
+    const participants = [];
+    const splits = [];
+
+    if (mode === "equal") {
+        splitIncludes.forEach(include => {
+            if (include.checked) {
+                participants.push(include.dataset.username);
+            }
+        });
+    } else {
+        splitInputs.forEach(input => {
+            const value = input.value.trim();
+            if (value && isIncluded(input.dataset.username)) {
+                splits.push({"username": input.dataset.username, "value": value});
+            }
+        });
+    }
+
+    return {"split_mode": mode, "participants": participants, "splits": splits};
+};
@@ -0,0 +1,32 @@
+import { showError, hide } from "./status.js"
+
+const restoreButtons = document.querySelectorAll(".btn-restore");
+const status = document.getElementById("status");
+
+restoreButtons.forEach(btn => {
+    const txID = btn.dataset.id;
+
+    btn.addEventListener("click", async (event) => {
+        hide(status);
+
+        try {
+            const resp = await fetch(
+                `/api/groups/${groupID}/transactions/${txID}/restore`,
+                {
+                    method: "POST",
+                    credentials: "same-origin"
+                }
+            );
+
+            if (resp.ok) {
+                window.location.reload();
+            } else {
+                const msg = await resp.text();
+                showError(status, msg);
+                console.log(`${resp.status}: ${msg}`);
+            }
+        } catch (e) {
+            console.log(e);
+        }
+    });
+});